@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	config "github.com/NordCoder/Pingerus/internal/config/api-gateway"
+	"github.com/NordCoder/Pingerus/internal/services/api-gateway/auth"
+)
+
+func buildKeySet(cfg *config.Config) (*auth.KeySet, error) {
+	keys := make([]auth.KeyConfig, 0, len(cfg.Auth.JWT.Keys))
+	for _, k := range cfg.Auth.JWT.Keys {
+		kc := auth.KeyConfig{
+			Kid:            k.Kid,
+			Alg:            k.Alg,
+			PrivateKeyFile: k.PrivateKeyFile,
+			PublicKeyFile:  k.PublicKeyFile,
+		}
+		if k.ActiveFrom != "" {
+			t, err := time.Parse(time.RFC3339, k.ActiveFrom)
+			if err != nil {
+				return nil, fmt.Errorf("jwt key %q: active_from: %w", k.Kid, err)
+			}
+			kc.ActiveFrom = t
+		}
+		keys = append(keys, kc)
+	}
+	return auth.NewKeySet(auth.KeySetConfig{
+		Keys:        keys,
+		Secret:      []byte(cfg.Auth.JWTSecret),
+		AcceptHS256: cfg.Auth.JWT.AcceptHS256,
+		Issuer:      cfg.Auth.JWT.Issuer,
+		Audience:    cfg.Auth.JWT.Audience,
+	})
+}
+
+func oidcProviders(cfg *config.Config) []auth.OIDCProviderConfig {
+	if !cfg.Auth.OIDC.Enable {
+		return nil
+	}
+	out := make([]auth.OIDCProviderConfig, 0, len(cfg.Auth.OIDC.Providers))
+	for _, p := range cfg.Auth.OIDC.Providers {
+		secret := p.ClientSecret
+		if p.ClientSecretEnv != "" {
+			if v := os.Getenv(p.ClientSecretEnv); v != "" {
+				secret = v
+			}
+		}
+		out = append(out, auth.OIDCProviderConfig{
+			Name:           p.Name,
+			DisplayName:    p.DisplayName,
+			Issuer:         p.Issuer,
+			ClientID:       p.ClientID,
+			ClientSecret:   secret,
+			RedirectURL:    p.RedirectURL,
+			Scopes:         p.Scopes,
+			AutoProvision:  p.AutoProvision,
+			AllowedDomains: p.AllowedDomains,
+		})
+	}
+	return out
+}
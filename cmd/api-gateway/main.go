@@ -49,8 +49,17 @@ func main() {
 	}
 	defer db.Close()
 
+	// jwt keys
+	keys, err := buildKeySet(cfg)
+	if err != nil {
+		logger.Fatal("jwt keys", zap.Error(err))
+	}
+	if len(cfg.Auth.JWT.Keys) > 0 && cfg.Auth.JWT.ReloadInterval > 0 {
+		go keys.Watch(rootCtx, cfg.Auth.JWT.ReloadInterval, logger)
+	}
+
 	// grpc
-	grpcServer, grpcLn, grpcMetrics, err := buildGRPCServer(cfg, logger, db)
+	grpcServer, grpcLn, grpcMetrics, err := buildGRPCServer(rootCtx, cfg, logger, db, keys)
 	if err != nil {
 		logger.Fatal("build grpc", zap.Error(err))
 	}
@@ -59,7 +68,7 @@ func main() {
 	go func() { grpcErrCh <- serveGRPC(grpcServer, grpcLn, cfg, logger) }()
 
 	// http
-	httpSrv, conn, err := buildHTTPServer(rootCtx, cfg, logger, db, grpcMetrics)
+	httpSrv, conn, err := buildHTTPServer(rootCtx, cfg, logger, db, keys, grpcMetrics)
 	if err != nil {
 		logger.Fatal("build http server", zap.Error(err))
 	}
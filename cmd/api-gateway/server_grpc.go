@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	pb "github.com/NordCoder/Pingerus/generated/v1"
+	domainauth "github.com/NordCoder/Pingerus/internal/domain/auth"
 	"github.com/NordCoder/Pingerus/internal/domain/check"
 	"github.com/NordCoder/Pingerus/internal/obs"
 	pg "github.com/NordCoder/Pingerus/internal/repository/postgres"
@@ -13,24 +15,81 @@ import (
 
 	pbauth "github.com/NordCoder/Pingerus/generated/v1"
 	checksvc "github.com/NordCoder/Pingerus/internal/services/api-gateway/check"
+	orgsvc "github.com/NordCoder/Pingerus/internal/services/api-gateway/org"
+	"github.com/NordCoder/Pingerus/internal/services/api-gateway/policy"
+	statuspagesvc "github.com/NordCoder/Pingerus/internal/services/api-gateway/statuspage"
 
 	config "github.com/NordCoder/Pingerus/internal/config/api-gateway"
 	grpcprometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 )
 
-func buildGRPCServer(cfg *config.Config, logger *zap.Logger, db *pg.DB) (*grpc.Server, net.Listener, *grpcprometheus.ServerMetrics, error) {
+func buildGRPCServer(ctx context.Context, cfg *config.Config, logger *zap.Logger, db *pg.DB, keys *auth.KeySet) (*grpc.Server, net.Listener, *grpcprometheus.ServerMetrics, error) {
+	tx := pg.NewTransactor(db, logger)
+	userRepo := pg.NewUserRepo(db)
+	orgRepo := pg.NewOrgRepo(db)
+	pol := policy.New(orgRepo)
+	orgSrv := orgsvc.NewServer(logger, orgsvc.NewUsecase(orgRepo, userRepo, pol))
+
 	var checkRepo check.Repo = pg.NewCheckRepo(db)
-	checkUC := checksvc.NewUsecase(checkRepo)
+	hub := checksvc.NewHub(pg.NewCheckEventRepo(db), checksvc.WatchConfig{
+		Heartbeat:     cfg.Watch.Heartbeat,
+		PollInterval:  cfg.Watch.PollInterval,
+		Retention:     cfg.Watch.Retention,
+		PruneInterval: cfg.Watch.PruneInterval,
+		Buffer:        cfg.Watch.Buffer,
+	}, logger)
+	wake := make(chan struct{}, 1)
+	go func() { _ = pg.NewListener(db, pg.CheckEventsChannel, logger).Run(ctx, wake) }()
+	go func() { _ = hub.Run(ctx, wake) }()
+	checkUC := checksvc.NewUsecase(checkRepo, pg.NewCheckDependencyRepo(db), pg.NewPlanRepo(db),
+		cfg.Plans.AsCatalog(), pol, tx, hub)
+	if cfg.Plans.PruneInterval > 0 {
+		go checkUC.PruneRuns(ctx, cfg.Plans.PruneInterval, logger)
+	}
 	checkSrv := checksvc.NewServer(logger, checkUC)
 
-	userRepo := pg.NewUserRepo(db)
+	pageSrv := statuspagesvc.NewServer(logger, statuspagesvc.NewUsecase(
+		pg.NewStatusPageRepo(db), checkRepo, pg.NewRunRepo(db), pg.NewCheckEventRepo(db),
+		pol, tx, cfg.StatusPages.CacheTTL, cfg.StatusPages.IncidentWindow,
+	))
+
 	rtRepo := pg.NewRefreshTokenRepo(db)
+	apiKeyRepo := pg.NewAPIKeyRepo(db)
+	authEventRepo := pg.NewAuthEventRepo(db)
+	outboxRepo := pg.NewOutboxRepo(db)
+	if cfg.Outbox.Notify {
+		outboxRepo = outboxRepo.WithNotify(cfg.Outbox.Channel)
+	}
 	authUC := auth.NewUseCase(
-		userRepo, rtRepo,
+		userRepo, rtRepo, apiKeyRepo, authEventRepo, pg.NewMFARepo(db),
+		auth.Mail{
+			Verify: pg.NewEmailVerificationTokenRepo(db),
+			Reset:  pg.NewPasswordResetTokenRepo(db),
+			Outbox: outboxRepo,
+		},
+		auth.SSO{
+			Identities: pg.NewIdentityRepo(db),
+			States:     pg.NewOIDCStateRepo(db),
+			Providers:  oidcProviders(cfg),
+			StateTTL:   cfg.Auth.OIDC.StateTTL,
+		},
+		pol,
+		tx,
 		auth.Config{
 			Secret:     []byte(cfg.Auth.JWTSecret),
+			Keys:       keys,
 			AccessTTL:  cfg.Auth.AccessTTL,
 			RefreshTTL: cfg.Auth.RefreshTTL,
+
+			AdminEmails: cfg.Auth.AdminEmails,
+			AppURL:      cfg.Auth.AppURL,
+			VerifyTTL:   cfg.Auth.VerifyTTL,
+			ResetTTL:    cfg.Auth.ResetTTL,
+
+			MFAKey:          []byte(cfg.Auth.MFAKey),
+			MFAIssuer:       cfg.Auth.MFAIssuer,
+			MFAChallengeTTL: cfg.Auth.MFAChallengeTTL,
+			Logger:          logger,
 		},
 	)
 	authSrv := auth.NewServer(
@@ -47,14 +106,37 @@ func buildGRPCServer(cfg *config.Config, logger *zap.Logger, db *pg.DB) (*grpc.S
 
 	grpcMetrics := grpcprometheus.NewServerMetrics()
 
+	proxies, err := auth.ParseProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	unary := []grpc.UnaryServerInterceptor{grpcMetrics.UnaryServerInterceptor(), auth.UnaryClientInterceptor(proxies)}
+	if rl := cfg.RateLimit; rl.Enable {
+		limiter := auth.NewRateLimiter(pg.NewRateLimitRepo(db), auth.RateLimitConfig{
+			Window:      rl.Window,
+			MaxPerIP:    rl.MaxPerIP,
+			MaxPerEmail: rl.MaxPerEmail,
+			Lockout: domainauth.LockoutPolicy{
+				Threshold:  rl.LockoutThreshold,
+				Base:       rl.LockoutBase,
+				Max:        rl.LockoutMax,
+				FailureTTL: rl.FailureTTL,
+			},
+		}, logger)
+		if rl.PruneInterval > 0 {
+			go limiter.Prune(ctx, rl.PruneInterval)
+		}
+		unary = append(unary, auth.UnaryRateLimitInterceptor(limiter))
+	}
+	unary = append(unary, auth.UnaryAuthInterceptor(authUC))
+
 	opts := obs.GRPCServerOpts()
 	opts = append(opts,
-		grpc.ChainUnaryInterceptor(
-			grpcMetrics.UnaryServerInterceptor(),
-			auth.UnaryAuthInterceptor(authUC.ParseAccess),
-		),
+		grpc.ChainUnaryInterceptor(unary...),
 		grpc.ChainStreamInterceptor(
 			grpcMetrics.StreamServerInterceptor(),
+			auth.StreamClientInterceptor(proxies),
+			auth.StreamAuthInterceptor(authUC),
 		),
 	)
 
@@ -63,6 +145,8 @@ func buildGRPCServer(cfg *config.Config, logger *zap.Logger, db *pg.DB) (*grpc.S
 
 	pb.RegisterCheckServiceServer(grpcServer, checkSrv)
 	pbauth.RegisterAuthServiceServer(grpcServer, authSrv)
+	pb.RegisterOrgServiceServer(grpcServer, orgSrv)
+	pb.RegisterStatusPageServiceServer(grpcServer, pageSrv)
 
 	reflection.Register(grpcServer)
 
@@ -7,16 +7,22 @@ import (
 	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
 	"net/http"
+	"strings"
 	"time"
 
 	pb "github.com/NordCoder/Pingerus/generated/v1"
 	pbauth "github.com/NordCoder/Pingerus/generated/v1"
 	"github.com/NordCoder/Pingerus/internal/obs"
 	pg "github.com/NordCoder/Pingerus/internal/repository/postgres"
+	"github.com/NordCoder/Pingerus/internal/services/api-gateway/auth"
+	"github.com/NordCoder/Pingerus/internal/services/api-gateway/badge"
+	"github.com/NordCoder/Pingerus/internal/services/api-gateway/heartbeat"
+	"github.com/NordCoder/Pingerus/internal/services/api-gateway/statuspage"
 
 	grpcprometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
 func dialGRPCBlocking(ctx context.Context, target string) (*grpc.ClientConn, error) {
@@ -42,11 +48,36 @@ func dialGRPCBlocking(ctx context.Context, target string) (*grpc.ClientConn, err
 	}
 }
 
+// headerMatcher forwards X-Api-Key and Last-Event-ID next to the gateway's
+// default headers.
+func headerMatcher(key string) (string, bool) {
+	switch {
+	case strings.EqualFold(key, "X-Api-Key"):
+		return "x-api-key", true
+	case strings.EqualFold(key, "Last-Event-ID"):
+		return "last-event-id", true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// outgoingHeaderMatcher exposes Retry-After and the auth cookies as plain
+// HTTP headers.
+func outgoingHeaderMatcher(key string) (string, bool) {
+	switch key {
+	case auth.RetryAfterHeader:
+		return "Retry-After", true
+	case "set-cookie":
+		return "Set-Cookie", true
+	}
+	return runtime.MetadataHeaderPrefix + key, true
+}
+
 func buildHTTPServer(
 	ctx context.Context,
 	cfg *config.Config,
 	logger *zap.Logger,
 	db *pg.DB,
+	keys *auth.KeySet,
 	grpcMetrics *grpcprometheus.ServerMetrics,
 ) (*http.Server, *grpc.ClientConn, error) {
 
@@ -58,7 +89,14 @@ func buildHTTPServer(
 		return nil, nil, err
 	}
 
-	mux := runtime.NewServeMux()
+	mux := runtime.NewServeMux(
+		runtime.WithIncomingHeaderMatcher(headerMatcher),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeaderMatcher),
+		runtime.WithMarshalerOption(mimeEventStream, sseMarshaler{&runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{EmitUnpopulated: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}}),
+	)
 	if err := pb.RegisterCheckServiceHandler(ctx, mux, conn); err != nil {
 		_ = conn.Close()
 		return nil, nil, err
@@ -67,10 +105,32 @@ func buildHTTPServer(
 		_ = conn.Close()
 		return nil, nil, err
 	}
+	if err := pb.RegisterOrgServiceHandler(ctx, mux, conn); err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	if err := pb.RegisterStatusPageServiceHandler(ctx, mux, conn); err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
 
 	root := http.NewServeMux()
 	root.Handle("/", mux)
 	root.Handle("/metrics", obs.MetricsHandler())
+	root.Handle("/.well-known/jwks.json", auth.JWKSHandler(keys))
+	root.Handle("GET /status/{slug}", statuspage.HTMLHandler(pb.NewStatusPageServiceClient(conn), logger))
+	badges := badge.NewService(pg.NewCheckRepo(db), pg.NewRunRepo(db), cfg.Badges.CacheTTL)
+	root.Handle("GET /badge/{file}", badge.Handler(badges, cfg.Badges.CacheTTL, logger))
+	outboxRepo := pg.NewOutboxRepo(db)
+	if cfg.Outbox.Notify {
+		outboxRepo = outboxRepo.WithNotify(cfg.Outbox.Channel)
+	}
+	pings := heartbeat.Handler(heartbeat.NewUsecase(
+		pg.NewCheckRepo(db), pg.NewRunRepo(db), outboxRepo, pg.NewTransactor(db, logger),
+	), logger)
+	for _, pattern := range []string{"GET /ping/{token}", "POST /ping/{token}", "GET /ping/{token}/{kind}", "POST /ping/{token}/{kind}"} {
+		root.Handle(pattern, pings)
+	}
 	root.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
 		hctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
 		defer cancel()
@@ -83,7 +143,7 @@ func buildHTTPServer(
 	})
 
 	// todo: вынести в конфиг
-	handler := cors([]string{"http://frontend:80"})(root)
+	handler := cors([]string{"http://frontend:80"})(watchStreams(root))
 
 	httpSrv := &http.Server{
 		Addr:              cfg.Server.HTTPAddr,
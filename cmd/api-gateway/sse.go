@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+const mimeEventStream = "text/event-stream"
+
+// sseMarshaler frames streamed responses as Server-Sent Events: each chunk
+// becomes a "data:" line, with an "id:" line for messages that carry a
+// cursor so that EventSource reconnects send it back as Last-Event-ID.
+type sseMarshaler struct {
+	runtime.Marshaler
+}
+
+type cursored interface{ GetCursor() int64 }
+
+func (m sseMarshaler) Marshal(v any) ([]byte, error) {
+	data, err := m.Marshaler.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if chunk, ok := v.(map[string]any); ok {
+		if c, ok := chunk["result"].(cursored); ok {
+			buf.WriteString("id: " + strconv.FormatInt(c.GetCursor(), 10) + "\n")
+		}
+	}
+	buf.WriteString("data: ")
+	buf.Write(data)
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+func (sseMarshaler) ContentType(any) string { return mimeEventStream }
+
+// Delimiter ends each event with the blank line SSE requires.
+func (sseMarshaler) Delimiter() []byte { return []byte("\n") }
+
+// watchStreams lifts the server write timeout for the :watch endpoints, which
+// stay open indefinitely, and tells proxies not to buffer event streams.
+func watchStreams(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ":watch") {
+			_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("X-Accel-Buffering", "no")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	kafkax "github.com/NordCoder/Pingerus/internal/repository/kafka"
+	"github.com/segmentio/kafka-go"
+)
+
+const usage = `usage: dlq <list|replay> [flags]
+
+  list    print messages of a dead-letter topic
+  replay  republish dead-lettered messages to their original topic
+
+flags:
+`
+
+type opts struct {
+	brokers   string
+	topic     string
+	partition int
+	from      int64
+	offset    int64
+	limit     int
+	dryRun    bool
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cmd := os.Args[1]
+
+	var o opts
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	fs.StringVar(&o.brokers, "brokers", env("KAFKA_BROKER", "localhost:9094"), "comma separated broker list")
+	fs.StringVar(&o.topic, "topic", "", "dead-letter topic, e.g. status-change.dlq")
+	fs.IntVar(&o.partition, "partition", 0, "partition to read")
+	fs.Int64Var(&o.from, "from", 0, "first offset to read")
+	fs.Int64Var(&o.offset, "offset", -1, "only this offset (replay a single message)")
+	fs.IntVar(&o.limit, "limit", 100, "max messages to read (0 = up to the end)")
+	fs.BoolVar(&o.dryRun, "dry-run", false, "replay: print what would be republished")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(os.Args[2:])
+
+	if o.topic == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if o.offset >= 0 {
+		o.from, o.limit = o.offset, 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch cmd {
+	case "list":
+		err = list(ctx, o)
+	case "replay":
+		err = replay(ctx, o)
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("%s: %v", cmd, err)
+	}
+}
+
+func list(ctx context.Context, o opts) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "OFFSET\tKEY\tORIGIN\tGROUP\tFAILED AT\tBYTES\tERROR")
+	err := scan(ctx, o, func(m kafka.Message) error {
+		origin := header(m, kafkax.HeaderDLQTopic) + "/" + header(m, kafkax.HeaderDLQPartition) + "@" + header(m, kafkax.HeaderDLQOffset)
+		_, err := fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			m.Offset, m.Key, origin, header(m, kafkax.HeaderDLQGroup), header(m, kafkax.HeaderDLQFailedAt),
+			len(m.Value), header(m, kafkax.HeaderDLQError))
+		return err
+	})
+	_ = tw.Flush()
+	return err
+}
+
+func replay(ctx context.Context, o opts) error {
+	producers := kafkax.NewProducers(strings.Split(o.brokers, ","))
+	defer func() { _ = producers.Close() }()
+
+	n := 0
+	err := scan(ctx, o, func(m kafka.Message) error {
+		dst := header(m, kafkax.HeaderDLQTopic)
+		if dst == "" {
+			log.Printf("offset %d: no %s header; skipped", m.Offset, kafkax.HeaderDLQTopic)
+			return nil
+		}
+		if o.dryRun {
+			log.Printf("offset %d: would replay to %s", m.Offset, dst)
+			return nil
+		}
+		err := producers.Topic(dst).PublishMessage(ctx, kafka.Message{
+			Key:     m.Key,
+			Value:   m.Value,
+			Headers: kafkax.StripDLQHeaders(m.Headers),
+		})
+		if err != nil {
+			return fmt.Errorf("offset %d: %w", m.Offset, err)
+		}
+		n++
+		log.Printf("offset %d: replayed to %s", m.Offset, dst)
+		return nil
+	})
+	log.Printf("replayed %d message(s)", n)
+	return err
+}
+
+// scan reads [from, high watermark) of one partition, stopping after limit messages.
+func scan(ctx context.Context, o opts, fn func(kafka.Message) error) error {
+	brokers := strings.Split(o.brokers, ",")
+	conn, err := kafka.DialLeader(ctx, "tcp", brokers[0], o.topic, o.partition)
+	if err != nil {
+		return fmt.Errorf("dial leader: %w", err)
+	}
+	first, last, err := conn.ReadOffsets()
+	_ = conn.Close()
+	if err != nil {
+		return fmt.Errorf("read offsets: %w", err)
+	}
+	from := max(o.from, first)
+	if from >= last {
+		return nil
+	}
+
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   brokers,
+		Topic:     o.topic,
+		Partition: o.partition,
+		MaxWait:   time.Second,
+	})
+	defer func() { _ = r.Close() }()
+	if err := r.SetOffset(from); err != nil {
+		return fmt.Errorf("set offset: %w", err)
+	}
+
+	for read := 0; o.limit <= 0 || read < o.limit; read++ {
+		m, err := r.ReadMessage(ctx)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return fmt.Errorf("read: %w", err)
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+		if m.Offset >= last-1 {
+			return nil
+		}
+	}
+	return nil
+}
+
+func header(m kafka.Message, key string) string {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func env(k, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return def
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	config "github.com/NordCoder/Pingerus/internal/config/email-notifier"
+	"github.com/NordCoder/Pingerus/internal/inbox"
 	"github.com/NordCoder/Pingerus/internal/obs"
 	"github.com/NordCoder/Pingerus/internal/repository/kafka"
 	pg "github.com/NordCoder/Pingerus/internal/repository/postgres"
@@ -23,22 +24,34 @@ type systemClock struct{}
 
 func (systemClock) Now() time.Time { return time.Now().UTC() }
 
-func wiring(db *pg.DB, cfg *config.Config, cons *kafka.Consumer, l *zap.Logger) *notifier.Controller {
+func wiring(db *pg.DB, cfg *config.Config, cons, emailCons *kafka.Consumer, l *zap.Logger) *notifier.Controller {
 	checks := pg.NewCheckRepo(db)
 	users := pg.NewUserRepo(db)
 	notifs := pg.NewNotificationRepo(db)
 	mailer := notifier.New(cfg.SMTP).WithLogger(l)
 
 	uc := &notifier.Handler{
-		Checks: repo.CheckReader{R: checks},
-		Users:  repo.UserReader{R: users},
-		Store:  repo.NotificationRepo{R: notifs},
-		Out:    mailer,
-		Clock:  systemClock{},
-		Log:    l,
+		Checks:  repo.CheckReader{R: checks},
+		Users:   repo.UserReader{R: users},
+		Store:   repo.NotificationRepo{R: notifs},
+		Deps:    &repo.Dependencies{R: pg.NewCheckDependencyRepo(db)},
+		Members: &repo.Members{R: pg.NewOrgRepo(db)},
+		Plans:   &repo.Plans{R: pg.NewPlanRepo(db), Catalog: cfg.Plans.AsCatalog()},
+		Out:     mailer,
+		Clock:   systemClock{},
+		Log:     l,
 	}
 
-	return &notifier.Controller{Log: l, Sub: cons, UC: uc}
+	ctrl := &notifier.Controller{Log: l, Sub: cons, UC: uc, EmailSub: emailCons}
+	if cfg.In.Inbox {
+		ctrl.Inbox = inbox.New(cfg.In.GroupID, pg.NewInboxRepo(db), pg.NewTransactor(db, l), l)
+		uc.Inbox = ctrl.Inbox
+	}
+	if emailCons != nil && cfg.EmailIn.Inbox {
+		ctrl.EmailInbox = inbox.New(cfg.EmailIn.GroupID, pg.NewInboxRepo(db), pg.NewTransactor(db, l), l)
+	}
+
+	return ctrl
 }
 
 func main() {
@@ -88,13 +101,28 @@ func main() {
 	cons := kafka.BootstrapConsumer(rootCtx, cfg.In.AsConsumerConfig(), l).WithLogger(l)
 	defer func() { _ = cons.Close() }()
 
+	var emailCons *kafka.Consumer
+	if cfg.EmailIn.Topic != "" {
+		emailCons = kafka.BootstrapConsumer(rootCtx, cfg.EmailIn.AsConsumerConfig(), l).WithLogger(l)
+		defer func() { _ = emailCons.Close() }()
+	}
+
 	// start
-	ctrl := wiring(db, cfg, cons, l)
-	errCh := make(chan error, 1)
+	ctrl := wiring(db, cfg, cons, emailCons, l)
+	if ctrl.Inbox != nil && cfg.In.InboxPruneInterval > 0 {
+		go ctrl.Inbox.Prune(rootCtx, cfg.In.InboxRetention, cfg.In.InboxPruneInterval)
+	}
+	if ctrl.EmailInbox != nil && cfg.EmailIn.InboxPruneInterval > 0 {
+		go ctrl.EmailInbox.Prune(rootCtx, cfg.EmailIn.InboxRetention, cfg.EmailIn.InboxPruneInterval)
+	}
+	errCh := make(chan error, 2)
 	go func() {
 		l.Info("controller starting")
 		errCh <- ctrl.Run(rootCtx)
 	}()
+	if emailCons != nil {
+		go func() { errCh <- ctrl.RunEmails(rootCtx) }()
+	}
 
 	l.Info("email-notifier started")
 
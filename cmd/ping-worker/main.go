@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"errors"
+	"github.com/NordCoder/Pingerus/internal/inbox"
 	"github.com/NordCoder/Pingerus/internal/obs/retry"
 	"github.com/NordCoder/Pingerus/internal/outbox"
 	pingworker "github.com/NordCoder/Pingerus/internal/services/ping-worker"
@@ -25,19 +26,31 @@ type systemClock struct{}
 
 func (systemClock) Now() time.Time { return time.Now().UTC() }
 
-func wire(cfg *config.Config, db *pg.DB, events *kafka.CheckEventsKafka, cons *kafka.Consumer, l *zap.Logger) (*outbox.Runner, *pingworker.Controller) {
+func wire(cfg *config.Config, db *pg.DB, pubs *kafka.Producers, cons *kafka.Consumer, l *zap.Logger) (*outbox.Runner, *pingworker.Controller, error) {
 	outboxRepo := pg.NewOutboxRepo(db)
+	if cfg.Outbox.Notify {
+		outboxRepo = outboxRepo.WithNotify(cfg.Outbox.Channel)
+	}
 	transactor := pg.NewTransactor(db, l)
 
-	dispatch := outbox.MakeGlobalOutboxHandler(events, retry.DefaultKafkaPolicy(l))
-	outboxRunner := outbox.NewOutboxRunner( // todo config
+	// ping-worker relays the whole outbox table, so every kind is registered
+	// here, including those enqueued by other services.
+	registry := outbox.NewRegistry()
+	if err := outbox.RegisterStatusChanged(registry, cfg.Out.Topic); err != nil {
+		return nil, nil, err
+	}
+	if err := outbox.RegisterEmailRequested(registry, cfg.Out.EmailTopic); err != nil {
+		return nil, nil, err
+	}
+	dispatch := registry.Dispatcher(pubs, retry.DefaultKafkaPolicy(l))
+	outboxRunner := outbox.NewOutboxRunner(
 		l,
 		outboxRepo,
 		dispatch,
-		20,
-		100,
-		2*time.Second,
-		30*time.Second)
+		cfg.Outbox.Workers,
+		cfg.Outbox.BatchSize,
+		cfg.Outbox.WaitTime,
+		cfg.Outbox.InProgressTTL)
 
 	checks := pg.NewCheckRepo(db)
 	runs := pg.NewRunRepo(db)
@@ -54,12 +67,16 @@ func wire(cfg *config.Config, db *pg.DB, events *kafka.CheckEventsKafka, cons *k
 		Runs:       workerrepo.RunRepo{R: runs},
 		Outbox:     outboxRepo,
 		Transactor: transactor,
-		Events:     workerrepo.Events{P: events},
 		Clock:      systemClock{},
 		HTTP:       pingworker.HTTPPing{Client: httpc, UserAgent: cfg.HTTP.UserAgent},
 	}
 
-	return outboxRunner, &pingworker.Controller{Log: l, Sub: cons, UC: uc}
+	ctrl := &pingworker.Controller{Log: l, Sub: cons, UC: uc}
+	if cfg.In.Inbox {
+		ctrl.Inbox = inbox.New(cfg.In.GroupID, pg.NewInboxRepo(db), transactor, l)
+	}
+
+	return outboxRunner, ctrl, nil
 }
 
 func main() {
@@ -107,16 +124,27 @@ func main() {
 	cons := kafka.BootstrapConsumer(root, cfg.In.AsConsumerConfig(), l).WithLogger(l)
 	defer func() { _ = cons.Close() }()
 
-	prod := kafka.NewProducer(cfg.Out.Brokers, cfg.Out.Topic).WithLogger(l) // todo make bootstrap
-	defer func() { _ = prod.Close() }()
-
-	events := kafka.NewCheckEventsKafka(prod)
+	// the outbox publishes everything the worker produces
+	pubs := kafka.NewProducers(cfg.Out.Brokers).WithLogger(l)
+	defer func() { _ = pubs.Close() }()
 
 	// wiring
-	outboxRunner, ctrl := wire(cfg, db, events, cons, l)
+	outboxRunner, ctrl, err := wire(cfg, db, pubs, cons, l)
+	if err != nil {
+		l.Fatal("wiring", zap.Error(err))
+	}
 
 	// start
+	if cfg.Outbox.Notify {
+		wake := make(chan struct{}, 1)
+		listener := pg.NewListener(db, cfg.Outbox.Channel, l)
+		go func() { _ = listener.Run(root, wake) }()
+		outboxRunner.WithWakeup(wake)
+	}
 	outboxRunner.Start(root)
+	if ctrl.Inbox != nil && cfg.In.InboxPruneInterval > 0 {
+		go ctrl.Inbox.Prune(root, cfg.In.InboxRetention, cfg.In.InboxPruneInterval)
+	}
 	errCh := make(chan error, 1)
 	go func() { errCh <- ctrl.Run(root) }()
 
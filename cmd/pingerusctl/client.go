@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+
+	pb "github.com/NordCoder/Pingerus/generated/v1"
+	"github.com/NordCoder/Pingerus/internal/services/api-gateway/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var errUsage = errors.New("invalid arguments")
+
+type client struct {
+	conn   *grpc.ClientConn
+	checks pb.CheckServiceClient
+	orgs   pb.OrgServiceClient
+}
+
+func dial(o opts) (*client, error) {
+	creds := insecure.NewCredentials()
+	if o.tls {
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+	conn, err := grpc.NewClient(o.server,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithUnaryInterceptor(func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+			return invoker(withToken(ctx, o.token), method, req, reply, cc, callOpts...)
+		}),
+		grpc.WithStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return streamer(withToken(ctx, o.token), desc, cc, method, callOpts...)
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", o.server, err)
+	}
+	return &client{
+		conn:   conn,
+		checks: pb.NewCheckServiceClient(conn),
+		orgs:   pb.NewOrgServiceClient(conn),
+	}, nil
+}
+
+func (c *client) Close() error { return c.conn.Close() }
+
+// withToken sends API keys the way the gateway expects them and anything
+// else as a bearer token.
+func withToken(ctx context.Context, token string) context.Context {
+	if auth.IsAPIKey(token) {
+		return metadata.AppendToOutgoingContext(ctx, "x-api-key", token)
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+// resolveOrg picks the org commands act on when -org is not given: the only
+// org an API key sees, or else the personal org.
+func (c *client) resolveOrg(ctx context.Context, org int64) (int64, error) {
+	if org != 0 {
+		return org, nil
+	}
+	resp, err := c.orgs.ListOrgs(ctx, &emptypb.Empty{})
+	if err != nil {
+		return 0, fmt.Errorf("list orgs: %w", err)
+	}
+	orgs := resp.GetOrgs()
+	if len(orgs) == 1 {
+		return orgs[0].GetId(), nil
+	}
+	for _, o := range orgs {
+		if o.GetPersonal() {
+			return o.GetId(), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: cannot tell which org to use, pass -org", errUsage)
+}
+
+// allChecks pages through the checks of org, only those of type typ unless
+// it is empty.
+func (c *client) allChecks(ctx context.Context, org int64, typ string, pageSize int) ([]*pb.Check, error) {
+	var (
+		out   []*pb.Check
+		token string
+	)
+	for {
+		resp, err := c.checks.ListChecks(ctx, &pb.ListChecksRequest{
+			OrgId:     org,
+			Type:      typ,
+			PageSize:  int32(pageSize),
+			PageToken: token,
+			Sort:      pb.CheckSort_CHECK_SORT_NAME,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list checks: %w", err)
+		}
+		out = append(out, resp.GetChecks()...)
+		if token = resp.GetNextPageToken(); token == "" {
+			return out, nil
+		}
+	}
+}
+
+func fileFormat(o opts) (pb.CheckFormat, error) {
+	f := o.format
+	if f == "" {
+		switch {
+		case strings.HasSuffix(o.file, ".json"):
+			f = "json"
+		case strings.HasSuffix(o.file, ".csv"):
+			f = "csv"
+		default:
+			f = "yaml"
+		}
+	}
+	switch f {
+	case "yaml", "yml":
+		return pb.CheckFormat_CHECK_FORMAT_YAML, nil
+	case "json":
+		return pb.CheckFormat_CHECK_FORMAT_JSON, nil
+	case "csv":
+		return pb.CheckFormat_CHECK_FORMAT_CSV, nil
+	default:
+		return 0, fmt.Errorf("%w: unknown format %q", errUsage, f)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	pb "github.com/NordCoder/Pingerus/generated/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func getChecks(ctx context.Context, c *client, o opts, ids []string) error {
+	var list []*pb.Check
+	if len(ids) == 0 {
+		org, err := c.resolveOrg(ctx, o.org)
+		if err != nil {
+			return err
+		}
+		if list, err = c.allChecks(ctx, org, "", o.pageSize); err != nil {
+			return err
+		}
+	}
+	for _, s := range ids {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: bad check id %q", errUsage, s)
+		}
+		chk, err := c.checks.GetCheck(ctx, &pb.GetCheckRequest{Id: id})
+		if err != nil {
+			return fmt.Errorf("get check %d: %w", id, err)
+		}
+		list = append(list, chk)
+	}
+	return printChecks(os.Stdout, o.output, list)
+}
+
+func getOrgs(ctx context.Context, c *client, o opts) error {
+	resp, err := c.orgs.ListOrgs(ctx, &emptypb.Empty{})
+	if err != nil {
+		return fmt.Errorf("list orgs: %w", err)
+	}
+	return printOrgs(os.Stdout, o.output, resp.GetOrgs())
+}
+
+func export(ctx context.Context, c *client, o opts) error {
+	format, err := fileFormat(o)
+	if err != nil {
+		return err
+	}
+	org, err := c.resolveOrg(ctx, o.org)
+	if err != nil {
+		return err
+	}
+	stream, err := c.checks.ExportChecks(ctx, &pb.ExportChecksRequest{OrgId: org, Format: format})
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+		if _, err := os.Stdout.Write(chunk.GetData()); err != nil {
+			return err
+		}
+	}
+}
+
+// plan is what apply would do: the server's import plan plus the checks
+// missing from the file.
+type plan struct {
+	Import  *pb.ImportChecksResponse
+	Deletes []*pb.Check
+}
+
+func (p plan) changes() int {
+	return int(p.Import.GetCreated()+p.Import.GetUpdated()) + len(p.Deletes)
+}
+
+// sync diffs o.file against the server and, with apply, writes the diff.
+func sync(ctx context.Context, c *client, o opts, apply bool) error {
+	format, err := fileFormat(o)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(o.file)
+	if err != nil {
+		return err
+	}
+	org, err := c.resolveOrg(ctx, o.org)
+	if err != nil {
+		return err
+	}
+
+	req := &pb.ImportChecksRequest{OrgId: org, Format: format, Data: string(data), DryRun: true}
+	resp, err := c.checks.ImportChecks(ctx, req)
+	if err != nil {
+		return fmt.Errorf("plan: %w", err)
+	}
+	p := plan{Import: resp}
+	if !o.noPrune {
+		// files only carry HTTP checks, so nothing else is theirs to prune
+		existing, err := c.allChecks(ctx, org, "http", o.pageSize)
+		if err != nil {
+			return err
+		}
+		inFile := make(map[int64]bool, len(resp.GetRows()))
+		for _, r := range resp.GetRows() {
+			inFile[r.GetCheckId()] = true
+		}
+		for _, chk := range existing {
+			if !inFile[chk.GetId()] {
+				p.Deletes = append(p.Deletes, chk)
+			}
+		}
+	}
+
+	if err := printPlan(os.Stdout, o.output, p); err != nil {
+		return err
+	}
+	if resp.GetFailed() > 0 {
+		return fmt.Errorf("%w: %d of %d", errInvalidRows, resp.GetFailed(), len(resp.GetRows()))
+	}
+	if p.changes() == 0 {
+		return nil
+	}
+	if !apply {
+		return errChanges
+	}
+
+	req = proto.Clone(req).(*pb.ImportChecksRequest)
+	req.DryRun = false
+	applied, err := c.checks.ImportChecks(ctx, req)
+	if err != nil {
+		return fmt.Errorf("apply: %w", err)
+	}
+	if !applied.GetApplied() {
+		_ = printPlan(os.Stderr, "table", plan{Import: applied})
+		return errors.New("apply: the server rejected the import, nothing was written")
+	}
+	deleted := 0
+	for _, chk := range p.Deletes {
+		_, err := c.checks.DeleteCheck(ctx, &pb.DeleteCheckRequest{Id: chk.GetId()})
+		if err != nil && status.Code(err) != codes.NotFound {
+			return fmt.Errorf("apply: delete check %d: %w (creates and updates were applied)", chk.GetId(), err)
+		}
+		deleted++
+	}
+	fmt.Fprintf(os.Stderr, "Applied: %d created, %d updated, %d deleted.\n",
+		applied.GetCreated(), applied.GetUpdated(), deleted)
+	return nil
+}
+
+func deleteChecks(ctx context.Context, c *client, ids []string) error {
+	for _, s := range ids {
+		id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: bad check id %q", errUsage, s)
+		}
+		if _, err := c.checks.DeleteCheck(ctx, &pb.DeleteCheckRequest{Id: id}); err != nil {
+			return fmt.Errorf("delete check %d: %w", id, err)
+		}
+		fmt.Fprintf(os.Stderr, "deleted check %d\n", id)
+	}
+	return nil
+}
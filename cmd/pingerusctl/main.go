@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const usage = `usage: pingerusctl <command> [flags] [args]
+
+  get checks [id...]    list checks, or show the given ones
+  get orgs              list the orgs the token can see
+  export                print the org's checks in apply format
+  diff -f FILE          show what apply would change
+  apply -f FILE         create, update and delete checks to match FILE
+  delete ID...          delete checks
+
+FILE is YAML, JSON or CSV as written by export; the format follows the
+extension unless -format is given. Files hold HTTP checks only; apply never
+deletes heartbeats.
+
+exit codes: 0 ok, 1 error, 2 usage, 3 diff found changes, 4 FILE has
+invalid rows
+
+flags:
+`
+
+const (
+	exitOK      = 0
+	exitError   = 1
+	exitUsage   = 2
+	exitChanges = 3
+	exitInvalid = 4
+)
+
+// errInvalidRows and errChanges carry exit codes out of commands.
+var (
+	errInvalidRows = errors.New("file has invalid rows")
+	errChanges     = errors.New("changes pending")
+)
+
+type opts struct {
+	server   string
+	token    string
+	tls      bool
+	org      int64
+	output   string
+	file     string
+	format   string
+	noPrune  bool
+	pageSize int
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(exitUsage)
+	}
+	cmd := os.Args[1]
+
+	var o opts
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	fs.StringVar(&o.server, "server", env("PINGERUS_SERVER", "localhost:9090"), "api-gateway gRPC address")
+	fs.StringVar(&o.token, "token", os.Getenv("PINGERUS_TOKEN"), "API key or access token (env PINGERUS_TOKEN)")
+	fs.BoolVar(&o.tls, "tls", false, "connect with TLS")
+	fs.Int64Var(&o.org, "org", 0, "org ID (0 = the token's org, else the personal org)")
+	fs.StringVar(&o.output, "o", "table", "output: table, json or yaml")
+	fs.StringVar(&o.file, "f", "", "checks file for diff and apply")
+	fs.StringVar(&o.format, "format", "", "file format: yaml, json or csv")
+	fs.BoolVar(&o.noPrune, "no-prune", false, "apply: keep checks that are not in FILE")
+	fs.IntVar(&o.pageSize, "page-size", 200, "checks fetched per request")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		fs.PrintDefaults()
+	}
+	// flags may follow positionals, as in "get checks -o json"
+	var args []string
+	for rest := os.Args[2:]; ; {
+		_ = fs.Parse(rest)
+		if rest = fs.Args(); len(rest) == 0 {
+			break
+		}
+		args, rest = append(args, rest[0]), rest[1:]
+	}
+
+	switch o.output {
+	case "table", "json", "yaml":
+	default:
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	if o.token == "" {
+		fmt.Fprintln(os.Stderr, "pingerusctl: -token or PINGERUS_TOKEN is required")
+		os.Exit(exitUsage)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	c, err := dial(o)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pingerusctl: %v\n", err)
+		os.Exit(exitError)
+	}
+	defer func() { _ = c.Close() }()
+
+	switch {
+	case cmd == "get" && len(args) > 0 && args[0] == "checks":
+		err = getChecks(ctx, c, o, args[1:])
+	case cmd == "get" && len(args) == 1 && args[0] == "orgs":
+		err = getOrgs(ctx, c, o)
+	case cmd == "export" && len(args) == 0:
+		err = export(ctx, c, o)
+	case (cmd == "diff" || cmd == "apply") && o.file != "" && len(args) == 0:
+		err = sync(ctx, c, o, cmd == "apply")
+	case cmd == "delete" && len(args) > 0:
+		err = deleteChecks(ctx, c, args)
+	default:
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+
+	switch {
+	case err == nil:
+		os.Exit(exitOK)
+	case errors.Is(err, errChanges):
+		os.Exit(exitChanges)
+	case errors.Is(err, errInvalidRows):
+		fmt.Fprintf(os.Stderr, "pingerusctl %s: %v\n", cmd, err)
+		os.Exit(exitInvalid)
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(os.Stderr, "pingerusctl %s: %v\n", cmd, err)
+		os.Exit(exitUsage)
+	default:
+		fmt.Fprintf(os.Stderr, "pingerusctl %s: %v\n", cmd, err)
+		os.Exit(exitError)
+	}
+}
+
+func env(k, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return def
+}
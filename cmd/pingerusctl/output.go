@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	pb "github.com/NordCoder/Pingerus/generated/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+func printChecks(w io.Writer, output string, list []*pb.Check) error {
+	if output != "table" {
+		return printStructured(w, output, protoList(list))
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ID\tNAME\tURL\tINTERVAL\tSTATUS\tTAGS\tNEXT RUN")
+	for _, c := range list {
+		_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			c.GetId(), c.GetName(), c.GetUrl(), time.Duration(c.GetIntervalSec())*time.Second,
+			checkStatus(c), strings.Join(c.GetTags(), ","), c.GetNextRun().AsTime().Local().Format(time.DateTime))
+	}
+	return tw.Flush()
+}
+
+func printOrgs(w io.Writer, output string, list []*pb.Org) error {
+	if output != "table" {
+		return printStructured(w, output, protoList(list))
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ID\tNAME\tROLE\tPERSONAL")
+	for _, o := range list {
+		role := strings.ToLower(strings.TrimPrefix(o.GetRole().String(), "ORG_ROLE_"))
+		_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%t\n", o.GetId(), o.GetName(), role, o.GetPersonal())
+	}
+	return tw.Flush()
+}
+
+// printPlan shows changes only; unchanged rows are counted in the summary.
+func printPlan(w io.Writer, output string, p plan) error {
+	if output != "table" {
+		return printStructured(w, output, map[string]any{
+			"rows":   protoList(p.Import.GetRows()),
+			"delete": protoList(p.Deletes),
+			"summary": map[string]int{
+				"create":    int(p.Import.GetCreated()),
+				"update":    int(p.Import.GetUpdated()),
+				"delete":    len(p.Deletes),
+				"unchanged": int(p.Import.GetUnchanged()),
+				"invalid":   int(p.Import.GetFailed()),
+			},
+		})
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ACTION\tROW\tID\tNAME\tURL\tDETAIL")
+	for _, r := range p.Import.GetRows() {
+		var action, detail string
+		switch r.GetAction() {
+		case pb.ImportAction_IMPORT_ACTION_CREATE:
+			action = "+ create"
+		case pb.ImportAction_IMPORT_ACTION_UPDATE:
+			action, detail = "~ update", strings.Join(r.GetChangedFields(), ", ")
+		case pb.ImportAction_IMPORT_ACTION_ERROR:
+			action, detail = "! invalid", strings.Join(r.GetErrors(), "; ")
+		default:
+			continue
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\n", action, r.GetRow(), optionalID(r.GetCheckId()), r.GetName(), r.GetUrl(), detail)
+	}
+	for _, c := range p.Deletes {
+		_, _ = fmt.Fprintf(tw, "- delete\t\t%d\t%s\t%s\t\n", c.GetId(), c.GetName(), c.GetUrl())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\nPlan: %d to create, %d to update, %d to delete, %d unchanged, %d invalid.\n",
+		p.Import.GetCreated(), p.Import.GetUpdated(), len(p.Deletes), p.Import.GetUnchanged(), p.Import.GetFailed())
+	return err
+}
+
+func printStructured(w io.Writer, output string, v any) error {
+	if output == "yaml" {
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// protoList converts messages to plain values with proto field names, so
+// JSON and YAML output read the same.
+func protoList[M proto.Message](msgs []M) []any {
+	out := make([]any, 0, len(msgs))
+	for _, m := range msgs {
+		b, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(m)
+		if err != nil {
+			continue
+		}
+		var v any
+		if err := json.Unmarshal(b, &v); err == nil {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func checkStatus(c *pb.Check) string {
+	switch {
+	case c.LastStatus == nil:
+		return "unknown"
+	case c.GetLastStatus():
+		return "up"
+	default:
+		return "down"
+	}
+}
+
+func optionalID(id int64) string {
+	if id == 0 {
+		return ""
+	}
+	return fmt.Sprint(id)
+}
@@ -71,6 +71,16 @@ func main() {
 		repo.Events{P: publisher},
 	)
 	runner := scheduler.New(l, uc, &cfg.Sched)
+	outboxRepo := pg.NewOutboxRepo(db)
+	if cfg.Outbox.Notify {
+		outboxRepo = outboxRepo.WithNotify(cfg.Outbox.Channel)
+	}
+	runner.HB = &scheduler.Heartbeats{
+		Checks:     checkRepo,
+		Runs:       pg.NewRunRepo(db),
+		Outbox:     outboxRepo,
+		Transactor: pg.NewTransactor(db, l),
+	}
 
 	// run
 	errCh := make(chan error, 1)
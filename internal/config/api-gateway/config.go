@@ -1,6 +1,7 @@
 package api_gateway_config
 
 import (
+	"github.com/NordCoder/Pingerus/internal/domain/plan"
 	"github.com/NordCoder/Pingerus/internal/obs"
 	"time"
 
@@ -20,6 +21,9 @@ type Server struct {
 	WriteTimeout    time.Duration `mapstructure:"write_timeout"`
 	IdleTimeout     time.Duration `mapstructure:"idle_timeout"`
 	GracefulTimeout time.Duration `mapstructure:"graceful_timeout"`
+	// TrustedProxies are CIDRs whose X-Forwarded-For is believed when
+	// telling clients apart; the HTTP gateway dials in over loopback.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 }
 
 type OTEL struct {
@@ -62,6 +66,134 @@ type Auth struct {
 	CookieDomain string        `mapstructure:"cookie_domain"`
 	CookiePath   string        `mapstructure:"cookie_path"`
 	CookieSecure bool          `mapstructure:"cookie_secure"`
+	AdminEmails  []string      `mapstructure:"admin_emails"`
+	AppURL       string        `mapstructure:"app_url"`
+	VerifyTTL    time.Duration `mapstructure:"verify_ttl"`
+	ResetTTL     time.Duration `mapstructure:"reset_ttl"`
+	// MFAKey encrypts TOTP secrets at rest; jwt_secret is used when empty.
+	MFAKey          string        `mapstructure:"mfa_key"`
+	MFAIssuer       string        `mapstructure:"mfa_issuer"`
+	MFAChallengeTTL time.Duration `mapstructure:"mfa_challenge_ttl"`
+	JWT             JWT           `mapstructure:"jwt"`
+	OIDC            OIDC          `mapstructure:"oidc"`
+}
+
+// OIDC configures single sign-on; providers are ignored unless enabled.
+type OIDC struct {
+	Enable    bool           `mapstructure:"enable"`
+	StateTTL  time.Duration  `mapstructure:"state_ttl"`
+	Providers []OIDCProvider `mapstructure:"providers"`
+}
+
+type OIDCProvider struct {
+	Name         string `mapstructure:"name"`
+	DisplayName  string `mapstructure:"display_name"`
+	Issuer       string `mapstructure:"issuer"`
+	ClientID     string `mapstructure:"client_id"`
+	ClientSecret string `mapstructure:"client_secret"`
+	// ClientSecretEnv names an environment variable that overrides
+	// client_secret, so the secret can stay out of the file.
+	ClientSecretEnv string   `mapstructure:"client_secret_env"`
+	RedirectURL     string   `mapstructure:"redirect_url"`
+	Scopes          []string `mapstructure:"scopes"`
+	AutoProvision   bool     `mapstructure:"auto_provision"`
+	AllowedDomains  []string `mapstructure:"allowed_domains"`
+}
+
+// JWT switches access tokens from HS256 with jwt_secret to asymmetric keys.
+type JWT struct {
+	Keys []JWTKey `mapstructure:"keys"`
+	// AcceptHS256 keeps jwt_secret tokens valid while migrating to keys.
+	AcceptHS256    bool          `mapstructure:"accept_hs256"`
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+	// Issuer and Audience are set on and required of key-signed tokens.
+	Issuer   string `mapstructure:"issuer"`
+	Audience string `mapstructure:"audience"`
+}
+
+type JWTKey struct {
+	Kid            string `mapstructure:"kid"`
+	Alg            string `mapstructure:"alg"`
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	PublicKeyFile  string `mapstructure:"public_key_file"`
+	// ActiveFrom is an RFC 3339 time; empty means active immediately.
+	ActiveFrom string `mapstructure:"active_from"`
+}
+
+// Outbox is where the gateway queues emails; ping-worker relays them.
+type Outbox struct {
+	Notify  bool   `mapstructure:"notify"`
+	Channel string `mapstructure:"channel"`
+}
+
+// Watch tunes the WatchChecks streams.
+type Watch struct {
+	Heartbeat    time.Duration `mapstructure:"heartbeat"`
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	// Retention is how long events stay available for resuming. Status
+	// page incidents are read from the same events, so it must cover
+	// StatusPages.IncidentWindow.
+	Retention     time.Duration `mapstructure:"retention"`
+	PruneInterval time.Duration `mapstructure:"prune_interval"`
+	Buffer        int           `mapstructure:"buffer"`
+}
+
+type StatusPages struct {
+	// CacheTTL is how long a public page is served from memory.
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+	// IncidentWindow is how far back a public page lists incidents.
+	IncidentWindow time.Duration `mapstructure:"incident_window"`
+}
+
+type Badges struct {
+	// CacheTTL is how long a rendered badge is reused, and the max-age
+	// clients and CDNs may cache it for.
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+}
+
+// Plan is one tier of limits; zero values are unlimited.
+type Plan struct {
+	Name         string        `mapstructure:"name"`
+	MaxChecks    int           `mapstructure:"max_checks"`
+	MinInterval  time.Duration `mapstructure:"min_interval"`
+	MaxChannels  int           `mapstructure:"max_channels"`
+	RunRetention time.Duration `mapstructure:"run_retention"`
+}
+
+// Plans lists the tiers users can be on. Users without a plan get Default;
+// with no tiers configured nobody is limited.
+type Plans struct {
+	Default string `mapstructure:"default"`
+	// PruneInterval is how often runs past their plan's retention are
+	// deleted; 0 disables pruning.
+	PruneInterval time.Duration `mapstructure:"prune_interval"`
+	Tiers         []Plan        `mapstructure:"tiers"`
+}
+
+func (pc *Plans) AsCatalog() plan.Catalog {
+	c := plan.Catalog{Default: pc.Default, Plans: make(map[string]plan.Limits, len(pc.Tiers))}
+	for _, t := range pc.Tiers {
+		c.Plans[t.Name] = plan.Limits{
+			Name:         t.Name,
+			MaxChecks:    t.MaxChecks,
+			MinInterval:  t.MinInterval,
+			MaxChannels:  t.MaxChannels,
+			RunRetention: t.RunRetention,
+		}
+	}
+	return c
+}
+
+type RateLimit struct {
+	Enable           bool          `mapstructure:"enable"`
+	Window           time.Duration `mapstructure:"window"`
+	MaxPerIP         int           `mapstructure:"max_per_ip"`
+	MaxPerEmail      int           `mapstructure:"max_per_email"`
+	LockoutThreshold int           `mapstructure:"lockout_threshold"`
+	LockoutBase      time.Duration `mapstructure:"lockout_base"`
+	LockoutMax       time.Duration `mapstructure:"lockout_max"`
+	FailureTTL       time.Duration `mapstructure:"failure_ttl"`
+	PruneInterval    time.Duration `mapstructure:"prune_interval"`
 }
 
 type Config struct {
@@ -71,6 +203,14 @@ type Config struct {
 	OTEL   OTEL      `mapstructure:"otel"`
 	Log    Log       `mapstructure:"log"`
 	Auth   Auth      `mapstructure:"auth"`
+
+	RateLimit RateLimit `mapstructure:"rate_limit"`
+	Outbox    Outbox    `mapstructure:"outbox"`
+	Watch     Watch     `mapstructure:"watch"`
+
+	StatusPages StatusPages `mapstructure:"status_pages"`
+	Badges      Badges      `mapstructure:"badges"`
+	Plans       Plans       `mapstructure:"plans"`
 }
 
 type ErrConfig string
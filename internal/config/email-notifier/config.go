@@ -1,6 +1,7 @@
 package email_notifier_config
 
 import (
+	"github.com/NordCoder/Pingerus/internal/domain/plan"
 	"github.com/NordCoder/Pingerus/internal/obs"
 	"github.com/NordCoder/Pingerus/internal/repository/kafka"
 	"time"
@@ -12,6 +13,19 @@ type KafkaIn struct {
 	Brokers []string `mapstructure:"brokers"`
 	Topic   string   `mapstructure:"topic"`
 	GroupID string   `mapstructure:"group_id"`
+	Inbox   bool     `mapstructure:"inbox"`
+	// InboxRetention is how long processed offsets are remembered; it has
+	// to outlast any redelivery. Pruning runs every InboxPruneInterval, 0
+	// disables it.
+	InboxRetention     time.Duration `mapstructure:"inbox_retention"`
+	InboxPruneInterval time.Duration `mapstructure:"inbox_prune_interval"`
+
+	MaxAttempts  int           `mapstructure:"max_attempts"`
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+	DLQ          bool          `mapstructure:"dlq"`
+	DLQTopic     string        `mapstructure:"dlq_topic"`
+	Concurrency  int           `mapstructure:"concurrency"`
+	LaneBuffer   int           `mapstructure:"lane_buffer"`
 }
 
 func (kic *KafkaIn) AsConsumerConfig() *kafka.ConsumerConfig {
@@ -21,6 +35,12 @@ func (kic *KafkaIn) AsConsumerConfig() *kafka.ConsumerConfig {
 		Topic:         kic.Topic,
 		FromBeginning: true, // todo add in config
 		Logger:        nil,
+		MaxAttempts:   kic.MaxAttempts,
+		RetryBackoff:  kic.RetryBackoff,
+		DLQ:           kic.DLQ,
+		DLQTopic:      kic.DLQTopic,
+		Concurrency:   kic.Concurrency,
+		LaneBuffer:    kic.LaneBuffer,
 	}
 }
 
@@ -38,6 +58,26 @@ type Server struct {
 	MetricsAddr string `mapstructure:"metrics_addr"`
 }
 
+// Plans repeats the api-gateway's plan tiers for the one limit enforced
+// here; keep the names and default in step with api-gateway.yaml.
+type Plans struct {
+	Default string `mapstructure:"default"`
+	Tiers   []Plan `mapstructure:"tiers"`
+}
+
+type Plan struct {
+	Name        string `mapstructure:"name"`
+	MaxChannels int    `mapstructure:"max_channels"`
+}
+
+func (pc *Plans) AsCatalog() plan.Catalog {
+	c := plan.Catalog{Default: pc.Default, Plans: make(map[string]plan.Limits, len(pc.Tiers))}
+	for _, t := range pc.Tiers {
+		c.Plans[t.Name] = plan.Limits{Name: t.Name, MaxChannels: t.MaxChannels}
+	}
+	return c
+}
+
 type OTEL struct {
 	Enable       bool    `mapstructure:"enable"`
 	OTLPEndpoint string  `mapstructure:"otlp_endpoint"`
@@ -70,10 +110,14 @@ func (lc *Log) AsLoggerConfig() *obs.LogConfig {
 }
 
 type Config struct {
-	DB     pginfra.Config `mapstructure:"db"`
-	In     KafkaIn        `mapstructure:"kafka_in"`
-	SMTP   SMTP           `mapstructure:"smtp"`
-	Server Server         `mapstructure:"server"`
-	Log    Log            `mapstructure:"log"`
-	OTEL   OTEL           `mapstructure:"otel"`
+	DB pginfra.Config `mapstructure:"db"`
+	In KafkaIn        `mapstructure:"kafka_in"`
+	// EmailIn carries emails requested by other services (verification,
+	// password reset); an empty topic disables it.
+	EmailIn KafkaIn `mapstructure:"kafka_email_in"`
+	SMTP    SMTP    `mapstructure:"smtp"`
+	Plans   Plans   `mapstructure:"plans"`
+	Server  Server  `mapstructure:"server"`
+	Log     Log     `mapstructure:"log"`
+	OTEL    OTEL    `mapstructure:"otel"`
 }
@@ -24,6 +24,26 @@ func Load(path string) (*Config, error) {
 	v.SetDefault("kafka_in.brokers", []string{"kafka:9092"})
 	v.SetDefault("kafka_in.topic", "pingerus.status.change")
 	v.SetDefault("kafka_in.group_id", "email-notifier")
+	v.SetDefault("kafka_in.inbox", false)
+	v.SetDefault("kafka_in.inbox_retention", "168h")
+	v.SetDefault("kafka_in.inbox_prune_interval", "1h")
+	v.SetDefault("kafka_in.max_attempts", 5)
+	v.SetDefault("kafka_in.retry_backoff", "500ms")
+	v.SetDefault("kafka_in.dlq", false)
+	v.SetDefault("kafka_in.concurrency", 1)
+	v.SetDefault("kafka_in.lane_buffer", 64)
+
+	v.SetDefault("kafka_email_in.brokers", []string{"kafka:9092"})
+	v.SetDefault("kafka_email_in.topic", "email-requested")
+	v.SetDefault("kafka_email_in.group_id", "email-notifier")
+	v.SetDefault("kafka_email_in.inbox", false)
+	v.SetDefault("kafka_email_in.inbox_retention", "168h")
+	v.SetDefault("kafka_email_in.inbox_prune_interval", "1h")
+	v.SetDefault("kafka_email_in.max_attempts", 5)
+	v.SetDefault("kafka_email_in.retry_backoff", "500ms")
+	v.SetDefault("kafka_email_in.dlq", false)
+	v.SetDefault("kafka_email_in.concurrency", 1)
+	v.SetDefault("kafka_email_in.lane_buffer", 64)
 
 	v.SetDefault("smtp.addr", "localhost:1025")
 	v.SetDefault("smtp.from", "noreply@pingerus.dev")
@@ -31,6 +51,8 @@ func Load(path string) (*Config, error) {
 	v.SetDefault("smtp.timeout", "5s")
 	v.SetDefault("smtp.subj_prefix", "[Pingerus]")
 
+	v.SetDefault("plans.default", "free")
+
 	v.SetDefault("otel.enable", false)
 	v.SetDefault("otel.service_name", "email-notifier")
 	v.SetDefault("otel.sample_ratio", 1.0)
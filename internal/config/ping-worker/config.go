@@ -12,6 +12,19 @@ type KafkaIn struct {
 	Brokers []string `mapstructure:"brokers"`
 	Topic   string   `mapstructure:"topic"`
 	GroupID string   `mapstructure:"group_id"`
+	Inbox   bool     `mapstructure:"inbox"`
+	// InboxRetention is how long processed offsets are remembered; it has
+	// to outlast any redelivery. Pruning runs every InboxPruneInterval, 0
+	// disables it.
+	InboxRetention     time.Duration `mapstructure:"inbox_retention"`
+	InboxPruneInterval time.Duration `mapstructure:"inbox_prune_interval"`
+
+	MaxAttempts  int           `mapstructure:"max_attempts"`
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+	DLQ          bool          `mapstructure:"dlq"`
+	DLQTopic     string        `mapstructure:"dlq_topic"`
+	Concurrency  int           `mapstructure:"concurrency"`
+	LaneBuffer   int           `mapstructure:"lane_buffer"`
 }
 
 func (kic *KafkaIn) AsConsumerConfig() *kafka.ConsumerConfig {
@@ -21,12 +34,20 @@ func (kic *KafkaIn) AsConsumerConfig() *kafka.ConsumerConfig {
 		Topic:         kic.Topic,
 		FromBeginning: true, // todo add in config
 		Logger:        nil,
+		MaxAttempts:   kic.MaxAttempts,
+		RetryBackoff:  kic.RetryBackoff,
+		DLQ:           kic.DLQ,
+		DLQTopic:      kic.DLQTopic,
+		Concurrency:   kic.Concurrency,
+		LaneBuffer:    kic.LaneBuffer,
 	}
 }
 
 type KafkaOut struct {
 	Brokers []string `mapstructure:"brokers"`
 	Topic   string   `mapstructure:"topic"`
+	// EmailTopic receives emails other services queued in the outbox.
+	EmailTopic string `mapstructure:"email_topic"`
 }
 
 type HTTPPing struct {
@@ -36,6 +57,15 @@ type HTTPPing struct {
 	VerifyTLS       bool          `mapstructure:"verify_tls"`
 }
 
+type Outbox struct {
+	Workers       int           `mapstructure:"workers"`
+	BatchSize     int           `mapstructure:"batch_size"`
+	WaitTime      time.Duration `mapstructure:"wait_time"`
+	InProgressTTL time.Duration `mapstructure:"in_progress_ttl"`
+	Notify        bool          `mapstructure:"notify"`
+	Channel       string        `mapstructure:"channel"`
+}
+
 type Server struct {
 	MetricsAddr string `mapstructure:"metrics_addr"`
 }
@@ -76,6 +106,7 @@ type Config struct {
 	In     KafkaIn        `mapstructure:"kafka_in"`
 	Out    KafkaOut       `mapstructure:"kafka_out"`
 	HTTP   HTTPPing       `mapstructure:"http"`
+	Outbox Outbox         `mapstructure:"outbox"`
 	Server Server         `mapstructure:"server"`
 	Log    Log            `mapstructure:"log"`
 	OTEL   OTEL           `mapstructure:"otel"`
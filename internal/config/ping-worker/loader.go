@@ -24,15 +24,31 @@ func Load(path string) (*Config, error) {
 	v.SetDefault("kafka_in.brokers", []string{"localhost:9094"})
 	v.SetDefault("kafka_in.topic", "pingerus.checks.request")
 	v.SetDefault("kafka_in.group_id", "ping-worker")
+	v.SetDefault("kafka_in.inbox", false)
+	v.SetDefault("kafka_in.inbox_retention", "168h")
+	v.SetDefault("kafka_in.inbox_prune_interval", "1h")
+	v.SetDefault("kafka_in.max_attempts", 5)
+	v.SetDefault("kafka_in.retry_backoff", "500ms")
+	v.SetDefault("kafka_in.dlq", false)
+	v.SetDefault("kafka_in.concurrency", 1)
+	v.SetDefault("kafka_in.lane_buffer", 64)
 
 	v.SetDefault("kafka_out.brokers", []string{"localhost:9094"})
 	v.SetDefault("kafka_out.topic", "pingerus.status.changed")
+	v.SetDefault("kafka_out.email_topic", "email-requested")
 
 	v.SetDefault("http.timeout", "5s")
 	v.SetDefault("http.user_agent", "Pingerus/1.0")
 	v.SetDefault("http.follow_redirects", true)
 	v.SetDefault("http.verify_tls", true)
 
+	v.SetDefault("outbox.workers", 20)
+	v.SetDefault("outbox.batch_size", 100)
+	v.SetDefault("outbox.wait_time", "2s")
+	v.SetDefault("outbox.in_progress_ttl", "30s")
+	v.SetDefault("outbox.notify", false)
+	v.SetDefault("outbox.channel", "outbox_enqueued")
+
 	v.SetDefault("otel.enable", false)
 	v.SetDefault("otel.service_name", "ping-worker")
 	v.SetDefault("otel.sample_ratio", 1.0)
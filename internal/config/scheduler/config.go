@@ -18,6 +18,12 @@ type SchedCfg struct {
 	MetricsAddr string        `mapstructure:"metrics_addr"`
 }
 
+// Outbox says how status changes of missed heartbeats reach the relay.
+type Outbox struct {
+	Notify  bool   `mapstructure:"notify"`
+	Channel string `mapstructure:"channel"`
+}
+
 type OTEL struct {
 	Enable       bool    `mapstructure:"enable"`
 	OTLPEndpoint string  `mapstructure:"otlp_endpoint"`
@@ -50,9 +56,10 @@ func (lc *Log) AsLoggerConfig() *obs.LogConfig {
 }
 
 type Config struct {
-	DB    pginfra.Config `mapstructure:"db"`
-	Kafka KafkaCfg       `mapstructure:"kafka"`
-	Sched SchedCfg       `mapstructure:"sched"`
-	Log   Log            `mapstructure:"log"`
-	OTEL  OTEL           `mapstructure:"otel"`
+	DB     pginfra.Config `mapstructure:"db"`
+	Kafka  KafkaCfg       `mapstructure:"kafka"`
+	Sched  SchedCfg       `mapstructure:"sched"`
+	Outbox Outbox         `mapstructure:"outbox"`
+	Log    Log            `mapstructure:"log"`
+	OTEL   OTEL           `mapstructure:"otel"`
 }
@@ -28,6 +28,9 @@ func Load(path string) (*Config, error) {
 	v.SetDefault("sched.batch_limit", 100)
 	v.SetDefault("sched.metrics_addr", ":8082")
 
+	v.SetDefault("outbox.notify", false)
+	v.SetDefault("outbox.channel", "outbox_enqueued")
+
 	v.SetDefault("otel.enable", false)
 	v.SetDefault("otel.service_name", "scheduler")
 	v.SetDefault("otel.sample_ratio", 1.0)
@@ -0,0 +1,7 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+ALTER TYPE outbox_status ADD VALUE IF NOT EXISTS 'UNKNOWN_KIND';
+
+-- +goose Down
+-- enum values cannot be dropped; release parked messages instead
+UPDATE outbox SET status = 'CREATED' WHERE status = 'UNKNOWN_KIND';
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS inbox
+(
+    consumer     TEXT                                   NOT NULL,
+    topic        TEXT                                   NOT NULL,
+    partition    INT                                    NOT NULL,
+    kafka_offset BIGINT                                 NOT NULL,
+    processed_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL,
+    PRIMARY KEY (consumer, topic, partition, kafka_offset)
+);
+
+CREATE INDEX IF NOT EXISTS idx_inbox_processed_at
+    ON inbox (processed_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS inbox;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE api_keys (
+                          id            BIGSERIAL PRIMARY KEY,
+                          user_id       INT     NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                          name          TEXT    NOT NULL,
+                          prefix        TEXT    NOT NULL,
+                          key_hash      TEXT    UNIQUE NOT NULL,
+                          scope         TEXT    NOT NULL CHECK (scope IN ('read', 'read_write')),
+                          created_at    TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL,
+                          last_used_at  TIMESTAMP WITH TIME ZONE,
+                          expires_at    TIMESTAMP WITH TIME ZONE,
+                          revoked_at    TIMESTAMP WITH TIME ZONE
+);
+CREATE INDEX idx_api_keys_user ON api_keys(user_id);
+-- +goose Down
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE refresh_tokens
+    ADD COLUMN family_id UUID NOT NULL DEFAULT gen_random_uuid();
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens(family_id);
+
+ALTER TABLE auth_events
+    ADD COLUMN metadata JSONB NOT NULL DEFAULT '{}'::jsonb;
+-- +goose Down
+ALTER TABLE auth_events DROP COLUMN IF EXISTS metadata;
+DROP INDEX IF EXISTS idx_refresh_tokens_family;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS family_id;
@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE refresh_tokens
+    ADD COLUMN user_agent      TEXT,
+    ADD COLUMN ip_address      INET,
+    ADD COLUMN last_refresh_at TIMESTAMP WITH TIME ZONE;
+CREATE INDEX idx_refresh_tokens_user_active ON refresh_tokens(user_id) WHERE revoked = FALSE;
+-- +goose Down
+DROP INDEX IF EXISTS idx_refresh_tokens_user_active;
+ALTER TABLE refresh_tokens
+    DROP COLUMN IF EXISTS last_refresh_at,
+    DROP COLUMN IF EXISTS ip_address,
+    DROP COLUMN IF EXISTS user_agent;
@@ -0,0 +1,6 @@
+-- +goose Up
+CREATE INDEX idx_auth_events_user_id_desc ON auth_events(user_id, id DESC);
+CREATE INDEX idx_auth_events_type ON auth_events(event_type);
+-- +goose Down
+DROP INDEX IF EXISTS idx_auth_events_type;
+DROP INDEX IF EXISTS idx_auth_events_user_id_desc;
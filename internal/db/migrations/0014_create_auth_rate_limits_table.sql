@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE auth_rate_limits (
+                                  key             TEXT PRIMARY KEY,
+                                  window_start    TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL,
+                                  attempts        INT     DEFAULT 0 NOT NULL,
+                                  failures        INT     DEFAULT 0 NOT NULL,
+                                  last_failure_at TIMESTAMP WITH TIME ZONE,
+                                  locked_until    TIMESTAMP WITH TIME ZONE,
+                                  updated_at      TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL
+);
+CREATE INDEX idx_auth_rate_limits_updated ON auth_rate_limits(updated_at);
+-- +goose Down
+DROP TABLE IF EXISTS auth_rate_limits;
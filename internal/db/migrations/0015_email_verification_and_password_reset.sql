@@ -0,0 +1,30 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP WITH TIME ZONE;
+-- accounts created before verification existed keep receiving alerts
+UPDATE users SET email_verified_at = created_at;
+
+CREATE TABLE email_verification_tokens (
+                                           id          BIGSERIAL PRIMARY KEY,
+                                           user_id     INT     NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                           email       TEXT    NOT NULL,
+                                           token_hash  TEXT    UNIQUE NOT NULL,
+                                           created_at  TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL,
+                                           expires_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+                                           used_at     TIMESTAMP WITH TIME ZONE
+);
+CREATE INDEX idx_email_verification_tokens_user ON email_verification_tokens(user_id);
+
+CREATE TABLE password_reset_tokens (
+                                       id          BIGSERIAL PRIMARY KEY,
+                                       user_id     INT     NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                       email       TEXT    NOT NULL,
+                                       token_hash  TEXT    UNIQUE NOT NULL,
+                                       created_at  TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL,
+                                       expires_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+                                       used_at     TIMESTAMP WITH TIME ZONE
+);
+CREATE INDEX idx_password_reset_tokens_user ON password_reset_tokens(user_id);
+-- +goose Down
+DROP TABLE IF EXISTS password_reset_tokens;
+DROP TABLE IF EXISTS email_verification_tokens;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
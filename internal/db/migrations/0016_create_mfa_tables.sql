@@ -0,0 +1,32 @@
+-- +goose Up
+CREATE TABLE user_totp (
+                           user_id         INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+                           secret_enc      BYTEA   NOT NULL,
+                           enabled_at      TIMESTAMP WITH TIME ZONE,
+                           last_used_step  BIGINT  DEFAULT 0 NOT NULL,
+                           created_at      TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL
+);
+
+CREATE TABLE mfa_recovery_codes (
+                                    id         BIGSERIAL PRIMARY KEY,
+                                    user_id    INT     NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                    code_hash  TEXT    NOT NULL,
+                                    used_at    TIMESTAMP WITH TIME ZONE,
+                                    created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL,
+                                    UNIQUE (user_id, code_hash)
+);
+
+CREATE TABLE mfa_challenges (
+                                id          BIGSERIAL PRIMARY KEY,
+                                user_id     INT     NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                token_hash  TEXT    UNIQUE NOT NULL,
+                                attempts    INT     DEFAULT 0 NOT NULL,
+                                created_at  TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL,
+                                expires_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+                                used_at     TIMESTAMP WITH TIME ZONE
+);
+CREATE INDEX idx_mfa_challenges_expires ON mfa_challenges(expires_at);
+-- +goose Down
+DROP TABLE IF EXISTS mfa_challenges;
+DROP TABLE IF EXISTS mfa_recovery_codes;
+DROP TABLE IF EXISTS user_totp;
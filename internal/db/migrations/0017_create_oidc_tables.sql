@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE user_identities (
+                                 id          BIGSERIAL PRIMARY KEY,
+                                 user_id     INT     NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                 provider    TEXT    NOT NULL,
+                                 subject     TEXT    NOT NULL,
+                                 email       TEXT    NOT NULL,
+                                 created_at  TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL,
+                                 UNIQUE (provider, subject)
+);
+CREATE INDEX idx_user_identities_user ON user_identities(user_id);
+
+CREATE TABLE oidc_login_states (
+                                   id             BIGSERIAL PRIMARY KEY,
+                                   state_hash     TEXT    UNIQUE NOT NULL,
+                                   provider       TEXT    NOT NULL,
+                                   nonce          TEXT    NOT NULL,
+                                   code_verifier  TEXT    NOT NULL,
+                                   created_at     TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL,
+                                   expires_at     TIMESTAMP WITH TIME ZONE NOT NULL
+);
+CREATE INDEX idx_oidc_login_states_expires ON oidc_login_states(expires_at);
+-- +goose Down
+DROP TABLE IF EXISTS oidc_login_states;
+DROP TABLE IF EXISTS user_identities;
@@ -0,0 +1,34 @@
+-- +goose Up
+CREATE TABLE orgs (
+                      id                 BIGSERIAL PRIMARY KEY,
+                      name               TEXT    NOT NULL,
+                      -- set for the personal org every user gets
+                      personal_owner_id  INT     UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+                      created_at         TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL
+);
+
+CREATE TABLE org_members (
+                             org_id      BIGINT  NOT NULL REFERENCES orgs(id) ON DELETE CASCADE,
+                             user_id     INT     NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                             role        TEXT    NOT NULL CHECK (role IN ('owner', 'admin', 'editor', 'viewer')),
+                             created_at  TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL,
+                             PRIMARY KEY (org_id, user_id)
+);
+CREATE INDEX idx_org_members_user ON org_members(user_id);
+
+INSERT INTO orgs (name, personal_owner_id) SELECT 'Personal', id FROM users;
+INSERT INTO org_members (org_id, user_id, role) SELECT id, personal_owner_id, 'owner' FROM orgs;
+
+ALTER TABLE checks ADD COLUMN org_id BIGINT REFERENCES orgs(id) ON DELETE CASCADE;
+UPDATE checks c SET org_id = o.id FROM orgs o WHERE o.personal_owner_id = c.user_id;
+ALTER TABLE checks ALTER COLUMN org_id SET NOT NULL;
+CREATE INDEX idx_checks_org ON checks(org_id);
+
+ALTER TABLE api_keys ADD COLUMN org_id BIGINT REFERENCES orgs(id) ON DELETE CASCADE;
+UPDATE api_keys k SET org_id = o.id FROM orgs o WHERE o.personal_owner_id = k.user_id;
+ALTER TABLE api_keys ALTER COLUMN org_id SET NOT NULL;
+-- +goose Down
+ALTER TABLE api_keys DROP COLUMN IF EXISTS org_id;
+ALTER TABLE checks DROP COLUMN IF EXISTS org_id;
+DROP TABLE IF EXISTS org_members;
+DROP TABLE IF EXISTS orgs;
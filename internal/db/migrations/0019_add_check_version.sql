@@ -0,0 +1,5 @@
+-- +goose Up
+-- version only moves on user edits; updated_at also moves on every probe
+ALTER TABLE checks ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+-- +goose Down
+ALTER TABLE checks DROP COLUMN IF EXISTS version;
@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE checks
+    ADD COLUMN name              TEXT   NOT NULL DEFAULT '',
+    ADD COLUMN type              TEXT   NOT NULL DEFAULT 'http' CHECK (type IN ('http')),
+    ADD COLUMN tags              TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN status_changed_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_checks_org_id ON checks(org_id, id);
+CREATE INDEX idx_checks_org_name ON checks(org_id, name, id);
+CREATE INDEX idx_checks_tags ON checks USING GIN (tags);
+-- +goose Down
+DROP INDEX IF EXISTS idx_checks_tags;
+DROP INDEX IF EXISTS idx_checks_org_name;
+DROP INDEX IF EXISTS idx_checks_org_id;
+ALTER TABLE checks
+    DROP COLUMN IF EXISTS status_changed_at,
+    DROP COLUMN IF EXISTS tags,
+    DROP COLUMN IF EXISTS type,
+    DROP COLUMN IF EXISTS name;
@@ -0,0 +1,70 @@
+-- +goose Up
+CREATE TABLE check_events (
+                              id          BIGSERIAL PRIMARY KEY,
+                              check_id    INT     NOT NULL REFERENCES checks(id) ON DELETE CASCADE,
+                              org_id      BIGINT  NOT NULL,
+                              kind        TEXT    NOT NULL CHECK (kind IN ('run', 'status_changed', 'incident_opened', 'incident_resolved')),
+                              status      BOOLEAN,
+                              old_status  BOOLEAN,
+                              run_id      BIGINT,
+                              code        INT,
+                              latency_ms  BIGINT,
+                              created_at  TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL
+);
+CREATE INDEX idx_check_events_org ON check_events(org_id, id);
+CREATE INDEX idx_check_events_check ON check_events(check_id, id);
+CREATE INDEX idx_check_events_created_at ON check_events(created_at);
+
+-- every run and every status flip lands in check_events, whoever writes it;
+-- pg_notify wakes the api-gateway watchers once the transaction commits
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION check_events_on_run() RETURNS TRIGGER AS
+$$
+BEGIN
+    INSERT INTO check_events (check_id, org_id, kind, status, run_id, code, latency_ms)
+    SELECT NEW.check_id, c.org_id, 'run', NEW.status, NEW.id, NEW.code, NEW.latency_ms
+    FROM checks c
+    WHERE c.id = NEW.check_id;
+    PERFORM pg_notify('check_events', NEW.check_id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION check_events_on_status() RETURNS TRIGGER AS
+$$
+BEGIN
+    INSERT INTO check_events (check_id, org_id, kind, status, old_status)
+    VALUES (NEW.id, NEW.org_id, 'status_changed', NEW.last_status, OLD.last_status);
+    IF NEW.last_status = FALSE THEN
+        INSERT INTO check_events (check_id, org_id, kind, status, old_status)
+        VALUES (NEW.id, NEW.org_id, 'incident_opened', NEW.last_status, OLD.last_status);
+    ELSIF NEW.last_status AND OLD.last_status = FALSE THEN
+        INSERT INTO check_events (check_id, org_id, kind, status, old_status)
+        VALUES (NEW.id, NEW.org_id, 'incident_resolved', NEW.last_status, OLD.last_status);
+    END IF;
+    PERFORM pg_notify('check_events', NEW.id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER trigger_check_events_on_run
+    AFTER INSERT
+    ON runs
+    FOR EACH ROW
+EXECUTE FUNCTION check_events_on_run();
+
+CREATE TRIGGER trigger_check_events_on_status
+    AFTER UPDATE OF last_status
+    ON checks
+    FOR EACH ROW
+    WHEN (OLD.last_status IS DISTINCT FROM NEW.last_status)
+EXECUTE FUNCTION check_events_on_status();
+-- +goose Down
+DROP TRIGGER IF EXISTS trigger_check_events_on_status ON checks;
+DROP TRIGGER IF EXISTS trigger_check_events_on_run ON runs;
+DROP FUNCTION IF EXISTS check_events_on_status();
+DROP FUNCTION IF EXISTS check_events_on_run();
+DROP TABLE IF EXISTS check_events;
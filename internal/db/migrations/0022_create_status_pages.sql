@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE status_pages (
+                              id           BIGSERIAL PRIMARY KEY,
+                              org_id       BIGINT  NOT NULL REFERENCES orgs(id) ON DELETE CASCADE,
+                              slug         TEXT    NOT NULL UNIQUE,
+                              title        TEXT    NOT NULL,
+                              description  TEXT    NOT NULL DEFAULT '',
+                              logo_url     TEXT    NOT NULL DEFAULT '',
+                              created_at   TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL,
+                              updated_at   TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL
+);
+CREATE INDEX idx_status_pages_org ON status_pages(org_id, id);
+
+CREATE TABLE status_page_checks (
+                                    page_id       BIGINT  NOT NULL REFERENCES status_pages(id) ON DELETE CASCADE,
+                                    check_id      INT     NOT NULL REFERENCES checks(id) ON DELETE CASCADE,
+                                    position      INT     NOT NULL,
+                                    display_name  TEXT    NOT NULL DEFAULT '',
+                                    PRIMARY KEY (page_id, check_id)
+);
+CREATE INDEX idx_status_page_checks_check ON status_page_checks(check_id);
+
+CREATE TABLE status_page_notes (
+                                   id           BIGSERIAL PRIMARY KEY,
+                                   page_id      BIGINT  NOT NULL REFERENCES status_pages(id) ON DELETE CASCADE,
+                                   title        TEXT    NOT NULL,
+                                   body         TEXT    NOT NULL DEFAULT '',
+                                   created_at   TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL,
+                                   resolved_at  TIMESTAMP WITH TIME ZONE
+);
+CREATE INDEX idx_status_page_notes_page ON status_page_notes(page_id, created_at DESC);
+-- +goose Down
+DROP TABLE IF EXISTS status_page_notes;
+DROP TABLE IF EXISTS status_page_checks;
+DROP TABLE IF EXISTS status_pages;
@@ -0,0 +1,5 @@
+-- +goose Up
+-- badge_token publishes a check's badge at /badge/{token}.svg; NULL keeps it private
+ALTER TABLE checks ADD COLUMN badge_token TEXT UNIQUE;
+-- +goose Down
+ALTER TABLE checks DROP COLUMN IF EXISTS badge_token;
@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE checks DROP CONSTRAINT IF EXISTS checks_type_check;
+ALTER TABLE checks
+    ADD CONSTRAINT checks_type_check CHECK (type IN ('http', 'heartbeat')),
+    ADD COLUMN ping_token   TEXT UNIQUE,
+    ADD COLUMN grace_sec    INT NOT NULL DEFAULT 0,
+    ADD COLUMN last_ping_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN started_at   TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_checks_heartbeat_due ON checks(next_run) WHERE type = 'heartbeat' AND active;
+-- +goose Down
+DROP INDEX IF EXISTS idx_checks_heartbeat_due;
+DELETE FROM checks WHERE type = 'heartbeat';
+ALTER TABLE checks DROP CONSTRAINT IF EXISTS checks_type_check;
+ALTER TABLE checks
+    ADD CONSTRAINT checks_type_check CHECK (type IN ('http')),
+    DROP COLUMN IF EXISTS started_at,
+    DROP COLUMN IF EXISTS last_ping_at,
+    DROP COLUMN IF EXISTS grace_sec,
+    DROP COLUMN IF EXISTS ping_token;
@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE TABLE check_dependencies (
+                                    check_id  INT NOT NULL REFERENCES checks(id) ON DELETE CASCADE,
+                                    parent_id INT NOT NULL REFERENCES checks(id) ON DELETE CASCADE,
+                                    PRIMARY KEY (check_id, parent_id),
+                                    CHECK (check_id <> parent_id)
+);
+CREATE INDEX idx_check_dependencies_parent ON check_dependencies(parent_id);
+
+-- status changes whose alert was held back because a parent was down
+CREATE TABLE suppressed_alerts (
+                                   id          BIGSERIAL PRIMARY KEY,
+                                   check_id    INT     NOT NULL REFERENCES checks(id) ON DELETE CASCADE,
+                                   parent_id   INT     NOT NULL REFERENCES checks(id) ON DELETE CASCADE,
+                                   old_status  BOOLEAN NOT NULL,
+                                   new_status  BOOLEAN NOT NULL,
+                                   changed_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+                                   created_at  TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL
+);
+CREATE INDEX idx_suppressed_alerts_parent ON suppressed_alerts(parent_id, changed_at);
+CREATE INDEX idx_suppressed_alerts_check ON suppressed_alerts(check_id, id);
+-- +goose Down
+DROP TABLE IF EXISTS suppressed_alerts;
+DROP TABLE IF EXISTS check_dependencies;
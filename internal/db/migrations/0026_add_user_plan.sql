@@ -0,0 +1,8 @@
+-- +goose Up
+-- plan names one of the plans in the api-gateway config; NULL or a name
+-- that isn't configured means the default plan
+ALTER TABLE users ADD COLUMN plan TEXT;
+CREATE INDEX idx_checks_user_id ON checks(user_id);
+-- +goose Down
+DROP INDEX IF EXISTS idx_checks_user_id;
+ALTER TABLE users DROP COLUMN IF EXISTS plan;
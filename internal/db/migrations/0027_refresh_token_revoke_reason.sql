@@ -0,0 +1,7 @@
+-- +goose Up
+-- revoke_reason tells a token spent by rotation (rotated), whose replay
+-- revokes its family, from one its user ended (logout, revoked); NULL for
+-- tokens revoked before it was added
+ALTER TABLE refresh_tokens ADD COLUMN revoke_reason TEXT;
+-- +goose Down
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS revoke_reason;
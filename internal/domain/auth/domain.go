@@ -8,13 +8,194 @@ type AccessClaims struct {
 	Sub string `json:"sub"` // user id
 	Iat int64  `json:"iat"` // created at
 	Exp int64  `json:"exp"` // expires at
+	Iss string `json:"iss,omitempty"`
+	Aud string `json:"aud,omitempty"`
 }
 
+type APIKeyScope string
+
+const (
+	ScopeRead      APIKeyScope = "read"
+	ScopeReadWrite APIKeyScope = "read_write"
+)
+
+type APIKey struct {
+	ID         int64
+	UserID     int64
+	OrgID      int64
+	Name       string
+	Prefix     string // first chars of the raw key, for display only
+	KeyHash    string
+	Scope      APIKeyScope
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	ExpiresAt  *time.Time
+	RevokedAt  *time.Time
+}
+
+// RevokeReason tells a refresh token spent by rotation, whose replay means
+// it leaked, from one its user ended.
+type RevokeReason string
+
+const (
+	RevokedRotated RevokeReason = "rotated"
+	RevokedLogout  RevokeReason = "logout"
+	RevokedByUser  RevokeReason = "revoked"
+	RevokedReuse   RevokeReason = "reuse"
+)
+
 type RefreshToken struct {
-	ID        int64
-	UserID    int64
+	ID     int64
+	UserID int64
+	// FamilyID is shared by every token rotated from the same sign-in.
+	FamilyID  string
 	TokenHash string
 	IssuedAt  time.Time
 	ExpiresAt time.Time
 	Revoked   bool
+	// RevokeReason says why a revoked token was revoked; empty for tokens
+	// revoked before reasons were kept.
+	RevokeReason RevokeReason
+
+	UserAgent string
+	IP        string
+	// LastRefreshAt is when the token was issued by Refresh; nil for the
+	// first token of a family.
+	LastRefreshAt *time.Time
+}
+
+// TOTP is a user's authenticator enrollment; it only counts once EnabledAt
+// is set.
+type TOTP struct {
+	UserID       int64
+	SecretEnc    []byte
+	EnabledAt    *time.Time
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+func (t *TOTP) Enabled() bool { return t != nil && t.EnabledAt != nil }
+
+// MFAChallenge is handed out by SignIn when a second factor is required.
+type MFAChallenge struct {
+	ID        int64
+	UserID    int64
+	TokenHash string
+	Attempts  int
+	ExpiresAt time.Time
+}
+
+// Identity links an account to a subject at an OIDC provider.
+type Identity struct {
+	ID        int64
+	UserID    int64
+	Provider  string
+	Subject   string
+	Email     string // address the provider asserted when linking
+	CreatedAt time.Time
+}
+
+// OIDCState is the server side of an authorization request, keyed by the
+// hash of its state parameter.
+type OIDCState struct {
+	ID           int64
+	StateHash    string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+// OneTimeToken backs email verification and password reset links.
+type OneTimeToken struct {
+	ID        int64
+	UserID    int64
+	Email     string // address the link was sent to
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// Session is a refresh token family seen through its active token.
+type Session struct {
+	ID            string // family id
+	UserID        int64
+	UserAgent     string
+	IP            string
+	CreatedAt     time.Time
+	LastRefreshAt *time.Time
+	ExpiresAt     time.Time
+	Current       bool
+}
+
+// Client describes the caller of an auth RPC.
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+type EventType string
+
+const (
+	EventSignUp         EventType = "sign_up"
+	EventSignIn         EventType = "sign_in"
+	EventSignInFailed   EventType = "sign_in_failed"
+	EventRefresh        EventType = "refresh"
+	EventLogout         EventType = "logout"
+	EventPasswordChange EventType = "password_changed"
+	EventPasswordReset  EventType = "password_reset"
+	EventResetRequested EventType = "password_reset_requested"
+	EventEmailVerified  EventType = "email_verified"
+	EventSSOLinked      EventType = "sso_linked"
+	EventMFAEnabled     EventType = "mfa_enabled"
+	EventMFADisabled    EventType = "mfa_disabled"
+	EventMFAFailed      EventType = "mfa_failed"
+	EventRecoveryUsed   EventType = "mfa_recovery_code_used"
+	EventRecoveryReset  EventType = "mfa_recovery_codes_regenerated"
+	EventAPIKeyCreated  EventType = "api_key_created"
+	EventAPIKeyRevoked  EventType = "api_key_revoked"
+	// EventAPIKeyUsed is recorded at most once a minute per key, together
+	// with the last_used_at bump.
+	EventAPIKeyUsed      EventType = "api_key_used"
+	EventSessionRevoked  EventType = "session_revoked"
+	EventSessionsRevoked EventType = "other_sessions_revoked"
+	// EventRefreshReuse: a revoked refresh token was presented again; its
+	// whole family has been revoked.
+	EventRefreshReuse EventType = "refresh_token_reuse"
+)
+
+type Event struct {
+	ID        int64
+	UserID    *int64
+	Type      EventType
+	IP        string
+	UserAgent string
+	Metadata  map[string]string
+	CreatedAt time.Time
+}
+
+type EventFilter struct {
+	UserID int64
+	Type   EventType // empty: any
+	// BeforeID pages backwards from the newest event; 0 starts at the top.
+	BeforeID int64
+	Limit    int
+}
+
+// RateLimitState is the counter row behind one rate limit key.
+type RateLimitState struct {
+	Attempts    int // within the current window
+	WindowStart time.Time
+	Failures    int // consecutive, until reset or FailureTTL passes
+	LockedUntil *time.Time
+}
+
+// LockoutPolicy: once Failures reaches Threshold the key is locked for
+// Base, doubling with every further failure up to Max.
+type LockoutPolicy struct {
+	Threshold  int
+	Base       time.Duration
+	Max        time.Duration
+	FailureTTL time.Duration
 }
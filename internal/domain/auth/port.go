@@ -2,19 +2,147 @@ package auth
 
 import (
 	"context"
+	"time"
+
 	"github.com/NordCoder/Pingerus/internal/domain/user"
 )
 
 type Usecase interface {
 	SignUp(ctx context.Context, email, password string) (*user.User, string, string, error)
-	SignIn(ctx context.Context, email, password string) (*user.User, string, string, error)
+	// SignIn returns either access and refresh tokens or, for accounts with
+	// a second factor, only an MFA challenge token for VerifyMFA.
+	SignIn(ctx context.Context, email, password string) (u *user.User, access, refresh, mfaToken string, err error)
+	VerifyMFA(ctx context.Context, mfaToken, code, recoveryCode string) (*user.User, string, string, error)
 	Refresh(ctx context.Context, raw string) (string, string, int64, error)
 	Logout(ctx context.Context, raw string) error
 	ParseAccess(token string) (int64, error)
+
+	CreateAPIKey(ctx context.Context, userID, orgID int64, name string, scope APIKeyScope, expiresAt *time.Time) (*APIKey, string, error)
+	ListAPIKeys(ctx context.Context, userID int64) ([]*APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, id int64) error
+	ParseAPIKey(ctx context.Context, raw string) (*APIKey, error)
+
+	ListSessions(ctx context.Context, userID int64, currentRaw string) ([]*Session, error)
+	RevokeSession(ctx context.Context, userID int64, id string) error
+	RevokeOtherSessions(ctx context.Context, userID int64, currentRaw string) (int64, error)
+
+	ListEvents(ctx context.Context, callerID int64, f EventFilter) ([]*Event, error)
+
+	SendVerificationEmail(ctx context.Context, userID int64) error
+	VerifyEmail(ctx context.Context, raw string) error
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, raw, newPassword string) error
+	ChangePassword(ctx context.Context, userID int64, oldPassword, newPassword, currentRaw string) error
+
+	// EnrollTOTP starts (or restarts) enrollment and returns the secret and
+	// its otpauth:// URI.
+	EnrollTOTP(ctx context.Context, userID int64) (secret, uri string, err error)
+	// ConfirmTOTP enables the pending enrollment and returns recovery codes.
+	ConfirmTOTP(ctx context.Context, userID int64, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, userID int64, password, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) ([]string, error)
+
+	OIDCProviders() []OIDCProviderInfo
+	// StartOIDC returns the provider URL to send the browser to and the
+	// state it carries.
+	StartOIDC(ctx context.Context, provider string) (authURL, state string, err error)
+	// CompleteOIDC finishes the login like SignIn does.
+	CompleteOIDC(ctx context.Context, provider, code, state string) (u *user.User, access, refresh, mfaToken string, err error)
+}
+
+type OIDCProviderInfo struct {
+	Name        string
+	DisplayName string
 }
 
 type RefreshTokenRepo interface {
+	// Create starts a new family when t.FamilyID is empty and fills it in.
 	Create(ctx context.Context, t *RefreshToken) error
 	FindValid(ctx context.Context, tokenHash string) (*RefreshToken, error)
+	// FindByHash returns the token whether or not it is revoked or expired.
+	FindByHash(ctx context.Context, tokenHash string) (*RefreshToken, error)
+	// Revoke revokes the token as logged out.
 	Revoke(ctx context.Context, tokenHash string) error
+	// RevokeActive revokes the token as rotated only if it is still active
+	// and reports whether it did, so concurrent rotations of one token
+	// cannot both win.
+	RevokeActive(ctx context.Context, tokenHash string) (bool, error)
+	// RevokeFamily revokes the active tokens of a family after reuse.
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeAllForUser(ctx context.Context, userID int64) error
+
+	ListSessions(ctx context.Context, userID int64) ([]*Session, error)
+	// RevokeSession revokes a family of userID and reports whether it had
+	// an active token.
+	RevokeSession(ctx context.Context, userID int64, familyID string) (bool, error)
+	// RevokeOtherSessions revokes every family of userID except keepFamilyID
+	// and returns how many sessions were ended.
+	RevokeOtherSessions(ctx context.Context, userID int64, keepFamilyID string) (int64, error)
+}
+
+type OneTimeTokenRepo interface {
+	Create(ctx context.Context, t *OneTimeToken) error
+	// Consume marks an unused, unexpired token used and returns it.
+	Consume(ctx context.Context, tokenHash string) (*OneTimeToken, error)
+	// InvalidateUser marks every outstanding token of userID used.
+	InvalidateUser(ctx context.Context, userID int64) error
+}
+
+type EventRepo interface {
+	Create(ctx context.Context, e *Event) error
+	// List returns events newest first.
+	List(ctx context.Context, f EventFilter) ([]*Event, error)
+}
+
+type APIKeyRepo interface {
+	Create(ctx context.Context, k *APIKey) error
+	ListByUser(ctx context.Context, userID int64) ([]*APIKey, error)
+	FindByHash(ctx context.Context, keyHash string) (*APIKey, error)
+	Revoke(ctx context.Context, userID, id int64) error
+	// Touch bumps last_used_at, at most about once a minute per key, and
+	// reports whether it did.
+	Touch(ctx context.Context, id int64) (bool, error)
+}
+
+type RateLimitRepo interface {
+	// Hit counts an attempt for key in a fixed window.
+	Hit(ctx context.Context, key string, window time.Duration) (*RateLimitState, error)
+	// Fail counts a failed attempt and applies the lockout policy.
+	Fail(ctx context.Context, key string, p LockoutPolicy) (*RateLimitState, error)
+	// Reset clears failures and any lock on key.
+	Reset(ctx context.Context, key string) error
+	// Prune drops rows untouched since before.
+	Prune(ctx context.Context, before time.Time) (int64, error)
+}
+
+type MFARepo interface {
+	GetTOTP(ctx context.Context, userID int64) (*TOTP, error)
+	// SavePendingTOTP stores a not yet enabled secret, replacing any earlier
+	// pending one; it fails with a conflict if TOTP is already enabled.
+	SavePendingTOTP(ctx context.Context, userID int64, secretEnc []byte) error
+	EnableTOTP(ctx context.Context, userID int64, step int64) error
+	DeleteTOTP(ctx context.Context, userID int64) error
+	// UseStep records step as used and reports false if it (or a later one)
+	// already was, so a code cannot be replayed.
+	UseStep(ctx context.Context, userID int64, step int64) (bool, error)
+
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes []string) error
+	UseRecoveryCode(ctx context.Context, userID int64, hash string) (bool, error)
+
+	CreateChallenge(ctx context.Context, c *MFAChallenge) error
+	// GetChallenge returns an unused, unexpired challenge.
+	GetChallenge(ctx context.Context, tokenHash string) (*MFAChallenge, error)
+	FailChallenge(ctx context.Context, id int64) (int, error)
+	ConsumeChallenge(ctx context.Context, id int64) (bool, error)
+}
+
+type IdentityRepo interface {
+	Find(ctx context.Context, provider, subject string) (*Identity, error)
+	Create(ctx context.Context, i *Identity) error
+}
+
+type OIDCStateRepo interface {
+	Create(ctx context.Context, s *OIDCState) error
+	// Consume deletes and returns an unexpired state.
+	Consume(ctx context.Context, stateHash string) (*OIDCState, error)
 }
@@ -0,0 +1,17 @@
+package check
+
+import "time"
+
+// Suppression is a status change of a check whose alert was held back
+// because its parent was down.
+type Suppression struct {
+	CheckID   int64     `json:"check_id"`
+	ParentID  int64     `json:"parent_id"`
+	Old       bool      `json:"old"`
+	New       bool      `json:"new"`
+	ChangedAt time.Time `json:"changed_at"`
+	// CheckName and URL describe the check when suppressions are listed
+	// for a parent.
+	CheckName string `json:"check_name"`
+	URL       string `json:"url"`
+}
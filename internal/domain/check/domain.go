@@ -2,15 +2,105 @@ package check
 
 import "time"
 
+const (
+	// TypeHTTP is a check that probes its URL on a schedule.
+	TypeHTTP = "http"
+	// TypeHeartbeat is a check that waits for a job to ping it at least once
+	// per Interval, plus Grace.
+	TypeHeartbeat = "heartbeat"
+)
+
 type Check struct {
 	ID         int64         `json:"id"`
 	UserID     int64         `json:"user_id"`
+	OrgID      int64         `json:"org_id"`
 	Name       string        `json:"name"`
+	Type       string        `json:"type"`
+	Tags       []string      `json:"tags"`
 	URL        string        `json:"url"`
 	Interval   time.Duration `json:"interval"`
 	Active     bool          `json:"active"`
 	LastStatus *bool         `json:"last_status"`
-	NextRun    time.Time     `json:"next_run"`
-	CreatedAt  time.Time     `json:"created_at"`
-	UpdatedAt  time.Time     `json:"updated_at"`
+	// StatusChangedAt is when LastStatus last took a new value.
+	StatusChangedAt *time.Time `json:"status_changed_at"`
+	NextRun         time.Time  `json:"next_run"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	// Version is bumped on every user edit and guards concurrent updates.
+	Version int64 `json:"version"`
+	// BadgeToken is the unguessable name of the check's public badge; empty
+	// while the badge is off.
+	BadgeToken string `json:"badge_token"`
+
+	// PingToken names a heartbeat check's ping URL.
+	PingToken string `json:"ping_token"`
+	// Grace is how late a heartbeat may be before the check goes down.
+	Grace      time.Duration `json:"grace"`
+	LastPingAt *time.Time    `json:"last_ping_at"`
+	// StartedAt is when the job behind a heartbeat last reported a start
+	// without finishing yet.
+	StartedAt *time.Time `json:"started_at"`
+
+	// ParentIDs are the checks this one depends on, in ID order. While one
+	// of them is down, this check's alerts are held back.
+	ParentIDs []int64 `json:"parent_ids"`
+}
+
+// Ping is what a heartbeat ping writes to its check.
+type Ping struct {
+	At time.Time
+	// Status is nil for a start ping, which leaves the status as is.
+	Status    *bool
+	StartedAt *time.Time
+	NextRun   time.Time
+}
+
+// Patch changes a check's user-editable fields; nil fields are left as is.
+type Patch struct {
+	Name     *string
+	Tags     *[]string
+	URL      *string
+	Interval *time.Duration
+	Grace    *time.Duration
+	Parents  *[]int64
+	Active   *bool
+}
+
+type Status string
+
+const (
+	StatusUp      Status = "up"
+	StatusDown    Status = "down"
+	StatusUnknown Status = "unknown"
+)
+
+type SortField string
+
+const (
+	SortCreated       SortField = "created"
+	SortName          SortField = "name"
+	SortStatusChanged SortField = "status_changed"
+	SortNextRun       SortField = "next_run"
+)
+
+// ListQuery selects a page of checks; zero-valued filters match everything.
+type ListQuery struct {
+	OrgIDs      []int64
+	Status      Status
+	Active      *bool
+	Type        string
+	Tag         string
+	URLContains string
+
+	Sort  SortField
+	Desc  bool
+	Limit int
+	// After resumes the listing behind the row the cursor was taken from.
+	After *Cursor
+}
+
+// Cursor is the sort key and ID of the last row of a page.
+type Cursor struct {
+	Key string
+	ID  int64
 }
@@ -0,0 +1,50 @@
+package check
+
+import "time"
+
+// EventKind says what a check Event reports.
+type EventKind string
+
+const (
+	EventRun              EventKind = "run"
+	EventStatusChanged    EventKind = "status_changed"
+	EventIncidentOpened   EventKind = "incident_opened"
+	EventIncidentResolved EventKind = "incident_resolved"
+)
+
+// Event is one entry of the check activity feed. IDs grow in insert order
+// and double as resume cursors.
+type Event struct {
+	ID        int64     `json:"id"`
+	CheckID   int64     `json:"check_id"`
+	OrgID     int64     `json:"org_id"`
+	Kind      EventKind `json:"kind"`
+	Status    *bool     `json:"status"`
+	OldStatus *bool     `json:"old_status"`
+	// RunID, Code and Latency (ms) are set for EventRun only.
+	RunID     int64     `json:"run_id"`
+	Code      int       `json:"code"`
+	Latency   int64     `json:"latency"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Incident is a stretch of downtime of one check; ResolvedAt is nil while it
+// lasts.
+type Incident struct {
+	CheckID    int64      `json:"check_id"`
+	OpenedAt   time.Time  `json:"opened_at"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+	// Suppressed counts the dependent checks whose alerts were held back
+	// during the incident.
+	Suppressed int `json:"suppressed"`
+}
+
+// EventQuery selects events with After < ID <= UpTo in ID order; nil OrgIDs,
+// a zero CheckID and a zero UpTo match everything.
+type EventQuery struct {
+	OrgIDs  []int64
+	CheckID int64
+	After   int64
+	UpTo    int64
+	Limit   int
+}
@@ -1,12 +1,69 @@
 package check
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Repo interface {
 	Create(ctx context.Context, c *Check) error
 	GetByID(ctx context.Context, id int64) (*Check, error)
-	ListByUser(ctx context.Context, userID int64) ([]*Check, error)
+	// LockByID loads the check and locks it until the surrounding
+	// transaction ends.
+	LockByID(ctx context.Context, id int64) (*Check, error)
+	GetByBadgeToken(ctx context.Context, token string) (*Check, error)
+	// SetBadgeToken publishes the check's badge under token; "" unpublishes it.
+	SetBadgeToken(ctx context.Context, id int64, token string) error
+	// List returns up to q.Limit checks and, when more follow, the cursor
+	// to pass as q.After for the next page.
+	List(ctx context.Context, q ListQuery) ([]*Check, *Cursor, error)
+	Count(ctx context.Context, q ListQuery) (int64, error)
 	Update(ctx context.Context, c *Check) error
+	// Patch applies p if the check is still at version (0 skips the check)
+	// and returns the stored result.
+	Patch(ctx context.Context, id int64, p Patch, version int64) (*Check, error)
 	Delete(ctx context.Context, id int64) error
+	// FetchDue claims the HTTP checks whose next run is due.
 	FetchDue(ctx context.Context, limit int) ([]*Check, error)
+
+	// LockByPingToken loads the heartbeat check behind token and locks it
+	// until the surrounding transaction ends.
+	LockByPingToken(ctx context.Context, token string) (*Check, error)
+	RecordPing(ctx context.Context, id int64, p Ping) error
+	// LockMissedHeartbeats locks up to limit heartbeat checks that are up
+	// but past their deadline, skipping rows locked elsewhere.
+	LockMissedHeartbeats(ctx context.Context, limit int) ([]*Check, error)
+}
+
+// DependencyRepo stores which checks depend on which, and the alerts held
+// back while a parent was down.
+type DependencyRepo interface {
+	// LockDependencies serializes dependency edits in orgID until the
+	// surrounding transaction ends.
+	LockDependencies(ctx context.Context, orgID int64) error
+	// Ancestors returns the parents of ids, their parents and so on.
+	Ancestors(ctx context.Context, ids []int64) ([]int64, error)
+	// SetParents replaces the parents of id.
+	SetParents(ctx context.Context, id int64, parents []int64) error
+	// DownParents returns the parents of id whose last status is down.
+	DownParents(ctx context.Context, id int64) ([]int64, error)
+	Suppress(ctx context.Context, s []Suppression) error
+	// HeldBack returns the parent that held back the last down alert of id,
+	// or 0 when no alert of id was held back since one was sent.
+	HeldBack(ctx context.Context, id int64) (int64, error)
+	// Suppressed lists the alerts held back by parentID since its latest
+	// incident opened, oldest first.
+	Suppressed(ctx context.Context, parentID int64) ([]Suppression, error)
+}
+
+type EventRepo interface {
+	Events(ctx context.Context, q EventQuery) ([]*Event, error)
+	// EventBounds returns the oldest and newest stored event IDs, zeros when
+	// there are none.
+	EventBounds(ctx context.Context) (first, last int64, err error)
+	// Incidents returns up to limit incidents of checkIDs opened since since,
+	// newest first.
+	Incidents(ctx context.Context, checkIDs []int64, since time.Time, limit int) ([]*Incident, error)
+	// PruneEvents drops events created before t.
+	PruneEvents(ctx context.Context, before time.Time) (int64, error)
 }
@@ -0,0 +1,15 @@
+package inbox
+
+import (
+	"context"
+	"time"
+)
+
+type Repo interface {
+	// Claim records the message as processed by consumer. It reports false
+	// when the message was already recorded, i.e. this is a redelivery.
+	Claim(ctx context.Context, consumer, topic string, partition int, offset int64) (bool, error)
+	// Prune deletes up to limit records of consumer, and of its parts
+	// recorded as "consumer/part", processed before before.
+	Prune(ctx context.Context, consumer string, before time.Time, limit int) (int64, error)
+}
@@ -0,0 +1,44 @@
+package org
+
+import "time"
+
+// Role is a member's role in an org; each role can do everything the roles
+// below it can.
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+	RoleOwner  Role = "owner"
+)
+
+var roleRank = map[Role]int{RoleViewer: 1, RoleEditor: 2, RoleAdmin: 3, RoleOwner: 4}
+
+func (r Role) Valid() bool { return roleRank[r] > 0 }
+
+// AtLeast reports whether r grants everything min does.
+func (r Role) AtLeast(min Role) bool { return r.Valid() && roleRank[r] >= roleRank[min] }
+
+type Org struct {
+	ID   int64
+	Name string
+	// Personal orgs are created with their user and cannot be shared or
+	// deleted.
+	Personal  bool
+	CreatedAt time.Time
+}
+
+type Member struct {
+	OrgID     int64
+	UserID    int64
+	Email     string
+	Role      Role
+	CreatedAt time.Time
+}
+
+// Membership is an org seen by one of its members.
+type Membership struct {
+	Org  Org
+	Role Role
+}
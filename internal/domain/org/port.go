@@ -0,0 +1,20 @@
+package org
+
+import "context"
+
+type Repo interface {
+	// Create stores o with ownerID as its only owner.
+	Create(ctx context.Context, o *Org, ownerID int64) error
+	GetByID(ctx context.Context, id int64) (*Org, error)
+	Delete(ctx context.Context, id int64) error
+	ListForUser(ctx context.Context, userID int64) ([]*Membership, error)
+	PersonalOrgID(ctx context.Context, userID int64) (int64, error)
+
+	GetMember(ctx context.Context, orgID, userID int64) (*Member, error)
+	ListMembers(ctx context.Context, orgID int64) ([]*Member, error)
+	AddMember(ctx context.Context, orgID, userID int64, role Role) error
+	// SetRole and RemoveMember fail with a conflict rather than leave the
+	// org without an owner.
+	SetRole(ctx context.Context, orgID, userID int64, role Role) error
+	RemoveMember(ctx context.Context, orgID, userID int64) error
+}
@@ -2,17 +2,29 @@ package outbox
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
 type Status string
 
+const (
+	StatusCreated     Status = "CREATED"
+	StatusInProgress  Status = "IN_PROGRESS"
+	StatusSuccess     Status = "SUCCESS"
+	StatusUnknownKind Status = "UNKNOWN_KIND"
+)
+
 type Kind int
 
 const (
-	KindStatusChanged Kind = 1
+	KindStatusChanged  Kind = 1
+	KindEmailRequested Kind = 2
 )
 
+// ErrUnknownKind is returned by a GlobalHandler for kinds nobody registered.
+var ErrUnknownKind = errors.New("unknown outbox kind")
+
 type Message struct {
 	IdempotencyKey string
 	Kind           Kind
@@ -31,6 +43,10 @@ type Repository interface {
 	PickBatch(ctx context.Context, batch int, inProgressTTL time.Duration) ([]Message, error)
 
 	MarkSuccess(ctx context.Context, keys []string) error
+
+	// MarkUnknownKind parks messages no handler is registered for, so they
+	// are kept for inspection but never picked again.
+	MarkUnknownKind(ctx context.Context, keys []string) error
 }
 
 type KindHandler func(ctx context.Context, data []byte) error
@@ -0,0 +1,52 @@
+package plan
+
+import "time"
+
+// Limits caps what the users on a plan may do; zero values are unlimited.
+type Limits struct {
+	Name        string
+	MaxChecks   int
+	MinInterval time.Duration
+	// MaxChannels bounds the people a check's alerts are emailed to, owners
+	// of its org first, then admins in the order they joined.
+	MaxChannels  int
+	RunRetention time.Duration
+}
+
+// PublicHistory is the longest stretch of runs shown in public: the uptime
+// bars of a status page and the widest badge window. Runs kept for less
+// would leave them partly blank.
+const PublicHistory = 90 * 24 * time.Hour
+
+// Catalog holds the configured plans. Users without a plan, or on one that
+// is no longer configured, get Default.
+type Catalog struct {
+	Default string
+	Plans   map[string]Limits
+}
+
+// Lookup returns the limits of the plan called name.
+func (c Catalog) Lookup(name string) Limits {
+	if l, ok := c.Plans[name]; ok {
+		return l
+	}
+	if l, ok := c.Plans[c.Default]; ok {
+		return l
+	}
+	return Limits{Name: c.Default}
+}
+
+// Names lists the configured plans.
+func (c Catalog) Names() []string {
+	out := make([]string, 0, len(c.Plans))
+	for name := range c.Plans {
+		out = append(out, name)
+	}
+	return out
+}
+
+// Usage is what a user consumes of its plan.
+type Usage struct {
+	Limits Limits
+	Checks int64
+}
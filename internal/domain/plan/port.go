@@ -0,0 +1,19 @@
+package plan
+
+import (
+	"context"
+	"time"
+)
+
+type Repo interface {
+	// PlanOf returns the plan name stored for userID, "" when there is none.
+	PlanOf(ctx context.Context, userID int64) (string, error)
+	// LockUsage serializes quota checks of userID until the surrounding
+	// transaction ends.
+	LockUsage(ctx context.Context, userID int64) error
+	// CountChecks counts the checks userID owns, in any org.
+	CountChecks(ctx context.Context, userID int64) (int64, error)
+	// PruneRuns deletes up to limit runs taken before before, of checks whose
+	// owners are on plan. Owners on a plan outside known count as on def.
+	PruneRuns(ctx context.Context, plan, def string, known []string, before time.Time, limit int) (int64, error)
+}
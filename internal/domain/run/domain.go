@@ -10,3 +10,11 @@ type Run struct {
 	Code      int       `json:"code"`
 	Latency   int64     `json:"latency"`
 }
+
+// Day sums up one check's runs over a UTC calendar day.
+type Day struct {
+	CheckID int64     `json:"check_id"`
+	Day     time.Time `json:"day"`
+	Total   int64     `json:"total"`
+	Up      int64     `json:"up"`
+}
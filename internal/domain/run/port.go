@@ -1,8 +1,16 @@
 package run
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Repo interface {
 	Insert(ctx context.Context, r *Run) error
 	ListByCheck(ctx context.Context, checkID int64, limit int) ([]*Run, error)
+	// DailyUptime returns a Day per check and UTC day with runs since since,
+	// ordered by check and day.
+	DailyUptime(ctx context.Context, checkIDs []int64, since time.Time) ([]Day, error)
+	// Uptime counts a check's runs since since, and how many of them were up.
+	Uptime(ctx context.Context, checkID int64, since time.Time) (total, up int64, err error)
 }
@@ -0,0 +1,43 @@
+package statuspage
+
+import "time"
+
+// Page is a public status page showing a selection of an org's checks to
+// anyone who knows its slug.
+type Page struct {
+	ID          int64       `json:"id"`
+	OrgID       int64       `json:"org_id"`
+	Slug        string      `json:"slug"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	LogoURL     string      `json:"logo_url"`
+	Checks      []PageCheck `json:"checks"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// PageCheck is a check shown on a page; pages keep them in display order.
+type PageCheck struct {
+	CheckID int64 `json:"check_id"`
+	// DisplayName replaces the check's own name on the page when set.
+	DisplayName string `json:"display_name"`
+}
+
+// Note is an incident note the page's owners post for visitors.
+type Note struct {
+	ID         int64      `json:"id"`
+	PageID     int64      `json:"page_id"`
+	Title      string     `json:"title"`
+	Body       string     `json:"body"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+}
+
+// Patch changes a page's editable fields; nil fields are left as is.
+type Patch struct {
+	Slug        *string
+	Title       *string
+	Description *string
+	LogoURL     *string
+	Checks      *[]PageCheck
+}
@@ -0,0 +1,25 @@
+package statuspage
+
+import (
+	"context"
+	"time"
+)
+
+type Repo interface {
+	// Create and Update store the page fields, not its checks; a taken slug
+	// is a conflict.
+	Create(ctx context.Context, p *Page) error
+	GetByID(ctx context.Context, id int64) (*Page, error)
+	GetBySlug(ctx context.Context, slug string) (*Page, error)
+	ListByOrgs(ctx context.Context, orgIDs []int64) ([]*Page, error)
+	Update(ctx context.Context, p *Page) error
+	// SetChecks replaces the checks of a page, keeping their order.
+	SetChecks(ctx context.Context, pageID int64, checks []PageCheck) error
+	Delete(ctx context.Context, id int64) error
+
+	AddNote(ctx context.Context, n *Note) error
+	ResolveNote(ctx context.Context, pageID, id int64, at time.Time) (*Note, error)
+	DeleteNote(ctx context.Context, pageID, id int64) error
+	// ListNotes returns the newest notes first.
+	ListNotes(ctx context.Context, pageID int64, limit int) ([]*Note, error)
+}
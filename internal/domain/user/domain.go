@@ -3,9 +3,13 @@ package user
 import "time"
 
 type User struct {
-	ID        int64     `json:"id"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID              int64      `json:"id"`
+	Email           string     `json:"email"`
+	Password        string     `json:"-"`
+	Active          bool       `json:"active"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
 }
+
+func (u *User) EmailVerified() bool { return u.EmailVerifiedAt != nil }
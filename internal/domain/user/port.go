@@ -7,4 +7,7 @@ type Repo interface {
 	GetByID(ctx context.Context, id int64) (*User, error)
 	GetByEmail(ctx context.Context, email string) (*User, error)
 	Update(ctx context.Context, u *User) error
+	SetPassword(ctx context.Context, id int64, hash string) error
+	// MarkEmailVerified verifies id's address if it is still email.
+	MarkEmailVerified(ctx context.Context, id int64, email string) error
 }
@@ -0,0 +1,166 @@
+package inbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/NordCoder/Pingerus/internal/domain/inbox"
+	kafkax "github.com/NordCoder/Pingerus/internal/repository/kafka"
+	"github.com/NordCoder/Pingerus/internal/repository/postgres"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var inboxDuplicates = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "inbox_duplicates_total",
+	Help: "Redelivered Kafka messages skipped by the inbox.",
+}, []string{"consumer"})
+
+// pruneBatch bounds one delete so pruning stays within the query timeout.
+const pruneBatch = 10000
+
+// Inbox makes a handler's DB effects exactly-once: the message offset is
+// recorded in the same transaction, so redeliveries after a crash between
+// commit and Kafka offset commit are skipped. Effects outside the database
+// (HTTP, SMTP) are still at-least-once.
+type Inbox struct {
+	consumer string
+	repo     inbox.Repo
+	tx       postgres.Transactor
+	log      *zap.Logger
+}
+
+func New(consumer string, repo inbox.Repo, tx postgres.Transactor, log *zap.Logger) *Inbox {
+	if log == nil {
+		log = zap.NewNop()
+	}
+	return &Inbox{
+		consumer: consumer,
+		repo:     repo,
+		tx:       tx,
+		log:      log.With(zap.String("component", "inbox"), zap.String("consumer", consumer)),
+	}
+}
+
+// Effect does the part of handling a message that happens outside the
+// database, such as an HTTP probe, and returns the writes recording it;
+// record may be nil when there is nothing to write.
+type Effect func(ctx context.Context, key, value []byte) (record func(ctx context.Context) error, err error)
+
+// Wrap runs all of h in the transaction that claims the message.
+func (i *Inbox) Wrap(h kafkax.Handler) kafkax.Handler {
+	return i.WrapEffect(func(_ context.Context, key, value []byte) (func(context.Context) error, error) {
+		return func(ctx context.Context) error { return h(ctx, key, value) }, nil
+	})
+}
+
+// WrapEffect runs the effect with no transaction open, so a slow one holds
+// no pooled connection, then claims the message and runs record in one
+// transaction. A redelivery repeats the effect but not the writes.
+func (i *Inbox) WrapEffect(effect Effect) kafkax.Handler {
+	return func(ctx context.Context, key, value []byte) error {
+		record, err := effect(ctx, key, value)
+		if err != nil {
+			return err
+		}
+		meta, ok := kafkax.MessageFromContext(ctx)
+		if !ok {
+			i.log.Warn("no message metadata in context; processing without inbox")
+			if record == nil {
+				return nil
+			}
+			return record(ctx)
+		}
+
+		dup := false
+		err = i.tx.WithTx(ctx, func(txCtx context.Context) error {
+			fresh, err := i.repo.Claim(txCtx, i.consumer, meta.Topic, meta.Partition, meta.Offset)
+			if err != nil {
+				return err
+			}
+			if !fresh {
+				dup = true
+				return nil
+			}
+			if record == nil {
+				return nil
+			}
+			return record(txCtx)
+		})
+		if err != nil {
+			return err
+		}
+		if dup {
+			inboxDuplicates.WithLabelValues(i.consumer).Inc()
+			i.log.Debug("duplicate message skipped",
+				zap.String("topic", meta.Topic),
+				zap.Int("partition", meta.Partition),
+				zap.Int64("offset", meta.Offset),
+			)
+		}
+		return nil
+	}
+}
+
+// Once runs fn in a transaction claiming part of the message in ctx, such
+// as one recipient of an alert, and skips it when an earlier delivery
+// already did. A failed part is released and retried with the message while
+// the parts done stay done. Call it outside the message's own transaction,
+// e.g. from an Effect, since WithTx would join that one.
+func (i *Inbox) Once(ctx context.Context, part string, fn func(ctx context.Context) error) error {
+	meta, ok := kafkax.MessageFromContext(ctx)
+	if !ok {
+		return fn(ctx)
+	}
+	consumer := i.consumer + "/" + part
+	return i.tx.WithTx(ctx, func(txCtx context.Context) error {
+		fresh, err := i.repo.Claim(txCtx, consumer, meta.Topic, meta.Partition, meta.Offset)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			inboxDuplicates.WithLabelValues(i.consumer).Inc()
+			i.log.Debug("duplicate message part skipped",
+				zap.String("part", part),
+				zap.String("topic", meta.Topic),
+				zap.Int("partition", meta.Partition),
+				zap.Int64("offset", meta.Offset),
+			)
+			return nil
+		}
+		return fn(txCtx)
+	})
+}
+
+// Prune deletes the consumer's records, its parts' included, older than
+// retention every interval until ctx is done. Retention has to outlast any
+// redelivery; a message redelivered after its record is gone is handled
+// again.
+func (i *Inbox) Prune(ctx context.Context, retention, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			before := time.Now().Add(-retention)
+			var total int64
+			for {
+				n, err := i.repo.Prune(ctx, i.consumer, before, pruneBatch)
+				if err != nil {
+					i.log.Warn("inbox prune failed", zap.Error(err))
+					break
+				}
+				total += n
+				if n < pruneBatch {
+					break
+				}
+			}
+			if total > 0 {
+				i.log.Debug("inbox pruned", zap.Int64("rows", total))
+			}
+		}
+	}
+}
@@ -2,16 +2,16 @@ package outbox
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"time"
 
+	pb "github.com/NordCoder/Pingerus/generated/v1"
 	"github.com/NordCoder/Pingerus/internal/domain/outbox"
 	"github.com/NordCoder/Pingerus/internal/obs/retry"
 	kafkax "github.com/NordCoder/Pingerus/internal/repository/kafka"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"go.opentelemetry.io/otel"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type StatusChangedPayload struct {
@@ -21,6 +21,57 @@ type StatusChangedPayload struct {
 	At      time.Time `json:"at"`
 }
 
+var StatusChanged = Kind[StatusChangedPayload]{ID: outbox.KindStatusChanged, Name: "status_changed"}
+
+func RegisterStatusChanged(r *Registry, topic string) error {
+	return Register(r, Spec[StatusChangedPayload]{
+		Kind:  StatusChanged,
+		Topic: topic,
+		Key:   func(p StatusChangedPayload) []byte { return kafkax.KeyFromInt64(p.CheckID) },
+		Codec: ProtoCodec(func(p StatusChangedPayload) *pb.StatusChange {
+			return &pb.StatusChange{
+				CheckId:   int32(p.CheckID),
+				OldStatus: p.Old,
+				NewStatus: p.New,
+				Ts:        timestamppb.New(p.At),
+			}
+		}),
+	})
+}
+
+// Email templates rendered by the email-notifier.
+const (
+	EmailTemplateVerify = "verify_email"
+	EmailTemplateReset  = "password_reset"
+)
+
+type EmailRequestedPayload struct {
+	UserID   int64             `json:"user_id"`
+	To       string            `json:"to"`
+	Template string            `json:"template"`
+	Params   map[string]string `json:"params"`
+	At       time.Time         `json:"at"`
+}
+
+var EmailRequested = Kind[EmailRequestedPayload]{ID: outbox.KindEmailRequested, Name: "email_requested"}
+
+func RegisterEmailRequested(r *Registry, topic string) error {
+	return Register(r, Spec[EmailRequestedPayload]{
+		Kind:  EmailRequested,
+		Topic: topic,
+		Key:   func(p EmailRequestedPayload) []byte { return kafkax.KeyFromInt64(p.UserID) },
+		Codec: ProtoCodec(func(p EmailRequestedPayload) *pb.EmailRequest {
+			return &pb.EmailRequest{
+				UserId:   p.UserID,
+				To:       p.To,
+				Template: p.Template,
+				Params:   p.Params,
+				Ts:       timestamppb.New(p.At),
+			}
+		}),
+	})
+}
+
 var (
 	outboxHandlerLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "outbox_handler_latency_seconds",
@@ -52,21 +103,3 @@ func instrument(kind string, h outbox.KindHandler, pol retry.Policy) outbox.Kind
 		return err
 	}
 }
-
-func MakeGlobalOutboxHandler(pub *kafkax.CheckEventsKafka, pol retry.Policy) outbox.GlobalHandler {
-	return func(kind outbox.Kind) (outbox.KindHandler, error) {
-		switch kind {
-		case outbox.KindStatusChanged:
-			base := func(ctx context.Context, data []byte) error {
-				var p StatusChangedPayload
-				if err := json.Unmarshal(data, &p); err != nil {
-					return fmt.Errorf("unmarshal status-changed payload: %w", err)
-				}
-				return pub.PublishStatusChanged(ctx, p.CheckID, p.Old, p.New)
-			}
-			return instrument("status_changed", base, pol), nil
-		default:
-			return nil, fmt.Errorf("unsupported outbox kind: %d", kind)
-		}
-	}
-}
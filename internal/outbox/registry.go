@@ -0,0 +1,122 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/NordCoder/Pingerus/internal/domain/outbox"
+	"github.com/NordCoder/Pingerus/internal/obs/retry"
+	"google.golang.org/protobuf/proto"
+)
+
+// Publisher delivers an encoded payload to a destination topic.
+type Publisher interface {
+	PublishTo(ctx context.Context, topic string, key, value []byte) error
+}
+
+// Codec turns a stored payload into the bytes sent to the topic.
+type Codec[T any] func(T) ([]byte, error)
+
+func JSONCodec[T any]() Codec[T] {
+	return func(v T) ([]byte, error) { return json.Marshal(v) }
+}
+
+func ProtoCodec[T any, M proto.Message](conv func(T) M) Codec[T] {
+	return func(v T) ([]byte, error) { return proto.Marshal(conv(v)) }
+}
+
+// Kind binds an outbox.Kind to its payload type. Payloads are stored as JSON
+// in the outbox table regardless of the codec used on the wire.
+type Kind[T any] struct {
+	ID   outbox.Kind
+	Name string
+}
+
+func (k Kind[T]) Enqueue(ctx context.Context, repo outbox.Repository, key string, payload T) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal %s payload: %w", k.Name, err)
+	}
+	return repo.Enqueue(ctx, key, k.ID, b)
+}
+
+type Spec[T any] struct {
+	Kind  Kind[T]
+	Topic string
+	Key   func(T) []byte
+	Codec Codec[T]
+}
+
+type entry struct {
+	name  string
+	topic string
+	build func(pub Publisher) outbox.KindHandler
+}
+
+type Registry struct {
+	mu    sync.RWMutex
+	kinds map[outbox.Kind]entry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{kinds: map[outbox.Kind]entry{}}
+}
+
+func Register[T any](r *Registry, s Spec[T]) error {
+	if s.Topic == "" {
+		return fmt.Errorf("outbox kind %s: empty topic", s.Kind.Name)
+	}
+	if s.Codec == nil {
+		s.Codec = JSONCodec[T]()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if prev, ok := r.kinds[s.Kind.ID]; ok {
+		return fmt.Errorf("outbox kind %d already registered as %s", s.Kind.ID, prev.name)
+	}
+	r.kinds[s.Kind.ID] = entry{
+		name:  s.Kind.Name,
+		topic: s.Topic,
+		build: func(pub Publisher) outbox.KindHandler {
+			return func(ctx context.Context, data []byte) error {
+				var p T
+				if err := json.Unmarshal(data, &p); err != nil {
+					return fmt.Errorf("unmarshal %s payload: %w", s.Kind.Name, err)
+				}
+				value, err := s.Codec(p)
+				if err != nil {
+					return fmt.Errorf("encode %s payload: %w", s.Kind.Name, err)
+				}
+				var key []byte
+				if s.Key != nil {
+					key = s.Key(p)
+				}
+				return pub.PublishTo(ctx, s.Topic, key, value)
+			}
+		},
+	}
+	return nil
+}
+
+// Dispatcher resolves handlers for registered kinds; unknown kinds yield
+// outbox.ErrUnknownKind so the runner can park them.
+func (r *Registry) Dispatcher(pub Publisher, pol retry.Policy) outbox.GlobalHandler {
+	r.mu.RLock()
+	handlers := make(map[outbox.Kind]outbox.KindHandler, len(r.kinds))
+	for k, e := range r.kinds {
+		handlers[k] = instrument(e.name, e.build(pub), pol)
+	}
+	r.mu.RUnlock()
+
+	return func(kind outbox.Kind) (outbox.KindHandler, error) {
+		h, ok := handlers[kind]
+		if !ok {
+			return nil, fmt.Errorf("%w: %d", outbox.ErrUnknownKind, kind)
+		}
+		return h, nil
+	}
+}
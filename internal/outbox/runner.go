@@ -2,6 +2,7 @@ package outbox
 
 import (
 	"context"
+	"errors"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 	"strconv"
@@ -17,6 +18,30 @@ import (
 	"go.uber.org/zap"
 )
 
+// The metrics are shared by every Runner of the process, so more than one
+// can be built, e.g. in tests.
+var (
+	runnerPicked = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "outbox_picked_total", Help: "Messages picked into processing.",
+	})
+	runnerOk = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "outbox_processed_ok_total", Help: "Messages processed successfully.",
+	})
+	runnerErr = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "outbox_processed_err_total", Help: "Handler errors.",
+	})
+	runnerUnknown = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "outbox_unknown_kind_total", Help: "Messages parked because no handler is registered for their kind.",
+	})
+	runnerTickDur = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "outbox_tick_duration_seconds", Help: "Tick duration.",
+		Buckets: prometheus.DefBuckets,
+	})
+	runnerBatchSize = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "outbox_last_batch_size", Help: "Size of last picked batch.",
+	})
+)
+
 type Runner struct {
 	log      *zap.Logger
 	repo     outbox.Repository
@@ -27,9 +52,14 @@ type Runner struct {
 	waitTime      time.Duration
 	inProgressTTL time.Duration
 
+	// wake, when set, lets workers run a tick right away instead of
+	// waiting for waitTime; polling stays on as a fallback.
+	wake <-chan struct{}
+
 	mPicked    prometheus.Counter
 	mOk        prometheus.Counter
 	mErr       prometheus.Counter
+	mUnknown   prometheus.Counter
 	mTickDur   prometheus.Histogram
 	mBatchSize prometheus.Gauge
 }
@@ -46,25 +76,16 @@ func NewOutboxRunner(
 	return &Runner{
 		log: log, repo: repo, dispatch: dispatch,
 		workers: workers, batchSize: batchSize, waitTime: waitTime, inProgressTTL: inProgressTTL,
-		mPicked: promauto.NewCounter(prometheus.CounterOpts{
-			Name: "outbox_picked_total", Help: "Messages picked into processing.",
-		}),
-		mOk: promauto.NewCounter(prometheus.CounterOpts{
-			Name: "outbox_processed_ok_total", Help: "Messages processed successfully.",
-		}),
-		mErr: promauto.NewCounter(prometheus.CounterOpts{
-			Name: "outbox_processed_err_total", Help: "Handler errors.",
-		}),
-		mTickDur: promauto.NewHistogram(prometheus.HistogramOpts{
-			Name: "outbox_tick_duration_seconds", Help: "Tick duration.",
-			Buckets: prometheus.DefBuckets,
-		}),
-		mBatchSize: promauto.NewGauge(prometheus.GaugeOpts{
-			Name: "outbox_last_batch_size", Help: "Size of last picked batch.",
-		}),
+		mPicked: runnerPicked, mOk: runnerOk, mErr: runnerErr, mUnknown: runnerUnknown,
+		mTickDur: runnerTickDur, mBatchSize: runnerBatchSize,
 	}
 }
 
+func (r *Runner) WithWakeup(wake <-chan struct{}) *Runner {
+	r.wake = wake
+	return r
+}
+
 func (r *Runner) Start(ctx context.Context) {
 	var wg sync.WaitGroup
 	for i := 0; i < r.workers; i++ {
@@ -75,14 +96,14 @@ func (r *Runner) Start(ctx context.Context) {
 
 func (r *Runner) worker(ctx context.Context, wg *sync.WaitGroup) {
 	defer wg.Done()
-	r.log.Info("outbox worker started", zap.String("wait_ms", strconv.FormatInt(r.waitTime.Milliseconds(), 10)))
+	r.log.Info("outbox worker started",
+		zap.String("wait_ms", strconv.FormatInt(r.waitTime.Milliseconds(), 10)),
+		zap.Bool("wakeup", r.wake != nil),
+	)
 
 	ticker := time.NewTicker(r.waitTime)
 	defer ticker.Stop()
 
-	tr := otel.Tracer("outbox.runner")
-	prop := otel.GetTextMapPropagator()
-
 	for {
 		select {
 		case <-ctx.Done():
@@ -90,73 +111,103 @@ func (r *Runner) worker(ctx context.Context, wg *sync.WaitGroup) {
 			return
 
 		case <-ticker.C:
-			t0 := time.Now()
-
-			ctxSpan, span := tr.Start(ctx, "outbox.tick")
-			span.SetAttributes(
-				attribute.Int("batch.limit", r.batchSize),
-				attribute.String("in_progress_ttl", r.inProgressTTL.String()),
-			)
-
-			messages, err := r.repo.PickBatch(ctxSpan, r.batchSize, r.inProgressTTL)
-			if err != nil {
-				span.RecordError(err)
-				r.mErr.Inc()
-				obs.WithTrace(ctxSpan, r.log).Error("outbox pick error", zap.Error(err))
-				span.End()
-				continue
-			}
-			r.mPicked.Add(float64(len(messages)))
-			r.mBatchSize.Set(float64(len(messages)))
-
-			okKeys := make([]string, 0, len(messages))
-
-			for _, m := range messages {
-				parent := prop.Extract(context.Background(), propagation.MapCarrier{
-					"traceparent": m.Traceparent,
-					"tracestate":  m.Tracestate,
-					"baggage":     m.Baggage,
-				})
-
-				msgCtx, msgSpan := tr.Start(parent, "outbox.dispatch",
-					trace.WithAttributes(
-						attribute.String("outbox.key", m.IdempotencyKey),
-						attribute.Int("outbox.kind", int(m.Kind)),
-					),
-				)
-
-				handler, herr := r.dispatch(m.Kind)
-				if herr != nil {
-					msgSpan.RecordError(herr)
-					r.mErr.Inc()
-					obs.WithTrace(msgCtx, r.log).Error("no handler for kind",
-						zap.Int("kind", int(m.Kind)), zap.Error(herr))
-					msgSpan.End()
-					continue
-				}
+			r.tick(ctx)
 
-				if err := handler(msgCtx, m.Data); err != nil {
-					msgSpan.RecordError(err)
-					r.mErr.Inc()
-					obs.WithTrace(msgCtx, r.log).Error("handler error",
-						zap.Int("kind", int(m.Kind)), zap.Error(err))
-					msgSpan.End()
-					continue
+		case <-r.wake:
+			// drain whatever is ready instead of waiting for the next tick
+			for {
+				if n := r.tick(ctx); n < r.batchSize || ctx.Err() != nil {
+					break
 				}
-
-				msgSpan.End()
-				okKeys = append(okKeys, m.IdempotencyKey)
-				r.mOk.Inc()
 			}
+		}
+	}
+}
 
-			if err := r.repo.MarkSuccess(ctxSpan, okKeys); err != nil {
-				span.RecordError(err)
-				r.mErr.Inc()
-				obs.WithTrace(ctxSpan, r.log).Error("mark success error", zap.Error(err))
-			}
+func (r *Runner) tick(ctx context.Context) int {
+	t0 := time.Now()
+	defer func() { r.mTickDur.Observe(time.Since(t0).Seconds()) }()
+
+	tr := otel.Tracer("outbox.runner")
+	prop := otel.GetTextMapPropagator()
 
-			span.End()
-			r.mTickDur.Observe(time.Since(t0).Seconds())
+	ctxSpan, span := tr.Start(ctx, "outbox.tick")
+	defer span.End()
+	span.SetAttributes(
+		attribute.Int("batch.limit", r.batchSize),
+		attribute.String("in_progress_ttl", r.inProgressTTL.String()),
+	)
+
+	messages, err := r.repo.PickBatch(ctxSpan, r.batchSize, r.inProgressTTL)
+	if err != nil {
+		span.RecordError(err)
+		r.mErr.Inc()
+		obs.WithTrace(ctxSpan, r.log).Error("outbox pick error", zap.Error(err))
+		return 0
+	}
+	r.mPicked.Add(float64(len(messages)))
+	r.mBatchSize.Set(float64(len(messages)))
+
+	okKeys := make([]string, 0, len(messages))
+	var unknownKeys []string
+
+	for _, m := range messages {
+		parent := prop.Extract(context.Background(), propagation.MapCarrier{
+			"traceparent": m.Traceparent,
+			"tracestate":  m.Tracestate,
+			"baggage":     m.Baggage,
+		})
+
+		msgCtx, msgSpan := tr.Start(parent, "outbox.dispatch",
+			trace.WithAttributes(
+				attribute.String("outbox.key", m.IdempotencyKey),
+				attribute.Int("outbox.kind", int(m.Kind)),
+			),
+		)
+
+		handler, herr := r.dispatch(m.Kind)
+		if errors.Is(herr, outbox.ErrUnknownKind) {
+			msgSpan.RecordError(herr)
+			r.mUnknown.Inc()
+			obs.WithTrace(msgCtx, r.log).Warn("unknown kind; parking message",
+				zap.Int("kind", int(m.Kind)), zap.String("key", m.IdempotencyKey))
+			msgSpan.End()
+			unknownKeys = append(unknownKeys, m.IdempotencyKey)
+			continue
+		}
+		if herr != nil {
+			msgSpan.RecordError(herr)
+			r.mErr.Inc()
+			obs.WithTrace(msgCtx, r.log).Error("no handler for kind",
+				zap.Int("kind", int(m.Kind)), zap.Error(herr))
+			msgSpan.End()
+			continue
 		}
+
+		if err := handler(msgCtx, m.Data); err != nil {
+			msgSpan.RecordError(err)
+			r.mErr.Inc()
+			obs.WithTrace(msgCtx, r.log).Error("handler error",
+				zap.Int("kind", int(m.Kind)), zap.Error(err))
+			msgSpan.End()
+			continue
+		}
+
+		msgSpan.End()
+		okKeys = append(okKeys, m.IdempotencyKey)
+		r.mOk.Inc()
+	}
+
+	if err := r.repo.MarkSuccess(ctxSpan, okKeys); err != nil {
+		span.RecordError(err)
+		r.mErr.Inc()
+		obs.WithTrace(ctxSpan, r.log).Error("mark success error", zap.Error(err))
+	}
+	if err := r.repo.MarkUnknownKind(ctxSpan, unknownKeys); err != nil {
+		span.RecordError(err)
+		r.mErr.Inc()
+		obs.WithTrace(ctxSpan, r.log).Error("mark unknown kind error", zap.Error(err))
 	}
+
+	return len(messages)
 }
@@ -14,5 +14,14 @@ func BootstrapConsumer(ctx context.Context, cfg *ConsumerConfig, logger *zap.Log
 		MaxWait:           5 * time.Second,
 	}, logger)
 
+	if cfg.DLQ {
+		_ = EnsureTopic(ctx, cfg.Brokers, TopicSpec{
+			Name:              cfg.dlqTopic(),
+			NumPartitions:     1,
+			ReplicationFactor: 1,
+			MaxWait:           5 * time.Second,
+		}, logger)
+	}
+
 	return NewConsumer(cfg)
 }
@@ -6,6 +6,7 @@ import (
 	"io"
 	"time"
 
+	"github.com/NordCoder/Pingerus/internal/obs/retry"
 	"github.com/segmentio/kafka-go"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/trace"
@@ -14,8 +15,30 @@ import (
 
 type Handler func(ctx context.Context, key, value []byte) error
 
+// MessageMeta identifies the Kafka message a Handler is processing.
+type MessageMeta struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Group     string
+}
+
+type messageMetaKey struct{}
+
+// ContextWithMessage returns ctx carrying m, as the consumer hands it to a
+// Handler.
+func ContextWithMessage(ctx context.Context, m MessageMeta) context.Context {
+	return context.WithValue(ctx, messageMetaKey{}, m)
+}
+
+func MessageFromContext(ctx context.Context) (MessageMeta, bool) {
+	m, ok := ctx.Value(messageMetaKey{}).(MessageMeta)
+	return m, ok
+}
+
 type Consumer struct {
 	reader *kafka.Reader
+	dlq    *Producer
 	log    *zap.Logger
 	cfg    *ConsumerConfig
 }
@@ -26,6 +49,32 @@ type ConsumerConfig struct {
 	Topic         string
 	FromBeginning bool
 	Logger        *zap.Logger
+
+	// MaxAttempts is the per-message handler budget; 0 or 1 means no retries.
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	// DLQ routes messages that exhausted MaxAttempts to DLQTopic
+	// (<topic>.dlq by default) instead of dropping them.
+	DLQ      bool
+	DLQTopic string
+
+	// Concurrency > 1 processes messages on that many workers. Messages with
+	// the same key stay ordered; offsets are committed up to the lowest
+	// contiguous completed message of each partition.
+	Concurrency int
+	// LaneBuffer is how many messages may queue for each worker, 64 when 0.
+	// A single fetcher feeds all workers, so once a slow key fills its lane
+	// the others idle until it drains; a deeper buffer rides out longer
+	// stalls at the cost of more redelivery after a crash. Time spent
+	// blocked is exported as kafka_consumer_lane_blocked_seconds_total.
+	LaneBuffer int
+}
+
+func (cfg *ConsumerConfig) dlqTopic() string {
+	if cfg.DLQTopic != "" {
+		return cfg.DLQTopic
+	}
+	return DLQTopic(cfg.Topic)
 }
 
 func NewConsumer(cfg *ConsumerConfig) *Consumer {
@@ -58,7 +107,11 @@ func NewConsumer(cfg *ConsumerConfig) *Consumer {
 		zap.String("group", cfg.GroupID),
 	)
 
-	return &Consumer{reader: r, log: log, cfg: cfg}
+	c := &Consumer{reader: r, log: log, cfg: cfg}
+	if cfg.DLQ {
+		c.dlq = NewProducer(cfg.Brokers, cfg.dlqTopic()).WithLogger(cfg.Logger)
+	}
+	return c
 }
 
 func (c *Consumer) WithLogger(l *zap.Logger) *Consumer {
@@ -75,82 +128,147 @@ func (c *Consumer) WithLogger(l *zap.Logger) *Consumer {
 }
 
 func (c *Consumer) Consume(ctx context.Context, h Handler) error {
+	if c.cfg.Concurrency > 1 {
+		return c.consumeConcurrent(ctx, h)
+	}
+
 	log := c.log
 	log.Info("consumer started")
 
-	backoff := 200 * time.Millisecond
-	const maxBackoff = 5 * time.Second
-
-	tr := otel.Tracer("kafka.consumer")
-
+	f := fetcher{c: c}
 	for {
-		select {
-		case <-ctx.Done():
+		msg, err := f.next(ctx)
+		if err != nil {
 			log.Info("consumer stopped (ctx canceled)")
-			return ctx.Err()
-		default:
+			return err
 		}
 
-		msg, err := c.reader.FetchMessage(ctx)
-		if err != nil {
+		if err := c.process(ctx, h, msg); err != nil {
+			log.Info("consumer stopped (ctx canceled)")
+			return err
+		}
+
+		if err := c.reader.CommitMessages(ctx, msg); err != nil {
 			if ctx.Err() != nil {
-				log.Info("consumer stopped (ctx canceled)")
+				log.Info("commit interrupted by context cancel")
 				return ctx.Err()
 			}
-			if errors.Is(err, io.EOF) {
-				log.Debug("fetch EOF; retry", zap.Duration("backoff", backoff))
-			} else {
-				log.Warn("fetch failed; retry", zap.Error(err), zap.Duration("backoff", backoff))
-			}
-			time.Sleep(backoff)
-			if backoff < maxBackoff {
-				backoff *= 2
-			}
-			if backoff > maxBackoff {
-				backoff = maxBackoff
-			}
-			continue
+			log.Warn("commit failed; will retry later", zap.Error(err))
+		}
+	}
+}
+
+// fetcher wraps FetchMessage with backoff on transient errors; it only
+// returns an error once ctx is done.
+type fetcher struct {
+	c       *Consumer
+	backoff time.Duration
+}
+
+func (f *fetcher) next(ctx context.Context) (kafka.Message, error) {
+	const (
+		minBackoff = 200 * time.Millisecond
+		maxBackoff = 5 * time.Second
+	)
+	if f.backoff == 0 {
+		f.backoff = minBackoff
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return kafka.Message{}, err
 		}
 
-		backoff = 200 * time.Millisecond
+		msg, err := f.c.reader.FetchMessage(ctx)
+		if err == nil {
+			f.backoff = minBackoff
+			return msg, nil
+		}
+		if ctx.Err() != nil {
+			return kafka.Message{}, ctx.Err()
+		}
+		if errors.Is(err, io.EOF) {
+			f.c.log.Debug("fetch EOF; retry", zap.Duration("backoff", f.backoff))
+		} else {
+			f.c.log.Warn("fetch failed; retry", zap.Error(err), zap.Duration("backoff", f.backoff))
+		}
+		time.Sleep(f.backoff)
+		f.backoff = min(f.backoff*2, maxBackoff)
+	}
+}
 
-		prop := otel.GetTextMapPropagator()
-		parent := prop.Extract(ctx, mapCarrierFromKafka(msg.Headers))
+// process runs h for msg with tracing, retries and dead-lettering. A non-nil
+// error means ctx was canceled and msg must not be committed.
+func (c *Consumer) process(ctx context.Context, h Handler, msg kafka.Message) error {
+	tr := otel.Tracer("kafka.consumer")
 
-		rcvCtx, rcvSpan := tr.Start(
-			parent,
-			"kafka.receive "+c.cfg.Topic,
-			trace.WithSpanKind(trace.SpanKindConsumer),
-		)
-		rcvSpan.End()
+	prop := otel.GetTextMapPropagator()
+	parent := prop.Extract(ctx, mapCarrierFromKafka(msg.Headers))
 
-		procCtx, procSpan := tr.Start(
-			rcvCtx,
-			"process "+c.cfg.Topic,
-			trace.WithSpanKind(trace.SpanKindInternal),
-		)
+	rcvCtx, rcvSpan := tr.Start(
+		parent,
+		"kafka.receive "+c.cfg.Topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+	)
+	rcvSpan.End()
 
-		if err := h(procCtx, msg.Key, msg.Value); err != nil {
-			procSpan.RecordError(err)
-			procSpan.End()
+	procCtx, procSpan := tr.Start(
+		rcvCtx,
+		"process "+c.cfg.Topic,
+		trace.WithSpanKind(trace.SpanKindInternal),
+	)
+	defer procSpan.End()
 
-			log.Error("handler error",
-				zap.Int("partition", msg.Partition),
-				zap.Error(err),
-			)
-			continue
-		}
+	procCtx = ContextWithMessage(procCtx, MessageMeta{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Group:     c.cfg.GroupID,
+	})
+
+	err := c.handle(procCtx, h, msg)
+	if err == nil {
+		return nil
+	}
+	procSpan.RecordError(err)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 
-		procSpan.End()
+	c.log.Error("handler error; retries exhausted",
+		zap.Int("partition", msg.Partition),
+		zap.Int64("offset", msg.Offset),
+		zap.Error(err),
+	)
+	return c.deadLetter(ctx, msg, err)
+}
 
-		if err := c.reader.CommitMessages(ctx, msg); err != nil {
-			if ctx.Err() != nil {
-				log.Info("commit interrupted by context cancel")
-				return ctx.Err()
-			}
-			log.Warn("commit failed; will retry later", zap.Error(err))
-		}
+func (c *Consumer) handle(ctx context.Context, h Handler, msg kafka.Message) error {
+	attempts := c.cfg.MaxAttempts
+	if attempts <= 1 {
+		return h(ctx, msg.Key, msg.Value)
 	}
+	base := c.cfg.RetryBackoff
+	if base <= 0 {
+		base = 200 * time.Millisecond
+	}
+	return retry.Do(ctx, func() error { return h(ctx, msg.Key, msg.Value) }, retry.Policy{
+		Name:     "kafka_consume_" + c.cfg.Topic,
+		Attempts: attempts,
+		Backoff:  retry.ExpoJitter{Base: base, Max: 30 * time.Second, Jitter: 0.2},
+		OnAttempt: func(i int, err error) {
+			c.log.Warn("handler attempt failed",
+				zap.Int("attempt", i+1),
+				zap.Int("max_attempts", attempts),
+				zap.Int64("offset", msg.Offset),
+				zap.Error(err),
+			)
+		},
+	})
 }
 
-func (c *Consumer) Close() error { return c.reader.Close() }
+func (c *Consumer) Close() error {
+	if c.dlq != nil {
+		_ = c.dlq.Close()
+	}
+	return c.reader.Close()
+}
@@ -0,0 +1,95 @@
+package kafka
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+const (
+	HeaderDLQError     = "x-dlq-error"
+	HeaderDLQTopic     = "x-dlq-original-topic"
+	HeaderDLQPartition = "x-dlq-original-partition"
+	HeaderDLQOffset    = "x-dlq-original-offset"
+	HeaderDLQGroup     = "x-dlq-consumer-group"
+	HeaderDLQAttempts  = "x-dlq-attempts"
+	HeaderDLQFailedAt  = "x-dlq-failed-at"
+
+	dlqHeaderPrefix = "x-dlq-"
+)
+
+var (
+	consumerDeadLettered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_consumer_dead_lettered_total",
+		Help: "Messages moved to the dead-letter topic after exhausting retries.",
+	}, []string{"topic"})
+	consumerDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_consumer_dropped_total",
+		Help: "Messages skipped after exhausting retries with no DLQ configured.",
+	}, []string{"topic"})
+)
+
+func DLQTopic(topic string) string { return topic + ".dlq" }
+
+// deadLetter publishes msg to the DLQ, retrying until it succeeds or ctx is
+// done, so that the original offset is committed only once the message is safe.
+func (c *Consumer) deadLetter(ctx context.Context, msg kafka.Message, cause error) error {
+	if c.dlq == nil {
+		consumerDropped.WithLabelValues(c.cfg.Topic).Inc()
+		c.log.Error("message dropped (no dlq)", zap.Int("partition", msg.Partition), zap.Int64("offset", msg.Offset))
+		return nil
+	}
+
+	headers := append(StripDLQHeaders(msg.Headers),
+		kafka.Header{Key: HeaderDLQError, Value: []byte(cause.Error())},
+		kafka.Header{Key: HeaderDLQTopic, Value: []byte(msg.Topic)},
+		kafka.Header{Key: HeaderDLQPartition, Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: HeaderDLQOffset, Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		kafka.Header{Key: HeaderDLQGroup, Value: []byte(c.cfg.GroupID)},
+		kafka.Header{Key: HeaderDLQAttempts, Value: []byte(strconv.Itoa(max(c.cfg.MaxAttempts, 1)))},
+		kafka.Header{Key: HeaderDLQFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	)
+
+	backoff := 200 * time.Millisecond
+	const maxBackoff = 5 * time.Second
+	for {
+		err := c.dlq.PublishMessage(ctx, kafka.Message{Key: msg.Key, Value: msg.Value, Headers: headers})
+		if err == nil {
+			consumerDeadLettered.WithLabelValues(c.cfg.Topic).Inc()
+			c.log.Warn("message dead-lettered",
+				zap.String("dlq", c.dlq.topic),
+				zap.Int("partition", msg.Partition),
+				zap.Int64("offset", msg.Offset),
+			)
+			return nil
+		}
+		c.log.Error("dlq publish failed; retry", zap.Error(err), zap.Duration("backoff", backoff))
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// StripDLQHeaders drops headers added by a previous dead-lettering.
+func StripDLQHeaders(hs []kafka.Header) []kafka.Header {
+	out := make([]kafka.Header, 0, len(hs))
+	for _, h := range hs {
+		if strings.HasPrefix(h.Key, dlqHeaderPrefix) {
+			continue
+		}
+		out = append(out, h)
+	}
+	return out
+}
@@ -0,0 +1,176 @@
+package kafka
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+// defaultLaneBuffer is the per-worker queue when LaneBuffer is not set.
+const defaultLaneBuffer = 64
+
+var consumerLaneBlocked = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "kafka_consumer_lane_blocked_seconds_total",
+	Help: "Time the fetcher waited for a full worker lane, leaving the other workers without new messages.",
+}, []string{"topic"})
+
+func (c *Consumer) consumeConcurrent(ctx context.Context, h Handler) error {
+	n := c.cfg.Concurrency
+	buf := c.cfg.LaneBuffer
+	if buf <= 0 {
+		buf = defaultLaneBuffer
+	}
+	log := c.log.With(zap.Int("concurrency", n), zap.Int("lane_buffer", buf))
+	log.Info("consumer started")
+
+	tracker := newOffsetTracker()
+	done := make(chan kafka.Message, n)
+	lanes := make([]chan kafka.Message, n)
+
+	var workers sync.WaitGroup
+	for i := range lanes {
+		lanes[i] = make(chan kafka.Message, buf)
+		workers.Add(1)
+		go func(in <-chan kafka.Message) {
+			defer workers.Done()
+			for msg := range in {
+				if err := c.process(ctx, h, msg); err != nil {
+					// canceled: leave the offset uncommitted
+					continue
+				}
+				done <- msg
+			}
+		}(lanes[i])
+	}
+
+	committed := make(chan struct{})
+	go func() {
+		defer close(committed)
+		c.commitLoop(tracker, done)
+	}()
+
+	f := fetcher{c: c}
+	var err error
+	for {
+		var msg kafka.Message
+		if msg, err = f.next(ctx); err != nil {
+			break
+		}
+		tracker.add(msg.Partition, msg.Offset)
+
+		ch := lanes[lane(msg, n)]
+		select {
+		case ch <- msg:
+			continue
+		default:
+		}
+		// head-of-line: every worker waits on this one lane
+		blocked := time.Now()
+		select {
+		case ch <- msg:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		consumerLaneBlocked.WithLabelValues(c.cfg.Topic).Add(time.Since(blocked).Seconds())
+		if err != nil {
+			break
+		}
+	}
+
+	for _, l := range lanes {
+		close(l)
+	}
+	workers.Wait()
+	close(done)
+	<-committed
+
+	log.Info("consumer stopped (ctx canceled)")
+	return err
+}
+
+// commitLoop commits, per partition, the highest offset below which every
+// fetched message has completed. It drains done until it is closed, so work
+// finished during shutdown is still committed.
+func (c *Consumer) commitLoop(t *offsetTracker, done <-chan kafka.Message) {
+	for msg := range done {
+		off, ok := t.complete(msg.Partition, msg.Offset)
+		if !ok {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := c.reader.CommitMessages(ctx, kafka.Message{Topic: msg.Topic, Partition: msg.Partition, Offset: off})
+		cancel()
+		if err != nil {
+			c.log.Warn("commit failed; will retry later",
+				zap.Int("partition", msg.Partition), zap.Int64("offset", off), zap.Error(err))
+		}
+	}
+}
+
+// lane keeps messages with the same key on the same worker; keyless
+// messages are spread by offset.
+func lane(msg kafka.Message, n int) int {
+	if len(msg.Key) == 0 {
+		return int(msg.Offset % int64(n))
+	}
+	hs := fnv.New32a()
+	_, _ = hs.Write(msg.Key)
+	return int(hs.Sum32() % uint32(n))
+}
+
+type partitionOffsets struct {
+	pending []int64
+	done    map[int64]bool
+}
+
+type offsetTracker struct {
+	mu    sync.Mutex
+	parts map[int]*partitionOffsets
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{parts: map[int]*partitionOffsets{}}
+}
+
+func (t *offsetTracker) add(partition int, offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.parts[partition]
+	if !ok {
+		p = &partitionOffsets{done: map[int64]bool{}}
+		t.parts[partition] = p
+	}
+	p.pending = append(p.pending, offset)
+}
+
+// complete marks offset as processed and returns the last offset of the
+// contiguous completed prefix, if that prefix grew.
+func (t *offsetTracker) complete(partition int, offset int64) (int64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.parts[partition]
+	if !ok {
+		return 0, false
+	}
+	p.done[offset] = true
+
+	var (
+		last     int64
+		advanced bool
+	)
+	for len(p.pending) > 0 && p.done[p.pending[0]] {
+		last = p.pending[0]
+		delete(p.done, last)
+		p.pending = p.pending[1:]
+		advanced = true
+	}
+	return last, advanced
+}
@@ -47,7 +47,15 @@ func (p *Producer) PublishProto(ctx context.Context, key []byte, m proto.Message
 		p.log.Error("proto marshal failed", zap.Error(err))
 		return err
 	}
+	return p.Publish(ctx, key, value)
+}
+
+func (p *Producer) Publish(ctx context.Context, key, value []byte) error {
+	return p.PublishMessage(ctx, kafka.Message{Key: key, Value: value})
+}
 
+// PublishMessage writes msg as is, adding trace context to its headers.
+func (p *Producer) PublishMessage(ctx context.Context, msg kafka.Message) error {
 	tr := otel.Tracer("kafka.producer")
 	ctx, span := tr.Start(ctx, "kafka.produce "+p.topic, trace.WithSpanKind(trace.SpanKindProducer),
 		trace.WithAttributes(
@@ -61,16 +69,22 @@ func (p *Producer) PublishProto(ctx context.Context, key []byte, m proto.Message
 	hdrs := mapCarrierHeaders{}
 	otel.GetTextMapPropagator().Inject(ctx, hdrs)
 
-	msg := kafka.Message{Key: key, Value: value, Headers: hdrs.ToKafka()}
+	headers := make([]kafka.Header, 0, len(msg.Headers)+len(hdrs))
+	for _, h := range msg.Headers {
+		if _, ok := hdrs[h.Key]; !ok {
+			headers = append(headers, h)
+		}
+	}
+	msg.Headers = append(headers, hdrs.ToKafka()...)
 
-	err = p.w.WriteMessages(ctx, msg)
+	err := p.w.WriteMessages(ctx, msg)
 	if err != nil {
 		p.log.Error("kafka write failed", zap.Error(err))
 		return err
 	}
 	p.log.Debug("message published",
-		zap.Int("key_len", len(key)),
-		zap.Int("value_len", len(value)),
+		zap.Int("key_len", len(msg.Key)),
+		zap.Int("value_len", len(msg.Value)),
 	)
 	return nil
 }
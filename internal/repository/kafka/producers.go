@@ -0,0 +1,54 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Producers hands out one Producer per topic over the same set of brokers.
+type Producers struct {
+	brokers []string
+	log     *zap.Logger
+
+	mu sync.Mutex
+	m  map[string]*Producer
+}
+
+func NewProducers(brokers []string) *Producers {
+	return &Producers{brokers: brokers, m: map[string]*Producer{}}
+}
+
+func (ps *Producers) WithLogger(l *zap.Logger) *Producers {
+	ps.log = l
+	return ps
+}
+
+func (ps *Producers) Topic(topic string) *Producer {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	p, ok := ps.m[topic]
+	if !ok {
+		p = NewProducer(ps.brokers, topic).WithLogger(ps.log)
+		ps.m[topic] = p
+	}
+	return p
+}
+
+func (ps *Producers) PublishTo(ctx context.Context, topic string, key, value []byte) error {
+	return ps.Topic(topic).Publish(ctx, key, value)
+}
+
+func (ps *Producers) Close() error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	var errs []error
+	for _, p := range ps.m {
+		errs = append(errs, p.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,130 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/NordCoder/Pingerus/internal/domain/auth"
+	"github.com/jackc/pgx/v5"
+)
+
+var _ auth.APIKeyRepo = (*APIKeyRepo)(nil)
+
+type APIKeyRepo struct{ db *DB }
+
+func NewAPIKeyRepo(db *DB) *APIKeyRepo { return &APIKeyRepo{db: db} }
+
+const (
+	qAPIKeyInsert = `
+INSERT INTO api_keys (user_id, org_id, name, prefix, key_hash, scope, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, created_at;`
+
+	qAPIKeysByUser = `
+SELECT id, user_id, org_id, name, prefix, key_hash, scope, created_at, last_used_at, expires_at, revoked_at
+FROM api_keys
+WHERE user_id = $1
+ORDER BY id DESC;`
+
+	qAPIKeyByHash = `
+SELECT id, user_id, org_id, name, prefix, key_hash, scope, created_at, last_used_at, expires_at, revoked_at
+FROM api_keys
+WHERE key_hash = $1;`
+
+	qAPIKeyRevoke = `
+UPDATE api_keys
+SET revoked_at = now()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;`
+
+	qAPIKeyTouch = `
+UPDATE api_keys
+SET last_used_at = now()
+WHERE id = $1
+  AND (last_used_at IS NULL OR last_used_at < now() - INTERVAL '1 minute');`
+)
+
+func (r *APIKeyRepo) Create(ctx context.Context, k *auth.APIKey) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	if err := r.db.Pool.QueryRow(ctx, qAPIKeyInsert,
+		k.UserID, k.OrgID, k.Name, k.Prefix, k.KeyHash, string(k.Scope), k.ExpiresAt,
+	).Scan(&k.ID, &k.CreatedAt); err != nil {
+		return fmt.Errorf("insert api key: %w", err)
+	}
+	return nil
+}
+
+func (r *APIKeyRepo) ListByUser(ctx context.Context, userID int64) ([]*auth.APIKey, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.Pool.Query(ctx, qAPIKeysByUser, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*auth.APIKey
+	for rows.Next() {
+		var k auth.APIKey
+		if err := scanAPIKey(rows, &k); err != nil {
+			return nil, err
+		}
+		out = append(out, &k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return out, nil
+}
+
+func (r *APIKeyRepo) FindByHash(ctx context.Context, keyHash string) (*auth.APIKey, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var k auth.APIKey
+	if err := scanAPIKey(r.db.Pool.QueryRow(ctx, qAPIKeyByHash, keyHash), &k); err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r *APIKeyRepo) Revoke(ctx context.Context, userID, id int64) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.Pool.Exec(ctx, qAPIKeyRevoke, id, userID)
+	if err != nil {
+		return fmt.Errorf("revoke api key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *APIKeyRepo) Touch(ctx context.Context, id int64) (bool, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.Pool.Exec(ctx, qAPIKeyTouch, id)
+	if err != nil {
+		return false, fmt.Errorf("touch api key: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func scanAPIKey(row pgx.Row, k *auth.APIKey) error {
+	var scope string
+	if err := row.Scan(&k.ID, &k.UserID, &k.OrgID, &k.Name, &k.Prefix, &k.KeyHash, &scope,
+		&k.CreatedAt, &k.LastUsedAt, &k.ExpiresAt, &k.RevokedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("scan api key: %w", err)
+	}
+	k.Scope = auth.APIKeyScope(scope)
+	return nil
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/NordCoder/Pingerus/internal/domain/auth"
+)
+
+var _ auth.EventRepo = (*AuthEventRepo)(nil)
+
+type AuthEventRepo struct{ db *DB }
+
+func NewAuthEventRepo(db *DB) *AuthEventRepo { return &AuthEventRepo{db: db} }
+
+const (
+	qAuthEventInsert = `
+INSERT INTO auth_events (user_id, event_type, ip_address, user_agent, metadata)
+VALUES ($1, $2, NULLIF($3, '')::inet, NULLIF($4, ''), COALESCE($5::jsonb, '{}'::jsonb))
+RETURNING id, created_at;`
+
+	qAuthEventList = `
+SELECT id, user_id, event_type, COALESCE(host(ip_address), ''), COALESCE(user_agent, ''), metadata, created_at
+FROM auth_events
+WHERE user_id = $1
+  AND ($2 = '' OR event_type = $2)
+  AND ($3 = 0 OR id < $3)
+ORDER BY id DESC
+LIMIT $4;`
+)
+
+func (r *AuthEventRepo) Create(ctx context.Context, e *auth.Event) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	meta := e.Metadata
+	if meta == nil {
+		meta = map[string]string{}
+	}
+	if err := r.db.execQueryer(ctx).QueryRow(ctx, qAuthEventInsert,
+		e.UserID, string(e.Type), e.IP, e.UserAgent, meta,
+	).Scan(&e.ID, &e.CreatedAt); err != nil {
+		return fmt.Errorf("insert auth event: %w", err)
+	}
+	return nil
+}
+
+func (r *AuthEventRepo) List(ctx context.Context, f auth.EventFilter) ([]*auth.Event, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.Pool.Query(ctx, qAuthEventList, f.UserID, string(f.Type), f.BeforeID, f.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("query auth events: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*auth.Event
+	for rows.Next() {
+		var (
+			e   auth.Event
+			typ string
+		)
+		if err := rows.Scan(&e.ID, &e.UserID, &typ, &e.IP, &e.UserAgent, &e.Metadata, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan auth event: %w", err)
+		}
+		e.Type = auth.EventType(typ)
+		out = append(out, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return out, nil
+}
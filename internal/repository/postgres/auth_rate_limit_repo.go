@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/NordCoder/Pingerus/internal/domain/auth"
+)
+
+var _ auth.RateLimitRepo = (*RateLimitRepo)(nil)
+
+type RateLimitRepo struct{ db *DB }
+
+func NewRateLimitRepo(db *DB) *RateLimitRepo { return &RateLimitRepo{db: db} }
+
+const (
+	qRLHit = `
+INSERT INTO auth_rate_limits AS r (key, window_start, attempts, updated_at)
+VALUES ($1, now(), 1, now())
+ON CONFLICT (key) DO UPDATE SET
+    attempts     = CASE WHEN r.window_start <= now() - make_interval(secs => $2::float8) THEN 1 ELSE r.attempts + 1 END,
+    window_start = CASE WHEN r.window_start <= now() - make_interval(secs => $2::float8) THEN now() ELSE r.window_start END,
+    updated_at   = now()
+RETURNING attempts, window_start, failures, locked_until;`
+
+	// $2 threshold, $3 base lock secs, $4 max lock secs, $5 failure ttl secs.
+	// Failures older than the ttl are forgotten; the row lock taken by the
+	// upsert keeps concurrent failures from being lost.
+	qRLFail = `
+INSERT INTO auth_rate_limits AS r (key, failures, last_failure_at, locked_until, updated_at)
+VALUES ($1::text, 1, now(),
+        CASE WHEN 1 >= $2::int THEN now() + make_interval(secs => $3::float8) END,
+        now())
+ON CONFLICT (key) DO UPDATE SET
+    failures        = CASE WHEN r.last_failure_at > now() - make_interval(secs => $5::float8)
+                           THEN r.failures + 1 ELSE 1 END,
+    locked_until    = CASE WHEN (CASE WHEN r.last_failure_at > now() - make_interval(secs => $5::float8)
+                                      THEN r.failures + 1 ELSE 1 END) >= $2::int
+                           THEN now() + make_interval(secs => LEAST($4::float8, $3::float8 * power(2,
+                                    (CASE WHEN r.last_failure_at > now() - make_interval(secs => $5::float8)
+                                          THEN r.failures + 1 ELSE 1 END) - $2::int)))
+                           ELSE r.locked_until END,
+    last_failure_at = now(),
+    updated_at      = now()
+RETURNING attempts, window_start, failures, locked_until;`
+
+	qRLReset = `
+UPDATE auth_rate_limits
+SET failures = 0, last_failure_at = NULL, locked_until = NULL, updated_at = now()
+WHERE key = $1;`
+
+	qRLPrune = `
+DELETE FROM auth_rate_limits
+WHERE updated_at < $1 AND (locked_until IS NULL OR locked_until < now());`
+)
+
+func (r *RateLimitRepo) Hit(ctx context.Context, key string, window time.Duration) (*auth.RateLimitState, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var s auth.RateLimitState
+	if err := r.db.Pool.QueryRow(ctx, qRLHit, key, window.Seconds()).
+		Scan(&s.Attempts, &s.WindowStart, &s.Failures, &s.LockedUntil); err != nil {
+		return nil, fmt.Errorf("rate limit hit: %w", err)
+	}
+	return &s, nil
+}
+
+func (r *RateLimitRepo) Fail(ctx context.Context, key string, p auth.LockoutPolicy) (*auth.RateLimitState, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var s auth.RateLimitState
+	if err := r.db.Pool.QueryRow(ctx, qRLFail,
+		key, p.Threshold, p.Base.Seconds(), p.Max.Seconds(), p.FailureTTL.Seconds(),
+	).Scan(&s.Attempts, &s.WindowStart, &s.Failures, &s.LockedUntil); err != nil {
+		return nil, fmt.Errorf("rate limit fail: %w", err)
+	}
+	return &s, nil
+}
+
+func (r *RateLimitRepo) Reset(ctx context.Context, key string) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := r.db.Pool.Exec(ctx, qRLReset, key); err != nil {
+		return fmt.Errorf("rate limit reset: %w", err)
+	}
+	return nil
+}
+
+func (r *RateLimitRepo) Prune(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.Pool.Exec(ctx, qRLPrune, before)
+	if err != nil {
+		return 0, fmt.Errorf("rate limit prune: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,185 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/NordCoder/Pingerus/internal/domain/check"
+	"github.com/jackc/pgx/v5"
+)
+
+var _ check.DependencyRepo = (*CheckDependencyRepo)(nil)
+
+type CheckDependencyRepo struct{ db *DB }
+
+func NewCheckDependencyRepo(db *DB) *CheckDependencyRepo { return &CheckDependencyRepo{db: db} }
+
+const (
+	// hashing the name with the org id as seed keeps the key apart from
+	// other advisory locks and takes ids of any size
+	qLockDependencies = `SELECT pg_advisory_xact_lock(hashtextextended('check_dependencies', $1::bigint));`
+
+	qAncestors = `
+WITH RECURSIVE up(id) AS (
+    SELECT d.parent_id FROM check_dependencies d WHERE d.check_id = ANY($1)
+    UNION
+    SELECT d.parent_id FROM check_dependencies d JOIN up ON d.check_id = up.id
+)
+SELECT id::bigint FROM up;`
+
+	qDeleteParents = `DELETE FROM check_dependencies WHERE check_id = $1;`
+
+	qInsertParents = `
+INSERT INTO check_dependencies (check_id, parent_id)
+SELECT $1, unnest($2::int[]);`
+
+	qDownParents = `
+SELECT c.id::bigint
+FROM check_dependencies d
+JOIN checks c ON c.id = d.parent_id
+WHERE d.check_id = $1 AND c.last_status = FALSE
+ORDER BY c.id;`
+
+	qInsertSuppression = `
+INSERT INTO suppressed_alerts (check_id, parent_id, old_status, new_status, changed_at)
+VALUES ($1, $2, $3, $4, $5);`
+
+	// an alert counts as sent once a notification for the check is stored
+	// after the suppression
+	qHeldBack = `
+SELECT s.parent_id::bigint
+FROM suppressed_alerts s
+WHERE s.check_id = $1
+  AND s.new_status = FALSE
+  AND NOT EXISTS (SELECT 1 FROM notifications n WHERE n.check_id = s.check_id AND n.sent_at > s.created_at)
+ORDER BY s.id DESC
+LIMIT 1;`
+
+	qSuppressed = `
+SELECT s.check_id, s.parent_id, s.old_status, s.new_status, s.changed_at, c.name, c.host
+FROM suppressed_alerts s
+JOIN checks c ON c.id = s.check_id
+WHERE s.parent_id = $1
+  AND s.changed_at >= COALESCE(
+        (SELECT max(e.created_at) FROM check_events e WHERE e.check_id = $1 AND e.kind = 'incident_opened'),
+        '-infinity')
+ORDER BY s.id;`
+)
+
+func (r *CheckDependencyRepo) LockDependencies(ctx context.Context, orgID int64) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := r.db.execQueryer(ctx).Exec(ctx, qLockDependencies, orgID); err != nil {
+		return fmt.Errorf("lock dependencies: %w", err)
+	}
+	return nil
+}
+
+func (r *CheckDependencyRepo) Ancestors(ctx context.Context, ids []int64) ([]int64, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.execQueryer(ctx).Query(ctx, qAncestors, ids)
+	if err != nil {
+		return nil, fmt.Errorf("query ancestors: %w", err)
+	}
+	return collectIDs(rows)
+}
+
+func (r *CheckDependencyRepo) SetParents(ctx context.Context, id int64, parents []int64) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	eq := r.db.execQueryer(ctx)
+	if _, err := eq.Exec(ctx, qDeleteParents, id); err != nil {
+		return fmt.Errorf("delete parents: %w", err)
+	}
+	if len(parents) == 0 {
+		return nil
+	}
+	if _, err := eq.Exec(ctx, qInsertParents, id, parents); err != nil {
+		return fmt.Errorf("insert parents: %w", err)
+	}
+	return nil
+}
+
+func (r *CheckDependencyRepo) DownParents(ctx context.Context, id int64) ([]int64, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.Pool.Query(ctx, qDownParents, id)
+	if err != nil {
+		return nil, fmt.Errorf("query down parents: %w", err)
+	}
+	return collectIDs(rows)
+}
+
+func (r *CheckDependencyRepo) Suppress(ctx context.Context, s []check.Suppression) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	eq := r.db.execQueryer(ctx)
+	for _, x := range s {
+		if _, err := eq.Exec(ctx, qInsertSuppression, x.CheckID, x.ParentID, x.Old, x.New, x.ChangedAt); err != nil {
+			return fmt.Errorf("insert suppression: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *CheckDependencyRepo) HeldBack(ctx context.Context, id int64) (int64, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.Pool.Query(ctx, qHeldBack, id)
+	if err != nil {
+		return 0, fmt.Errorf("query held back: %w", err)
+	}
+	ids, err := collectIDs(rows)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+func (r *CheckDependencyRepo) Suppressed(ctx context.Context, parentID int64) ([]check.Suppression, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.Pool.Query(ctx, qSuppressed, parentID)
+	if err != nil {
+		return nil, fmt.Errorf("query suppressed alerts: %w", err)
+	}
+	defer rows.Close()
+
+	var out []check.Suppression
+	for rows.Next() {
+		var s check.Suppression
+		if err := rows.Scan(&s.CheckID, &s.ParentID, &s.Old, &s.New, &s.ChangedAt, &s.CheckName, &s.URL); err != nil {
+			return nil, fmt.Errorf("scan suppressed alert: %w", err)
+		}
+		out = append(out, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return out, nil
+}
+
+func collectIDs(rows pgx.Rows) ([]int64, error) {
+	defer rows.Close()
+
+	var out []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan id: %w", err)
+		}
+		out = append(out, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/NordCoder/Pingerus/internal/domain/check"
+)
+
+var _ check.EventRepo = (*CheckEventRepo)(nil)
+
+// CheckEventsChannel is notified by the check_events triggers (migration
+// 0021) whenever new events are committed.
+const CheckEventsChannel = "check_events"
+
+type CheckEventRepo struct{ db *DB }
+
+func NewCheckEventRepo(db *DB) *CheckEventRepo { return &CheckEventRepo{db: db} }
+
+const (
+	qCheckEvents = `
+SELECT id, check_id, org_id, kind, status, old_status,
+       COALESCE(run_id, 0), COALESCE(code, 0), COALESCE(latency_ms, 0), created_at
+FROM check_events
+WHERE id > $1
+  AND ($2::bigint = 0 OR id <= $2)
+  AND ($3::bigint[] IS NULL OR org_id = ANY($3))
+  AND ($4::bigint = 0 OR check_id = $4)
+ORDER BY id
+LIMIT $5;`
+
+	qCheckIncidents = `
+SELECT o.check_id, o.created_at, r.created_at,
+       (SELECT count(DISTINCT s.check_id)
+        FROM suppressed_alerts s
+        WHERE s.parent_id = o.check_id
+          AND s.changed_at >= o.created_at
+          AND (r.created_at IS NULL OR s.changed_at <= r.created_at))
+FROM check_events o
+LEFT JOIN LATERAL (
+    SELECT r.created_at
+    FROM check_events r
+    WHERE r.check_id = o.check_id AND r.kind = 'incident_resolved' AND r.id > o.id
+    ORDER BY r.id
+    LIMIT 1
+) r ON TRUE
+WHERE o.kind = 'incident_opened'
+  AND o.check_id = ANY($1)
+  AND o.created_at >= $2
+ORDER BY o.id DESC
+LIMIT $3;`
+
+	qCheckEventBounds = `SELECT COALESCE(MIN(id), 0), COALESCE(MAX(id), 0) FROM check_events;`
+
+	qPruneCheckEvents = `DELETE FROM check_events WHERE created_at < $1;`
+)
+
+func (r *CheckEventRepo) Events(ctx context.Context, q check.EventQuery) ([]*check.Event, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.Pool.Query(ctx, qCheckEvents, q.After, q.UpTo, q.OrgIDs, q.CheckID, q.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("query check events: %w", err)
+	}
+	defer rows.Close()
+
+	out := make([]*check.Event, 0, q.Limit)
+	for rows.Next() {
+		var e check.Event
+		if err := rows.Scan(&e.ID, &e.CheckID, &e.OrgID, &e.Kind, &e.Status, &e.OldStatus,
+			&e.RunID, &e.Code, &e.Latency, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan check event: %w", err)
+		}
+		out = append(out, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return out, nil
+}
+
+func (r *CheckEventRepo) Incidents(ctx context.Context, checkIDs []int64, since time.Time, limit int) ([]*check.Incident, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.Pool.Query(ctx, qCheckIncidents, checkIDs, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query incidents: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*check.Incident
+	for rows.Next() {
+		var in check.Incident
+		if err := rows.Scan(&in.CheckID, &in.OpenedAt, &in.ResolvedAt, &in.Suppressed); err != nil {
+			return nil, fmt.Errorf("scan incident: %w", err)
+		}
+		out = append(out, &in)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return out, nil
+}
+
+func (r *CheckEventRepo) EventBounds(ctx context.Context) (first, last int64, err error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	err = r.db.Pool.QueryRow(ctx, qCheckEventBounds).Scan(&first, &last)
+	return first, last, err
+}
+
+func (r *CheckEventRepo) PruneEvents(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.Pool.Exec(ctx, qPruneCheckEvents, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/NordCoder/Pingerus/internal/domain/check"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -18,32 +19,116 @@ type CheckRepoImpl struct {
 
 func NewCheckRepo(db *DB) *CheckRepoImpl { return &CheckRepoImpl{db: db} }
 
+// checkColumns is the column list scanFull expects.
+const checkColumns = `id, user_id, org_id, name, type, tags, host, interval_sec, last_status,
+       status_changed_at, next_run, created_at, updated_at, active, version, COALESCE(badge_token, ''),
+       COALESCE(ping_token, ''), grace_sec, last_ping_at, started_at,
+       ARRAY(SELECT d.parent_id::bigint FROM check_dependencies d WHERE d.check_id = checks.id ORDER BY d.parent_id)`
+
 const (
+	// a heartbeat's first deadline is one period and grace away
 	qInsert = `
-INSERT INTO checks (user_id, host, interval_sec, active, next_run)
-VALUES ($1, $2, $3, TRUE, NOW())
-RETURNING id, user_id, host, interval_sec, last_status, next_run, created_at, updated_at, active;
+INSERT INTO checks (user_id, org_id, name, type, tags, host, interval_sec, ping_token, grace_sec, active, next_run)
+VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, TRUE,
+        CASE WHEN $4 = 'heartbeat' THEN NOW() + (($7 + $9) * INTERVAL '1 second') ELSE NOW() END)
+RETURNING ` + checkColumns + `;
 `
 
 	qGetByID = `
-SELECT id, user_id, host, interval_sec, last_status, next_run, created_at, updated_at, active
+SELECT ` + checkColumns + `
 FROM checks
 WHERE id = $1;
 `
 
-	qListByUser = `
-SELECT id, user_id, host, interval_sec, last_status, next_run, created_at, updated_at, active
+	qDelete = `DELETE FROM checks WHERE id = $1;`
+
+	qFetchDue = `
+SELECT ` + checkColumns + `
 FROM checks
-WHERE user_id = $1
-ORDER BY id DESC;
+WHERE active = TRUE AND type = 'http' AND next_run <= NOW()
+ORDER BY next_run
+FOR UPDATE SKIP LOCKED
+LIMIT $1;
 `
 
-	qDelete = `DELETE FROM checks WHERE id = $1;`
+	qUpdateStatus = `
+UPDATE checks
+SET last_status = $2,
+    status_changed_at = CASE WHEN last_status IS DISTINCT FROM $2 THEN NOW() ELSE status_changed_at END,
+    updated_at = now()
+WHERE id = $1;`
 
-	qFetchDue = `
-SELECT id, user_id, host, interval_sec, last_status, next_run, created_at, updated_at, active
+	// a new interval restarts the schedule from now, a new URL is probed
+	// right away; a heartbeat's deadline moves with its period and grace.
+	// A resumed check starts over as if just created.
+	qPatch = `
+UPDATE checks
+SET name         = COALESCE($5, name),
+    tags         = COALESCE($6, tags),
+    host         = COALESCE($2, host),
+    interval_sec = COALESCE($3, interval_sec),
+    grace_sec    = COALESCE($7, grace_sec),
+    active       = COALESCE($8, active),
+    started_at   = CASE WHEN $8::bool AND NOT active THEN NULL ELSE started_at END,
+    next_run     = CASE
+                     WHEN $8::bool AND NOT active THEN
+                       CASE WHEN type = 'heartbeat' THEN NOW() + ((COALESCE($3, interval_sec) + COALESCE($7, grace_sec)) * INTERVAL '1 second')
+                            ELSE NOW()
+                       END
+                     WHEN type = 'heartbeat' THEN
+                       CASE WHEN started_at IS NOT NULL THEN started_at + (COALESCE(NULLIF(COALESCE($7, grace_sec), 0), COALESCE($3, interval_sec)) * INTERVAL '1 second')
+                            ELSE COALESCE(last_ping_at, created_at) + ((COALESCE($3, interval_sec) + COALESCE($7, grace_sec)) * INTERVAL '1 second')
+                       END
+                     WHEN $3::int IS NOT NULL AND $3 <> interval_sec THEN NOW() + ($3 * INTERVAL '1 second')
+                     WHEN $2::text IS NOT NULL AND $2 <> host THEN NOW()
+                     ELSE next_run
+                   END,
+    version      = version + 1,
+    updated_at   = NOW()
+WHERE id = $1 AND ($4::bigint = 0 OR version = $4)
+RETURNING ` + checkColumns + `;
+`
+
+	qExists = `SELECT EXISTS (SELECT 1 FROM checks WHERE id = $1);`
+
+	qGetByBadgeToken = `
+SELECT ` + checkColumns + `
+FROM checks
+WHERE badge_token = $1;
+`
+
+	qSetBadgeToken = `UPDATE checks SET badge_token = NULLIF($2, '') WHERE id = $1;`
+
+	qLockByID = `
+SELECT ` + checkColumns + `
+FROM checks
+WHERE id = $1
+FOR UPDATE;
+`
+
+	qLockByPingToken = `
+SELECT ` + checkColumns + `
+FROM checks
+WHERE ping_token = $1 AND type = 'heartbeat'
+FOR UPDATE;
+`
+
+	qRecordPing = `
+UPDATE checks
+SET last_status       = COALESCE($2, last_status),
+    status_changed_at = CASE WHEN $2::bool IS NOT NULL AND last_status IS DISTINCT FROM $2 THEN NOW() ELSE status_changed_at END,
+    last_ping_at      = $3,
+    started_at        = $4,
+    next_run          = $5,
+    updated_at        = NOW()
+WHERE id = $1;
+`
+
+	// heartbeats that never pinged stay unknown, so only up ones can miss
+	qLockMissedHeartbeats = `
+SELECT ` + checkColumns + `
 FROM checks
-WHERE active = TRUE AND next_run <= NOW()
+WHERE type = 'heartbeat' AND active = TRUE AND next_run <= NOW() AND last_status = TRUE
 ORDER BY next_run
 FOR UPDATE SKIP LOCKED
 LIMIT $1;
@@ -57,20 +142,42 @@ WHERE id = ANY($1);
 `
 )
 
+// checkSortKeys are the ORDER BY expressions behind each sort field, with
+// the SQL type their cursor key is cast to. NULL status changes sort as the
+// oldest.
+var checkSortKeys = map[check.SortField]struct{ expr, typ string }{
+	check.SortName:          {"name", "text"},
+	check.SortStatusChanged: {"COALESCE(status_changed_at, '-infinity'::timestamptz)", "timestamptz"},
+	check.SortNextRun:       {"next_run", "timestamptz"},
+}
+
 func scanFull(row pgx.Row, c *check.Check) error {
 	var (
 		intervalSec int
+		graceSec    int
 	)
 	if err := row.Scan(
 		&c.ID,
 		&c.UserID,
+		&c.OrgID,
+		&c.Name,
+		&c.Type,
+		&c.Tags,
 		&c.URL,
 		&intervalSec,
 		&c.LastStatus,
+		&c.StatusChangedAt,
 		&c.NextRun,
 		&c.CreatedAt,
 		&c.UpdatedAt,
 		&c.Active,
+		&c.Version,
+		&c.BadgeToken,
+		&c.PingToken,
+		&graceSec,
+		&c.LastPingAt,
+		&c.StartedAt,
+		&c.ParentIDs,
 	); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return ErrNotFound
@@ -78,7 +185,7 @@ func scanFull(row pgx.Row, c *check.Check) error {
 		return fmt.Errorf("scan check: %w", err)
 	}
 	c.Interval = time.Duration(intervalSec) * time.Second
-	c.Name = ""
+	c.Grace = time.Duration(graceSec) * time.Second
 	return nil
 }
 
@@ -91,8 +198,23 @@ func (r *CheckRepoImpl) Create(ctx context.Context, c *check.Check) error {
 		intervalSec = 0
 	}
 
-	row := r.db.Pool.QueryRow(ctx, qInsert, c.UserID, c.URL, intervalSec)
-	return scanFull(row, c)
+	if c.Type == "" {
+		c.Type = check.TypeHTTP
+	}
+	tags := c.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	row := r.db.execQueryer(ctx).QueryRow(ctx, qInsert, c.UserID, c.OrgID, c.Name, c.Type, tags, c.URL, intervalSec,
+		c.PingToken, int(c.Grace/time.Second))
+	if err := scanFull(row, c); err != nil {
+		if isUniqueViolation(err) {
+			return ErrConflict
+		}
+		return err
+	}
+	return nil
 }
 
 func (r *CheckRepoImpl) GetByID(ctx context.Context, id int64) (*check.Check, error) {
@@ -106,13 +228,65 @@ func (r *CheckRepoImpl) GetByID(ctx context.Context, id int64) (*check.Check, er
 	return &c, nil
 }
 
-func (r *CheckRepoImpl) ListByUser(ctx context.Context, userID int64) ([]*check.Check, error) {
+func (r *CheckRepoImpl) GetByBadgeToken(ctx context.Context, token string) (*check.Check, error) {
 	ctx, cancel := r.db.withTimeout(ctx)
 	defer cancel()
 
-	rows, err := r.db.Pool.Query(ctx, qListByUser, userID)
+	var c check.Check
+	if err := scanFull(r.db.Pool.QueryRow(ctx, qGetByBadgeToken, token), &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *CheckRepoImpl) SetBadgeToken(ctx context.Context, id int64, token string) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.Pool.Exec(ctx, qSetBadgeToken, id, token)
 	if err != nil {
-		return nil, fmt.Errorf("query checks: %w", err)
+		if isUniqueViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("set badge token: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *CheckRepoImpl) List(ctx context.Context, q check.ListQuery) ([]*check.Check, *check.Cursor, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	where, args := checkListWhere(q)
+	key, keyed := checkSortKeys[q.Sort]
+	dir, cmp := "ASC", ">"
+	if q.Desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	if c := q.After; c != nil {
+		if keyed {
+			args = append(args, c.Key, c.ID)
+			where += fmt.Sprintf(" AND (%s, id) %s ($%d::%s, $%d)", key.expr, cmp, len(args)-1, key.typ, len(args))
+		} else {
+			args = append(args, c.ID)
+			where += fmt.Sprintf(" AND id %s $%d", cmp, len(args))
+		}
+	}
+	order := "id " + dir
+	if keyed {
+		order = key.expr + " " + dir + ", " + order
+	}
+	// one extra row tells whether there is a next page
+	args = append(args, q.Limit+1)
+	sql := fmt.Sprintf("SELECT %s FROM checks WHERE %s ORDER BY %s LIMIT $%d", checkColumns, where, order, len(args))
+
+	rows, err := r.db.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query checks: %w", err)
 	}
 	defer rows.Close()
 
@@ -120,30 +294,126 @@ func (r *CheckRepoImpl) ListByUser(ctx context.Context, userID int64) ([]*check.
 	for rows.Next() {
 		var c check.Check
 		if err := scanFull(rows, &c); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		out = append(out, &c)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows: %w", err)
+		return nil, nil, fmt.Errorf("rows: %w", err)
 	}
-	return out, nil
+	if len(out) <= q.Limit {
+		return out, nil, nil
+	}
+	out = out[:q.Limit]
+	last := out[len(out)-1]
+	return out, &check.Cursor{Key: checkSortKey(q.Sort, last), ID: last.ID}, nil
 }
 
-func (r *CheckRepoImpl) Update(ctx context.Context, c *check.Check) error {
+func (r *CheckRepoImpl) Count(ctx context.Context, q check.ListQuery) (int64, error) {
 	ctx, cancel := r.db.withTimeout(ctx)
 	defer cancel()
 
-	q := `
-UPDATE checks
-SET last_status = $2, updated_at = now()
-WHERE id = $1;`
+	where, args := checkListWhere(q)
+	var n int64
+	if err := r.db.Pool.QueryRow(ctx, "SELECT count(*) FROM checks WHERE "+where, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count checks: %w", err)
+	}
+	return n, nil
+}
+
+func checkListWhere(q check.ListQuery) (string, []any) {
+	args := []any{q.OrgIDs}
+	conds := []string{"org_id = ANY($1)"}
+	add := func(cond string, v any) {
+		args = append(args, v)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	switch q.Status {
+	case check.StatusUp:
+		conds = append(conds, "last_status = TRUE")
+	case check.StatusDown:
+		conds = append(conds, "last_status = FALSE")
+	case check.StatusUnknown:
+		conds = append(conds, "last_status IS NULL")
+	}
+	if q.Active != nil {
+		add("active = $%d", *q.Active)
+	}
+	if q.Type != "" {
+		add("type = $%d", q.Type)
+	}
+	if q.Tag != "" {
+		add("tags @> ARRAY[$%d::text]", q.Tag)
+	}
+	if q.URLContains != "" {
+		add("host ILIKE '%%' || $%d || '%%'", likeEscaper.Replace(q.URLContains))
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// checkSortKey renders c's value of the sort expression so it casts back to
+// the same value in the next page's query.
+func checkSortKey(sort check.SortField, c *check.Check) string {
+	switch sort {
+	case check.SortName:
+		return c.Name
+	case check.SortStatusChanged:
+		if c.StatusChangedAt == nil {
+			return "-infinity"
+		}
+		return c.StatusChangedAt.Format(time.RFC3339Nano)
+	case check.SortNextRun:
+		return c.NextRun.Format(time.RFC3339Nano)
+	default:
+		return ""
+	}
+}
+
+func (r *CheckRepoImpl) Update(ctx context.Context, c *check.Check) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
 
 	eq := r.db.execQueryer(ctx)
-	_, err := eq.Exec(ctx, q, c.ID, c.LastStatus)
+	_, err := eq.Exec(ctx, qUpdateStatus, c.ID, c.LastStatus)
 	return err
 }
 
+func (r *CheckRepoImpl) Patch(ctx context.Context, id int64, p check.Patch, version int64) (*check.Check, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var intervalSec, graceSec *int
+	if p.Interval != nil {
+		sec := int(*p.Interval / time.Second)
+		intervalSec = &sec
+	}
+	if p.Grace != nil {
+		sec := int(*p.Grace / time.Second)
+		graceSec = &sec
+	}
+
+	var c check.Check
+	err := scanFull(r.db.execQueryer(ctx).QueryRow(ctx, qPatch, id, p.URL, intervalSec, version, p.Name, p.Tags, graceSec, p.Active), &c)
+	if errors.Is(err, ErrNotFound) {
+		// the row is either gone or was edited since version
+		var exists bool
+		if err := r.db.execQueryer(ctx).QueryRow(ctx, qExists, id).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("check exists: %w", err)
+		}
+		if exists {
+			return nil, ErrConflict
+		}
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
 func (r *CheckRepoImpl) Delete(ctx context.Context, id int64) error {
 	ctx, cancel := r.db.withTimeout(ctx)
 	defer cancel()
@@ -205,3 +475,66 @@ func (r *CheckRepoImpl) FetchDue(ctx context.Context, limit int) ([]*check.Check
 	}
 	return out, nil
 }
+
+func (r *CheckRepoImpl) LockByID(ctx context.Context, id int64) (*check.Check, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var c check.Check
+	if err := scanFull(r.db.execQueryer(ctx).QueryRow(ctx, qLockByID, id), &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *CheckRepoImpl) LockByPingToken(ctx context.Context, token string) (*check.Check, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var c check.Check
+	if err := scanFull(r.db.execQueryer(ctx).QueryRow(ctx, qLockByPingToken, token), &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *CheckRepoImpl) RecordPing(ctx context.Context, id int64, p check.Ping) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.execQueryer(ctx).Exec(ctx, qRecordPing, id, p.Status, p.At, p.StartedAt, p.NextRun)
+	if err != nil {
+		return fmt.Errorf("record ping: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *CheckRepoImpl) LockMissedHeartbeats(ctx context.Context, limit int) ([]*check.Check, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.execQueryer(ctx).Query(ctx, qLockMissedHeartbeats, limit)
+	if err != nil {
+		return nil, fmt.Errorf("lock missed heartbeats: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*check.Check
+	for rows.Next() {
+		var c check.Check
+		if err := scanFull(rows, &c); err != nil {
+			return nil, err
+		}
+		out = append(out, &c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/NordCoder/Pingerus/internal/domain/auth"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var _ auth.IdentityRepo = (*IdentityRepo)(nil)
+var _ auth.OIDCStateRepo = (*OIDCStateRepo)(nil)
+
+type IdentityRepo struct{ db *DB }
+
+func NewIdentityRepo(db *DB) *IdentityRepo { return &IdentityRepo{db: db} }
+
+const (
+	qIdentityFind = `
+SELECT id, user_id, provider, subject, email, created_at
+FROM user_identities
+WHERE provider = $1 AND subject = $2;`
+
+	qIdentityInsert = `
+INSERT INTO user_identities (user_id, provider, subject, email)
+VALUES ($1, $2, $3, $4)
+RETURNING id, created_at;`
+)
+
+func (r *IdentityRepo) Find(ctx context.Context, provider, subject string) (*auth.Identity, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var i auth.Identity
+	if err := r.db.Pool.QueryRow(ctx, qIdentityFind, provider, subject).
+		Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("find identity: %w", err)
+	}
+	return &i, nil
+}
+
+func (r *IdentityRepo) Create(ctx context.Context, i *auth.Identity) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	if err := r.db.execQueryer(ctx).QueryRow(ctx, qIdentityInsert, i.UserID, i.Provider, i.Subject, i.Email).
+		Scan(&i.ID, &i.CreatedAt); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrConflict
+		}
+		return fmt.Errorf("insert identity: %w", err)
+	}
+	return nil
+}
+
+type OIDCStateRepo struct{ db *DB }
+
+func NewOIDCStateRepo(db *DB) *OIDCStateRepo { return &OIDCStateRepo{db: db} }
+
+const (
+	qOIDCStatePrune = `DELETE FROM oidc_login_states WHERE expires_at < now();`
+
+	qOIDCStateInsert = `
+INSERT INTO oidc_login_states (state_hash, provider, nonce, code_verifier, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id;`
+
+	qOIDCStateConsume = `
+DELETE FROM oidc_login_states
+WHERE state_hash = $1 AND expires_at > now()
+RETURNING id, state_hash, provider, nonce, code_verifier, expires_at;`
+)
+
+// Create also drops expired states, which abandoned logins leave behind.
+func (r *OIDCStateRepo) Create(ctx context.Context, s *auth.OIDCState) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := r.db.Pool.Exec(ctx, qOIDCStatePrune); err != nil {
+		return fmt.Errorf("prune oidc states: %w", err)
+	}
+	if err := r.db.Pool.QueryRow(ctx, qOIDCStateInsert, s.StateHash, s.Provider, s.Nonce, s.CodeVerifier, s.ExpiresAt).
+		Scan(&s.ID); err != nil {
+		return fmt.Errorf("insert oidc state: %w", err)
+	}
+	return nil
+}
+
+func (r *OIDCStateRepo) Consume(ctx context.Context, stateHash string) (*auth.OIDCState, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var s auth.OIDCState
+	if err := r.db.Pool.QueryRow(ctx, qOIDCStateConsume, stateHash).
+		Scan(&s.ID, &s.StateHash, &s.Provider, &s.Nonce, &s.CodeVerifier, &s.ExpiresAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("consume oidc state: %w", err)
+	}
+	return &s, nil
+}
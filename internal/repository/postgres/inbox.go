@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/NordCoder/Pingerus/internal/domain/inbox"
+)
+
+var _ inbox.Repo = (*InboxRepo)(nil)
+
+type InboxRepo struct{ db *DB }
+
+func NewInboxRepo(db *DB) *InboxRepo { return &InboxRepo{db: db} }
+
+const (
+	qInboxClaim = `
+INSERT INTO inbox (consumer, topic, partition, kafka_offset)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT DO NOTHING;`
+
+	qInboxPrune = `
+DELETE FROM inbox
+WHERE (consumer, topic, partition, kafka_offset) IN (
+    SELECT consumer, topic, partition, kafka_offset
+    FROM inbox
+    WHERE (consumer = $1 OR starts_with(consumer, $1::text || '/')) AND processed_at < $2
+    LIMIT $3
+);`
+)
+
+func (r *InboxRepo) Claim(ctx context.Context, consumer, topic string, partition int, offset int64) (bool, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	eq := r.db.execQueryer(ctx)
+	tag, err := eq.Exec(ctx, qInboxClaim, consumer, topic, partition, offset)
+	if err != nil {
+		return false, fmt.Errorf("inbox claim: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *InboxRepo) Prune(ctx context.Context, consumer string, before time.Time, limit int) (int64, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.execQueryer(ctx).Exec(ctx, qInboxPrune, consumer, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("inbox prune: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// Listener holds a dedicated connection LISTENing on a channel and turns
+// notifications into non-blocking wake-ups. Notifications are coalesced:
+// a pending wake-up is never duplicated.
+type Listener struct {
+	db      *DB
+	channel string
+	log     *zap.Logger
+}
+
+func NewListener(db *DB, channel string, log *zap.Logger) *Listener {
+	if log == nil {
+		log = zap.L()
+	}
+	return &Listener{
+		db:      db,
+		channel: channel,
+		log:     log.With(zap.String("component", "pg.listener"), zap.String("channel", channel)),
+	}
+}
+
+// Run blocks until ctx is done, reconnecting with backoff on connection errors.
+func (l *Listener) Run(ctx context.Context, wake chan<- struct{}) error {
+	backoff := 200 * time.Millisecond
+	const maxBackoff = 5 * time.Second
+
+	for {
+		err := l.listen(ctx, wake)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		l.log.Warn("listen failed; reconnecting", zap.Error(err), zap.Duration("backoff", backoff))
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		if backoff < maxBackoff {
+			backoff *= 2
+		}
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func (l *Listener) listen(ctx context.Context, wake chan<- struct{}) error {
+	pc, err := l.db.Pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire: %w", err)
+	}
+	// a LISTENing connection must not go back to the pool
+	conn := pc.Hijack()
+	defer func() { _ = conn.Close(context.Background()) }()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{l.channel}.Sanitize()); err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	l.log.Info("listening")
+
+	// anything enqueued while we were disconnected is picked up right away
+	wakeUp(wake)
+
+	for {
+		if _, err := conn.WaitForNotification(ctx); err != nil {
+			return fmt.Errorf("wait notification: %w", err)
+		}
+		wakeUp(wake)
+	}
+}
+
+func wakeUp(wake chan<- struct{}) {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,209 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/NordCoder/Pingerus/internal/domain/auth"
+	"github.com/jackc/pgx/v5"
+)
+
+var _ auth.MFARepo = (*MFARepo)(nil)
+
+type MFARepo struct{ db *DB }
+
+func NewMFARepo(db *DB) *MFARepo { return &MFARepo{db: db} }
+
+const (
+	qTOTPGet = `
+SELECT user_id, secret_enc, enabled_at, last_used_step, created_at
+FROM user_totp
+WHERE user_id = $1;`
+
+	qTOTPSavePending = `
+INSERT INTO user_totp (user_id, secret_enc)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET secret_enc = EXCLUDED.secret_enc, last_used_step = 0, created_at = now()
+WHERE user_totp.enabled_at IS NULL;`
+
+	qTOTPEnable = `
+UPDATE user_totp SET enabled_at = now(), last_used_step = $2
+WHERE user_id = $1 AND enabled_at IS NULL;`
+
+	qTOTPDelete = `DELETE FROM user_totp WHERE user_id = $1;`
+
+	qTOTPUseStep = `
+UPDATE user_totp SET last_used_step = $2
+WHERE user_id = $1 AND last_used_step < $2;`
+
+	qRecoveryDelete = `DELETE FROM mfa_recovery_codes WHERE user_id = $1;`
+
+	qRecoveryInsert = `
+INSERT INTO mfa_recovery_codes (user_id, code_hash)
+SELECT $1, unnest($2::text[]);`
+
+	qRecoveryUse = `
+UPDATE mfa_recovery_codes SET used_at = now()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;`
+
+	qChallengeInsert = `
+INSERT INTO mfa_challenges (user_id, token_hash, expires_at)
+VALUES ($1, $2, $3)
+RETURNING id;`
+
+	qChallengeGet = `
+SELECT id, user_id, token_hash, attempts, expires_at
+FROM mfa_challenges
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now();`
+
+	qChallengeFail = `
+UPDATE mfa_challenges SET attempts = attempts + 1
+WHERE id = $1
+RETURNING attempts;`
+
+	qChallengeConsume = `
+UPDATE mfa_challenges SET used_at = now()
+WHERE id = $1 AND used_at IS NULL;`
+)
+
+func (r *MFARepo) GetTOTP(ctx context.Context, userID int64) (*auth.TOTP, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var t auth.TOTP
+	if err := r.db.Pool.QueryRow(ctx, qTOTPGet, userID).
+		Scan(&t.UserID, &t.SecretEnc, &t.EnabledAt, &t.LastUsedStep, &t.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get totp: %w", err)
+	}
+	return &t, nil
+}
+
+func (r *MFARepo) SavePendingTOTP(ctx context.Context, userID int64, secretEnc []byte) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.Pool.Exec(ctx, qTOTPSavePending, userID, secretEnc)
+	if err != nil {
+		return fmt.Errorf("save totp: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *MFARepo) EnableTOTP(ctx context.Context, userID int64, step int64) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.execQueryer(ctx).Exec(ctx, qTOTPEnable, userID, step)
+	if err != nil {
+		return fmt.Errorf("enable totp: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *MFARepo) DeleteTOTP(ctx context.Context, userID int64) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	eq := r.db.execQueryer(ctx)
+	if _, err := eq.Exec(ctx, qTOTPDelete, userID); err != nil {
+		return fmt.Errorf("delete totp: %w", err)
+	}
+	if _, err := eq.Exec(ctx, qRecoveryDelete, userID); err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+	return nil
+}
+
+func (r *MFARepo) UseStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.Pool.Exec(ctx, qTOTPUseStep, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("use totp step: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *MFARepo) ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes []string) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	eq := r.db.execQueryer(ctx)
+	if _, err := eq.Exec(ctx, qRecoveryDelete, userID); err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+	if _, err := eq.Exec(ctx, qRecoveryInsert, userID, hashes); err != nil {
+		return fmt.Errorf("insert recovery codes: %w", err)
+	}
+	return nil
+}
+
+func (r *MFARepo) UseRecoveryCode(ctx context.Context, userID int64, hash string) (bool, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.Pool.Exec(ctx, qRecoveryUse, userID, hash)
+	if err != nil {
+		return false, fmt.Errorf("use recovery code: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *MFARepo) CreateChallenge(ctx context.Context, c *auth.MFAChallenge) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	if err := r.db.Pool.QueryRow(ctx, qChallengeInsert, c.UserID, c.TokenHash, c.ExpiresAt).Scan(&c.ID); err != nil {
+		return fmt.Errorf("insert mfa challenge: %w", err)
+	}
+	return nil
+}
+
+func (r *MFARepo) GetChallenge(ctx context.Context, tokenHash string) (*auth.MFAChallenge, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var c auth.MFAChallenge
+	if err := r.db.Pool.QueryRow(ctx, qChallengeGet, tokenHash).
+		Scan(&c.ID, &c.UserID, &c.TokenHash, &c.Attempts, &c.ExpiresAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get mfa challenge: %w", err)
+	}
+	return &c, nil
+}
+
+func (r *MFARepo) FailChallenge(ctx context.Context, id int64) (int, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var n int
+	if err := r.db.Pool.QueryRow(ctx, qChallengeFail, id).Scan(&n); err != nil {
+		return 0, fmt.Errorf("fail mfa challenge: %w", err)
+	}
+	return n, nil
+}
+
+func (r *MFARepo) ConsumeChallenge(ctx context.Context, id int64) (bool, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.Pool.Exec(ctx, qChallengeConsume, id)
+	if err != nil {
+		return false, fmt.Errorf("consume mfa challenge: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
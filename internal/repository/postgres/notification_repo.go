@@ -32,7 +32,8 @@ func (r *NotificationRepoImpl) Create(ctx context.Context, n *notification.Notif
 	ctx, cancel := r.db.withTimeout(ctx)
 	defer cancel()
 
-	if err := r.db.Pool.QueryRow(ctx, qNotifInsert,
+	eq := r.db.execQueryer(ctx)
+	if err := eq.QueryRow(ctx, qNotifInsert,
 		n.CheckID,
 		n.UserID,
 		n.Type,
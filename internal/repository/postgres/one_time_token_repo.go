@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/NordCoder/Pingerus/internal/domain/auth"
+	"github.com/jackc/pgx/v5"
+)
+
+var _ auth.OneTimeTokenRepo = (*OneTimeTokenRepo)(nil)
+
+// OneTimeTokenRepo serves the single-use token tables, which share a layout.
+type OneTimeTokenRepo struct {
+	db *DB
+
+	qCreate     string
+	qConsume    string
+	qInvalidate string
+}
+
+func NewEmailVerificationTokenRepo(db *DB) *OneTimeTokenRepo {
+	return newOneTimeTokenRepo(db, "email_verification_tokens")
+}
+
+func NewPasswordResetTokenRepo(db *DB) *OneTimeTokenRepo {
+	return newOneTimeTokenRepo(db, "password_reset_tokens")
+}
+
+func newOneTimeTokenRepo(db *DB, table string) *OneTimeTokenRepo {
+	return &OneTimeTokenRepo{
+		db: db,
+		qCreate: fmt.Sprintf(`
+INSERT INTO %s (user_id, email, token_hash, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING id, created_at;`, table),
+		qConsume: fmt.Sprintf(`
+UPDATE %s
+SET used_at = now()
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+RETURNING id, user_id, email, token_hash, created_at, expires_at, used_at;`, table),
+		qInvalidate: fmt.Sprintf(`
+UPDATE %s SET used_at = now() WHERE user_id = $1 AND used_at IS NULL;`, table),
+	}
+}
+
+func (r *OneTimeTokenRepo) Create(ctx context.Context, t *auth.OneTimeToken) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	if err := r.db.execQueryer(ctx).QueryRow(ctx, r.qCreate, t.UserID, t.Email, t.TokenHash, t.ExpiresAt).
+		Scan(&t.ID, &t.CreatedAt); err != nil {
+		return fmt.Errorf("insert token: %w", err)
+	}
+	return nil
+}
+
+func (r *OneTimeTokenRepo) Consume(ctx context.Context, tokenHash string) (*auth.OneTimeToken, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var t auth.OneTimeToken
+	if err := r.db.execQueryer(ctx).QueryRow(ctx, r.qConsume, tokenHash).
+		Scan(&t.ID, &t.UserID, &t.Email, &t.TokenHash, &t.CreatedAt, &t.ExpiresAt, &t.UsedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("consume token: %w", err)
+	}
+	return &t, nil
+}
+
+func (r *OneTimeTokenRepo) InvalidateUser(ctx context.Context, userID int64) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := r.db.execQueryer(ctx).Exec(ctx, r.qInvalidate, userID); err != nil {
+		return fmt.Errorf("invalidate tokens: %w", err)
+	}
+	return nil
+}
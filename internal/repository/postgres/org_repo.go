@@ -0,0 +1,232 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/NordCoder/Pingerus/internal/domain/org"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var _ org.Repo = (*OrgRepo)(nil)
+
+type OrgRepo struct{ db *DB }
+
+func NewOrgRepo(db *DB) *OrgRepo { return &OrgRepo{db: db} }
+
+const (
+	qOrgInsert = `
+WITH o AS (
+    INSERT INTO orgs (name) VALUES ($1)
+    RETURNING id, created_at
+), m AS (
+    INSERT INTO org_members (org_id, user_id, role)
+    SELECT id, $2, 'owner' FROM o
+)
+SELECT id, created_at FROM o;`
+
+	qOrgByID = `
+SELECT id, name, personal_owner_id IS NOT NULL, created_at
+FROM orgs
+WHERE id = $1;`
+
+	qOrgDelete = `DELETE FROM orgs WHERE id = $1;`
+
+	qOrgsForUser = `
+SELECT o.id, o.name, o.personal_owner_id IS NOT NULL, o.created_at, m.role
+FROM org_members m
+JOIN orgs o ON o.id = m.org_id
+WHERE m.user_id = $1
+ORDER BY o.personal_owner_id IS NULL, o.name, o.id;`
+
+	qOrgPersonal = `SELECT id FROM orgs WHERE personal_owner_id = $1;`
+
+	qOrgMember = `
+SELECT m.org_id, m.user_id, u.email, m.role, m.created_at
+FROM org_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.org_id = $1 AND m.user_id = $2;`
+
+	qOrgMembers = `
+SELECT m.org_id, m.user_id, u.email, m.role, m.created_at
+FROM org_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.org_id = $1
+ORDER BY m.created_at, m.user_id;`
+
+	qOrgMemberInsert = `
+INSERT INTO org_members (org_id, user_id, role)
+VALUES ($1, $2, $3);`
+
+	// the last owner is never demoted or removed
+	qOrgMemberSetRole = `
+UPDATE org_members SET role = $3
+WHERE org_id = $1 AND user_id = $2
+  AND (role <> 'owner' OR $3 = 'owner'
+       OR (SELECT count(*) FROM org_members WHERE org_id = $1 AND role = 'owner') > 1);`
+
+	qOrgMemberDelete = `
+DELETE FROM org_members
+WHERE org_id = $1 AND user_id = $2
+  AND (role <> 'owner'
+       OR (SELECT count(*) FROM org_members WHERE org_id = $1 AND role = 'owner') > 1);`
+)
+
+func (r *OrgRepo) Create(ctx context.Context, o *org.Org, ownerID int64) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	if err := r.db.execQueryer(ctx).QueryRow(ctx, qOrgInsert, o.Name, ownerID).Scan(&o.ID, &o.CreatedAt); err != nil {
+		return fmt.Errorf("insert org: %w", err)
+	}
+	return nil
+}
+
+func (r *OrgRepo) GetByID(ctx context.Context, id int64) (*org.Org, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var o org.Org
+	if err := r.db.Pool.QueryRow(ctx, qOrgByID, id).Scan(&o.ID, &o.Name, &o.Personal, &o.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get org: %w", err)
+	}
+	return &o, nil
+}
+
+func (r *OrgRepo) Delete(ctx context.Context, id int64) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.Pool.Exec(ctx, qOrgDelete, id)
+	if err != nil {
+		return fmt.Errorf("delete org: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *OrgRepo) ListForUser(ctx context.Context, userID int64) ([]*org.Membership, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.Pool.Query(ctx, qOrgsForUser, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query orgs: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*org.Membership
+	for rows.Next() {
+		var m org.Membership
+		if err := rows.Scan(&m.Org.ID, &m.Org.Name, &m.Org.Personal, &m.Org.CreatedAt, &m.Role); err != nil {
+			return nil, fmt.Errorf("scan org: %w", err)
+		}
+		out = append(out, &m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return out, nil
+}
+
+func (r *OrgRepo) PersonalOrgID(ctx context.Context, userID int64) (int64, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var id int64
+	if err := r.db.Pool.QueryRow(ctx, qOrgPersonal, userID).Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrNotFound
+		}
+		return 0, fmt.Errorf("personal org: %w", err)
+	}
+	return id, nil
+}
+
+func (r *OrgRepo) GetMember(ctx context.Context, orgID, userID int64) (*org.Member, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var m org.Member
+	if err := r.db.Pool.QueryRow(ctx, qOrgMember, orgID, userID).
+		Scan(&m.OrgID, &m.UserID, &m.Email, &m.Role, &m.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get org member: %w", err)
+	}
+	return &m, nil
+}
+
+func (r *OrgRepo) ListMembers(ctx context.Context, orgID int64) ([]*org.Member, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.Pool.Query(ctx, qOrgMembers, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("query org members: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*org.Member
+	for rows.Next() {
+		var m org.Member
+		if err := rows.Scan(&m.OrgID, &m.UserID, &m.Email, &m.Role, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan org member: %w", err)
+		}
+		out = append(out, &m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return out, nil
+}
+
+func (r *OrgRepo) AddMember(ctx context.Context, orgID, userID int64, role org.Role) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := r.db.Pool.Exec(ctx, qOrgMemberInsert, orgID, userID, string(role)); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrConflict
+		}
+		return fmt.Errorf("insert org member: %w", err)
+	}
+	return nil
+}
+
+func (r *OrgRepo) SetRole(ctx context.Context, orgID, userID int64, role org.Role) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.Pool.Exec(ctx, qOrgMemberSetRole, orgID, userID, string(role))
+	if err != nil {
+		return fmt.Errorf("set org role: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *OrgRepo) RemoveMember(ctx context.Context, orgID, userID int64) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.Pool.Exec(ctx, qOrgMemberDelete, orgID, userID)
+	if err != nil {
+		return fmt.Errorf("remove org member: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrConflict
+	}
+	return nil
+}
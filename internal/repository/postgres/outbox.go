@@ -11,16 +11,29 @@ import (
 	"github.com/NordCoder/Pingerus/internal/domain/outbox"
 )
 
-type OutboxRepo struct{ db *DB }
+type OutboxRepo struct {
+	db      *DB
+	channel string
+}
 
 func NewOutboxRepo(db *DB) *OutboxRepo { return &OutboxRepo{db: db} }
 
+// WithNotify makes Enqueue issue pg_notify on channel in the same transaction,
+// so listeners are woken only once the message is committed.
+func (r *OutboxRepo) WithNotify(channel string) *OutboxRepo {
+	cp := *r
+	cp.channel = channel
+	return &cp
+}
+
 const (
 	qEnqueue = `
 INSERT INTO outbox (idempotency_key, data, status, kind, traceparent, tracestate, baggage)
 VALUES ($1, $2, 'CREATED', $3, $4, $5, $6)
 ON CONFLICT (idempotency_key) DO NOTHING;`
 
+	qNotify = `SELECT pg_notify($1, $2);`
+
 	qPickLocked = `
 WITH cand AS (
   SELECT idempotency_key
@@ -49,6 +62,12 @@ FROM upd;`
 	qMarkSuccess = `
 UPDATE outbox
 SET status = 'SUCCESS', updated_at = now()
+WHERE idempotency_key = ANY($1)
+  AND status = 'IN_PROGRESS';`
+
+	qMarkUnknownKind = `
+UPDATE outbox
+SET status = 'UNKNOWN_KIND', updated_at = now()
 WHERE idempotency_key = ANY($1)
   AND status = 'IN_PROGRESS';`
 )
@@ -61,8 +80,16 @@ func (r *OutboxRepo) Enqueue(ctx context.Context, key string, kind outbox.Kind,
 	otel.GetTextMapPropagator().Inject(ctx, carrier)
 
 	eq := r.db.execQueryer(ctx)
-	_, err := eq.Exec(ctx, qEnqueue, key, data, kind, carrier["traceparent"], carrier["tracestate"], carrier["baggage"])
-	return err
+	if _, err := eq.Exec(ctx, qEnqueue, key, data, kind, carrier["traceparent"], carrier["tracestate"], carrier["baggage"]); err != nil {
+		return err
+	}
+	if r.channel == "" {
+		return nil
+	}
+	if _, err := eq.Exec(ctx, qNotify, r.channel, key); err != nil {
+		return fmt.Errorf("outbox notify: %w", err)
+	}
+	return nil
 }
 
 func (r *OutboxRepo) PickBatch(ctx context.Context, batch int, inProgressTTL time.Duration) ([]outbox.Message, error) {
@@ -121,3 +148,16 @@ func (r *OutboxRepo) MarkSuccess(ctx context.Context, keys []string) error {
 	_ = tag
 	return nil
 }
+
+func (r *OutboxRepo) MarkUnknownKind(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := r.db.Pool.Exec(ctx, qMarkUnknownKind, keys); err != nil {
+		return fmt.Errorf("outbox mark unknown kind: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NordCoder/Pingerus/internal/domain/plan"
+	"github.com/jackc/pgx/v5"
+)
+
+var _ plan.Repo = (*PlanRepo)(nil)
+
+type PlanRepo struct{ db *DB }
+
+func NewPlanRepo(db *DB) *PlanRepo { return &PlanRepo{db: db} }
+
+const (
+	qPlanOf = `SELECT COALESCE(plan, '') FROM users WHERE id = $1;`
+
+	// hashing the name with the id as seed keeps the key apart from other
+	// advisory locks and takes ids of any size
+	qLockUsage = `SELECT pg_advisory_xact_lock(hashtextextended('check_quota', $1::bigint));`
+
+	qCountUserChecks = `SELECT count(*) FROM checks WHERE user_id = $1;`
+
+	qPruneRuns = `
+DELETE FROM runs
+WHERE id IN (
+    SELECT r.id
+    FROM runs r
+    JOIN checks c ON c.id = r.check_id
+    JOIN users u ON u.id = c.user_id
+    WHERE r.ts < $1
+      AND CASE WHEN u.plan = ANY($3) THEN u.plan ELSE $4 END = $2
+    LIMIT $5
+);`
+)
+
+func (r *PlanRepo) PlanOf(ctx context.Context, userID int64) (string, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var name string
+	if err := r.db.execQueryer(ctx).QueryRow(ctx, qPlanOf, userID).Scan(&name); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("get plan: %w", err)
+	}
+	return name, nil
+}
+
+func (r *PlanRepo) LockUsage(ctx context.Context, userID int64) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := r.db.execQueryer(ctx).Exec(ctx, qLockUsage, userID); err != nil {
+		return fmt.Errorf("lock usage: %w", err)
+	}
+	return nil
+}
+
+func (r *PlanRepo) CountChecks(ctx context.Context, userID int64) (int64, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var n int64
+	if err := r.db.execQueryer(ctx).QueryRow(ctx, qCountUserChecks, userID).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count checks: %w", err)
+	}
+	return n, nil
+}
+
+func (r *PlanRepo) PruneRuns(ctx context.Context, plan, def string, known []string, before time.Time, limit int) (int64, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.Pool.Exec(ctx, qPruneRuns, before, plan, known, def, limit)
+	if err != nil {
+		return 0, fmt.Errorf("prune runs: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -2,8 +2,11 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
+
 	"github.com/NordCoder/Pingerus/internal/domain/auth"
+	"github.com/jackc/pgx/v5"
 )
 
 type RefreshTokenRepo struct{ db *DB }
@@ -11,19 +14,63 @@ type RefreshTokenRepo struct{ db *DB }
 func NewRefreshTokenRepo(db *DB) *RefreshTokenRepo { return &RefreshTokenRepo{db: db} }
 
 const (
+	// a token issued into a family that reuse revoked meanwhile is born
+	// revoked, so a Refresh racing the replay cannot outlive it
 	qRTCreate = `
-INSERT INTO refresh_tokens(user_id, token_hash, issued_at, expires_at, revoked)
-VALUES ($1, $2, $3, $4, FALSE)
-RETURNING id;
+WITH fam AS (
+    SELECT f.id, EXISTS (SELECT 1 FROM refresh_tokens r WHERE r.family_id = f.id AND r.revoke_reason = 'reuse') AS reused
+    FROM (SELECT COALESCE(NULLIF($2::text, '')::uuid, gen_random_uuid()) AS id) f
+)
+INSERT INTO refresh_tokens(user_id, family_id, token_hash, issued_at, expires_at, revoked, revoke_reason,
+                           user_agent, ip_address, last_refresh_at)
+SELECT $1::int, fam.id, $3::text, $4::timestamptz, $5::timestamptz, fam.reused, CASE WHEN fam.reused THEN 'reuse' END,
+       NULLIF($6::text, ''), NULLIF($7::text, '')::inet, $8::timestamptz
+FROM fam
+RETURNING id, family_id::text;
 `
 	qRTFindValid = `
-SELECT id, user_id, token_hash, issued_at, expires_at, revoked
+SELECT id, user_id, family_id::text, token_hash, issued_at, expires_at, revoked, COALESCE(revoke_reason, '')
 FROM refresh_tokens
 WHERE token_hash = $1 AND revoked = FALSE AND expires_at > NOW()
 LIMIT 1;
+`
+	qRTFindByHash = `
+SELECT id, user_id, family_id::text, token_hash, issued_at, expires_at, revoked, COALESCE(revoke_reason, '')
+FROM refresh_tokens
+WHERE token_hash = $1
+LIMIT 1;
 `
 	qRTRevoke = `
-UPDATE refresh_tokens SET revoked=TRUE WHERE token_hash = $1;
+UPDATE refresh_tokens SET revoked=TRUE, revoke_reason='logout' WHERE token_hash = $1 AND revoked = FALSE;
+`
+	qRTRevokeActive = `
+UPDATE refresh_tokens SET revoked=TRUE, revoke_reason='rotated' WHERE token_hash = $1 AND revoked = FALSE;
+`
+	qRTRevokeFamily = `
+UPDATE refresh_tokens SET revoked=TRUE, revoke_reason='reuse' WHERE family_id = $1::uuid AND revoked = FALSE;
+`
+	qRTRevokeAllForUser = `
+UPDATE refresh_tokens SET revoked=TRUE, revoke_reason='revoked' WHERE user_id = $1 AND revoked = FALSE;
+`
+	qRTListSessions = `
+SELECT t.family_id::text, t.user_id, COALESCE(t.user_agent, ''), COALESCE(host(t.ip_address), ''),
+       (SELECT MIN(f.issued_at) FROM refresh_tokens f WHERE f.family_id = t.family_id),
+       t.last_refresh_at, t.expires_at
+FROM refresh_tokens t
+WHERE t.user_id = $1 AND t.revoked = FALSE AND t.expires_at > NOW()
+ORDER BY COALESCE(t.last_refresh_at, t.issued_at) DESC;
+`
+	qRTRevokeSession = `
+UPDATE refresh_tokens SET revoked=TRUE, revoke_reason='revoked'
+WHERE user_id = $1 AND family_id = $2::uuid AND revoked = FALSE;
+`
+	qRTRevokeOtherSessions = `
+WITH revoked AS (
+    UPDATE refresh_tokens SET revoked=TRUE, revoke_reason='revoked'
+    WHERE user_id = $1 AND family_id <> $2::uuid AND revoked = FALSE
+    RETURNING family_id
+)
+SELECT COUNT(DISTINCT family_id) FROM revoked;
 `
 )
 
@@ -31,22 +78,32 @@ func (r *RefreshTokenRepo) Create(ctx context.Context, t *auth.RefreshToken) err
 	ctx, cancel := r.db.withTimeout(ctx)
 	defer cancel()
 
-	return r.db.Pool.QueryRow(ctx, qRTCreate, t.UserID, t.TokenHash, t.IssuedAt, t.ExpiresAt).Scan(&t.ID)
+	return r.db.Pool.QueryRow(ctx, qRTCreate,
+		t.UserID, t.FamilyID, t.TokenHash, t.IssuedAt, t.ExpiresAt,
+		t.UserAgent, t.IP, t.LastRefreshAt,
+	).Scan(&t.ID, &t.FamilyID)
 }
 
 func (r *RefreshTokenRepo) FindValid(ctx context.Context, tokenHash string) (*auth.RefreshToken, error) {
 	ctx, cancel := r.db.withTimeout(ctx)
 	defer cancel()
 
-	var t auth.RefreshToken
-	if err := r.db.Pool.QueryRow(ctx, qRTFindValid, tokenHash).
-		Scan(&t.ID, &t.UserID, &t.TokenHash, &t.IssuedAt, &t.ExpiresAt, &t.Revoked); err != nil {
-		if err.Error() == "no rows in result set" {
-			return nil, ErrNotFound
-		}
+	t, err := scanRefreshToken(r.db.Pool.QueryRow(ctx, qRTFindValid, tokenHash))
+	if err != nil {
 		return nil, fmt.Errorf("find valid refresh: %w", err)
 	}
-	return &t, nil
+	return t, nil
+}
+
+func (r *RefreshTokenRepo) FindByHash(ctx context.Context, tokenHash string) (*auth.RefreshToken, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	t, err := scanRefreshToken(r.db.Pool.QueryRow(ctx, qRTFindByHash, tokenHash))
+	if err != nil {
+		return nil, fmt.Errorf("find refresh: %w", err)
+	}
+	return t, nil
 }
 
 func (r *RefreshTokenRepo) Revoke(ctx context.Context, tokenHash string) error {
@@ -59,3 +116,93 @@ func (r *RefreshTokenRepo) Revoke(ctx context.Context, tokenHash string) error {
 	}
 	return nil
 }
+
+func (r *RefreshTokenRepo) RevokeActive(ctx context.Context, tokenHash string) (bool, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.Pool.Exec(ctx, qRTRevokeActive, tokenHash)
+	if err != nil {
+		return false, fmt.Errorf("revoke refresh: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *RefreshTokenRepo) RevokeFamily(ctx context.Context, familyID string) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	_, err := r.db.Pool.Exec(ctx, qRTRevokeFamily, familyID)
+	if err != nil {
+		return fmt.Errorf("revoke refresh family: %w", err)
+	}
+	return nil
+}
+
+func (r *RefreshTokenRepo) RevokeAllForUser(ctx context.Context, userID int64) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	_, err := r.db.Pool.Exec(ctx, qRTRevokeAllForUser, userID)
+	if err != nil {
+		return fmt.Errorf("revoke user refresh: %w", err)
+	}
+	return nil
+}
+
+func (r *RefreshTokenRepo) ListSessions(ctx context.Context, userID int64) ([]*auth.Session, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.Pool.Query(ctx, qRTListSessions, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*auth.Session
+	for rows.Next() {
+		var s auth.Session
+		if err := rows.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastRefreshAt, &s.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("scan session: %w", err)
+		}
+		out = append(out, &s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return out, nil
+}
+
+func (r *RefreshTokenRepo) RevokeSession(ctx context.Context, userID int64, familyID string) (bool, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.Pool.Exec(ctx, qRTRevokeSession, userID, familyID)
+	if err != nil {
+		return false, fmt.Errorf("revoke session: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *RefreshTokenRepo) RevokeOtherSessions(ctx context.Context, userID int64, keepFamilyID string) (int64, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var n int64
+	if err := r.db.Pool.QueryRow(ctx, qRTRevokeOtherSessions, userID, keepFamilyID).Scan(&n); err != nil {
+		return 0, fmt.Errorf("revoke other sessions: %w", err)
+	}
+	return n, nil
+}
+
+func scanRefreshToken(row pgx.Row) (*auth.RefreshToken, error) {
+	var t auth.RefreshToken
+	if err := row.Scan(&t.ID, &t.UserID, &t.FamilyID, &t.TokenHash, &t.IssuedAt, &t.ExpiresAt, &t.Revoked, &t.RevokeReason); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &t, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/NordCoder/Pingerus/internal/domain/run"
+	"time"
 )
 
 var _ run.Repo = (*RunRepoImpl)(nil)
@@ -24,6 +25,18 @@ FROM runs
 WHERE check_id = $1
 ORDER BY ts DESC
 LIMIT $2;
+`
+	qRunsDailyUptime = `
+SELECT check_id, (ts AT TIME ZONE 'UTC')::date AS day, count(*), count(*) FILTER (WHERE status)
+FROM runs
+WHERE check_id = ANY($1) AND ts >= $2
+GROUP BY check_id, day
+ORDER BY check_id, day;
+`
+	qRunsUptime = `
+SELECT count(*), count(*) FILTER (WHERE status)
+FROM runs
+WHERE check_id = $1 AND ts >= $2;
 `
 )
 
@@ -65,3 +78,35 @@ func (r *RunRepoImpl) ListByCheck(ctx context.Context, checkID int64, limit int)
 	}
 	return out, nil
 }
+
+func (r *RunRepoImpl) DailyUptime(ctx context.Context, checkIDs []int64, since time.Time) ([]run.Day, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.Pool.Query(ctx, qRunsDailyUptime, checkIDs, since)
+	if err != nil {
+		return nil, fmt.Errorf("query daily uptime: %w", err)
+	}
+	defer rows.Close()
+
+	var out []run.Day
+	for rows.Next() {
+		var d run.Day
+		if err := rows.Scan(&d.CheckID, &d.Day, &d.Total, &d.Up); err != nil {
+			return nil, fmt.Errorf("scan daily uptime: %w", err)
+		}
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return out, nil
+}
+
+func (r *RunRepoImpl) Uptime(ctx context.Context, checkID int64, since time.Time) (total, up int64, err error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	err = r.db.Pool.QueryRow(ctx, qRunsUptime, checkID, since).Scan(&total, &up)
+	return total, up, err
+}
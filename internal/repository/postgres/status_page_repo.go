@@ -0,0 +1,299 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NordCoder/Pingerus/internal/domain/statuspage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var _ statuspage.Repo = (*StatusPageRepo)(nil)
+
+type StatusPageRepo struct{ db *DB }
+
+func NewStatusPageRepo(db *DB) *StatusPageRepo { return &StatusPageRepo{db: db} }
+
+const statusPageColumns = `id, org_id, slug, title, description, logo_url, created_at, updated_at`
+
+const (
+	qStatusPageInsert = `
+INSERT INTO status_pages (org_id, slug, title, description, logo_url)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, created_at, updated_at;`
+
+	qStatusPageByID = `SELECT ` + statusPageColumns + ` FROM status_pages WHERE id = $1;`
+
+	qStatusPageBySlug = `SELECT ` + statusPageColumns + ` FROM status_pages WHERE slug = $1;`
+
+	qStatusPagesByOrgs = `
+SELECT ` + statusPageColumns + `
+FROM status_pages
+WHERE org_id = ANY($1)
+ORDER BY id;`
+
+	qStatusPageUpdate = `
+UPDATE status_pages
+SET slug = $2, title = $3, description = $4, logo_url = $5, updated_at = now()
+WHERE id = $1
+RETURNING updated_at;`
+
+	qStatusPageDelete = `DELETE FROM status_pages WHERE id = $1;`
+
+	qStatusPageChecks = `
+SELECT page_id, check_id, display_name
+FROM status_page_checks
+WHERE page_id = ANY($1)
+ORDER BY page_id, position;`
+
+	qStatusPageChecksClear = `DELETE FROM status_page_checks WHERE page_id = $1;`
+
+	qStatusPageChecksInsert = `
+INSERT INTO status_page_checks (page_id, check_id, display_name, position)
+SELECT $1, c.check_id, c.display_name, c.position
+FROM unnest($2::bigint[], $3::text[]) WITH ORDINALITY AS c(check_id, display_name, position);`
+
+	qStatusPageNoteInsert = `
+INSERT INTO status_page_notes (page_id, title, body)
+VALUES ($1, $2, $3)
+RETURNING id, created_at;`
+
+	qStatusPageNoteResolve = `
+UPDATE status_page_notes
+SET resolved_at = COALESCE(resolved_at, $3)
+WHERE page_id = $1 AND id = $2
+RETURNING id, page_id, title, body, created_at, resolved_at;`
+
+	qStatusPageNoteDelete = `DELETE FROM status_page_notes WHERE page_id = $1 AND id = $2;`
+
+	qStatusPageNotes = `
+SELECT id, page_id, title, body, created_at, resolved_at
+FROM status_page_notes
+WHERE page_id = $1
+ORDER BY created_at DESC, id DESC
+LIMIT $2;`
+)
+
+func (r *StatusPageRepo) Create(ctx context.Context, p *statuspage.Page) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	err := r.db.execQueryer(ctx).QueryRow(ctx, qStatusPageInsert, p.OrgID, p.Slug, p.Title, p.Description, p.LogoURL).
+		Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("insert status page: %w", err)
+	}
+	return nil
+}
+
+func (r *StatusPageRepo) GetByID(ctx context.Context, id int64) (*statuspage.Page, error) {
+	return r.getOne(ctx, qStatusPageByID, id)
+}
+
+func (r *StatusPageRepo) GetBySlug(ctx context.Context, slug string) (*statuspage.Page, error) {
+	return r.getOne(ctx, qStatusPageBySlug, slug)
+}
+
+func (r *StatusPageRepo) getOne(ctx context.Context, q string, arg any) (*statuspage.Page, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.Pool.Query(ctx, q, arg)
+	if err != nil {
+		return nil, fmt.Errorf("query status page: %w", err)
+	}
+	pages, err := r.collect(ctx, rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, ErrNotFound
+	}
+	return pages[0], nil
+}
+
+func (r *StatusPageRepo) ListByOrgs(ctx context.Context, orgIDs []int64) ([]*statuspage.Page, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.Pool.Query(ctx, qStatusPagesByOrgs, orgIDs)
+	if err != nil {
+		return nil, fmt.Errorf("query status pages: %w", err)
+	}
+	return r.collect(ctx, rows)
+}
+
+// collect scans page rows and attaches their checks.
+func (r *StatusPageRepo) collect(ctx context.Context, rows pgx.Rows) ([]*statuspage.Page, error) {
+	var (
+		out  []*statuspage.Page
+		ids  []int64
+		byID = map[int64]*statuspage.Page{}
+	)
+	for rows.Next() {
+		var p statuspage.Page
+		if err := rows.Scan(&p.ID, &p.OrgID, &p.Slug, &p.Title, &p.Description, &p.LogoURL, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan status page: %w", err)
+		}
+		p.Checks = []statuspage.PageCheck{}
+		out = append(out, &p)
+		ids = append(ids, p.ID)
+		byID[p.ID] = &p
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	if len(ids) == 0 {
+		return out, nil
+	}
+
+	crows, err := r.db.Pool.Query(ctx, qStatusPageChecks, ids)
+	if err != nil {
+		return nil, fmt.Errorf("query status page checks: %w", err)
+	}
+	defer crows.Close()
+	for crows.Next() {
+		var (
+			pageID int64
+			c      statuspage.PageCheck
+		)
+		if err := crows.Scan(&pageID, &c.CheckID, &c.DisplayName); err != nil {
+			return nil, fmt.Errorf("scan status page check: %w", err)
+		}
+		p := byID[pageID]
+		p.Checks = append(p.Checks, c)
+	}
+	if err := crows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return out, nil
+}
+
+func (r *StatusPageRepo) Update(ctx context.Context, p *statuspage.Page) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	err := r.db.execQueryer(ctx).QueryRow(ctx, qStatusPageUpdate, p.ID, p.Slug, p.Title, p.Description, p.LogoURL).
+		Scan(&p.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		if isUniqueViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("update status page: %w", err)
+	}
+	return nil
+}
+
+func (r *StatusPageRepo) SetChecks(ctx context.Context, pageID int64, checks []statuspage.PageCheck) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	ids := make([]int64, len(checks))
+	names := make([]string, len(checks))
+	for i, c := range checks {
+		ids[i], names[i] = c.CheckID, c.DisplayName
+	}
+
+	eq := r.db.execQueryer(ctx)
+	if _, err := eq.Exec(ctx, qStatusPageChecksClear, pageID); err != nil {
+		return fmt.Errorf("clear status page checks: %w", err)
+	}
+	if _, err := eq.Exec(ctx, qStatusPageChecksInsert, pageID, ids, names); err != nil {
+		return fmt.Errorf("insert status page checks: %w", err)
+	}
+	return nil
+}
+
+func (r *StatusPageRepo) Delete(ctx context.Context, id int64) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.Pool.Exec(ctx, qStatusPageDelete, id)
+	if err != nil {
+		return fmt.Errorf("delete status page: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *StatusPageRepo) AddNote(ctx context.Context, n *statuspage.Note) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	if err := r.db.Pool.QueryRow(ctx, qStatusPageNoteInsert, n.PageID, n.Title, n.Body).Scan(&n.ID, &n.CreatedAt); err != nil {
+		return fmt.Errorf("insert status page note: %w", err)
+	}
+	return nil
+}
+
+func (r *StatusPageRepo) ResolveNote(ctx context.Context, pageID, id int64, at time.Time) (*statuspage.Note, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var n statuspage.Note
+	err := r.db.Pool.QueryRow(ctx, qStatusPageNoteResolve, pageID, id, at).
+		Scan(&n.ID, &n.PageID, &n.Title, &n.Body, &n.CreatedAt, &n.ResolvedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("resolve status page note: %w", err)
+	}
+	return &n, nil
+}
+
+func (r *StatusPageRepo) DeleteNote(ctx context.Context, pageID, id int64) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.Pool.Exec(ctx, qStatusPageNoteDelete, pageID, id)
+	if err != nil {
+		return fmt.Errorf("delete status page note: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *StatusPageRepo) ListNotes(ctx context.Context, pageID int64, limit int) ([]*statuspage.Note, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.Pool.Query(ctx, qStatusPageNotes, pageID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query status page notes: %w", err)
+	}
+	defer rows.Close()
+
+	out := []*statuspage.Note{}
+	for rows.Next() {
+		var n statuspage.Note
+		if err := rows.Scan(&n.ID, &n.PageID, &n.Title, &n.Body, &n.CreatedAt, &n.ResolvedAt); err != nil {
+			return nil, fmt.Errorf("scan status page note: %w", err)
+		}
+		out = append(out, &n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return out, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
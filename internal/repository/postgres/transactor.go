@@ -29,13 +29,17 @@ func NewTransactor(db *DB, logger *zap.Logger) Transactor {
 	}
 }
 func (t *transactorImpl) WithTx(ctx context.Context, function func(ctx context.Context) error) (txErr error) {
-	ctxWithTx, tx, err := injectTx(ctx, t.db)
+	ctxWithTx, tx, owned, err := injectTx(ctx, t.db)
 
 	if err != nil {
 		return fmt.Errorf("can not inject transaction, error: %w", err)
 	}
 
 	defer func() {
+		// joined an outer transaction: its owner commits or rolls back
+		if !owned {
+			return
+		}
 		if txErr != nil {
 			err = tx.Rollback(ctxWithTx)
 			if err != nil {
@@ -47,6 +51,7 @@ func (t *transactorImpl) WithTx(ctx context.Context, function func(ctx context.C
 		err = tx.Commit(ctxWithTx)
 		if err != nil {
 			t.logger.Error("commit", zap.Error(err))
+			txErr = fmt.Errorf("commit: %w", err)
 		}
 	}()
 
@@ -63,18 +68,18 @@ type txInjector struct{}
 
 var ErrTxNotFound = errors.New("tx not found in context")
 
-func injectTx(ctx context.Context, pool *DB) (context.Context, pgx.Tx, error) {
+func injectTx(ctx context.Context, pool *DB) (context.Context, pgx.Tx, bool, error) {
 	if tx, err := extractTx(ctx); err == nil {
-		return ctx, tx, nil
+		return ctx, tx, false, nil
 	}
 
 	tx, err := pool.Pool.Begin(ctx)
 
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 
-	return context.WithValue(ctx, txInjector{}, tx), tx, nil
+	return context.WithValue(ctx, txInjector{}, tx), tx, true, nil
 }
 
 func extractTx(ctx context.Context) (pgx.Tx, error) {
@@ -90,6 +95,7 @@ func extractTx(ctx context.Context) (pgx.Tx, error) {
 type execQueryer interface {
 	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
 	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
 }
 
 func (db *DB) execQueryer(ctx context.Context) execQueryer {
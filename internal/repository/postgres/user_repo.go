@@ -20,36 +20,55 @@ type UserRepo struct {
 func NewUserRepo(db *DB) *UserRepo { return &UserRepo{db: db} }
 
 const (
+	// every user starts with a personal org they own
 	qUserInsert = `
-INSERT INTO users (email, password_hash, is_active)
-VALUES ($1, $2, TRUE)
-RETURNING id, email, password_hash, created_at, updated_at;`
+WITH u AS (
+    INSERT INTO users (email, password_hash, is_active)
+    VALUES ($1, $2, TRUE)
+    RETURNING id, email, password_hash, is_active, email_verified_at, created_at, updated_at
+), o AS (
+    INSERT INTO orgs (name, personal_owner_id)
+    SELECT 'Personal', id FROM u
+    RETURNING id, personal_owner_id
+), m AS (
+    INSERT INTO org_members (org_id, user_id, role)
+    SELECT id, personal_owner_id, 'owner' FROM o
+)
+SELECT id, email, password_hash, is_active, email_verified_at, created_at, updated_at FROM u;`
 
 	qUserByID = `
-SELECT id, email, password_hash, created_at, updated_at
+SELECT id, email, password_hash, is_active, email_verified_at, created_at, updated_at
 FROM users
 WHERE id = $1;`
 
 	qUserByEmail = `
-SELECT id, email, password_hash, created_at, updated_at
+SELECT id, email, password_hash, is_active, email_verified_at, created_at, updated_at
 FROM users
 WHERE email = $1;`
 
 	qUserUpdate = `
 UPDATE users
-SET email         = $2,
-    password_hash = $3,
-    updated_at    = NOW()
+SET email             = $2,
+    password_hash     = $3,
+    email_verified_at = CASE WHEN email = $2 THEN email_verified_at END,
+    updated_at        = NOW()
 WHERE id = $1
-RETURNING id, email, password_hash, created_at, updated_at;`
+RETURNING id, email, password_hash, is_active, email_verified_at, created_at, updated_at;`
+
+	qUserSetPassword = `
+UPDATE users SET password_hash = $2, updated_at = NOW() WHERE id = $1;`
+
+	qUserMarkVerified = `
+UPDATE users
+SET email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW()
+WHERE id = $1 AND email = $2;`
 )
 
 func (r *UserRepo) Create(ctx context.Context, u *user.User) error {
 	ctx, cancel := r.db.withTimeout(ctx)
 	defer cancel()
 
-	if err := r.db.Pool.QueryRow(ctx, qUserInsert, u.Email, u.Password).
-		Scan(&u.ID, &u.Email, &u.Password, &u.CreatedAt, &u.UpdatedAt); err != nil {
+	if err := scanUser(r.db.execQueryer(ctx).QueryRow(ctx, qUserInsert, u.Email, u.Password), u); err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
 			return ErrConflict
@@ -85,16 +104,43 @@ func (r *UserRepo) Update(ctx context.Context, u *user.User) error {
 	ctx, cancel := r.db.withTimeout(ctx)
 	defer cancel()
 
-	if err := r.db.Pool.QueryRow(ctx, qUserUpdate, u.ID, u.Email, u.Password).
-		Scan(&u.ID, &u.Email, &u.Password, &u.CreatedAt, &u.UpdatedAt); err != nil {
+	if err := scanUser(r.db.Pool.QueryRow(ctx, qUserUpdate, u.ID, u.Email, u.Password), u); err != nil {
 		return fmt.Errorf("user update: %w", err)
 	}
 	return nil
 }
 
+func (r *UserRepo) SetPassword(ctx context.Context, id int64, hash string) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.execQueryer(ctx).Exec(ctx, qUserSetPassword, id, hash)
+	if err != nil {
+		return fmt.Errorf("user set password: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *UserRepo) MarkEmailVerified(ctx context.Context, id int64, email string) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.execQueryer(ctx).Exec(ctx, qUserMarkVerified, id, email)
+	if err != nil {
+		return fmt.Errorf("user mark verified: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 func scanUser(row pgx.Row, out *user.User) error {
 	var created, updated time.Time
-	if err := row.Scan(&out.ID, &out.Email, &out.Password, &created, &updated); err != nil {
+	if err := row.Scan(&out.ID, &out.Email, &out.Password, &out.Active, &out.EmailVerifiedAt, &created, &updated); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return ErrNotFound
 		}
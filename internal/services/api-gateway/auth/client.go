@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	domainauth "github.com/NordCoder/Pingerus/internal/domain/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// Proxies are the networks whose X-Forwarded-For entries are believed: the
+// HTTP gateway dialing in over loopback and any reverse proxy in front of it.
+type Proxies []netip.Prefix
+
+// ParseProxies reads CIDRs; a bare address stands for itself.
+func ParseProxies(cidrs []string) (Proxies, error) {
+	out := make(Proxies, 0, len(cidrs))
+	for _, s := range cidrs {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", s, err)
+			}
+			addr = addr.Unmap()
+			out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", s, err)
+		}
+		out = append(out, p.Masked())
+	}
+	return out, nil
+}
+
+func (p Proxies) trusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	for _, n := range p {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Client resolves who sent the request. X-Forwarded-For is walked from the
+// right, where grpc-gateway appends the address it was called from, and only
+// while the hop that vouches for the next one is a trusted proxy; a caller
+// reaching the server directly can't forge it, as its own address starts the
+// walk.
+func (p Proxies) Client(ctx context.Context) domainauth.Client {
+	var c domainauth.Client
+	if pr, ok := peer.FromContext(ctx); ok && pr.Addr != nil {
+		if host, _, err := net.SplitHostPort(pr.Addr.String()); err == nil {
+			c.IP = normalizeIP(host)
+		}
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	var hops []string
+	for _, v := range md.Get("x-forwarded-for") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0 && p.trusted(c.IP); i-- {
+		ip := normalizeIP(hops[i])
+		if ip == "" {
+			break
+		}
+		c.IP = ip
+	}
+	for _, k := range []string{"grpcgateway-user-agent", "user-agent"} {
+		if vals := md.Get(k); len(vals) > 0 && vals[0] != "" {
+			c.UserAgent = vals[0]
+			break
+		}
+	}
+	return c
+}
+
+func normalizeIP(s string) string {
+	addr, err := netip.ParseAddr(strings.TrimSpace(s))
+	if err != nil {
+		return ""
+	}
+	return addr.Unmap().String()
+}
+
+// UnaryClientInterceptor resolves the caller once for the rate limiter,
+// sessions and audit events; it goes first in the chain.
+func UnaryClientInterceptor(p Proxies) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
+		return next(WithClient(ctx, p.Client(ctx)), req)
+	}
+}
+
+func StreamClientInterceptor(p Proxies) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, next grpc.StreamHandler) error {
+		ctx := WithClient(ss.Context(), p.Client(ss.Context()))
+		return next(srv, &authedStream{ServerStream: ss, ctx: ctx})
+	}
+}
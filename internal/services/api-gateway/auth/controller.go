@@ -2,14 +2,17 @@ package auth
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"github.com/NordCoder/Pingerus/internal/domain/auth"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	pb "github.com/NordCoder/Pingerus/generated/v1"
 	"github.com/NordCoder/Pingerus/internal/domain/user"
+	"github.com/NordCoder/Pingerus/internal/services/api-gateway/policy"
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
@@ -21,6 +24,11 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+const (
+	oidcStateCookie    = "oidc_state"
+	oidcStateCookieTTL = 10 * time.Minute
+)
+
 type Server struct {
 	pb.UnimplementedAuthServiceServer
 	log          *zap.Logger
@@ -82,7 +90,29 @@ func (s *Server) SignIn(ctx context.Context, req *pb.SignInRequest) (*pb.AuthRes
 
 	s.log.Info("auth.signin", zap.String("email", req.GetEmail()))
 
-	u, access, refresh, err := s.uc.SignIn(ctx, req.GetEmail(), req.GetPassword())
+	u, access, refresh, mfaToken, err := s.uc.SignIn(ctx, req.GetEmail(), req.GetPassword())
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	if mfaToken != "" {
+		return &pb.AuthResponse{User: toPBUser(u), MfaRequired: true, MfaToken: mfaToken}, nil
+	}
+
+	s.setRefreshCookie(ctx, refresh)
+	return &pb.AuthResponse{AccessToken: access, User: toPBUser(u)}, nil
+}
+
+func (s *Server) VerifyMFA(ctx context.Context, req *pb.VerifyMFARequest) (*pb.AuthResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if (req.GetCode() == "") == (req.GetRecoveryCode() == "") {
+		return nil, status.Error(codes.InvalidArgument, "exactly one of code and recovery_code is required")
+	}
+
+	s.log.Info("auth.verify_mfa")
+
+	u, access, refresh, err := s.uc.VerifyMFA(ctx, req.GetMfaToken(), req.GetCode(), req.GetRecoveryCode())
 	if err != nil {
 		return nil, s.mapErr(err)
 	}
@@ -119,10 +149,8 @@ func (s *Server) Logout(ctx context.Context, _ *emptypb.Empty) (*emptypb.Empty,
 }
 
 func (s *Server) Me(ctx context.Context, _ *emptypb.Empty) (*pb.User, error) {
-	token := bearer(ctx)
-
-	id, err := s.uc.ParseAccess(token)
-	if err != nil {
+	id, ok := UserIDFromCtx(ctx)
+	if !ok {
 		return nil, status.Error(codes.Unauthenticated, "invalid access token")
 	}
 
@@ -134,14 +162,367 @@ func (s *Server) Me(ctx context.Context, _ *emptypb.Empty) (*pb.User, error) {
 	return toPBUser(u), nil
 }
 
+func (s *Server) CreateAPIKey(ctx context.Context, req *pb.CreateAPIKeyRequest) (*pb.CreateAPIKeyResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, ok := UserIDFromCtx(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "auth required")
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresAt != nil {
+		t := req.GetExpiresAt().AsTime()
+		expiresAt = &t
+	}
+
+	s.log.Info("auth.create_api_key", zap.Int64("uid", uid), zap.String("name", req.GetName()))
+
+	k, raw, err := s.uc.CreateAPIKey(ctx, uid, req.GetOrgId(), req.GetName(), scopeFromPB(req.GetScope()), expiresAt)
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	return &pb.CreateAPIKeyResponse{ApiKey: toPBAPIKey(k), Secret: raw}, nil
+}
+
+func (s *Server) ListAPIKeys(ctx context.Context, _ *emptypb.Empty) (*pb.ListAPIKeysResponse, error) {
+	uid, ok := UserIDFromCtx(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "auth required")
+	}
+
+	keys, err := s.uc.ListAPIKeys(ctx, uid)
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	out := make([]*pb.APIKey, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, toPBAPIKey(k))
+	}
+	return &pb.ListAPIKeysResponse{ApiKeys: out}, nil
+}
+
+func (s *Server) RevokeAPIKey(ctx context.Context, req *pb.RevokeAPIKeyRequest) (*emptypb.Empty, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, ok := UserIDFromCtx(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "auth required")
+	}
+
+	s.log.Info("auth.revoke_api_key", zap.Int64("uid", uid), zap.Int64("id", req.GetId()))
+
+	if err := s.uc.RevokeAPIKey(ctx, uid, req.GetId()); err != nil {
+		return nil, s.mapErr(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) ListSessions(ctx context.Context, _ *emptypb.Empty) (*pb.ListSessionsResponse, error) {
+	uid, ok := UserIDFromCtx(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "auth required")
+	}
+
+	sessions, err := s.uc.ListSessions(ctx, uid, s.getRefreshFromCtx(ctx))
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	out := make([]*pb.Session, 0, len(sessions))
+	for _, ss := range sessions {
+		out = append(out, toPBSession(ss))
+	}
+	return &pb.ListSessionsResponse{Sessions: out}, nil
+}
+
+func (s *Server) RevokeSession(ctx context.Context, req *pb.RevokeSessionRequest) (*emptypb.Empty, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, ok := UserIDFromCtx(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "auth required")
+	}
+
+	s.log.Info("auth.revoke_session", zap.Int64("uid", uid), zap.String("session", req.GetId()))
+
+	if err := s.uc.RevokeSession(ctx, uid, req.GetId()); err != nil {
+		return nil, s.mapErr(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) RevokeAllOtherSessions(ctx context.Context, _ *emptypb.Empty) (*pb.RevokeAllOtherSessionsResponse, error) {
+	uid, ok := UserIDFromCtx(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "auth required")
+	}
+
+	n, err := s.uc.RevokeOtherSessions(ctx, uid, s.getRefreshFromCtx(ctx))
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+
+	s.log.Info("auth.revoke_other_sessions", zap.Int64("uid", uid), zap.Int64("revoked", n))
+
+	return &pb.RevokeAllOtherSessionsResponse{Revoked: n}, nil
+}
+
+func (s *Server) ListAuthEvents(ctx context.Context, req *pb.ListAuthEventsRequest) (*pb.ListAuthEventsResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, ok := UserIDFromCtx(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "auth required")
+	}
+	before, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+
+	events, err := s.uc.ListEvents(ctx, uid, auth.EventFilter{
+		UserID:   req.GetUserId(),
+		Type:     auth.EventType(req.GetEventType()),
+		BeforeID: before,
+		Limit:    int(req.GetPageSize()),
+	})
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+
+	resp := &pb.ListAuthEventsResponse{Events: make([]*pb.AuthEvent, 0, len(events))}
+	for _, e := range events {
+		resp.Events = append(resp.Events, toPBAuthEvent(e))
+	}
+	pageSize := int(req.GetPageSize())
+	if pageSize == 0 {
+		pageSize = defaultEventsPageSize
+	}
+	if len(events) == pageSize {
+		resp.NextPageToken = encodePageToken(events[len(events)-1].ID)
+	}
+	return resp, nil
+}
+
+func (s *Server) SendVerificationEmail(ctx context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
+	uid, ok := UserIDFromCtx(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "auth required")
+	}
+
+	s.log.Info("auth.send_verification", zap.Int64("uid", uid))
+
+	if err := s.uc.SendVerificationEmail(ctx, uid); err != nil {
+		return nil, s.mapErr(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) VerifyEmail(ctx context.Context, req *pb.VerifyEmailRequest) (*emptypb.Empty, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s.log.Info("auth.verify_email")
+
+	if err := s.uc.VerifyEmail(ctx, req.GetToken()); err != nil {
+		return nil, s.mapErr(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) RequestPasswordReset(ctx context.Context, req *pb.RequestPasswordResetRequest) (*emptypb.Empty, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s.log.Info("auth.request_password_reset")
+
+	if err := s.uc.RequestPasswordReset(ctx, req.GetEmail()); err != nil {
+		return nil, s.mapErr(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest) (*emptypb.Empty, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s.log.Info("auth.reset_password")
+
+	if err := s.uc.ResetPassword(ctx, req.GetToken(), req.GetNewPassword()); err != nil {
+		return nil, s.mapErr(err)
+	}
+	s.clearRefreshCookie(ctx)
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) ChangePassword(ctx context.Context, req *pb.ChangePasswordRequest) (*emptypb.Empty, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, ok := UserIDFromCtx(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "auth required")
+	}
+
+	s.log.Info("auth.change_password", zap.Int64("uid", uid))
+
+	if err := s.uc.ChangePassword(ctx, uid, req.GetOldPassword(), req.GetNewPassword(), s.getRefreshFromCtx(ctx)); err != nil {
+		return nil, s.mapErr(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) EnrollTOTP(ctx context.Context, _ *emptypb.Empty) (*pb.EnrollTOTPResponse, error) {
+	uid, ok := UserIDFromCtx(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "auth required")
+	}
+
+	s.log.Info("auth.enroll_totp", zap.Int64("uid", uid))
+
+	secret, uri, err := s.uc.EnrollTOTP(ctx, uid)
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	return &pb.EnrollTOTPResponse{Secret: secret, OtpauthUri: uri}, nil
+}
+
+func (s *Server) ConfirmTOTP(ctx context.Context, req *pb.ConfirmTOTPRequest) (*pb.RecoveryCodesResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, ok := UserIDFromCtx(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "auth required")
+	}
+
+	s.log.Info("auth.confirm_totp", zap.Int64("uid", uid))
+
+	recovery, err := s.uc.ConfirmTOTP(ctx, uid, req.GetCode())
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	return &pb.RecoveryCodesResponse{RecoveryCodes: recovery}, nil
+}
+
+func (s *Server) DisableTOTP(ctx context.Context, req *pb.DisableTOTPRequest) (*emptypb.Empty, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, ok := UserIDFromCtx(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "auth required")
+	}
+
+	s.log.Info("auth.disable_totp", zap.Int64("uid", uid))
+
+	if err := s.uc.DisableTOTP(ctx, uid, req.GetPassword(), req.GetCode()); err != nil {
+		return nil, s.mapErr(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) RegenerateRecoveryCodes(ctx context.Context, req *pb.RegenerateRecoveryCodesRequest) (*pb.RecoveryCodesResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, ok := UserIDFromCtx(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "auth required")
+	}
+
+	s.log.Info("auth.regenerate_recovery_codes", zap.Int64("uid", uid))
+
+	recovery, err := s.uc.RegenerateRecoveryCodes(ctx, uid, req.GetCode())
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	return &pb.RecoveryCodesResponse{RecoveryCodes: recovery}, nil
+}
+
+func (s *Server) ListOIDCProviders(ctx context.Context, _ *emptypb.Empty) (*pb.ListOIDCProvidersResponse, error) {
+	providers := s.uc.OIDCProviders()
+	out := &pb.ListOIDCProvidersResponse{Providers: make([]*pb.OIDCProvider, 0, len(providers))}
+	for _, p := range providers {
+		out.Providers = append(out.Providers, &pb.OIDCProvider{Name: p.Name, DisplayName: p.DisplayName})
+	}
+	return out, nil
+}
+
+func (s *Server) StartOIDCLogin(ctx context.Context, req *pb.StartOIDCLoginRequest) (*pb.StartOIDCLoginResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s.log.Info("auth.oidc_start", zap.String("provider", req.GetProvider()))
+
+	authURL, state, err := s.uc.StartOIDC(ctx, req.GetProvider())
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	// binds the login to this browser so a victim cannot be signed in to an
+	// attacker's account with a forged callback
+	s.setCookie(ctx, oidcStateCookie, state, oidcStateCookieTTL)
+	return &pb.StartOIDCLoginResponse{AuthorizationUrl: authURL}, nil
+}
+
+func (s *Server) CompleteOIDCLogin(ctx context.Context, req *pb.CompleteOIDCLoginRequest) (*pb.AuthResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s.log.Info("auth.oidc_callback", zap.String("provider", req.GetProvider()))
+
+	if s.cookieFromCtx(ctx, oidcStateCookie) != req.GetState() {
+		return nil, status.Error(codes.InvalidArgument, "sso state does not match this browser")
+	}
+	s.setCookie(ctx, oidcStateCookie, "", -1)
+
+	u, access, refresh, mfaToken, err := s.uc.CompleteOIDC(ctx, req.GetProvider(), req.GetCode(), req.GetState())
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	if mfaToken != "" {
+		return &pb.AuthResponse{User: toPBUser(u), MfaRequired: true, MfaToken: mfaToken}, nil
+	}
+
+	s.setRefreshCookie(ctx, refresh)
+	return &pb.AuthResponse{AccessToken: access, User: toPBUser(u)}, nil
+}
+
 func (s *Server) mapErr(err error) error {
 	switch {
-	case errors.Is(err, ErrInvalidCredentials):
+	case errors.Is(err, ErrInvalidCredentials), errors.Is(err, ErrTokenReused), errors.Is(err, ErrInvalidMFACode),
+		errors.Is(err, ErrSSOFailed):
 		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, ErrUnknownProvider):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrSSOUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, ErrSSOEmailNotVerified), errors.Is(err, ErrSSODomainNotAllowed), errors.Is(err, ErrSSONoAccount):
+		return status.Error(codes.PermissionDenied, err.Error())
 	case errors.Is(err, ErrEmailExists):
 		return status.Error(codes.AlreadyExists, err.Error())
 	case errors.Is(err, ErrWeakPassword):
 		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrInvalidScope), errors.Is(err, ErrInvalidExpiry):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrAPIKeyNotFound), errors.Is(err, ErrSessionNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrNoSession):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrForbidden), errors.Is(err, policy.ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, ErrInvalidToken):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrAlreadyVerified), errors.Is(err, ErrMFAEnabled), errors.Is(err, ErrMFANotEnabled):
+		return status.Error(codes.FailedPrecondition, err.Error())
 	default:
 		return err
 	}
@@ -149,10 +530,100 @@ func (s *Server) mapErr(err error) error {
 
 func toPBUser(u *user.User) *pb.User {
 	return &pb.User{
-		Id:        u.ID,
-		Email:     u.Email,
-		CreatedAt: timestamppb.New(u.CreatedAt),
-		UpdatedAt: timestamppb.New(u.UpdatedAt),
+		Id:            u.ID,
+		Email:         u.Email,
+		CreatedAt:     timestamppb.New(u.CreatedAt),
+		UpdatedAt:     timestamppb.New(u.UpdatedAt),
+		EmailVerified: u.EmailVerified(),
+	}
+}
+
+func toPBAPIKey(k *auth.APIKey) *pb.APIKey {
+	out := &pb.APIKey{
+		Id:        k.ID,
+		OrgId:     k.OrgID,
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Scope:     scopeToPB(k.Scope),
+		CreatedAt: timestamppb.New(k.CreatedAt),
+	}
+	if k.LastUsedAt != nil {
+		out.LastUsedAt = timestamppb.New(*k.LastUsedAt)
+	}
+	if k.ExpiresAt != nil {
+		out.ExpiresAt = timestamppb.New(*k.ExpiresAt)
+	}
+	if k.RevokedAt != nil {
+		out.RevokedAt = timestamppb.New(*k.RevokedAt)
+	}
+	return out
+}
+
+func toPBSession(s *auth.Session) *pb.Session {
+	out := &pb.Session{
+		Id:        s.ID,
+		UserAgent: s.UserAgent,
+		IpAddress: s.IP,
+		CreatedAt: timestamppb.New(s.CreatedAt),
+		ExpiresAt: timestamppb.New(s.ExpiresAt),
+		Current:   s.Current,
+	}
+	if s.LastRefreshAt != nil {
+		out.LastRefreshAt = timestamppb.New(*s.LastRefreshAt)
+	}
+	return out
+}
+
+func toPBAuthEvent(e *auth.Event) *pb.AuthEvent {
+	out := &pb.AuthEvent{
+		Id:        e.ID,
+		EventType: string(e.Type),
+		IpAddress: e.IP,
+		UserAgent: e.UserAgent,
+		Metadata:  e.Metadata,
+		CreatedAt: timestamppb.New(e.CreatedAt),
+	}
+	if e.UserID != nil {
+		out.UserId = *e.UserID
+	}
+	return out
+}
+
+// page tokens are opaque to clients; they wrap the last id of the page
+func encodePageToken(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodePageToken(tok string) (int64, error) {
+	if tok == "" {
+		return 0, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(tok)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(b), 10, 64)
+}
+
+func scopeFromPB(s pb.APIKeyScope) auth.APIKeyScope {
+	switch s {
+	case pb.APIKeyScope_API_KEY_SCOPE_READ:
+		return auth.ScopeRead
+	case pb.APIKeyScope_API_KEY_SCOPE_READ_WRITE:
+		return auth.ScopeReadWrite
+	default:
+		return ""
+	}
+}
+
+func scopeToPB(s auth.APIKeyScope) pb.APIKeyScope {
+	switch s {
+	case auth.ScopeRead:
+		return pb.APIKeyScope_API_KEY_SCOPE_READ
+	case auth.ScopeReadWrite:
+		return pb.APIKeyScope_API_KEY_SCOPE_READ_WRITE
+	default:
+		return pb.APIKeyScope_API_KEY_SCOPE_UNSPECIFIED
 	}
 }
 
@@ -174,6 +645,24 @@ func (s *Server) setRefreshCookie(ctx context.Context, raw string) {
 	_ = grpc.SetHeader(ctx, md)
 }
 
+// setCookie sets a short-lived helper cookie; maxAge < 0 deletes it.
+func (s *Server) setCookie(ctx context.Context, name, value string, maxAge time.Duration) {
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   s.cookieDomain,
+		HttpOnly: true,
+		Secure:   s.cookieSecure,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(maxAge.Seconds()),
+	}
+	if maxAge < 0 {
+		c.MaxAge = -1
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs("Set-Cookie", c.String()))
+}
+
 func (s *Server) clearRefreshCookie(ctx context.Context) {
 	c := &http.Cookie{
 		Name:     s.cookieName,
@@ -191,21 +680,10 @@ func (s *Server) clearRefreshCookie(ctx context.Context) {
 }
 
 func (s *Server) getRefreshFromCtx(ctx context.Context) string {
+	if raw := s.cookieFromCtx(ctx, s.cookieName); raw != "" {
+		return raw
+	}
 	if md, ok := metadata.FromIncomingContext(ctx); ok {
-		if vals := md.Get("grpcgateway-cookie"); len(vals) > 0 {
-			for _, v := range vals {
-				if raw := parseCookie(v, s.cookieName); raw != "" {
-					return raw
-				}
-			}
-		}
-		if vals := md.Get("cookie"); len(vals) > 0 {
-			for _, v := range vals {
-				if raw := parseCookie(v, s.cookieName); raw != "" {
-					return raw
-				}
-			}
-		}
 		if vals := md.Get("x-refresh-token"); len(vals) > 0 && vals[0] != "" {
 			return vals[0]
 		}
@@ -213,6 +691,21 @@ func (s *Server) getRefreshFromCtx(ctx context.Context) string {
 	return ""
 }
 
+func (s *Server) cookieFromCtx(ctx context.Context, name string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, key := range []string{"grpcgateway-cookie", "cookie"} {
+		for _, v := range md.Get(key) {
+			if raw := parseCookie(v, name); raw != "" {
+				return raw
+			}
+		}
+	}
+	return ""
+}
+
 func parseCookie(header, name string) string {
 	parts := strings.Split(header, ";")
 	for _, p := range parts {
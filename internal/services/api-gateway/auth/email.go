@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	domainauth "github.com/NordCoder/Pingerus/internal/domain/auth"
+	"github.com/NordCoder/Pingerus/internal/domain/user"
+	intoutbox "github.com/NordCoder/Pingerus/internal/outbox"
+	"github.com/NordCoder/Pingerus/internal/repository/postgres"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const minPasswordLen = 8
+
+var (
+	ErrInvalidToken    = errors.New("invalid or expired token")
+	ErrAlreadyVerified = errors.New("email already verified")
+)
+
+// SendVerificationEmail queues a fresh verification link; earlier links stop
+// working.
+func (u *Usecase) SendVerificationEmail(ctx context.Context, userID int64) error {
+	usr, err := u.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if usr.EmailVerified() {
+		return ErrAlreadyVerified
+	}
+	return u.sendVerification(ctx, usr)
+}
+
+func (u *Usecase) VerifyEmail(ctx context.Context, raw string) error {
+	t, err := u.mail.Verify.Consume(ctx, HashToken(raw))
+	if err != nil {
+		if errors.Is(err, postgres.ErrNotFound) {
+			return ErrInvalidToken
+		}
+		return err
+	}
+	if err := u.users.MarkEmailVerified(ctx, t.UserID, t.Email); err != nil {
+		if errors.Is(err, postgres.ErrNotFound) {
+			// the address changed after the link was sent
+			return ErrInvalidToken
+		}
+		return err
+	}
+	u.record(ctx, t.UserID, domainauth.EventEmailVerified, map[string]string{"email": t.Email})
+	return nil
+}
+
+// RequestPasswordReset never reports whether email is registered.
+func (u *Usecase) RequestPasswordReset(ctx context.Context, email string) error {
+	email = normalizeEmail(email)
+	usr, err := u.users.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, postgres.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if !usr.Active {
+		return nil
+	}
+	if err := u.sendOneTimeLink(ctx, usr, u.mail.Reset, u.cfg.ResetTTL, intoutbox.EmailTemplateReset, "/reset-password"); err != nil {
+		return err
+	}
+	u.record(ctx, usr.ID, domainauth.EventResetRequested, nil)
+	return nil
+}
+
+// ResetPassword sets a new password from a reset link and ends every
+// session of the account.
+func (u *Usecase) ResetPassword(ctx context.Context, raw, newPassword string) error {
+	if err := checkPassword(newPassword); err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	var userID int64
+	err = u.tx.WithTx(ctx, func(ctx context.Context) error {
+		t, err := u.mail.Reset.Consume(ctx, HashToken(raw))
+		if err != nil {
+			return err
+		}
+		userID = t.UserID
+		return u.users.SetPassword(ctx, t.UserID, string(hash))
+	})
+	if err != nil {
+		if errors.Is(err, postgres.ErrNotFound) {
+			return ErrInvalidToken
+		}
+		return err
+	}
+
+	if err := u.rt.RevokeAllForUser(ctx, userID); err != nil {
+		return err
+	}
+	u.record(ctx, userID, domainauth.EventPasswordReset, nil)
+	return nil
+}
+
+// ChangePassword keeps the caller's current session and ends the others.
+func (u *Usecase) ChangePassword(ctx context.Context, userID int64, oldPassword, newPassword, currentRaw string) error {
+	usr, err := u.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(usr.Password), []byte(oldPassword)) != nil {
+		return ErrInvalidCredentials
+	}
+	if err := checkPassword(newPassword); err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	if err := u.users.SetPassword(ctx, userID, string(hash)); err != nil {
+		return err
+	}
+	if err := u.mail.Reset.InvalidateUser(ctx, userID); err != nil {
+		return err
+	}
+
+	if current := u.currentFamily(ctx, userID, currentRaw); current != "" {
+		_, err = u.rt.RevokeOtherSessions(ctx, userID, current)
+	} else {
+		err = u.rt.RevokeAllForUser(ctx, userID)
+	}
+	if err != nil {
+		return err
+	}
+	u.record(ctx, userID, domainauth.EventPasswordChange, nil)
+	return nil
+}
+
+func checkPassword(p string) error {
+	if len(p) < minPasswordLen || strings.TrimSpace(p) == "" {
+		return ErrWeakPassword
+	}
+	return nil
+}
+
+func (u *Usecase) sendVerification(ctx context.Context, usr *user.User) error {
+	return u.sendOneTimeLink(ctx, usr, u.mail.Verify, u.cfg.VerifyTTL, intoutbox.EmailTemplateVerify, "/verify-email")
+}
+
+// sendOneTimeLink replaces usr's outstanding tokens in repo with a new one and
+// queues the email carrying it, atomically.
+func (u *Usecase) sendOneTimeLink(ctx context.Context, usr *user.User, repo domainauth.OneTimeTokenRepo, ttl time.Duration, template, path string) error {
+	raw, err := GenerateRawToken(32)
+	if err != nil {
+		return fmt.Errorf("gen token: %w", err)
+	}
+	now := u.cfg.Now()
+	t := &domainauth.OneTimeToken{
+		UserID:    usr.ID,
+		Email:     usr.Email,
+		TokenHash: HashToken(raw),
+		ExpiresAt: now.Add(ttl),
+	}
+
+	return u.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := repo.InvalidateUser(ctx, usr.ID); err != nil {
+			return err
+		}
+		if err := repo.Create(ctx, t); err != nil {
+			return err
+		}
+		key := template + ":" + strconv.FormatInt(t.ID, 10)
+		return intoutbox.EmailRequested.Enqueue(ctx, u.mail.Outbox, key, intoutbox.EmailRequestedPayload{
+			UserID:   usr.ID,
+			To:       usr.Email,
+			Template: template,
+			Params: map[string]string{
+				"link":       u.link(path, raw),
+				"expires_at": t.ExpiresAt.UTC().Format(time.RFC3339),
+			},
+			At: now,
+		})
+	})
+}
+
+func (u *Usecase) link(path, raw string) string {
+	return strings.TrimRight(u.cfg.AppURL, "/") + path + "?token=" + url.QueryEscape(raw)
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	domainauth "github.com/NordCoder/Pingerus/internal/domain/auth"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultEventsPageSize = 50
+	maxEventsPageSize     = 200
+)
+
+var ErrForbidden = errors.New("forbidden")
+
+// record writes an audit event for userID (0 if unknown) with the caller's
+// client info. Audit failures are logged, never returned.
+func (u *Usecase) record(ctx context.Context, userID int64, typ domainauth.EventType, meta map[string]string) {
+	cl := ClientFromCtx(ctx)
+	e := &domainauth.Event{
+		Type:      typ,
+		IP:        cl.IP,
+		UserAgent: cl.UserAgent,
+		Metadata:  meta,
+	}
+	if userID != 0 {
+		e.UserID = &userID
+	}
+	if err := u.events.Create(ctx, e); err != nil {
+		u.cfg.Logger.Warn("auth event not recorded",
+			zap.String("event", string(typ)), zap.Int64("uid", userID), zap.Error(err))
+	}
+}
+
+// ListEvents returns f.UserID's events; only admins may read someone else's.
+func (u *Usecase) ListEvents(ctx context.Context, callerID int64, f domainauth.EventFilter) ([]*domainauth.Event, error) {
+	if f.UserID == 0 {
+		f.UserID = callerID
+	}
+	if f.UserID != callerID {
+		ok, err := u.isAdmin(ctx, callerID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrForbidden
+		}
+	}
+	switch {
+	case f.Limit <= 0:
+		f.Limit = defaultEventsPageSize
+	case f.Limit > maxEventsPageSize:
+		f.Limit = maxEventsPageSize
+	}
+	return u.events.List(ctx, f)
+}
+
+func (u *Usecase) isAdmin(ctx context.Context, userID int64) (bool, error) {
+	if len(u.cfg.AdminEmails) == 0 {
+		return false, nil
+	}
+	usr, err := u.users.GetByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(u.cfg.AdminEmails, normalizeEmail(usr.Email)), nil
+}
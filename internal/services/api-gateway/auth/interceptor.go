@@ -2,42 +2,189 @@ package auth
 
 import (
 	"context"
+	"strings"
+
+	domainauth "github.com/NordCoder/Pingerus/internal/domain/auth"
+	"github.com/NordCoder/Pingerus/internal/services/api-gateway/policy"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
 type ctxKey int
 
-const userIDKey ctxKey = 1
+const (
+	userIDKey ctxKey = iota + 1
+	apiKeyKey
+	clientKey
+)
 
 func UserIDFromCtx(ctx context.Context) (int64, bool) {
 	id, ok := ctx.Value(userIDKey).(int64)
 	return id, ok
 }
 
+// APIKeyFromCtx returns the key the request was authenticated with, if any.
+func APIKeyFromCtx(ctx context.Context) (*domainauth.APIKey, bool) {
+	k, ok := ctx.Value(apiKeyKey).(*domainauth.APIKey)
+	return k, ok
+}
+
+func WithClient(ctx context.Context, c domainauth.Client) context.Context {
+	return context.WithValue(ctx, clientKey, c)
+}
+
+func ClientFromCtx(ctx context.Context) domainauth.Client {
+	c, _ := ctx.Value(clientKey).(domainauth.Client)
+	return c
+}
+
 var publicFullMethods = map[string]bool{
 	"/pingerus.v1.AuthService/SignUp":  true,
 	"/pingerus.v1.AuthService/SignIn":  true,
 	"/pingerus.v1.AuthService/Refresh": true,
 	"/pingerus.v1.AuthService/Logout":  true,
+
+	"/pingerus.v1.AuthService/VerifyEmail":          true,
+	"/pingerus.v1.AuthService/RequestPasswordReset": true,
+	"/pingerus.v1.AuthService/ResetPassword":        true,
+	"/pingerus.v1.AuthService/VerifyMFA":            true,
+
+	"/pingerus.v1.AuthService/ListOIDCProviders": true,
+	"/pingerus.v1.AuthService/StartOIDCLogin":    true,
+	"/pingerus.v1.AuthService/CompleteOIDCLogin": true,
+}
+
+// anonymousFullMethods serve content its owners chose to publish, such as
+// status pages, to anyone. Unlike publicFullMethods they are not part of
+// signing in, and credentials sent along are ignored. Handlers must only
+// return what was explicitly made public.
+var anonymousFullMethods = map[string]bool{
+	"/pingerus.v1.StatusPageService/GetPublicStatusPage": true,
 }
 
-func UnaryAuthInterceptor(parse func(token string) (int64, error)) grpc.UnaryServerInterceptor {
+// readOnlyFullMethods may be called with a read-scoped API key.
+var readOnlyFullMethods = map[string]bool{
+	"/pingerus.v1.AuthService/Me":             true,
+	"/pingerus.v1.AuthService/ListAPIKeys":    true,
+	"/pingerus.v1.AuthService/ListSessions":   true,
+	"/pingerus.v1.AuthService/ListAuthEvents": true,
+	"/pingerus.v1.CheckService/GetCheck":      true,
+	"/pingerus.v1.CheckService/ListChecks":    true,
+	"/pingerus.v1.CheckService/ExportChecks":  true,
+	"/pingerus.v1.CheckService/WatchChecks":   true,
+	"/pingerus.v1.CheckService/WatchCheck":    true,
+	"/pingerus.v1.CheckService/GetUsage":      true,
+	"/pingerus.v1.OrgService/ListOrgs":        true,
+	"/pingerus.v1.OrgService/ListOrgMembers":  true,
+
+	"/pingerus.v1.StatusPageService/GetStatusPage":       true,
+	"/pingerus.v1.StatusPageService/ListStatusPages":     true,
+	"/pingerus.v1.StatusPageService/ListStatusPageNotes": true,
+}
+
+// sessionOnlyFullMethods require a JWT session; API keys cannot manage keys,
+// sessions or org membership.
+var sessionOnlyFullMethods = map[string]bool{
+	"/pingerus.v1.AuthService/CreateAPIKey": true,
+	"/pingerus.v1.AuthService/RevokeAPIKey": true,
+
+	"/pingerus.v1.AuthService/RevokeSession":          true,
+	"/pingerus.v1.AuthService/RevokeAllOtherSessions": true,
+
+	"/pingerus.v1.AuthService/ChangePassword":        true,
+	"/pingerus.v1.AuthService/SendVerificationEmail": true,
+
+	"/pingerus.v1.AuthService/EnrollTOTP":              true,
+	"/pingerus.v1.AuthService/ConfirmTOTP":             true,
+	"/pingerus.v1.AuthService/DisableTOTP":             true,
+	"/pingerus.v1.AuthService/RegenerateRecoveryCodes": true,
+
+	"/pingerus.v1.OrgService/CreateOrg":       true,
+	"/pingerus.v1.OrgService/DeleteOrg":       true,
+	"/pingerus.v1.OrgService/AddOrgMember":    true,
+	"/pingerus.v1.OrgService/UpdateOrgMember": true,
+	"/pingerus.v1.OrgService/RemoveOrgMember": true,
+}
+
+type Authenticator interface {
+	ParseAccess(token string) (int64, error)
+	ParseAPIKey(ctx context.Context, raw string) (*domainauth.APIKey, error)
+}
+
+func UnaryAuthInterceptor(a Authenticator) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
-		if publicFullMethods[info.FullMethod] {
-			return next(ctx, req)
+		ctx, err := authenticate(ctx, a, info.FullMethod)
+		if err != nil {
+			return nil, err
 		}
+		return next(ctx, req)
+	}
+}
 
-		token := bearer(ctx)
-		if token == "" {
-			return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+func StreamAuthInterceptor(a Authenticator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, next grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), a, info.FullMethod)
+		if err != nil {
+			return err
 		}
-		uid, err := parse(token)
+		return next(srv, &authedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type authedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authedStream) Context() context.Context { return s.ctx }
+
+// authenticate resolves the caller of fullMethod and returns ctx carrying
+// their identity; the client address is already there, from
+// UnaryClientInterceptor or StreamClientInterceptor.
+func authenticate(ctx context.Context, a Authenticator, fullMethod string) (context.Context, error) {
+	if publicFullMethods[fullMethod] || anonymousFullMethods[fullMethod] {
+		return ctx, nil
+	}
+
+	token := apiKeyHeader(ctx)
+	if token == "" {
+		token = bearer(ctx)
+	}
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+
+	if !IsAPIKey(token) {
+		uid, err := a.ParseAccess(token)
 		if err != nil {
 			return nil, status.Error(codes.Unauthenticated, "invalid or expired token")
 		}
-		ctx = context.WithValue(ctx, userIDKey, uid)
-		return next(ctx, req)
+		return context.WithValue(ctx, userIDKey, uid), nil
+	}
+
+	k, err := a.ParseAPIKey(ctx, token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid, revoked or expired api key")
+	}
+	if sessionOnlyFullMethods[fullMethod] {
+		return nil, status.Error(codes.PermissionDenied, "this method requires a session")
+	}
+	if k.Scope == domainauth.ScopeRead && !readOnlyFullMethods[fullMethod] {
+		return nil, status.Error(codes.PermissionDenied, "api key is read-only")
+	}
+	ctx = context.WithValue(ctx, userIDKey, k.UserID)
+	ctx = context.WithValue(ctx, apiKeyKey, k)
+	ctx = policy.WithKeyOrg(ctx, k.OrgID)
+	return ctx, nil
+}
+
+func apiKeyHeader(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if vals := md.Get("x-api-key"); len(vals) > 0 {
+			return strings.TrimSpace(vals[0])
+		}
 	}
+	return ""
 }
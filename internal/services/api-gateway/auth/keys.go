@@ -0,0 +1,413 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	crand "crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NordCoder/Pingerus/internal/domain/auth"
+	"go.uber.org/zap"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+
+	minRSABits = 2048
+)
+
+var ErrNoSigningKey = errors.New("no active signing key")
+
+// KeyConfig describes one JWT key. Keys without a private key only verify,
+// e.g. a retired key whose tokens have not expired yet.
+type KeyConfig struct {
+	Kid            string
+	Alg            string
+	PrivateKeyFile string
+	PublicKeyFile  string
+	// ActiveFrom schedules rotation: the newest active key with a private
+	// key signs. A key is published in the JWKS before it becomes active.
+	ActiveFrom time.Time
+}
+
+type KeySetConfig struct {
+	Keys []KeyConfig
+	// Secret signs HS256 tokens when Keys is empty.
+	Secret []byte
+	// AcceptHS256 keeps Secret-signed tokens valid after moving to Keys.
+	AcceptHS256 bool
+	// Issuer and Audience go into every asymmetrically signed token and
+	// must match on verify, so a key shared with another service cannot
+	// mint tokens this gateway accepts.
+	Issuer   string
+	Audience string
+	Now      func() time.Time
+}
+
+type jwtKey struct {
+	kid        string
+	alg        string
+	priv       crypto.Signer
+	pub        crypto.PublicKey
+	activeFrom time.Time
+}
+
+// KeySet signs and verifies access tokens. Key files are re-read by Reload,
+// so new keys can be dropped in without a restart.
+type KeySet struct {
+	cfg KeySetConfig
+
+	mu    sync.RWMutex
+	keys  []*jwtKey // newest ActiveFrom first
+	byKid map[string]*jwtKey
+}
+
+func NewKeySet(cfg KeySetConfig) (*KeySet, error) {
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	if len(cfg.Keys) == 0 && len(cfg.Secret) == 0 {
+		return nil, errors.New("jwt: neither keys nor secret configured")
+	}
+	if len(cfg.Keys) > 0 && (cfg.Issuer == "" || cfg.Audience == "") {
+		return nil, errors.New("jwt: issuer and audience are required with keys")
+	}
+	ks := &KeySet{cfg: cfg}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// NewHS256KeySet signs and verifies with a shared secret only.
+func NewHS256KeySet(secret []byte) *KeySet {
+	return &KeySet{cfg: KeySetConfig{Secret: secret, Now: time.Now}, byKid: map[string]*jwtKey{}}
+}
+
+// Reload re-reads every key file; on error the current keys stay in use.
+func (ks *KeySet) Reload() error {
+	keys := make([]*jwtKey, 0, len(ks.cfg.Keys))
+	byKid := make(map[string]*jwtKey, len(ks.cfg.Keys))
+	for _, kc := range ks.cfg.Keys {
+		k, err := loadKey(kc)
+		if err != nil {
+			return fmt.Errorf("jwt key %q: %w", kc.Kid, err)
+		}
+		if _, dup := byKid[k.kid]; dup {
+			return fmt.Errorf("jwt key %q: duplicate kid", k.kid)
+		}
+		keys = append(keys, k)
+		byKid[k.kid] = k
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].activeFrom.After(keys[j].activeFrom) })
+
+	ks.mu.Lock()
+	ks.keys, ks.byKid = keys, byKid
+	ks.mu.Unlock()
+	return nil
+}
+
+// Watch reloads the key files every interval until ctx is done.
+func (ks *KeySet) Watch(ctx context.Context, interval time.Duration, log *zap.Logger) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := ks.Reload(); err != nil {
+				log.Warn("jwt keys reload failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (ks *KeySet) signingKey() (*jwtKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if len(ks.keys) == 0 {
+		return nil, nil
+	}
+	now := ks.cfg.Now()
+	for _, k := range ks.keys {
+		if k.priv != nil && !k.activeFrom.After(now) {
+			return k, nil
+		}
+	}
+	return nil, ErrNoSigningKey
+}
+
+// Sign issues a token with the current signing key, falling back to HS256
+// when no asymmetric keys are configured.
+func (ks *KeySet) Sign(c auth.AccessClaims) (string, error) {
+	k, err := ks.signingKey()
+	if err != nil {
+		return "", err
+	}
+	if k == nil {
+		return SignedString(c, ks.cfg.Secret)
+	}
+	c.Iss, c.Aud = ks.cfg.Issuer, ks.cfg.Audience
+
+	header, err := json.Marshal(jwtHeader{Alg: k.alg, Typ: "JWT", Kid: k.kid})
+	if err != nil {
+		return "", fmt.Errorf("marshal header: %w", err)
+	}
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("marshal payload: %w", err)
+	}
+	sigInput := base64URL(header) + "." + base64URL(payload)
+
+	var sig []byte
+	switch k.alg {
+	case AlgRS256:
+		sum := sha256.Sum256([]byte(sigInput))
+		sig, err = k.priv.Sign(crand.Reader, sum[:], crypto.SHA256)
+	case AlgEdDSA:
+		sig, err = k.priv.Sign(nil, []byte(sigInput), crypto.Hash(0))
+	}
+	if err != nil {
+		return "", fmt.Errorf("sign: %w", err)
+	}
+	return sigInput + "." + base64URL(sig), nil
+}
+
+// Verify checks the signature against the key named by kid and validates
+// the claims. The alg header must match the key, so a public key can never
+// be used as an HMAC secret.
+func (ks *KeySet) Verify(token string) (*auth.AccessClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenInvalid
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("decode header: %w", err)
+	}
+	var h jwtHeader
+	if err := json.Unmarshal(headerJSON, &h); err != nil {
+		return nil, fmt.Errorf("unmarshal header: %w", err)
+	}
+
+	if h.Alg == AlgHS256 {
+		ks.mu.RLock()
+		hmacOK := len(ks.keys) == 0 || ks.cfg.AcceptHS256
+		ks.mu.RUnlock()
+		if !hmacOK || len(ks.cfg.Secret) == 0 {
+			return nil, ErrTokenInvalid
+		}
+		return ParseAndValidate(token, ks.cfg.Secret)
+	}
+
+	ks.mu.RLock()
+	k := ks.byKid[h.Kid]
+	ks.mu.RUnlock()
+	if k == nil || k.alg != h.Alg {
+		return nil, ErrTokenInvalid
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("decode signature: %w", err)
+	}
+	sigInput := []byte(parts[0] + "." + parts[1])
+	switch pub := k.pub.(type) {
+	case *rsa.PublicKey:
+		sum := sha256.Sum256(sigInput)
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig) != nil {
+			return nil, ErrTokenInvalid
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, sigInput, sig) {
+			return nil, ErrTokenInvalid
+		}
+	default:
+		return nil, ErrTokenInvalid
+	}
+	claims, err := decodeClaims(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	if claims.Iss != ks.cfg.Issuer || claims.Aud != ks.cfg.Audience {
+		return nil, ErrTokenInvalid
+	}
+	return claims, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// JWK is the public half of a key as published in the JWKS document.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS lists every loaded asymmetric key, including scheduled and retired
+// ones, so verifiers learn about a key before it signs anything.
+func (ks *KeySet) JWKS() JWKSet {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	out := JWKSet{Keys: make([]JWK, 0, len(ks.keys))}
+	for _, k := range ks.keys {
+		j := JWK{Kid: k.kid, Use: "sig", Alg: k.alg}
+		switch pub := k.pub.(type) {
+		case *rsa.PublicKey:
+			j.Kty = "RSA"
+			j.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			j.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			j.Kty = "OKP"
+			j.Crv = "Ed25519"
+			j.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		out.Keys = append(out.Keys, j)
+	}
+	return out
+}
+
+// JWKSHandler serves /.well-known/jwks.json.
+func JWKSHandler(ks *KeySet) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		_ = json.NewEncoder(w).Encode(ks.JWKS())
+	})
+}
+
+func loadKey(kc KeyConfig) (*jwtKey, error) {
+	if kc.Kid == "" {
+		return nil, errors.New("kid is required")
+	}
+	if kc.Alg != AlgRS256 && kc.Alg != AlgEdDSA {
+		return nil, fmt.Errorf("unsupported alg %q", kc.Alg)
+	}
+	k := &jwtKey{kid: kc.Kid, alg: kc.Alg, activeFrom: kc.ActiveFrom}
+
+	switch {
+	case kc.PrivateKeyFile != "":
+		priv, err := readPrivateKey(kc.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		k.priv, k.pub = priv, priv.Public()
+	case kc.PublicKeyFile != "":
+		pub, err := readPublicKey(kc.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		k.pub = pub
+	default:
+		return nil, errors.New("private_key_file or public_key_file is required")
+	}
+
+	switch pub := k.pub.(type) {
+	case *rsa.PublicKey:
+		if kc.Alg != AlgRS256 {
+			return nil, fmt.Errorf("RSA key cannot be used with %s", kc.Alg)
+		}
+		if pub.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA key must be at least %d bits", minRSABits)
+		}
+	case ed25519.PublicKey:
+		if kc.Alg != AlgEdDSA {
+			return nil, fmt.Errorf("Ed25519 key cannot be used with %s", kc.Alg)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", pub)
+	}
+	return k, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block", path)
+	}
+	return block, nil
+}
+
+func readPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	var key any
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unexpected PEM type %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported private key", path)
+	}
+	return signer, nil
+}
+
+func readPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	var key any
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unexpected PEM type %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	domainauth "github.com/NordCoder/Pingerus/internal/domain/auth"
+	"github.com/NordCoder/Pingerus/internal/domain/user"
+	"github.com/NordCoder/Pingerus/internal/repository/postgres"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrUnknownProvider     = errors.New("unknown sso provider")
+	ErrSSOUnavailable      = errors.New("sso provider unavailable")
+	ErrSSOFailed           = errors.New("sso login failed")
+	ErrSSOEmailNotVerified = errors.New("sso provider did not verify the email address")
+	ErrSSODomainNotAllowed = errors.New("email domain not allowed for this sso provider")
+	ErrSSONoAccount        = errors.New("no account for this sso identity")
+)
+
+// SSO is what the OIDC login flow needs.
+type SSO struct {
+	Identities domainauth.IdentityRepo
+	States     domainauth.OIDCStateRepo
+	Providers  []OIDCProviderConfig
+	// HTTPClient talks to the providers; tests point it at a mock IdP.
+	HTTPClient *http.Client
+	StateTTL   time.Duration
+}
+
+func (u *Usecase) OIDCProviders() []domainauth.OIDCProviderInfo {
+	out := make([]domainauth.OIDCProviderInfo, 0, len(u.providerOrder))
+	for _, name := range u.providerOrder {
+		p := u.providers[name]
+		out = append(out, domainauth.OIDCProviderInfo{Name: p.cfg.Name, DisplayName: p.cfg.DisplayName})
+	}
+	return out
+}
+
+func (u *Usecase) StartOIDC(ctx context.Context, provider string) (string, string, error) {
+	p, ok := u.providers[provider]
+	if !ok {
+		return "", "", ErrUnknownProvider
+	}
+	meta, err := p.discover(ctx)
+	if err != nil {
+		u.cfg.Logger.Warn("oidc discovery failed", zap.String("provider", provider), zap.Error(err))
+		return "", "", ErrSSOUnavailable
+	}
+
+	var secrets [3]string
+	for i := range secrets {
+		if secrets[i], err = GenerateRawToken(32); err != nil {
+			return "", "", fmt.Errorf("gen oidc state: %w", err)
+		}
+	}
+	state, nonce, verifier := secrets[0], secrets[1], secrets[2]
+	if err := u.sso.States.Create(ctx, &domainauth.OIDCState{
+		StateHash:    HashToken(state),
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    u.cfg.Now().Add(u.sso.StateTTL),
+	}); err != nil {
+		return "", "", err
+	}
+	return p.authURL(meta, state, nonce, verifier), state, nil
+}
+
+func (u *Usecase) CompleteOIDC(ctx context.Context, provider, code, state string) (*user.User, string, string, string, error) {
+	p, ok := u.providers[provider]
+	if !ok {
+		return nil, "", "", "", ErrUnknownProvider
+	}
+	st, err := u.sso.States.Consume(ctx, HashToken(state))
+	if err != nil {
+		if errors.Is(err, postgres.ErrNotFound) {
+			return nil, "", "", "", ErrInvalidToken
+		}
+		return nil, "", "", "", err
+	}
+	if st.Provider != provider {
+		return nil, "", "", "", ErrInvalidToken
+	}
+
+	meta, err := p.discover(ctx)
+	if err != nil {
+		u.cfg.Logger.Warn("oidc discovery failed", zap.String("provider", provider), zap.Error(err))
+		return nil, "", "", "", ErrSSOUnavailable
+	}
+	raw, err := p.exchange(ctx, meta, code, st.CodeVerifier)
+	if err == nil {
+		var claims *idTokenClaims
+		if claims, err = p.verifyIDToken(ctx, meta, raw, st.Nonce); err == nil {
+			return u.ssoSignIn(ctx, p, claims)
+		}
+	}
+	u.cfg.Logger.Warn("oidc login failed", zap.String("provider", provider), zap.Error(err))
+	u.record(ctx, 0, domainauth.EventSignInFailed, map[string]string{"sso": provider, "reason": "oidc"})
+	return nil, "", "", "", ErrSSOFailed
+}
+
+func (u *Usecase) ssoSignIn(ctx context.Context, p *oidcProvider, c *idTokenClaims) (*user.User, string, string, string, error) {
+	email := normalizeEmail(c.Email)
+	if !p.domainAllowed(email) {
+		return nil, "", "", "", ErrSSODomainNotAllowed
+	}
+	usr, err := u.ssoUser(ctx, p, c, email)
+	if err != nil {
+		return nil, "", "", "", err
+	}
+	if !usr.Active {
+		u.record(ctx, usr.ID, domainauth.EventSignInFailed, map[string]string{"sso": p.cfg.Name, "reason": "inactive"})
+		return nil, "", "", "", ErrInvalidCredentials
+	}
+	access, refresh, mfaToken, err := u.startSession(ctx, usr, map[string]string{"sso": p.cfg.Name})
+	if err != nil {
+		return nil, "", "", "", err
+	}
+	return usr, access, refresh, mfaToken, nil
+}
+
+// ssoUser finds the account linked to the identity, otherwise links the
+// account with the verified email or, if the provider allows it, creates one.
+func (u *Usecase) ssoUser(ctx context.Context, p *oidcProvider, c *idTokenClaims, email string) (*user.User, error) {
+	ident, err := u.sso.Identities.Find(ctx, p.cfg.Name, c.Sub)
+	if err == nil {
+		return u.users.GetByID(ctx, ident.UserID)
+	}
+	if !errors.Is(err, postgres.ErrNotFound) {
+		return nil, err
+	}
+
+	if email == "" || !bool(c.EmailVerified) {
+		return nil, ErrSSOEmailNotVerified
+	}
+	usr, err := u.users.GetByEmail(ctx, email)
+	provision := errors.Is(err, postgres.ErrNotFound)
+	if err != nil && !provision {
+		return nil, err
+	}
+	if provision && !p.cfg.AutoProvision {
+		return nil, ErrSSONoAccount
+	}
+
+	if provision {
+		// the account has no usable password until the user resets it
+		raw, err := GenerateRawToken(32)
+		if err != nil {
+			return nil, fmt.Errorf("gen password: %w", err)
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(raw), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash password: %w", err)
+		}
+		now := u.cfg.Now()
+		usr = &user.User{Email: email, Password: string(hash), CreatedAt: now, UpdatedAt: now}
+	}
+
+	err = u.tx.WithTx(ctx, func(ctx context.Context) error {
+		if provision {
+			if err := u.users.Create(ctx, usr); err != nil {
+				return err
+			}
+		}
+		if !usr.EmailVerified() {
+			if err := u.users.MarkEmailVerified(ctx, usr.ID, email); err != nil {
+				return err
+			}
+			now := u.cfg.Now()
+			usr.EmailVerifiedAt = &now
+		}
+		return u.sso.Identities.Create(ctx, &domainauth.Identity{
+			UserID:   usr.ID,
+			Provider: p.cfg.Name,
+			Subject:  c.Sub,
+			Email:    email,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if provision {
+		u.record(ctx, usr.ID, domainauth.EventSignUp, map[string]string{"sso": p.cfg.Name})
+	}
+	u.record(ctx, usr.ID, domainauth.EventSSOLinked, map[string]string{"provider": p.cfg.Name, "subject": c.Sub})
+	return usr, nil
+}
@@ -0,0 +1,434 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	oidcClockSkew = time.Minute
+	// jwksMinRefresh throttles JWKS refetches triggered by unknown kids.
+	jwksMinRefresh = time.Minute
+	oidcMaxBody    = 1 << 20
+)
+
+// OIDCProviderConfig is one identity provider. ClientSecret may be empty for
+// public clients; PKCE is always used.
+type OIDCProviderConfig struct {
+	Name          string
+	DisplayName   string
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string
+	AutoProvision bool
+	// AllowedDomains restricts logins to these email domains when set.
+	AllowedDomains []string
+}
+
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type idTokenClaims struct {
+	Iss           string    `json:"iss"`
+	Sub           string    `json:"sub"`
+	Aud           audience  `json:"aud"`
+	Azp           string    `json:"azp"`
+	Exp           int64     `json:"exp"`
+	Iat           int64     `json:"iat"`
+	Nonce         string    `json:"nonce"`
+	Email         string    `json:"email"`
+	EmailVerified claimBool `json:"email_verified"`
+}
+
+// audience accepts both forms of the aud claim.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (a audience) contains(s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// claimBool accepts "true" as well; some providers send email_verified as a
+// string.
+type claimBool bool
+
+func (c *claimBool) UnmarshalJSON(b []byte) error {
+	var v bool
+	if err := json.Unmarshal(b, &v); err == nil {
+		*c = claimBool(v)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*c = claimBool(strings.EqualFold(s, "true"))
+	return nil
+}
+
+// oidcProvider talks to one identity provider. Discovery and keys are
+// fetched lazily so an unreachable provider does not stop the gateway.
+type oidcProvider struct {
+	cfg    OIDCProviderConfig
+	client *http.Client
+	now    func() time.Time
+
+	mu          sync.Mutex
+	meta        *oidcMetadata
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+func newOIDCProvider(cfg OIDCProviderConfig, client *http.Client, now func() time.Time) *oidcProvider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	if cfg.DisplayName == "" {
+		cfg.DisplayName = cfg.Name
+	}
+	for i, d := range cfg.AllowedDomains {
+		cfg.AllowedDomains[i] = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@"))
+	}
+	return &oidcProvider{cfg: cfg, client: client, now: now}
+}
+
+func (p *oidcProvider) discover(ctx context.Context) (*oidcMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	var m oidcMetadata
+	wellKnown := strings.TrimRight(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, &m); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if m.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("discovery: issuer %q does not match %q", m.Issuer, p.cfg.Issuer)
+	}
+	if m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || m.JWKSURI == "" {
+		return nil, errors.New("discovery: incomplete provider metadata")
+	}
+	p.meta = &m
+	return p.meta, nil
+}
+
+func (p *oidcProvider) authURL(m *oidcMetadata, state, nonce, verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(sum[:]))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(m.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return m.AuthorizationEndpoint + sep + q.Encode()
+}
+
+// exchange redeems an authorization code and returns the raw ID token.
+func (p *oidcProvider) exchange(ctx context.Context, m *oidcMetadata, code, verifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("code_verifier", verifier)
+	if p.cfg.ClientSecret == "" {
+		form.Set("client_id", p.cfg.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, oidcMaxBody)).Decode(&body); err != nil {
+		return "", fmt.Errorf("token response: status %d: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("token response: status %d: %s %s", resp.StatusCode, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("token response: no id_token")
+	}
+	return body.IDToken, nil
+}
+
+// verifyIDToken checks the signature against the provider's JWKS and the
+// claims required by OpenID Connect Core 3.1.3.7.
+func (p *oidcProvider) verifyIDToken(ctx context.Context, m *oidcMetadata, raw, nonce string) (*idTokenClaims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("id token: malformed")
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("id token header: %w", err)
+	}
+	var h jwtHeader
+	if err := json.Unmarshal(headerJSON, &h); err != nil {
+		return nil, fmt.Errorf("id token header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("id token signature: %w", err)
+	}
+	key, err := p.key(ctx, m, h.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(h.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("id token payload: %w", err)
+	}
+	var c idTokenClaims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, fmt.Errorf("id token payload: %w", err)
+	}
+
+	now := p.now()
+	switch {
+	case c.Iss != m.Issuer:
+		return nil, fmt.Errorf("id token: issuer %q", c.Iss)
+	case !c.Aud.contains(p.cfg.ClientID):
+		return nil, errors.New("id token: audience mismatch")
+	case len(c.Aud) > 1 && c.Azp != p.cfg.ClientID:
+		return nil, errors.New("id token: azp mismatch")
+	case c.Sub == "":
+		return nil, errors.New("id token: no subject")
+	case time.Unix(c.Exp, 0).Before(now.Add(-oidcClockSkew)):
+		return nil, errors.New("id token: expired")
+	case time.Unix(c.Iat, 0).After(now.Add(oidcClockSkew)):
+		return nil, errors.New("id token: issued in the future")
+	case subtle.ConstantTimeCompare([]byte(c.Nonce), []byte(nonce)) != 1:
+		return nil, errors.New("id token: nonce mismatch")
+	}
+	return &c, nil
+}
+
+// key returns the signing key named kid, refetching the JWKS once in a
+// while so provider key rotation is picked up.
+func (p *oidcProvider) key(ctx context.Context, m *oidcMetadata, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if k := p.lookupKey(kid); k != nil {
+		return k, nil
+	}
+	if !p.keysFetched.IsZero() && p.now().Sub(p.keysFetched) < jwksMinRefresh {
+		return nil, fmt.Errorf("id token: unknown key %q", kid)
+	}
+
+	var set JWKSet
+	if err := p.getJSON(ctx, m.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, j := range set.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		k, err := parseJWK(j)
+		if err != nil {
+			continue
+		}
+		keys[j.Kid] = k
+	}
+	p.keys, p.keysFetched = keys, p.now()
+
+	if k := p.lookupKey(kid); k != nil {
+		return k, nil
+	}
+	return nil, fmt.Errorf("id token: unknown key %q", kid)
+}
+
+// lookupKey also accepts a missing kid when the provider has a single key.
+func (p *oidcProvider) lookupKey(kid string) crypto.PublicKey {
+	if k, ok := p.keys[kid]; ok {
+		return k
+	}
+	if kid == "" && len(p.keys) == 1 {
+		for _, k := range p.keys {
+			return k
+		}
+	}
+	return nil
+}
+
+func (p *oidcProvider) domainAllowed(email string) bool {
+	if len(p.cfg.AllowedDomains) == 0 {
+		return true
+	}
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 {
+		return false
+	}
+	domain := email[at+1:]
+	for _, d := range p.cfg.AllowedDomains {
+		if domain == d {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *oidcProvider) getJSON(ctx context.Context, u string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, oidcMaxBody)).Decode(out)
+}
+
+func verifySignature(alg string, key crypto.PublicKey, input, sig []byte) error {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg != AlgRS256 {
+			break
+		}
+		sum := sha256.Sum256(input)
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig) != nil {
+			return errors.New("id token: bad signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if alg != "ES256" || k.Curve != elliptic.P256() || len(sig) != 64 {
+			break
+		}
+		sum := sha256.Sum256(input)
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(k, sum[:], r, s) {
+			return errors.New("id token: bad signature")
+		}
+		return nil
+	case ed25519.PublicKey:
+		if alg != AlgEdDSA {
+			break
+		}
+		if !ed25519.Verify(k, input, sig) {
+			return errors.New("id token: bad signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("id token: alg %q does not match key", alg)
+}
+
+func parseJWK(j JWK) (crypto.PublicKey, error) {
+	dec := base64.RawURLEncoding.DecodeString
+	switch j.Kty {
+	case "RSA":
+		n, err := dec(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := dec(j.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if j.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := dec(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := dec(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		// ecdsa.Verify rejects points that are not on the curve
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := dec(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("bad Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported kty %q", j.Kty)
+}
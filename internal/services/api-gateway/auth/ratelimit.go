@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	domainauth "github.com/NordCoder/Pingerus/internal/domain/auth"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RetryAfterHeader carries the seconds a rate-limited caller should wait.
+const RetryAfterHeader = "retry-after"
+
+type RateLimitConfig struct {
+	Window time.Duration
+	// MaxPerIP caps attempts from an IP and MaxPerEmail those from an IP on
+	// one account per Window; 0 disables the cap.
+	MaxPerIP    int
+	MaxPerEmail int
+	Lockout     domainauth.LockoutPolicy
+}
+
+// rateLimitedFullMethods are limited per IP; those reading an email from the
+// request are limited per pair of IP and account as well, and failures lock
+// out only that pair. Nothing counts per account alone, so nobody can
+// throttle or lock a known user out from elsewhere.
+var rateLimitedFullMethods = map[string]bool{
+	"/pingerus.v1.AuthService/SignUp": true,
+	"/pingerus.v1.AuthService/SignIn": true,
+
+	"/pingerus.v1.AuthService/VerifyEmail":          true,
+	"/pingerus.v1.AuthService/RequestPasswordReset": true,
+	"/pingerus.v1.AuthService/ResetPassword":        true,
+	"/pingerus.v1.AuthService/ChangePassword":       true,
+	"/pingerus.v1.AuthService/VerifyMFA":            true,
+	"/pingerus.v1.AuthService/CompleteOIDCLogin":    true,
+}
+
+type RateLimiter struct {
+	repo domainauth.RateLimitRepo
+	cfg  RateLimitConfig
+	log  *zap.Logger
+	now  func() time.Time
+}
+
+func NewRateLimiter(repo domainauth.RateLimitRepo, cfg RateLimitConfig, log *zap.Logger) *RateLimiter {
+	if log == nil {
+		log = zap.L()
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = time.Minute
+	}
+	return &RateLimiter{
+		repo: repo,
+		cfg:  cfg,
+		log:  log.With(zap.String("component", "auth.ratelimit")),
+		now:  time.Now,
+	}
+}
+
+type rlKey struct {
+	key string
+	max int
+	// lockout makes Unauthenticated results count toward a lockout
+	lockout bool
+}
+
+// keys needs the client resolved by UnaryClientInterceptor.
+func (l *RateLimiter) keys(ctx context.Context, req interface{}) []rlKey {
+	var out []rlKey
+	ip := ClientFromCtx(ctx).IP
+	if ip != "" {
+		out = append(out, rlKey{key: "ip:" + ip, max: l.cfg.MaxPerIP})
+	}
+	if r, ok := req.(interface{ GetEmail() string }); ok {
+		if email := normalizeEmail(r.GetEmail()); email != "" {
+			if ip != "" {
+				out = append(out, rlKey{key: "ip-email:" + ip + "/" + email, max: l.cfg.MaxPerEmail, lockout: true})
+			}
+		}
+	}
+	return out
+}
+
+// UnaryRateLimitInterceptor rejects callers over their attempt budget or
+// locked out after repeated Unauthenticated results. Storage errors fail
+// open.
+func UnaryRateLimitInterceptor(l *RateLimiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
+		if !rateLimitedFullMethods[info.FullMethod] {
+			return next(ctx, req)
+		}
+
+		keys := l.keys(ctx, req)
+		var wait time.Duration
+		for _, k := range keys {
+			st, err := l.repo.Hit(ctx, k.key, l.cfg.Window)
+			if err != nil {
+				l.log.Warn("rate limit hit failed", zap.String("key", k.key), zap.Error(err))
+				continue
+			}
+			wait = max(wait, l.retryAfter(st, k.max))
+		}
+		if wait > 0 {
+			return nil, l.exhausted(ctx, wait)
+		}
+
+		resp, err := next(ctx, req)
+
+		switch status.Code(err) {
+		case codes.OK:
+			for _, k := range keys {
+				if err := l.repo.Reset(ctx, k.key); err != nil {
+					l.log.Warn("rate limit reset failed", zap.String("key", k.key), zap.Error(err))
+				}
+			}
+		case codes.Unauthenticated:
+			for _, k := range keys {
+				if !k.lockout {
+					continue
+				}
+				if _, ferr := l.repo.Fail(ctx, k.key, l.cfg.Lockout); ferr != nil {
+					l.log.Warn("rate limit fail failed", zap.String("key", k.key), zap.Error(ferr))
+				}
+			}
+		}
+		return resp, err
+	}
+}
+
+func (l *RateLimiter) retryAfter(st *domainauth.RateLimitState, maxAttempts int) time.Duration {
+	now := l.now()
+	var wait time.Duration
+	if st.LockedUntil != nil && st.LockedUntil.After(now) {
+		wait = st.LockedUntil.Sub(now)
+	}
+	if maxAttempts > 0 && st.Attempts > maxAttempts {
+		wait = max(wait, st.WindowStart.Add(l.cfg.Window).Sub(now))
+	}
+	return wait
+}
+
+func (l *RateLimiter) exhausted(ctx context.Context, wait time.Duration) error {
+	secs := int64(math.Ceil(wait.Seconds()))
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RetryAfterHeader, strconv.FormatInt(secs, 10)))
+	return status.Errorf(codes.ResourceExhausted, "too many attempts, retry in %ds", secs)
+}
+
+// Prune deletes stale counters every interval until ctx is done.
+func (l *RateLimiter) Prune(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			keep := max(l.cfg.Window, l.cfg.Lockout.FailureTTL, l.cfg.Lockout.Max)
+			n, err := l.repo.Prune(ctx, l.now().Add(-keep))
+			if err != nil {
+				l.log.Warn("rate limit prune failed", zap.Error(err))
+				continue
+			}
+			if n > 0 {
+				l.log.Debug("rate limit pruned", zap.Int64("rows", n))
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	domainauth "github.com/NordCoder/Pingerus/internal/domain/auth"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrNoSession       = errors.New("current session unknown: refresh cookie required")
+)
+
+func (u *Usecase) ListSessions(ctx context.Context, userID int64, currentRaw string) ([]*domainauth.Session, error) {
+	sessions, err := u.rt.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if current := u.currentFamily(ctx, userID, currentRaw); current != "" {
+		for _, s := range sessions {
+			s.Current = s.ID == current
+		}
+	}
+	return sessions, nil
+}
+
+func (u *Usecase) RevokeSession(ctx context.Context, userID int64, id string) error {
+	ok, err := u.rt.RevokeSession(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrSessionNotFound
+	}
+	u.record(ctx, userID, domainauth.EventSessionRevoked, map[string]string{"family_id": id})
+	return nil
+}
+
+func (u *Usecase) RevokeOtherSessions(ctx context.Context, userID int64, currentRaw string) (int64, error) {
+	current := u.currentFamily(ctx, userID, currentRaw)
+	if current == "" {
+		return 0, ErrNoSession
+	}
+	n, err := u.rt.RevokeOtherSessions(ctx, userID, current)
+	if err != nil {
+		return 0, err
+	}
+	u.record(ctx, userID, domainauth.EventSessionsRevoked, map[string]string{
+		"kept_family_id": current,
+		"revoked":        strconv.FormatInt(n, 10),
+	})
+	return n, nil
+}
+
+// currentFamily resolves the caller's refresh token to its family, or ""
+// if it is missing, inactive or belongs to someone else.
+func (u *Usecase) currentFamily(ctx context.Context, userID int64, raw string) string {
+	if raw == "" {
+		return ""
+	}
+	rec, err := u.rt.FindValid(ctx, HashToken(raw))
+	if err != nil || rec.UserID != userID {
+		return ""
+	}
+	return rec.FamilyID
+}
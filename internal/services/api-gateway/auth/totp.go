@@ -0,0 +1,411 @@
+package auth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	domainauth "github.com/NordCoder/Pingerus/internal/domain/auth"
+	"github.com/NordCoder/Pingerus/internal/domain/user"
+	"github.com/NordCoder/Pingerus/internal/repository/postgres"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// RFC 6238 parameters understood by every common authenticator app.
+const (
+	totpPeriod      = 30
+	totpDigits      = 6
+	totpSkew        = 1
+	totpSecretBytes = 20
+
+	recoveryCodeCount = 10
+	// mfaMaxAttempts wrong codes burn an MFA challenge.
+	mfaMaxAttempts = 5
+)
+
+var (
+	ErrInvalidMFACode = errors.New("invalid authentication code")
+	ErrMFAEnabled     = errors.New("two-factor authentication already enabled")
+	ErrMFANotEnabled  = errors.New("two-factor authentication not enabled")
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// VerifyMFA completes a SignIn that returned an MFA token, with either a TOTP
+// code or an unused recovery code.
+func (u *Usecase) VerifyMFA(ctx context.Context, mfaToken, code, recoveryCode string) (*user.User, string, string, error) {
+	ch, err := u.mfa.GetChallenge(ctx, HashToken(mfaToken))
+	if err != nil {
+		if errors.Is(err, postgres.ErrNotFound) {
+			return nil, "", "", ErrInvalidCredentials
+		}
+		return nil, "", "", err
+	}
+	usr, err := u.users.GetByID(ctx, ch.UserID)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if !usr.Active {
+		return nil, "", "", ErrInvalidCredentials
+	}
+
+	method := "totp"
+	if recoveryCode != "" {
+		method = "recovery_code"
+	}
+	// the challenge and the code are used up together: a recovery code is
+	// not burned by a challenge that turns out to be spent
+	err = u.tx.WithTx(ctx, func(ctx context.Context) error {
+		consumed, err := u.mfa.ConsumeChallenge(ctx, ch.ID)
+		if err != nil {
+			return err
+		}
+		if !consumed {
+			return ErrInvalidCredentials
+		}
+		var ok bool
+		if recoveryCode != "" {
+			ok, err = u.mfa.UseRecoveryCode(ctx, usr.ID, HashToken(normalizeRecoveryCode(recoveryCode)))
+		} else {
+			ok, err = u.checkTOTP(ctx, usr.ID, code)
+		}
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		return nil
+	})
+	if errors.Is(err, ErrInvalidMFACode) {
+		return nil, "", "", u.failChallenge(ctx, ch, method)
+	}
+	if err != nil {
+		return nil, "", "", err
+	}
+	access, refresh, err := u.issueTokens(ctx, usr.ID, "")
+	if err != nil {
+		return nil, "", "", err
+	}
+	if method == "recovery_code" {
+		u.record(ctx, usr.ID, domainauth.EventRecoveryUsed, nil)
+	}
+	u.record(ctx, usr.ID, domainauth.EventSignIn, map[string]string{"mfa": method})
+	return usr, access, refresh, nil
+}
+
+func (u *Usecase) failChallenge(ctx context.Context, ch *domainauth.MFAChallenge, method string) error {
+	n, err := u.mfa.FailChallenge(ctx, ch.ID)
+	if err != nil {
+		return err
+	}
+	if n >= mfaMaxAttempts {
+		if _, err := u.mfa.ConsumeChallenge(ctx, ch.ID); err != nil {
+			return err
+		}
+	}
+	u.record(ctx, ch.UserID, domainauth.EventMFAFailed, map[string]string{"method": method})
+	return ErrInvalidMFACode
+}
+
+// startChallenge returns the raw token the client passes to VerifyMFA.
+func (u *Usecase) startChallenge(ctx context.Context, userID int64) (string, error) {
+	raw, err := GenerateRawToken(32)
+	if err != nil {
+		return "", fmt.Errorf("gen mfa token: %w", err)
+	}
+	ch := &domainauth.MFAChallenge{
+		UserID:    userID,
+		TokenHash: HashToken(raw),
+		ExpiresAt: u.cfg.Now().Add(u.cfg.MFAChallengeTTL),
+	}
+	if err := u.mfa.CreateChallenge(ctx, ch); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+func (u *Usecase) EnrollTOTP(ctx context.Context, userID int64) (string, string, error) {
+	usr, err := u.users.GetByID(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	secret := make([]byte, totpSecretBytes)
+	if _, err := crand.Read(secret); err != nil {
+		return "", "", fmt.Errorf("gen totp secret: %w", err)
+	}
+	enc, err := u.sealSecret(secret)
+	if err != nil {
+		return "", "", err
+	}
+	if err := u.mfa.SavePendingTOTP(ctx, userID, enc); err != nil {
+		if errors.Is(err, postgres.ErrConflict) {
+			return "", "", ErrMFAEnabled
+		}
+		return "", "", err
+	}
+	encoded := b32.EncodeToString(secret)
+	return encoded, u.otpauthURI(usr.Email, encoded), nil
+}
+
+func (u *Usecase) ConfirmTOTP(ctx context.Context, userID int64, code string) ([]string, error) {
+	t, err := u.mfa.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, postgres.ErrNotFound) {
+			return nil, ErrMFANotEnabled
+		}
+		return nil, err
+	}
+	if t.Enabled() {
+		return nil, ErrMFAEnabled
+	}
+	secret, err := u.openSecret(t.SecretEnc)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := matchTOTP(secret, code, u.cfg.Now(), t.LastUsedStep)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = u.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := u.mfa.EnableTOTP(ctx, userID, step); err != nil {
+			return err
+		}
+		return u.mfa.ReplaceRecoveryCodes(ctx, userID, hashes)
+	})
+	if err != nil {
+		if errors.Is(err, postgres.ErrConflict) {
+			return nil, ErrMFAEnabled
+		}
+		return nil, err
+	}
+	u.record(ctx, userID, domainauth.EventMFAEnabled, nil)
+	return codes, nil
+}
+
+// DisableTOTP asks for both the password and a current code so a stolen
+// session alone cannot turn the second factor off.
+func (u *Usecase) DisableTOTP(ctx context.Context, userID int64, password, code string) error {
+	usr, err := u.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(usr.Password), []byte(password)) != nil {
+		return ErrInvalidCredentials
+	}
+	if err := u.requireTOTP(ctx, userID, code); err != nil {
+		return err
+	}
+	if err := u.tx.WithTx(ctx, func(ctx context.Context) error {
+		return u.mfa.DeleteTOTP(ctx, userID)
+	}); err != nil {
+		return err
+	}
+	u.record(ctx, userID, domainauth.EventMFADisabled, nil)
+	return nil
+}
+
+// RegenerateRecoveryCodes invalidates every earlier recovery code.
+func (u *Usecase) RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) ([]string, error) {
+	if err := u.requireTOTP(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := u.tx.WithTx(ctx, func(ctx context.Context) error {
+		return u.mfa.ReplaceRecoveryCodes(ctx, userID, hashes)
+	}); err != nil {
+		return nil, err
+	}
+	u.record(ctx, userID, domainauth.EventRecoveryReset, nil)
+	return codes, nil
+}
+
+// mfaEnabled reports whether SignIn must stop at an MFA challenge.
+func (u *Usecase) mfaEnabled(ctx context.Context, userID int64) (bool, error) {
+	t, err := u.mfa.GetTOTP(ctx, userID)
+	if errors.Is(err, postgres.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return t.Enabled(), nil
+}
+
+func (u *Usecase) requireTOTP(ctx context.Context, userID int64, code string) error {
+	enabled, err := u.mfaEnabled(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return ErrMFANotEnabled
+	}
+	ok, err := u.checkTOTP(ctx, userID, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		u.record(ctx, userID, domainauth.EventMFAFailed, map[string]string{"method": "totp"})
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// checkTOTP validates code against the enabled secret and marks its time
+// step used.
+func (u *Usecase) checkTOTP(ctx context.Context, userID int64, code string) (bool, error) {
+	t, err := u.mfa.GetTOTP(ctx, userID)
+	if errors.Is(err, postgres.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !t.Enabled() {
+		return false, nil
+	}
+	secret, err := u.openSecret(t.SecretEnc)
+	if err != nil {
+		return false, err
+	}
+	step, ok := matchTOTP(secret, code, u.cfg.Now(), t.LastUsedStep)
+	if !ok {
+		return false, nil
+	}
+	return u.mfa.UseStep(ctx, userID, step)
+}
+
+func (u *Usecase) otpauthURI(email, secret string) string {
+	issuer := u.cfg.MFAIssuer
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(issuer + ":" + email)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// matchTOTP accepts codes up to totpSkew steps away from now that are newer
+// than lastStep, returning the matching step.
+func matchTOTP(secret []byte, code string, now time.Time, lastStep int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	cur := now.Unix() / totpPeriod
+	for s := cur - totpSkew; s <= cur+totpSkew; s++ {
+		if s <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(secret, s)), []byte(code)) == 1 {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+func totpCode(secret []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	off := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+	mod := uint32(1)
+	for range totpDigits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, bin%mod)
+}
+
+// newRecoveryCodes returns codes formatted for the user and the hashes to
+// store.
+func newRecoveryCodes() ([]string, []string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	size := big.NewInt(int64(len(alphabet)))
+	buf := make([]byte, 8)
+	for i := range codes {
+		for j := range buf {
+			n, err := crand.Int(crand.Reader, size)
+			if err != nil {
+				return nil, nil, fmt.Errorf("gen recovery code: %w", err)
+			}
+			buf[j] = alphabet[n.Int64()]
+		}
+		codes[i] = string(buf[:4]) + "-" + string(buf[4:])
+		hashes[i] = HashToken(normalizeRecoveryCode(codes[i]))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(s string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(s))
+}
+
+func (u *Usecase) mfaAEAD() (cipher.AEAD, error) {
+	key := u.cfg.MFAKey
+	if len(key) == 0 {
+		key = u.cfg.Secret
+	}
+	sum := sha256.Sum256(key)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (u *Usecase) sealSecret(secret []byte) ([]byte, error) {
+	aead, err := u.mfaAEAD()
+	if err != nil {
+		return nil, fmt.Errorf("totp cipher: %w", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := crand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("totp nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, secret, nil), nil
+}
+
+func (u *Usecase) openSecret(enc []byte) ([]byte, error) {
+	aead, err := u.mfaAEAD()
+	if err != nil {
+		return nil, fmt.Errorf("totp cipher: %w", err)
+	}
+	if len(enc) < aead.NonceSize() {
+		return nil, errors.New("totp secret: short ciphertext")
+	}
+	nonce, ct := enc[:aead.NonceSize()], enc[aead.NonceSize():]
+	secret, err := aead.Open(nil, nonce, ct, nil)
+	if err != nil {
+		return nil, fmt.Errorf("totp secret: %w", err)
+	}
+	return secret, nil
+}
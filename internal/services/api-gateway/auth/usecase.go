@@ -5,11 +5,16 @@ import (
 	"errors"
 	"fmt"
 	domainauth "github.com/NordCoder/Pingerus/internal/domain/auth"
+	"github.com/NordCoder/Pingerus/internal/domain/outbox"
 	"github.com/NordCoder/Pingerus/internal/domain/user"
+	"github.com/NordCoder/Pingerus/internal/repository/postgres"
+	"github.com/NordCoder/Pingerus/internal/services/api-gateway/policy"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -17,26 +22,92 @@ var (
 	ErrInvalidCredentials = errors.New("invalid email or password")
 	ErrEmailExists        = errors.New("email already registered")
 	ErrWeakPassword       = errors.New("password is too weak")
+	// ErrTokenReused is returned when a rotated refresh token is presented
+	// again; the session it belonged to has been revoked.
+	ErrTokenReused = errors.New("refresh token reuse detected")
 )
 
 type Config struct {
-	Secret     []byte
+	Secret []byte
+	// Keys signs access tokens; nil means HS256 with Secret.
+	Keys       *KeySet
 	AccessTTL  time.Duration
 	RefreshTTL time.Duration
-	Now        func() time.Time
+	// AdminEmails may read other users' auth events.
+	AdminEmails []string
+	// AppURL prefixes the links sent in verification and reset emails.
+	AppURL    string
+	VerifyTTL time.Duration
+	ResetTTL  time.Duration
+	// MFAKey encrypts stored TOTP secrets; Secret is used when empty.
+	MFAKey          []byte
+	MFAIssuer       string
+	MFAChallengeTTL time.Duration
+	Now             func() time.Time
+	Logger          *zap.Logger
+}
+
+// Mail is what the email verification and password reset flows need.
+type Mail struct {
+	Verify domainauth.OneTimeTokenRepo
+	Reset  domainauth.OneTimeTokenRepo
+	Outbox outbox.Repository
 }
 
 type Usecase struct {
-	users user.Repo
-	rt    domainauth.RefreshTokenRepo
-	cfg   Config
+	users   user.Repo
+	rt      domainauth.RefreshTokenRepo
+	apiKeys domainauth.APIKeyRepo
+	events  domainauth.EventRepo
+	mfa     domainauth.MFARepo
+	mail    Mail
+	sso     SSO
+	pol     *policy.Policy
+	tx      postgres.Transactor
+	cfg     Config
+
+	providers     map[string]*oidcProvider
+	providerOrder []string
 }
 
-func NewUseCase(users user.Repo, rt domainauth.RefreshTokenRepo, cfg Config) *Usecase {
+func NewUseCase(users user.Repo, rt domainauth.RefreshTokenRepo, apiKeys domainauth.APIKeyRepo, events domainauth.EventRepo, mfa domainauth.MFARepo, mail Mail, sso SSO, pol *policy.Policy, tx postgres.Transactor, cfg Config) *Usecase {
 	if cfg.Now == nil {
 		cfg.Now = func() time.Time { return time.Now().UTC() }
 	}
-	return &Usecase{users: users, rt: rt, cfg: cfg}
+	if cfg.Logger == nil {
+		cfg.Logger = zap.L()
+	}
+	if cfg.Keys == nil {
+		cfg.Keys = NewHS256KeySet(cfg.Secret)
+	}
+	if cfg.VerifyTTL <= 0 {
+		cfg.VerifyTTL = 48 * time.Hour
+	}
+	if cfg.ResetTTL <= 0 {
+		cfg.ResetTTL = time.Hour
+	}
+	if cfg.MFAChallengeTTL <= 0 {
+		cfg.MFAChallengeTTL = 5 * time.Minute
+	}
+	if cfg.MFAIssuer == "" {
+		cfg.MFAIssuer = "Pingerus"
+	}
+	for i, e := range cfg.AdminEmails {
+		cfg.AdminEmails[i] = normalizeEmail(e)
+	}
+	if sso.HTTPClient == nil {
+		sso.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if sso.StateTTL <= 0 {
+		sso.StateTTL = 10 * time.Minute
+	}
+	u := &Usecase{users: users, rt: rt, apiKeys: apiKeys, events: events, mfa: mfa, mail: mail, sso: sso, pol: pol, tx: tx, cfg: cfg}
+	u.providers = make(map[string]*oidcProvider, len(sso.Providers))
+	for _, pc := range sso.Providers {
+		u.providers[pc.Name] = newOIDCProvider(pc, sso.HTTPClient, cfg.Now)
+		u.providerOrder = append(u.providerOrder, pc.Name)
+	}
+	return u
 }
 
 func normalizeEmail(s string) string {
@@ -56,27 +127,57 @@ func (u *Usecase) SignUp(ctx context.Context, email, password string) (*user.Use
 		}
 		return nil, "", "", err
 	}
-	access, refresh, err := u.issueTokens(ctx, newUser.ID)
+	access, refresh, err := u.issueTokens(ctx, newUser.ID, "")
 	if err != nil {
 		return nil, "", "", err
 	}
+	u.record(ctx, newUser.ID, domainauth.EventSignUp, nil)
+	if err := u.sendVerification(ctx, newUser); err != nil {
+		// the account exists; the user can ask for another link
+		u.cfg.Logger.Warn("verification email not queued", zap.Int64("uid", newUser.ID), zap.Error(err))
+	}
 	return newUser, access, refresh, nil
 }
 
-func (u *Usecase) SignIn(ctx context.Context, email, password string) (*user.User, string, string, error) {
+func (u *Usecase) SignIn(ctx context.Context, email, password string) (*user.User, string, string, string, error) {
 	email = normalizeEmail(email)
 	uRec, err := u.users.GetByEmail(ctx, email)
 	if err != nil {
-		return nil, "", "", ErrInvalidCredentials
+		u.record(ctx, 0, domainauth.EventSignInFailed, map[string]string{"email": email, "reason": "unknown_email"})
+		return nil, "", "", "", ErrInvalidCredentials
 	}
 	if bcrypt.CompareHashAndPassword([]byte(uRec.Password), []byte(password)) != nil {
-		return nil, "", "", ErrInvalidCredentials
+		u.record(ctx, uRec.ID, domainauth.EventSignInFailed, map[string]string{"reason": "bad_password"})
+		return nil, "", "", "", ErrInvalidCredentials
 	}
-	access, refresh, err := u.issueTokens(ctx, uRec.ID)
+	if !uRec.Active {
+		u.record(ctx, uRec.ID, domainauth.EventSignInFailed, map[string]string{"reason": "inactive"})
+		return nil, "", "", "", ErrInvalidCredentials
+	}
+	access, refresh, mfaToken, err := u.startSession(ctx, uRec, nil)
 	if err != nil {
-		return nil, "", "", err
+		return nil, "", "", "", err
+	}
+	return uRec, access, refresh, mfaToken, nil
+}
+
+// startSession issues tokens for an authenticated user, or an MFA token if
+// a second factor is still required.
+func (u *Usecase) startSession(ctx context.Context, usr *user.User, meta map[string]string) (access, refresh, mfaToken string, err error) {
+	mfa, err := u.mfaEnabled(ctx, usr.ID)
+	if err != nil {
+		return "", "", "", err
+	}
+	if mfa {
+		mfaToken, err = u.startChallenge(ctx, usr.ID)
+		return "", "", mfaToken, err
 	}
-	return uRec, access, refresh, nil
+	access, refresh, err = u.issueTokens(ctx, usr.ID, "")
+	if err != nil {
+		return "", "", "", err
+	}
+	u.record(ctx, usr.ID, domainauth.EventSignIn, meta)
+	return access, refresh, "", nil
 }
 
 func (u *Usecase) Refresh(ctx context.Context, raw string) (string, string, int64, error) {
@@ -84,21 +185,34 @@ func (u *Usecase) Refresh(ctx context.Context, raw string) (string, string, int6
 		return "", "", 0, ErrInvalidCredentials
 	}
 	hash := HashToken(raw)
-	rec, err := u.rt.FindValid(ctx, hash)
+	rec, err := u.rt.FindByHash(ctx, hash)
 	if err != nil {
 		return "", "", 0, ErrInvalidCredentials
 	}
+	if rec.Revoked {
+		return "", "", 0, u.spentToken(ctx, rec)
+	}
 	now := u.cfg.Now()
-	if rec.ExpiresAt.Before(now) || rec.Revoked {
+	if rec.ExpiresAt.Before(now) {
 		return "", "", 0, ErrInvalidCredentials
 	}
-	if err := u.rt.Revoke(ctx, rec.TokenHash); err != nil {
+	ok, err := u.rt.RevokeActive(ctx, rec.TokenHash)
+	if err != nil {
 		return "", "", 0, err
 	}
-	access, refresh, err := u.issueTokens(ctx, rec.UserID)
+	if !ok {
+		// a concurrent Refresh or Logout with the same token got here first
+		rec, err = u.rt.FindByHash(ctx, hash)
+		if err != nil {
+			return "", "", 0, ErrInvalidCredentials
+		}
+		return "", "", 0, u.spentToken(ctx, rec)
+	}
+	access, refresh, err := u.issueTokens(ctx, rec.UserID, rec.FamilyID)
 	if err != nil {
 		return "", "", 0, err
 	}
+	u.record(ctx, rec.UserID, domainauth.EventRefresh, map[string]string{"family_id": rec.FamilyID})
 	return access, refresh, rec.UserID, nil
 }
 
@@ -106,17 +220,62 @@ func (u *Usecase) Logout(ctx context.Context, raw string) error {
 	if raw == "" {
 		return nil
 	}
-	return u.rt.Revoke(ctx, HashToken(raw))
+	rec, err := u.rt.FindValid(ctx, HashToken(raw))
+	if errors.Is(err, postgres.ErrNotFound) {
+		// unknown or already revoked: nothing to log out
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := u.rt.Revoke(ctx, rec.TokenHash); err != nil {
+		return err
+	}
+	u.record(ctx, rec.UserID, domainauth.EventLogout, map[string]string{"family_id": rec.FamilyID})
+	return nil
+}
+
+// spentToken rejects a revoked refresh token. Only a rotated one is a
+// replay: logged-out and revoked tokens were ended by their user.
+func (u *Usecase) spentToken(ctx context.Context, rec *domainauth.RefreshToken) error {
+	if rec.RevokeReason != domainauth.RevokedRotated {
+		return ErrInvalidCredentials
+	}
+	return u.reuseDetected(ctx, rec)
 }
 
-func (u *Usecase) issueTokens(ctx context.Context, userID int64) (access string, refreshRaw string, err error) {
+// reuseDetected revokes the family of a replayed refresh token and records
+// the event.
+func (u *Usecase) reuseDetected(ctx context.Context, rec *domainauth.RefreshToken) error {
+	if err := u.rt.RevokeFamily(ctx, rec.FamilyID); err != nil {
+		return fmt.Errorf("revoke family: %w", err)
+	}
+	uid := rec.UserID
+	cl := ClientFromCtx(ctx)
+	if err := u.events.Create(ctx, &domainauth.Event{
+		UserID:    &uid,
+		Type:      domainauth.EventRefreshReuse,
+		IP:        cl.IP,
+		UserAgent: cl.UserAgent,
+		Metadata: map[string]string{
+			"family_id":        rec.FamilyID,
+			"refresh_token_id": strconv.FormatInt(rec.ID, 10),
+		},
+	}); err != nil {
+		return fmt.Errorf("record reuse event: %w", err)
+	}
+	return ErrTokenReused
+}
+
+// issueTokens starts a new refresh token family when familyID is empty.
+func (u *Usecase) issueTokens(ctx context.Context, userID int64, familyID string) (access string, refreshRaw string, err error) {
 	now := u.cfg.Now()
 	claims := domainauth.AccessClaims{
 		Sub: strconv.FormatInt(userID, 10),
 		Iat: now.Unix(),
 		Exp: now.Add(u.cfg.AccessTTL).Unix(),
 	}
-	access, err = SignedString(claims, u.cfg.Secret)
+	access, err = u.cfg.Keys.Sign(claims)
 	if err != nil {
 		return "", "", fmt.Errorf("sign access: %w", err)
 	}
@@ -124,12 +283,19 @@ func (u *Usecase) issueTokens(ctx context.Context, userID int64) (access string,
 	if err != nil {
 		return "", "", fmt.Errorf("gen refresh: %w", err)
 	}
+	cl := ClientFromCtx(ctx)
 	rec := &domainauth.RefreshToken{
 		UserID:    userID,
+		FamilyID:  familyID,
 		TokenHash: HashToken(refreshRaw),
 		IssuedAt:  now,
 		ExpiresAt: now.Add(u.cfg.RefreshTTL),
 		Revoked:   false,
+		UserAgent: cl.UserAgent,
+		IP:        cl.IP,
+	}
+	if familyID != "" {
+		rec.LastRefreshAt = &now
 	}
 	if err := u.rt.Create(ctx, rec); err != nil {
 		return "", "", fmt.Errorf("save refresh: %w", err)
@@ -138,7 +304,7 @@ func (u *Usecase) issueTokens(ctx context.Context, userID int64) (access string,
 }
 
 func (u *Usecase) ParseAccess(token string) (int64, error) {
-	cl, err := ParseAndValidate(token, u.cfg.Secret)
+	cl, err := u.cfg.Keys.Verify(token)
 	if err != nil {
 		return 0, ErrInvalidCredentials
 	}
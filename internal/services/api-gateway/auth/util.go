@@ -31,7 +31,11 @@ func ParseAndValidate(token string, secret []byte) (*auth.AccessClaims, error) {
 	if !hmac.Equal(sig, expectedSig) {
 		return nil, ErrTokenInvalid
 	}
+	return decodeClaims(payloadB64)
+}
 
+// decodeClaims decodes a verified payload and checks its lifetime.
+func decodeClaims(payloadB64 string) (*auth.AccessClaims, error) {
 	payloadJSON, err := base64.RawURLEncoding.DecodeString(payloadB64)
 	if err != nil {
 		return nil, fmt.Errorf("decode payload: %w", err)
@@ -0,0 +1,90 @@
+package badge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// endpoint is the shields.io endpoint schema, so the JSON variant also works
+// as a source for https://img.shields.io/endpoint.
+type endpoint struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Label         string `json:"label"`
+	Message       string `json:"message"`
+	Color         string `json:"color"`
+	IsError       bool   `json:"isError,omitempty"`
+	CacheSeconds  int    `json:"cacheSeconds,omitempty"`
+}
+
+// Handler serves GET /badge/{file}, where file is the badge token followed
+// by .svg or .json. It needs no credentials: the token is unguessable and
+// only exists while the check's owners publish its badge.
+func Handler(svc *Service, maxAge time.Duration, log *zap.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file := r.PathValue("file")
+		token, asJSON := strings.CutSuffix(file, ".json")
+		if !asJSON {
+			var ok bool
+			if token, ok = strings.CutSuffix(file, ".svg"); !ok {
+				http.NotFound(w, r)
+				return
+			}
+		}
+
+		code := http.StatusOK
+		q, err := ParseQuery(r.URL.Query().Get)
+		var b Badge
+		if err == nil {
+			b, err = svc.Get(r.Context(), token, q)
+		}
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrNotFound):
+			code, b = http.StatusNotFound, Badge{Label: "badge", Message: "not found", Color: colorGrey}
+		case errors.Is(err, ErrInvalidMetric), errors.Is(err, ErrInvalidWindow), errors.Is(err, ErrInvalidLabel):
+			code, b = http.StatusBadRequest, Badge{Label: "badge", Message: err.Error(), Color: colorRed}
+		default:
+			log.Warn("render badge", zap.Error(err))
+			code, b = http.StatusServiceUnavailable, Badge{Label: "badge", Message: "unavailable", Color: colorGrey}
+		}
+
+		var body []byte
+		if asJSON {
+			body, _ = json.Marshal(endpoint{
+				SchemaVersion: 1,
+				Label:         b.Label,
+				Message:       b.Message,
+				Color:         b.Color,
+				IsError:       code != http.StatusOK,
+				CacheSeconds:  int(maxAge / time.Second),
+			})
+			w.Header().Set("Content-Type", "application/json")
+		} else {
+			body = b.SVG()
+			w.Header().Set("Content-Type", "image/svg+xml;charset=utf-8")
+		}
+
+		if code == http.StatusServiceUnavailable {
+			w.Header().Set("Cache-Control", "no-store")
+		} else {
+			w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge/time.Second)))
+		}
+		sum := sha256.Sum256(body)
+		etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+		w.Header().Set("ETag", etag)
+		if code == http.StatusOK && r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(code)
+		_, _ = w.Write(body)
+	})
+}
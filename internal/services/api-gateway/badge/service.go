@@ -0,0 +1,210 @@
+// Package badge renders shields-style status and uptime badges for checks
+// whose owners published them under a badge token.
+package badge
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NordCoder/Pingerus/internal/domain/check"
+	"github.com/NordCoder/Pingerus/internal/domain/plan"
+	"github.com/NordCoder/Pingerus/internal/domain/run"
+	"github.com/NordCoder/Pingerus/internal/repository/postgres"
+)
+
+var (
+	ErrNotFound      = errors.New("badge not found")
+	ErrInvalidMetric = errors.New("metric must be status or uptime")
+	ErrInvalidWindow = errors.New("window must be 24h, 7d, 30d or 90d")
+	ErrInvalidLabel  = errors.New("label must be at most 40 characters")
+)
+
+type Metric string
+
+const (
+	MetricStatus Metric = "status"
+	MetricUptime Metric = "uptime"
+)
+
+// windows are the uptime windows a badge may cover, by their query name.
+var windows = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+	"90d": plan.PublicHistory,
+}
+
+const (
+	defaultWindow = "30d"
+	maxLabelLen   = 40
+	maxCached     = 10000
+)
+
+// Query says what a badge shows; Window is one of the keys of windows.
+type Query struct {
+	Metric Metric
+	Window string
+	Label  string
+}
+
+// ParseQuery reads metric, window and label from URL query values, falling
+// back to a 30 day uptime badge.
+func ParseQuery(get func(string) string) (Query, error) {
+	q := Query{Metric: Metric(get("metric")), Window: get("window"), Label: strings.TrimSpace(get("label"))}
+	switch q.Metric {
+	case "":
+		q.Metric = MetricUptime
+	case MetricStatus, MetricUptime:
+	default:
+		return Query{}, ErrInvalidMetric
+	}
+	if q.Window == "" {
+		q.Window = defaultWindow
+	}
+	if _, ok := windows[q.Window]; !ok {
+		return Query{}, ErrInvalidWindow
+	}
+	if len(q.Label) > maxLabelLen {
+		return Query{}, ErrInvalidLabel
+	}
+	return q, nil
+}
+
+// Badge is what gets drawn: a grey label and a coloured message.
+type Badge struct {
+	Label   string
+	Message string
+	Color   string
+}
+
+type Service struct {
+	checks check.Repo
+	runs   run.Repo
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cached
+}
+
+type cached struct {
+	badge Badge
+	at    time.Time
+}
+
+// NewService builds a badge service that reuses rendered badges for ttl.
+func NewService(checks check.Repo, runs run.Repo, ttl time.Duration) *Service {
+	return &Service{checks: checks, runs: runs, ttl: ttl, cache: make(map[string]cached)}
+}
+
+// Get returns the badge of the check published under token.
+func (s *Service) Get(ctx context.Context, token string, q Query) (Badge, error) {
+	key := token + "|" + string(q.Metric) + "|" + q.Window
+	b, ok := s.cached(key)
+	if !ok {
+		var err error
+		if b, err = s.render(ctx, token, q); err != nil {
+			return Badge{}, err
+		}
+		s.store(key, b)
+	}
+	if q.Label != "" {
+		b.Label = q.Label
+	}
+	return b, nil
+}
+
+func (s *Service) render(ctx context.Context, token string, q Query) (Badge, error) {
+	c, err := s.checks.GetByBadgeToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, postgres.ErrNotFound) {
+			return Badge{}, ErrNotFound
+		}
+		return Badge{}, err
+	}
+
+	if q.Metric == MetricStatus {
+		b := Badge{Label: "status", Message: "unknown", Color: colorGrey}
+		switch {
+		case !c.Active:
+			b.Message = "paused"
+		case c.LastStatus == nil:
+		case *c.LastStatus:
+			b.Message, b.Color = "up", colorBrightGreen
+		default:
+			b.Message, b.Color = "down", colorRed
+		}
+		return b, nil
+	}
+
+	total, up, err := s.runs.Uptime(ctx, c.ID, time.Now().Add(-windows[q.Window]))
+	if err != nil {
+		return Badge{}, err
+	}
+	b := Badge{Label: "uptime " + q.Window, Message: "n/a", Color: colorGrey}
+	if total > 0 {
+		pct := float64(up) * 100 / float64(total)
+		b.Message, b.Color = formatPercent(pct), uptimeColor(pct)
+	}
+	return b, nil
+}
+
+// formatPercent keeps two decimals but never rounds up to a perfect 100%.
+func formatPercent(pct float64) string {
+	if pct >= 100 {
+		return "100%"
+	}
+	s := strconv.FormatFloat(math.Floor(pct*100+1e-9)/100, 'f', -1, 64)
+	return s + "%"
+}
+
+func uptimeColor(pct float64) string {
+	switch {
+	case pct >= 99.9:
+		return colorBrightGreen
+	case pct >= 99:
+		return colorGreen
+	case pct >= 95:
+		return colorYellow
+	case pct >= 90:
+		return colorOrange
+	default:
+		return colorRed
+	}
+}
+
+func (s *Service) cached(key string) (Badge, bool) {
+	if s.ttl <= 0 {
+		return Badge{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.cache[key]
+	if !ok || time.Since(e.at) > s.ttl {
+		return Badge{}, false
+	}
+	return e.badge, true
+}
+
+func (s *Service) store(key string, b Badge) {
+	if s.ttl <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.cache) >= maxCached {
+		for k, e := range s.cache {
+			if time.Since(e.at) > s.ttl {
+				delete(s.cache, k)
+			}
+		}
+		if len(s.cache) >= maxCached {
+			return
+		}
+	}
+	s.cache[key] = cached{badge: b, at: time.Now()}
+}
@@ -0,0 +1,75 @@
+package badge
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strings"
+)
+
+// Colors follow the shields.io palette, so the JSON variant can name them.
+const (
+	colorBrightGreen = "brightgreen"
+	colorGreen       = "green"
+	colorYellow      = "yellow"
+	colorOrange      = "orange"
+	colorRed         = "red"
+	colorGrey        = "lightgrey"
+)
+
+var colorHex = map[string]string{
+	colorBrightGreen: "#4c1",
+	colorGreen:       "#97ca00",
+	colorYellow:      "#dfb317",
+	colorOrange:      "#fe7d37",
+	colorRed:         "#e05d44",
+	colorGrey:        "#9f9f9f",
+}
+
+const svgTemplate = `<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[4]s: %[5]s">` +
+	`<title>%[4]s: %[5]s</title>` +
+	`<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>` +
+	`<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>` +
+	`<g clip-path="url(#r)"><rect width="%[2]d" height="20" fill="#555"/><rect x="%[2]d" width="%[3]d" height="20" fill="%[6]s"/><rect width="%[1]d" height="20" fill="url(#s)"/></g>` +
+	`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">` +
+	`<text x="%[7]s" y="15" fill="#010101" fill-opacity=".3">%[4]s</text><text x="%[7]s" y="14">%[4]s</text>` +
+	`<text x="%[8]s" y="15" fill="#010101" fill-opacity=".3">%[5]s</text><text x="%[8]s" y="14">%[5]s</text>` +
+	`</g></svg>`
+
+// SVG draws b in the shields "flat" style.
+func (b Badge) SVG() []byte {
+	lw := textWidth(b.Label) + 10
+	mw := textWidth(b.Message) + 10
+	fill, ok := colorHex[b.Color]
+	if !ok {
+		fill = colorHex[colorGrey]
+	}
+	return []byte(fmt.Sprintf(svgTemplate,
+		lw+mw, lw, mw,
+		html.EscapeString(b.Label), html.EscapeString(b.Message), fill,
+		half(0, lw), half(lw, mw),
+	))
+}
+
+func half(offset, width int) string {
+	return fmt.Sprintf("%.1f", float64(offset)+float64(width)/2)
+}
+
+// textWidth approximates the width of s in 11px Verdana, which is close
+// enough to size the badge boxes.
+func textWidth(s string) int {
+	var w float64
+	for _, r := range s {
+		switch {
+		case strings.ContainsRune("ijlI.,:;!|'()[] ", r):
+			w += 3.9
+		case strings.ContainsRune("mwMW%", r):
+			w += 10.5
+		case r >= 'A' && r <= 'Z':
+			w += 7.6
+		default:
+			w += 7
+		}
+	}
+	return int(math.Ceil(w))
+}
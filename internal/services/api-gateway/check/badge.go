@@ -0,0 +1,57 @@
+package check
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+
+	"github.com/NordCoder/Pingerus/internal/repository/postgres"
+	"github.com/NordCoder/Pingerus/internal/services/api-gateway/policy"
+)
+
+// tokenBytes makes badge and ping tokens as hard to guess as session tokens,
+// so a published URL does not reveal the check ID or its neighbours.
+const (
+	tokenBytes        = 18
+	badgeTokenRetries = 3
+)
+
+// RotateBadge publishes the check's badge under a new token, replacing any
+// previous one, and returns that token.
+func (u *Usecase) RotateBadge(ctx context.Context, requesterID, id int64) (string, error) {
+	if _, err := u.authorized(ctx, requesterID, id, policy.WriteChecks); err != nil {
+		return "", err
+	}
+	for i := 0; ; i++ {
+		token, err := newToken()
+		if err != nil {
+			return "", err
+		}
+		err = u.repo.SetBadgeToken(ctx, id, token)
+		if errors.Is(err, postgres.ErrConflict) && i < badgeTokenRetries {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return token, nil
+	}
+}
+
+// DisableBadge unpublishes the check's badge.
+func (u *Usecase) DisableBadge(ctx context.Context, requesterID, id int64) error {
+	if _, err := u.authorized(ctx, requesterID, id, policy.WriteChecks); err != nil {
+		return err
+	}
+	return u.repo.SetBadgeToken(ctx, id, "")
+}
+
+// newToken makes an unguessable URL-safe token for badges and ping URLs.
+func newToken() (string, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
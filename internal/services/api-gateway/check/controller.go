@@ -3,16 +3,23 @@ package check
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
+	"strconv"
 	"time"
 
 	"github.com/NordCoder/Pingerus/internal/domain/check"
+	"github.com/NordCoder/Pingerus/internal/repository/postgres"
 	"github.com/NordCoder/Pingerus/internal/services/api-gateway/auth"
 
 	pb "github.com/NordCoder/Pingerus/generated/v1"
 	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/api/httpbody"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -30,33 +37,120 @@ func toPB(c *check.Check) *pb.Check {
 	chk := &pb.Check{
 		Id:          c.ID,
 		UserId:      c.UserID,
+		OrgId:       c.OrgID,
+		Version:     c.Version,
+		Name:        c.Name,
+		Type:        c.Type,
+		Tags:        c.Tags,
 		Url:         c.URL,
 		IntervalSec: int32(c.Interval / time.Second),
 		NextRun:     timestamppb.New(c.NextRun),
 		UpdatedAt:   timestamppb.New(c.UpdatedAt),
 		LastStatus:  nil,
+		BadgeToken:  c.BadgeToken,
+		GraceSec:    int32(c.Grace / time.Second),
+		PingToken:   c.PingToken,
+		ParentIds:   c.ParentIDs,
+		Active:      &c.Active,
 	}
 	if c.LastStatus != nil {
 		chk.LastStatus = c.LastStatus
 	}
+	if c.StatusChangedAt != nil {
+		chk.StatusChangedAt = timestamppb.New(*c.StatusChangedAt)
+	}
+	if c.LastPingAt != nil {
+		chk.LastPingAt = timestamppb.New(*c.LastPingAt)
+	}
 	return chk
 }
 
-func fromPB(in *pb.Check) *check.Check {
-	var ls *bool
-	last := in.GetLastStatus()
-	ls = &last
+// outputOnlyPaths are ignored in update masks; grpc-gateway builds PATCH
+// masks from whatever keys the client echoed back.
+var outputOnlyPaths = map[string]bool{
+	"id": true, "user_id": true, "org_id": true, "last_status": true,
+	"next_run": true, "updated_at": true, "version": true,
+	"type": true, "status_changed_at": true, "badge_token": true,
+	"ping_token": true, "last_ping_at": true,
+}
+
+// replacedPaths are the fields a "*" mask replaces.
+var replacedPaths = []string{"name", "tags", "url", "interval_sec", "grace_sec", "parent_ids"}
+
+// patchFromPB picks the masked fields of in. Without a mask it picks the
+// fields in sets (AIP-134), so clients that send only what they change
+// don't clear the rest; "*" picks every mutable field. active is picked
+// only when set, since older clients never send it.
+func patchFromPB(in *pb.Check, mask *fieldmaskpb.FieldMask) (check.Patch, error) {
+	paths := mask.GetPaths()
+	switch {
+	case len(paths) == 0:
+		paths = populatedPaths(in)
+	case len(paths) == 1 && paths[0] == "*":
+		paths = replacedPaths
+		if in.Active != nil {
+			paths = append(slices.Clone(paths), "active")
+		}
+	}
 
-	return &check.Check{
-		ID:         in.GetId(),
-		UserID:     in.GetUserId(),
-		URL:        in.Url,
-		Interval:   time.Duration(in.GetIntervalSec()) * time.Second,
-		LastStatus: ls,
-		NextRun:    in.GetNextRun().AsTime(),
-		UpdatedAt:  in.GetUpdatedAt().AsTime(),
-		Active:     true,
+	var p check.Patch
+	for _, path := range paths {
+		switch path {
+		case "name":
+			n := in.GetName()
+			p.Name = &n
+		case "tags":
+			t := in.GetTags()
+			p.Tags = &t
+		case "url":
+			u := in.GetUrl()
+			p.URL = &u
+		case "interval_sec":
+			d := time.Duration(in.GetIntervalSec()) * time.Second
+			p.Interval = &d
+		case "grace_sec":
+			d := time.Duration(in.GetGraceSec()) * time.Second
+			p.Grace = &d
+		case "parent_ids":
+			ids := in.GetParentIds()
+			p.Parents = &ids
+		case "active":
+			a := in.GetActive()
+			p.Active = &a
+		default:
+			if !outputOnlyPaths[path] {
+				return check.Patch{}, fmt.Errorf("unknown or immutable field %q in update_mask", path)
+			}
+		}
 	}
+	return p, nil
+}
+
+// populatedPaths lists the mutable fields of in that are set.
+func populatedPaths(in *pb.Check) []string {
+	var paths []string
+	if in.GetName() != "" {
+		paths = append(paths, "name")
+	}
+	if len(in.GetTags()) > 0 {
+		paths = append(paths, "tags")
+	}
+	if in.GetUrl() != "" {
+		paths = append(paths, "url")
+	}
+	if in.GetIntervalSec() != 0 {
+		paths = append(paths, "interval_sec")
+	}
+	if in.GetGraceSec() != 0 {
+		paths = append(paths, "grace_sec")
+	}
+	if len(in.GetParentIds()) > 0 {
+		paths = append(paths, "parent_ids")
+	}
+	if in.Active != nil {
+		paths = append(paths, "active")
+	}
+	return paths
 }
 
 func (s *Server) userID(ctx context.Context) (int64, error) {
@@ -69,10 +163,26 @@ func (s *Server) userID(ctx context.Context) (int64, error) {
 
 func (s *Server) mapErr(err error) error {
 	switch {
-	case errors.Is(err, ErrInvalidInterval):
+	case errors.Is(err, ErrInvalidInterval), errors.Is(err, ErrInvalidURL), errors.Is(err, ErrInvalidName),
+		errors.Is(err, ErrInvalidTags), errors.Is(err, ErrInvalidPageToken), errors.Is(err, ErrInvalidImport),
+		errors.Is(err, ErrInvalidType), errors.Is(err, ErrInvalidPeriod), errors.Is(err, ErrInvalidGrace),
+		errors.Is(err, ErrNotHeartbeat), errors.Is(err, ErrHeartbeatURL),
+		errors.Is(err, ErrInvalidParents), errors.Is(err, ErrDependencyCycle):
 		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrQuotaExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, ErrVersionConflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, postgres.ErrNotFound):
+		return status.Error(codes.NotFound, "check not found")
 	case errors.Is(err, ErrForbidden):
 		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, ErrWatchUnavailable), errors.Is(err, ErrWatcherBehind):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, ErrCursorExpired):
+		return status.Error(codes.OutOfRange, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
 	default:
 		return err
 	}
@@ -87,9 +197,20 @@ func (s *Server) CreateCheck(ctx context.Context, req *pb.CreateCheckRequest) (*
 		return nil, err
 	}
 
-	s.log.Info("CreateCheck request", zap.Int64("uid", uid), zap.String("url", req.GetUrl()), zap.Int32("interval_sec", req.GetIntervalSec()))
+	s.log.Info("CreateCheck request", zap.Int64("uid", uid), zap.String("type", req.GetType()),
+		zap.String("url", req.GetUrl()), zap.Int32("interval_sec", req.GetIntervalSec()))
+
+	c, err := s.uc.Create(ctx, uid, &check.Check{
+		OrgID:    req.GetOrgId(),
+		Name:     req.GetName(),
+		Type:     req.GetType(),
+		Tags:     req.GetTags(),
+		URL:      req.GetUrl(),
+		Interval: time.Duration(req.GetIntervalSec()) * time.Second,
+		Grace:    time.Duration(req.GetGraceSec()) * time.Second,
 
-	c, err := s.uc.Create(ctx, uid, req.GetUrl(), time.Duration(req.GetIntervalSec())*time.Second)
+		ParentIDs: req.GetParentIds(),
+	})
 	if err != nil {
 		return nil, s.mapErr(err)
 	}
@@ -122,16 +243,18 @@ func (s *Server) UpdateCheck(ctx context.Context, req *pb.UpdateCheckRequest) (*
 		return nil, err
 	}
 	in := req.GetCheck()
-	if in == nil {
-		return nil, status.Error(codes.InvalidArgument, "empty check payload")
+	if in.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "check.id is required")
+	}
+	p, err := patchFromPB(in, req.GetUpdateMask())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	d := fromPB(in)
-	d.UserID = uid
-
-	s.log.Info("UpdateCheck request", zap.Int64("uid", uid), zap.Int64("id", in.GetId()), zap.String("url", d.URL), zap.Duration("interval", d.Interval))
+	s.log.Info("UpdateCheck request", zap.Int64("uid", uid), zap.Int64("id", in.GetId()),
+		zap.Strings("update_mask", req.GetUpdateMask().GetPaths()), zap.Int64("version", in.GetVersion()))
 
-	updated, err := s.uc.Update(ctx, uid, d)
+	updated, err := s.uc.Update(ctx, uid, in.GetId(), p, in.GetVersion())
 	if err != nil {
 		return nil, s.mapErr(err)
 	}
@@ -156,6 +279,67 @@ func (s *Server) DeleteCheck(ctx context.Context, req *pb.DeleteCheckRequest) (*
 	return &emptypb.Empty{}, nil
 }
 
+func (s *Server) RotateCheckBadge(ctx context.Context, req *pb.RotateCheckBadgeRequest) (*pb.CheckBadge, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("RotateCheckBadge request", zap.Int64("uid", uid), zap.Int64("id", req.GetId()))
+
+	token, err := s.uc.RotateBadge(ctx, uid, req.GetId())
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	return &pb.CheckBadge{
+		Token:    token,
+		SvgPath:  "/badge/" + token + ".svg",
+		JsonPath: "/badge/" + token + ".json",
+	}, nil
+}
+
+func (s *Server) DisableCheckBadge(ctx context.Context, req *pb.DisableCheckBadgeRequest) (*emptypb.Empty, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("DisableCheckBadge request", zap.Int64("uid", uid), zap.Int64("id", req.GetId()))
+
+	if err := s.uc.DisableBadge(ctx, uid, req.GetId()); err != nil {
+		return nil, s.mapErr(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) GetUsage(ctx context.Context, req *pb.GetUsageRequest) (*pb.Usage, error) {
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("GetUsage request", zap.Int64("uid", uid))
+
+	u, err := s.uc.Usage(ctx, uid)
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	return &pb.Usage{
+		Plan:            u.Limits.Name,
+		ChecksUsed:      u.Checks,
+		MaxChecks:       int32(u.Limits.MaxChecks),
+		MinIntervalSec:  int32(u.Limits.MinInterval / time.Second),
+		MaxChannels:     int32(u.Limits.MaxChannels),
+		RunRetentionSec: int64(u.Limits.RunRetention / time.Second),
+	}, nil
+}
+
 func (s *Server) ListChecks(ctx context.Context, req *pb.ListChecksRequest) (*pb.ListChecksResponse, error) {
 	if err := req.ValidateAll(); err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
@@ -165,15 +349,282 @@ func (s *Server) ListChecks(ctx context.Context, req *pb.ListChecksRequest) (*pb
 		return nil, err
 	}
 
-	s.log.Info("ListChecks request", zap.Int64("uid", uid))
+	s.log.Info("ListChecks request", zap.Int64("uid", uid), zap.Int64("org_id", req.GetOrgId()))
 
-	list, err := s.uc.ListByUser(ctx, uid)
+	page, err := s.uc.List(ctx, uid, req.GetOrgId(), listQueryFromPB(req), req.GetPageToken())
 	if err != nil {
 		return nil, s.mapErr(err)
 	}
-	out := make([]*pb.Check, 0, len(list))
-	for _, c := range list {
+	out := make([]*pb.Check, 0, len(page.Checks))
+	for _, c := range page.Checks {
 		out = append(out, toPB(c))
 	}
-	return &pb.ListChecksResponse{Checks: out}, nil
+	return &pb.ListChecksResponse{Checks: out, NextPageToken: page.NextPageToken, TotalSize: page.TotalSize}, nil
+}
+
+func (s *Server) ExportChecks(req *pb.ExportChecksRequest, stream pb.CheckService_ExportChecksServer) error {
+	if err := req.ValidateAll(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	ctx := stream.Context()
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("ExportChecks request", zap.Int64("uid", uid), zap.Int64("org_id", req.GetOrgId()), zap.String("format", req.GetFormat().String()))
+
+	f := formatFromPB(req.GetFormat())
+	enc := NewSpecEncoder(f)
+	send := func(data []byte) error {
+		if len(data) == 0 {
+			return nil
+		}
+		return stream.Send(&httpbody.HttpBody{ContentType: f.ContentType(), Data: data})
+	}
+
+	if err := send(enc.Header()); err != nil {
+		return err
+	}
+	err = s.uc.Export(ctx, uid, req.GetOrgId(), func(list []*check.Check) error {
+		specs := make([]Spec, 0, len(list))
+		for _, c := range list {
+			specs = append(specs, specOf(c))
+		}
+		data, err := enc.Encode(specs)
+		if err != nil {
+			return err
+		}
+		return send(data)
+	})
+	if err != nil {
+		return s.mapErr(err)
+	}
+	return send(enc.Footer())
+}
+
+func (s *Server) ImportChecks(ctx context.Context, req *pb.ImportChecksRequest) (*pb.ImportChecksResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	specs, err := DecodeSpecs(formatFromPB(req.GetFormat()), []byte(req.GetData()))
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+
+	s.log.Info("ImportChecks request", zap.Int64("uid", uid), zap.Int64("org_id", req.GetOrgId()),
+		zap.Int("rows", len(specs)), zap.Bool("dry_run", req.GetDryRun()))
+
+	rows := make([]*ImportRow, 0, len(specs))
+	for i, sr := range specs {
+		r := &ImportRow{Row: i + 1, Spec: sr.Spec}
+		if sr.Err != nil {
+			r.Errors = append(r.Errors, sr.Err.Error())
+		} else {
+			r.Errors = append(r.Errors, specViolations(sr.Spec)...)
+		}
+		rows = append(rows, r)
+	}
+
+	applied, err := s.uc.Import(ctx, uid, req.GetOrgId(), rows, req.GetDryRun())
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+
+	resp := &pb.ImportChecksResponse{Applied: applied, Rows: make([]*pb.ImportRowResult, 0, len(rows))}
+	for _, r := range rows {
+		resp.Rows = append(resp.Rows, &pb.ImportRowResult{
+			Row:           int32(r.Row),
+			Name:          r.Spec.Name,
+			Url:           r.Spec.URL,
+			Action:        importActionToPB(r.Action),
+			CheckId:       r.CheckID,
+			Errors:        r.Errors,
+			ChangedFields: r.Changed,
+		})
+		switch r.Action {
+		case ImportCreate:
+			resp.Created++
+		case ImportUpdate:
+			resp.Updated++
+		case ImportUnchanged:
+			resp.Unchanged++
+		case ImportError:
+			resp.Failed++
+		}
+	}
+	return resp, nil
+}
+
+// specViolations checks s against the rules CreateCheck enforces.
+func specViolations(s Spec) []string {
+	err := (&pb.CreateCheckRequest{
+		Url:         s.URL,
+		IntervalSec: s.IntervalSec,
+		Name:        s.Name,
+		Tags:        s.Tags,
+	}).ValidateAll()
+	if err == nil {
+		return nil
+	}
+	return []string{err.Error()}
+}
+
+func formatFromPB(f pb.CheckFormat) Format {
+	switch f {
+	case pb.CheckFormat_CHECK_FORMAT_YAML:
+		return FormatYAML
+	case pb.CheckFormat_CHECK_FORMAT_CSV:
+		return FormatCSV
+	default:
+		return FormatJSON
+	}
+}
+
+func importActionToPB(a ImportAction) pb.ImportAction {
+	switch a {
+	case ImportCreate:
+		return pb.ImportAction_IMPORT_ACTION_CREATE
+	case ImportUpdate:
+		return pb.ImportAction_IMPORT_ACTION_UPDATE
+	case ImportUnchanged:
+		return pb.ImportAction_IMPORT_ACTION_UNCHANGED
+	case ImportError:
+		return pb.ImportAction_IMPORT_ACTION_ERROR
+	default:
+		return pb.ImportAction_IMPORT_ACTION_UNSPECIFIED
+	}
+}
+
+func listQueryFromPB(req *pb.ListChecksRequest) check.ListQuery {
+	q := check.ListQuery{
+		Type:        req.GetType(),
+		Tag:         req.GetTag(),
+		URLContains: req.GetUrlContains(),
+		Limit:       int(req.GetPageSize()),
+	}
+	if req.Active != nil {
+		active := req.GetActive()
+		q.Active = &active
+	}
+	switch req.GetStatus() {
+	case pb.CheckStatus_CHECK_STATUS_UP:
+		q.Status = check.StatusUp
+	case pb.CheckStatus_CHECK_STATUS_DOWN:
+		q.Status = check.StatusDown
+	case pb.CheckStatus_CHECK_STATUS_UNKNOWN:
+		q.Status = check.StatusUnknown
+	}
+
+	// names and next runs read best ascending, creation and status
+	// changes newest first
+	switch req.GetSort() {
+	case pb.CheckSort_CHECK_SORT_NAME:
+		q.Sort = check.SortName
+	case pb.CheckSort_CHECK_SORT_STATUS_CHANGED:
+		q.Sort, q.Desc = check.SortStatusChanged, true
+	case pb.CheckSort_CHECK_SORT_NEXT_RUN:
+		q.Sort = check.SortNextRun
+	default:
+		q.Sort, q.Desc = check.SortCreated, true
+	}
+	switch req.GetOrder() {
+	case pb.SortOrder_SORT_ORDER_ASC:
+		q.Desc = false
+	case pb.SortOrder_SORT_ORDER_DESC:
+		q.Desc = true
+	}
+	return q
+}
+
+// minHeartbeat keeps clients from asking for a heartbeat storm.
+const minHeartbeat = 5 * time.Second
+
+var eventKindToPB = map[check.EventKind]pb.CheckEventKind{
+	EventHeartbeat:              pb.CheckEventKind_CHECK_EVENT_KIND_HEARTBEAT,
+	check.EventRun:              pb.CheckEventKind_CHECK_EVENT_KIND_RUN,
+	check.EventStatusChanged:    pb.CheckEventKind_CHECK_EVENT_KIND_STATUS_CHANGED,
+	check.EventIncidentOpened:   pb.CheckEventKind_CHECK_EVENT_KIND_INCIDENT_OPENED,
+	check.EventIncidentResolved: pb.CheckEventKind_CHECK_EVENT_KIND_INCIDENT_RESOLVED,
+}
+
+func eventToPB(e *check.Event) *pb.CheckEvent {
+	return &pb.CheckEvent{
+		Cursor:    e.ID,
+		Kind:      eventKindToPB[e.Kind],
+		CheckId:   e.CheckID,
+		OrgId:     e.OrgID,
+		Status:    e.Status,
+		OldStatus: e.OldStatus,
+		RunId:     e.RunID,
+		Code:      int32(e.Code),
+		LatencyMs: e.Latency,
+		Ts:        timestamppb.New(e.CreatedAt),
+	}
+}
+
+func (s *Server) WatchChecks(req *pb.WatchChecksRequest, stream pb.CheckService_WatchChecksServer) error {
+	if err := req.ValidateAll(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return s.watch(stream, WatchQuery{
+		OrgID:     req.GetOrgId(),
+		Cursor:    req.GetCursor(),
+		Heartbeat: time.Duration(req.GetHeartbeatSec()) * time.Second,
+	})
+}
+
+func (s *Server) WatchCheck(req *pb.WatchCheckRequest, stream pb.CheckService_WatchCheckServer) error {
+	if err := req.ValidateAll(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return s.watch(stream, WatchQuery{
+		CheckID:   req.GetId(),
+		Cursor:    req.GetCursor(),
+		Heartbeat: time.Duration(req.GetHeartbeatSec()) * time.Second,
+	})
+}
+
+type eventStream interface {
+	Context() context.Context
+	Send(*pb.CheckEvent) error
+}
+
+func (s *Server) watch(stream eventStream, q WatchQuery) error {
+	ctx := stream.Context()
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return err
+	}
+	if q.Cursor == 0 {
+		q.Cursor = lastEventID(ctx)
+	}
+	if q.Heartbeat != 0 && q.Heartbeat < minHeartbeat {
+		q.Heartbeat = minHeartbeat
+	}
+
+	s.log.Info("Watch request", zap.Int64("uid", uid), zap.Int64("org_id", q.OrgID),
+		zap.Int64("check_id", q.CheckID), zap.Int64("cursor", q.Cursor))
+
+	err = s.uc.Watch(ctx, uid, q, func(e *check.Event) error {
+		return stream.Send(eventToPB(e))
+	})
+	return s.mapErr(err)
+}
+
+// lastEventID reads the Last-Event-ID header an EventSource sends when it
+// reconnects, so SSE clients resume without passing a cursor themselves.
+func lastEventID(ctx context.Context) int64 {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("last-event-id") {
+		if id, err := strconv.ParseInt(v, 10, 64); err == nil && id > 0 {
+			return id
+		}
+	}
+	return 0
 }
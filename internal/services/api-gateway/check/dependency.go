@@ -0,0 +1,73 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/NordCoder/Pingerus/internal/domain/check"
+	"github.com/NordCoder/Pingerus/internal/repository/postgres"
+)
+
+var (
+	ErrInvalidParents  = errors.New("parents must be at most 20 other checks of the same org")
+	ErrDependencyCycle = errors.New("parents would make the check depend on itself")
+)
+
+const maxParents = 20
+
+// normalizeParents sorts and dedupes parent IDs.
+func normalizeParents(in []int64) ([]int64, error) {
+	out := slices.Clone(in)
+	slices.Sort(out)
+	out = slices.Compact(out)
+	if len(out) > maxParents {
+		return nil, ErrInvalidParents
+	}
+	for _, id := range out {
+		if id <= 0 {
+			return nil, ErrInvalidParents
+		}
+	}
+	return out, nil
+}
+
+// setParents makes c depend on parents, which must be checks of c's org
+// that don't already depend on c. It has to run inside a transaction: the
+// org's dependency lock is held until it ends, so two edits can't close a
+// cycle between them.
+func (u *Usecase) setParents(ctx context.Context, c *check.Check, parents []int64) error {
+	for _, id := range parents {
+		if id == c.ID {
+			return ErrDependencyCycle
+		}
+		p, err := u.repo.GetByID(ctx, id)
+		if errors.Is(err, postgres.ErrNotFound) {
+			return ErrInvalidParents
+		}
+		if err != nil {
+			return err
+		}
+		if p.OrgID != c.OrgID {
+			return ErrInvalidParents
+		}
+	}
+
+	if err := u.deps.LockDependencies(ctx, c.OrgID); err != nil {
+		return err
+	}
+	if len(parents) > 0 {
+		ancestors, err := u.deps.Ancestors(ctx, parents)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(ancestors, c.ID) {
+			return ErrDependencyCycle
+		}
+	}
+	if err := u.deps.SetParents(ctx, c.ID, parents); err != nil {
+		return err
+	}
+	c.ParentIDs = parents
+	return nil
+}
@@ -0,0 +1,113 @@
+package check
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/NordCoder/Pingerus/internal/domain/check"
+	"github.com/NordCoder/Pingerus/internal/services/api-gateway/policy"
+)
+
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// Page is one page of a check listing.
+type Page struct {
+	Checks        []*check.Check
+	NextPageToken string
+	TotalSize     int64
+}
+
+// List returns a page of the checks of orgID, or of every org the caller can
+// read when orgID is 0. q carries filters, sort and page size; pageToken
+// continues a previous listing with the same query.
+func (u *Usecase) List(ctx context.Context, requesterID, orgID int64, q check.ListQuery, pageToken string) (*Page, error) {
+	if q.Limit <= 0 {
+		q.Limit = defaultPageSize
+	}
+	if q.Limit > maxPageSize {
+		q.Limit = maxPageSize
+	}
+	q.Tag = strings.ToLower(strings.TrimSpace(q.Tag))
+	fp := fingerprint(orgID, q)
+	if pageToken != "" {
+		cur, err := decodePageToken(pageToken, fp)
+		if err != nil {
+			return nil, err
+		}
+		q.After = cur
+	}
+
+	if orgID != 0 {
+		if _, err := u.pol.Authorize(ctx, requesterID, orgID, policy.ReadChecks); err != nil {
+			return nil, err
+		}
+		q.OrgIDs = []int64{orgID}
+	} else {
+		var err error
+		if q.OrgIDs, err = u.pol.VisibleOrgs(ctx, requesterID, policy.ReadChecks); err != nil {
+			return nil, err
+		}
+	}
+	if len(q.OrgIDs) == 0 {
+		return &Page{}, nil
+	}
+
+	list, next, err := u.repo.List(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	total, err := u.repo.Count(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	page := &Page{Checks: list, TotalSize: total}
+	if next != nil {
+		page.NextPageToken = encodePageToken(next, fp)
+	}
+	return page, nil
+}
+
+type pageToken struct {
+	Key string `json:"k,omitempty"`
+	ID  int64  `json:"i"`
+	// Query ties the token to the filters and sort it was issued for.
+	Query string `json:"q"`
+}
+
+func encodePageToken(c *check.Cursor, fp string) string {
+	b, _ := json.Marshal(pageToken{Key: c.Key, ID: c.ID, Query: fp})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodePageToken(s, fp string) (*check.Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+	var t pageToken
+	if err := json.Unmarshal(b, &t); err != nil || t.ID <= 0 || t.Query != fp {
+		return nil, ErrInvalidPageToken
+	}
+	return &check.Cursor{Key: t.Key, ID: t.ID}, nil
+}
+
+// fingerprint identifies everything but the page size and cursor of a query.
+func fingerprint(orgID int64, q check.ListQuery) string {
+	active := "any"
+	if q.Active != nil {
+		active = fmt.Sprint(*q.Active)
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d|%s|%s|%s|%s|%s|%s|%t",
+		orgID, q.Status, active, q.Type, q.Tag, q.URLContains, q.Sort, q.Desc)))
+	return base64.RawURLEncoding.EncodeToString(sum[:9])
+}
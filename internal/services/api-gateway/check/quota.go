@@ -0,0 +1,106 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NordCoder/Pingerus/internal/domain/check"
+	"github.com/NordCoder/Pingerus/internal/domain/plan"
+	"go.uber.org/zap"
+)
+
+// ErrQuotaExceeded wraps every refusal of a plan limit; the wrapping error
+// says which limit.
+var ErrQuotaExceeded = errors.New("plan limit reached")
+
+// pruneBatch bounds one delete so pruning stays within the query timeout.
+const pruneBatch = 10000
+
+// limits returns the plan limits of userID.
+func (u *Usecase) limits(ctx context.Context, userID int64) (plan.Limits, error) {
+	name, err := u.plans.PlanOf(ctx, userID)
+	if err != nil {
+		return plan.Limits{}, err
+	}
+	return u.catalog.Lookup(name), nil
+}
+
+// checkInterval refuses HTTP intervals below the plan minimum; heartbeats
+// cost no probes, so their period is free.
+func checkInterval(lim plan.Limits, typ string, interval time.Duration) error {
+	if typ != check.TypeHTTP || lim.MinInterval <= 0 || interval >= lim.MinInterval {
+		return nil
+	}
+	return fmt.Errorf("%w: plan %q allows intervals of %s or more", ErrQuotaExceeded, lim.Name, lim.MinInterval)
+}
+
+// reserve fails unless userID may own n more checks. Inside a transaction
+// it holds the user's usage lock until the end, so concurrent creates can't
+// both take the last slot.
+func (u *Usecase) reserve(ctx context.Context, userID int64, lim plan.Limits, n int) error {
+	if lim.MaxChecks <= 0 || n <= 0 {
+		return nil
+	}
+	if err := u.plans.LockUsage(ctx, userID); err != nil {
+		return err
+	}
+	used, err := u.plans.CountChecks(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if used+int64(n) > int64(lim.MaxChecks) {
+		return fmt.Errorf("%w: plan %q allows %d checks and %d are in use", ErrQuotaExceeded, lim.Name, lim.MaxChecks, used)
+	}
+	return nil
+}
+
+// Usage reports the plan of requesterID and how much of it is used.
+func (u *Usecase) Usage(ctx context.Context, requesterID int64) (*plan.Usage, error) {
+	lim, err := u.limits(ctx, requesterID)
+	if err != nil {
+		return nil, err
+	}
+	n, err := u.plans.CountChecks(ctx, requesterID)
+	if err != nil {
+		return nil, err
+	}
+	return &plan.Usage{Limits: lim, Checks: n}, nil
+}
+
+// PruneRuns drops runs older than their owner's plan keeps them, every
+// interval until ctx is done.
+func (u *Usecase) PruneRuns(ctx context.Context, interval time.Duration, log *zap.Logger) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			known := u.catalog.Names()
+			for _, lim := range u.catalog.Plans {
+				if lim.RunRetention <= 0 {
+					continue
+				}
+				before := time.Now().Add(-lim.RunRetention)
+				var total int64
+				for {
+					n, err := u.plans.PruneRuns(ctx, lim.Name, u.catalog.Default, known, before, pruneBatch)
+					if err != nil {
+						log.Warn("run prune failed", zap.String("plan", lim.Name), zap.Error(err))
+						break
+					}
+					total += n
+					if n < pruneBatch {
+						break
+					}
+				}
+				if total > 0 {
+					log.Debug("runs pruned", zap.String("plan", lim.Name), zap.Int64("rows", total))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package check
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NordCoder/Pingerus/internal/domain/check"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrInvalidImport = errors.New("invalid import file")
+
+// maxImportRows bounds a single import so one transaction stays reasonable.
+const maxImportRows = 5000
+
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+	FormatCSV  Format = "csv"
+)
+
+// ContentType is what an export in f is served as.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatYAML:
+		return "application/yaml"
+	case FormatCSV:
+		return "text/csv"
+	default:
+		return "application/json"
+	}
+}
+
+// Spec is the portable form of a check used by import and export.
+type Spec struct {
+	Name        string   `json:"name,omitempty" yaml:"name,omitempty"`
+	URL         string   `json:"url" yaml:"url"`
+	IntervalSec int32    `json:"interval_sec" yaml:"interval_sec"`
+	Tags        []string `json:"tags,omitempty" yaml:"tags,omitempty,flow"`
+}
+
+var specFields = map[string]bool{"name": true, "url": true, "interval_sec": true, "tags": true}
+
+var csvHeader = []string{"name", "url", "interval_sec", "tags"}
+
+// csvTagSep joins tags inside a single CSV cell.
+const csvTagSep = ";"
+
+func specOf(c *check.Check) Spec {
+	return Spec{
+		Name:        c.Name,
+		URL:         c.URL,
+		IntervalSec: int32(c.Interval / time.Second),
+		Tags:        c.Tags,
+	}
+}
+
+// SpecEncoder renders specs in chunks so exports can be streamed; the
+// concatenated chunks form one document.
+type SpecEncoder struct {
+	f     Format
+	count int
+}
+
+func NewSpecEncoder(f Format) *SpecEncoder { return &SpecEncoder{f: f} }
+
+func (e *SpecEncoder) Header() []byte {
+	switch e.f {
+	case FormatCSV:
+		return csvLine(csvHeader)
+	case FormatYAML:
+		return nil
+	default:
+		return []byte("[")
+	}
+}
+
+func (e *SpecEncoder) Encode(specs []Spec) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, s := range specs {
+		switch e.f {
+		case FormatCSV:
+			buf.Write(csvLine([]string{s.Name, s.URL, strconv.Itoa(int(s.IntervalSec)), strings.Join(s.Tags, csvTagSep)}))
+		case FormatYAML:
+			b, err := yaml.Marshal([]Spec{s})
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(b)
+		default:
+			b, err := json.Marshal(s)
+			if err != nil {
+				return nil, err
+			}
+			if e.count > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString("\n  ")
+			buf.Write(b)
+		}
+		e.count++
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *SpecEncoder) Footer() []byte {
+	switch e.f {
+	case FormatCSV:
+		return nil
+	case FormatYAML:
+		if e.count == 0 {
+			return []byte("[]\n")
+		}
+		return nil
+	default:
+		return []byte("\n]\n")
+	}
+}
+
+func csvLine(rec []string) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write(rec)
+	w.Flush()
+	return buf.Bytes()
+}
+
+// SpecRow is one decoded record; Err is set when the record itself could
+// not be read, the rest of the file still is.
+type SpecRow struct {
+	Spec Spec
+	Err  error
+}
+
+// DecodeSpecs parses an import file. It fails as a whole only when the
+// file's structure is unreadable.
+func DecodeSpecs(f Format, data []byte) ([]SpecRow, error) {
+	var (
+		rows []SpecRow
+		err  error
+	)
+	switch f {
+	case FormatCSV:
+		rows, err = decodeCSV(data)
+	case FormatYAML:
+		rows, err = decodeYAML(data)
+	default:
+		rows, err = decodeJSON(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	if len(rows) > maxImportRows {
+		return nil, fmt.Errorf("%w: more than %d rows", ErrInvalidImport, maxImportRows)
+	}
+	return rows, nil
+}
+
+// decodeJSON accepts an array of specs or an object holding one in "checks".
+func decodeJSON(data []byte) ([]SpecRow, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		var wrapped struct {
+			Checks []json.RawMessage `json:"checks"`
+		}
+		if err2 := json.Unmarshal(data, &wrapped); err2 != nil || wrapped.Checks == nil {
+			return nil, err
+		}
+		items = wrapped.Checks
+	}
+
+	rows := make([]SpecRow, 0, len(items))
+	for _, raw := range items {
+		var row SpecRow
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&row.Spec); err != nil {
+			row.Err = err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// decodeYAML accepts a sequence of specs or a mapping holding one in "checks".
+func decodeYAML(data []byte) ([]SpecRow, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	seq := doc.Content[0]
+	if seq.Kind == yaml.MappingNode {
+		seq = yamlValue(seq, "checks")
+	}
+	if seq == nil || seq.Kind != yaml.SequenceNode {
+		return nil, errors.New("expected a list of checks")
+	}
+
+	rows := make([]SpecRow, 0, len(seq.Content))
+	for _, item := range seq.Content {
+		var row SpecRow
+		if item.Kind != yaml.MappingNode {
+			row.Err = fmt.Errorf("line %d: expected a mapping", item.Line)
+		} else {
+			for i := 0; i < len(item.Content); i += 2 {
+				if k := item.Content[i].Value; !specFields[k] {
+					row.Err = fmt.Errorf("line %d: unknown field %q", item.Content[i].Line, k)
+					break
+				}
+			}
+			if row.Err == nil {
+				row.Err = item.Decode(&row.Spec)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func yamlValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// decodeCSV needs a header row naming the columns; name and tags are
+// optional.
+func decodeCSV(data []byte) ([]SpecRow, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	col := make(map[string]int, len(header))
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if !specFields[h] {
+			return nil, fmt.Errorf("unknown column %q", h)
+		}
+		col[h] = i
+	}
+	for _, required := range []string{"url", "interval_sec"} {
+		if _, ok := col[required]; !ok {
+			return nil, fmt.Errorf("missing column %q", required)
+		}
+	}
+
+	var rows []SpecRow
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) != len(header) {
+			rows = append(rows, SpecRow{Err: fmt.Errorf("expected %d fields, got %d", len(header), len(rec))})
+			continue
+		}
+
+		var row SpecRow
+		cell := func(name string) string {
+			if i, ok := col[name]; ok {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		row.Spec.Name = cell("name")
+		row.Spec.URL = cell("url")
+		if tags := cell("tags"); tags != "" {
+			row.Spec.Tags = strings.Split(tags, csvTagSep)
+		}
+		sec, err := strconv.ParseInt(cell("interval_sec"), 10, 32)
+		if err != nil {
+			row.Err = fmt.Errorf("interval_sec: %q is not a number", cell("interval_sec"))
+		}
+		row.Spec.IntervalSec = int32(sec)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
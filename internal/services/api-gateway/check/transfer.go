@@ -0,0 +1,290 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/NordCoder/Pingerus/internal/domain/check"
+	"github.com/NordCoder/Pingerus/internal/domain/plan"
+	"github.com/NordCoder/Pingerus/internal/repository/postgres"
+	"github.com/NordCoder/Pingerus/internal/services/api-gateway/policy"
+)
+
+// errImportRejected rolls back an import whose rows failed while applying.
+var errImportRejected = errors.New("import rejected")
+
+type ImportAction string
+
+const (
+	ImportCreate    ImportAction = "create"
+	ImportUpdate    ImportAction = "update"
+	ImportUnchanged ImportAction = "unchanged"
+	ImportError     ImportAction = "error"
+)
+
+// ImportRow is one record of an import and what happens to it.
+type ImportRow struct {
+	Row  int
+	Spec Spec
+	// Errors holds validation failures found before the row reaches Import.
+	Errors  []string
+	Action  ImportAction
+	CheckID int64
+	Changed []string
+
+	match *check.Check
+	patch check.Patch
+}
+
+// Export calls emit with the checks of orgID a page at a time, oldest first.
+func (u *Usecase) Export(ctx context.Context, userID, orgID int64, emit func([]*check.Check) error) error {
+	orgID, err := u.resolveOrg(ctx, userID, orgID, policy.ReadChecks)
+	if err != nil {
+		return err
+	}
+	return u.eachPage(ctx, orgID, emit)
+}
+
+// eachPage walks the HTTP checks of orgID. Heartbeats are left out of
+// specs, since their ping tokens can't be carried to another org.
+func (u *Usecase) eachPage(ctx context.Context, orgID int64, fn func([]*check.Check) error) error {
+	q := check.ListQuery{OrgIDs: []int64{orgID}, Type: check.TypeHTTP, Sort: check.SortCreated, Limit: maxPageSize}
+	for {
+		list, next, err := u.repo.List(ctx, q)
+		if err != nil {
+			return err
+		}
+		if len(list) > 0 {
+			if err := fn(list); err != nil {
+				return err
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		q.After = next
+	}
+}
+
+// Import plans rows against the checks of orgID and, unless dryRun, applies
+// the plan in one transaction. Nothing is written if any row has errors;
+// applied reports whether the plan was written.
+func (u *Usecase) Import(ctx context.Context, userID, orgID int64, rows []*ImportRow, dryRun bool) (applied bool, err error) {
+	orgID, err = u.resolveOrg(ctx, userID, orgID, policy.WriteChecks)
+	if err != nil {
+		return false, err
+	}
+
+	byName := map[string][]*check.Check{}
+	byURL := map[string][]*check.Check{}
+	err = u.eachPage(ctx, orgID, func(list []*check.Check) error {
+		for _, c := range list {
+			if c.Name != "" {
+				byName[c.Name] = append(byName[c.Name], c)
+			}
+			byURL[c.URL] = append(byURL[c.URL], c)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	failed := false
+	claimed := map[int64]int{}
+	for _, r := range rows {
+		u.planRow(r, byName, byURL, claimed)
+	}
+	creates, err := u.planLimits(ctx, userID, rows)
+	if err != nil {
+		return false, err
+	}
+	for _, r := range rows {
+		if r.Action == ImportError {
+			failed = true
+		}
+	}
+	if failed {
+		return false, nil
+	}
+	lim, err := u.limits(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if dryRun {
+		return false, u.reserve(ctx, userID, lim, creates)
+	}
+
+	err = u.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := u.reserve(ctx, userID, lim, creates); err != nil {
+			return err
+		}
+		for _, r := range rows {
+			if err := u.applyRow(ctx, userID, orgID, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errImportRejected) {
+			// IDs handed out inside the rolled back transaction are void
+			for _, r := range rows {
+				if r.Action == ImportCreate {
+					r.CheckID = 0
+				}
+			}
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (u *Usecase) planRow(r *ImportRow, byName, byURL map[string][]*check.Check, claimed map[int64]int) {
+	fail := func(msg string) {
+		r.Errors = append(r.Errors, msg)
+		r.Action = ImportError
+	}
+	if len(r.Errors) > 0 {
+		r.Action = ImportError
+		return
+	}
+
+	name, err := normalizeName(r.Spec.Name)
+	if err != nil {
+		fail(err.Error())
+	}
+	tags, err := normalizeTags(r.Spec.Tags)
+	if err != nil {
+		fail(err.Error())
+	}
+	interval := time.Duration(r.Spec.IntervalSec) * time.Second
+	if !validURL(r.Spec.URL) {
+		fail(ErrInvalidURL.Error())
+	}
+	if interval < minInterval || interval > maxInterval {
+		fail(ErrInvalidInterval.Error())
+	}
+	if r.Action == ImportError {
+		return
+	}
+	r.Spec.Name, r.Spec.Tags = name, tags
+
+	candidates, key := byName[name], "name"
+	if name == "" || len(candidates) == 0 {
+		candidates, key = byURL[r.Spec.URL], "url"
+	}
+	switch {
+	case len(candidates) > 1:
+		fail(fmt.Sprintf("%s matches %d existing checks", key, len(candidates)))
+		return
+	case len(candidates) == 0:
+		r.Action = ImportCreate
+		return
+	}
+
+	cur := candidates[0]
+	if prev, ok := claimed[cur.ID]; ok {
+		fail(fmt.Sprintf("matches the same check as row %d", prev))
+		return
+	}
+	claimed[cur.ID] = r.Row
+	r.match, r.CheckID = cur, cur.ID
+
+	if name != cur.Name {
+		r.patch.Name = &name
+		r.Changed = append(r.Changed, "name")
+	}
+	if !slices.Equal(tags, cur.Tags) {
+		r.patch.Tags = &tags
+		r.Changed = append(r.Changed, "tags")
+	}
+	if r.Spec.URL != cur.URL {
+		r.patch.URL = &r.Spec.URL
+		r.Changed = append(r.Changed, "url")
+	}
+	if interval != cur.Interval {
+		r.patch.Interval = &interval
+		r.Changed = append(r.Changed, "interval_sec")
+	}
+	if len(r.Changed) == 0 {
+		r.Action = ImportUnchanged
+	} else {
+		r.Action = ImportUpdate
+	}
+}
+
+// planLimits fails rows whose interval is below the plan minimum of the
+// check's owner, the importer for new checks, and counts the creates.
+func (u *Usecase) planLimits(ctx context.Context, userID int64, rows []*ImportRow) (creates int, err error) {
+	plans := map[int64]plan.Limits{}
+	limitsOf := func(owner int64) (plan.Limits, error) {
+		if lim, ok := plans[owner]; ok {
+			return lim, nil
+		}
+		lim, err := u.limits(ctx, owner)
+		if err != nil {
+			return plan.Limits{}, err
+		}
+		plans[owner] = lim
+		return lim, nil
+	}
+	for _, r := range rows {
+		owner := userID
+		switch {
+		case r.Action == ImportCreate:
+			creates++
+		case r.Action == ImportUpdate && r.patch.Interval != nil:
+			owner = r.match.UserID
+		default:
+			continue
+		}
+		lim, err := limitsOf(owner)
+		if err != nil {
+			return 0, err
+		}
+		if err := checkInterval(lim, check.TypeHTTP, time.Duration(r.Spec.IntervalSec)*time.Second); err != nil {
+			r.Errors = append(r.Errors, err.Error())
+			r.Action = ImportError
+		}
+	}
+	return creates, nil
+}
+
+func (u *Usecase) applyRow(ctx context.Context, userID, orgID int64, r *ImportRow) error {
+	switch r.Action {
+	case ImportCreate:
+		now := time.Now().UTC()
+		c := &check.Check{
+			UserID:    userID,
+			OrgID:     orgID,
+			Name:      r.Spec.Name,
+			Type:      check.TypeHTTP,
+			Tags:      r.Spec.Tags,
+			URL:       r.Spec.URL,
+			Interval:  time.Duration(r.Spec.IntervalSec) * time.Second,
+			NextRun:   now,
+			Active:    true,
+			UpdatedAt: now,
+		}
+		if err := u.repo.Create(ctx, c); err != nil {
+			return err
+		}
+		r.CheckID = c.ID
+	case ImportUpdate:
+		// the version read while planning guards against edits made since
+		if _, err := u.repo.Patch(ctx, r.match.ID, r.patch, r.match.Version); err != nil {
+			if !errors.Is(err, postgres.ErrConflict) && !errors.Is(err, postgres.ErrNotFound) {
+				return err
+			}
+			r.Action = ImportError
+			r.Errors = append(r.Errors, "check changed or was deleted during the import; retry")
+			return errImportRejected
+		}
+	}
+	return nil
+}
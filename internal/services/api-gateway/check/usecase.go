@@ -3,82 +3,327 @@ package check
 import (
 	"context"
 	"errors"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/NordCoder/Pingerus/internal/domain/check"
+	"github.com/NordCoder/Pingerus/internal/domain/plan"
+	"github.com/NordCoder/Pingerus/internal/repository/postgres"
+	"github.com/NordCoder/Pingerus/internal/services/api-gateway/policy"
 )
 
 var (
-	ErrInvalidInterval = errors.New("interval must be >= 10s")
-	ErrForbidden       = errors.New("forbidden")
+	ErrInvalidInterval = errors.New("interval must be between 10s and 24h")
+	ErrInvalidURL      = errors.New("url must be an absolute http or https URL of at most 2048 characters")
+	ErrInvalidName     = errors.New("name must be at most 128 characters")
+	ErrInvalidTags     = errors.New("at most 20 tags of 1 to 64 characters each")
+	ErrInvalidType     = errors.New("type must be http or heartbeat")
+	ErrInvalidPeriod   = errors.New("heartbeat period must be between 10s and 30 days")
+	ErrInvalidGrace    = errors.New("grace must be between 0 and 24h")
+	ErrNotHeartbeat    = errors.New("only heartbeat checks have a grace time")
+	ErrHeartbeatURL    = errors.New("heartbeat checks have no url")
+	ErrVersionConflict = errors.New("check was changed by someone else; reload it and retry")
+	ErrForbidden       = policy.ErrForbidden
+)
+
+const (
+	minInterval = 10 * time.Second
+	maxInterval = 24 * time.Hour
+	maxPeriod   = 30 * 24 * time.Hour
+	maxGrace    = 24 * time.Hour
+	maxNameLen  = 128
+	maxTags     = 20
+	maxTagLen   = 64
 )
 
 type Usecase struct {
-	repo check.Repo
+	repo    check.Repo
+	deps    check.DependencyRepo
+	plans   plan.Repo
+	catalog plan.Catalog
+	pol     *policy.Policy
+	tx      postgres.Transactor
+	hub     *Hub
 }
 
-func NewUsecase(repo check.Repo) *Usecase {
-	return &Usecase{repo: repo}
+// NewUsecase builds the check usecase; hub may be nil, which disables Watch.
+func NewUsecase(repo check.Repo, deps check.DependencyRepo, plans plan.Repo, catalog plan.Catalog,
+	pol *policy.Policy, tx postgres.Transactor, hub *Hub) *Usecase {
+	return &Usecase{repo: repo, deps: deps, plans: plans, catalog: catalog, pol: pol, tx: tx, hub: hub}
 }
 
-func (u *Usecase) Create(ctx context.Context, ownerID int64, url string, interval time.Duration) (*check.Check, error) {
+// Create stores a new check from in's type, name, tags, URL, interval, grace
+// and parents. It goes to in.OrgID, or to the caller's default org when that
+// is 0. Heartbeat checks get a fresh ping token instead of a URL. The check
+// counts against the caller's plan, whichever org it lands in.
+func (u *Usecase) Create(ctx context.Context, ownerID int64, in *check.Check) (*check.Check, error) {
+	typ := in.Type
+	if typ == "" {
+		typ = check.TypeHTTP
+	}
+	if err := validateSchedule(typ, in.URL, in.Interval, in.Grace); err != nil {
+		return nil, err
+	}
+	var pingToken string
+	if typ == check.TypeHeartbeat {
+		var err error
+		if pingToken, err = newToken(); err != nil {
+			return nil, err
+		}
+	}
+	name, err := normalizeName(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := normalizeTags(in.Tags)
+	if err != nil {
+		return nil, err
+	}
+	parents, err := normalizeParents(in.ParentIDs)
+	if err != nil {
+		return nil, err
+	}
+	orgID, err := u.resolveOrg(ctx, ownerID, in.OrgID, policy.WriteChecks)
+	if err != nil {
+		return nil, err
+	}
+	lim, err := u.limits(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkInterval(lim, typ, in.Interval); err != nil {
+		return nil, err
+	}
+
 	now := time.Now().UTC()
 	c := &check.Check{
 		UserID:    ownerID,
-		URL:       url,
-		Interval:  interval,
+		OrgID:     orgID,
+		Name:      name,
+		Type:      typ,
+		Tags:      tags,
+		URL:       in.URL,
+		Interval:  in.Interval,
+		Grace:     in.Grace,
+		PingToken: pingToken,
 		NextRun:   now,
 		Active:    true,
 		UpdatedAt: now,
 	}
-	if err := u.repo.Create(ctx, c); err != nil {
+	err = u.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := u.reserve(ctx, ownerID, lim, 1); err != nil {
+			return err
+		}
+		if err := u.repo.Create(ctx, c); err != nil {
+			return err
+		}
+		if len(parents) == 0 {
+			return nil
+		}
+		return u.setParents(ctx, c, parents)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return c, nil
 }
 
 func (u *Usecase) Get(ctx context.Context, requesterID int64, id int64) (*check.Check, error) {
-	c, err := u.repo.GetByID(ctx, id)
-	if err != nil {
-		return nil, err
-	}
-	if c.UserID != requesterID {
-		return nil, ErrForbidden
-	}
-	return c, nil
+	return u.authorized(ctx, requesterID, id, policy.ReadChecks)
 }
 
-func (u *Usecase) Update(ctx context.Context, requesterID int64, upd *check.Check) (*check.Check, error) {
-	cur, err := u.repo.GetByID(ctx, upd.ID)
+// Update applies p to check id. A non-zero version must match the stored
+// one, otherwise ErrVersionConflict is returned and nothing is written.
+func (u *Usecase) Update(ctx context.Context, requesterID, id int64, p check.Patch, version int64) (*check.Check, error) {
+	cur, err := u.authorized(ctx, requesterID, id, policy.WriteChecks)
 	if err != nil {
 		return nil, err
 	}
-	if cur.UserID != requesterID {
-		return nil, ErrForbidden
+	if p.Name != nil {
+		name, err := normalizeName(*p.Name)
+		if err != nil {
+			return nil, err
+		}
+		p.Name = &name
+	}
+	if p.Tags != nil {
+		tags, err := normalizeTags(*p.Tags)
+		if err != nil {
+			return nil, err
+		}
+		p.Tags = &tags
+	}
+	// a full replace echoes the fields the other type doesn't use as zeros
+	if cur.Type == check.TypeHeartbeat && p.URL != nil && *p.URL == "" {
+		p.URL = nil
 	}
-	if upd.Interval < 10*time.Second {
-		return nil, ErrInvalidInterval
+	if cur.Type != check.TypeHeartbeat && p.Grace != nil && *p.Grace == 0 {
+		p.Grace = nil
+	}
+	if p.URL != nil || p.Interval != nil || p.Grace != nil {
+		url, interval, grace := cur.URL, cur.Interval, cur.Grace
+		if p.URL != nil {
+			url = *p.URL
+		}
+		if p.Interval != nil {
+			interval = *p.Interval
+		}
+		if p.Grace != nil {
+			grace = *p.Grace
+		}
+		if err := validateSchedule(cur.Type, url, interval, grace); err != nil {
+			return nil, err
+		}
+	}
+	// the owner's plan applies, and only to a changed interval, so checks
+	// made before a downgrade can still be edited
+	if p.Interval != nil && *p.Interval != cur.Interval {
+		lim, err := u.limits(ctx, cur.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkInterval(lim, cur.Type, *p.Interval); err != nil {
+			return nil, err
+		}
+	}
+	if p.Parents != nil {
+		parents, err := normalizeParents(*p.Parents)
+		if err != nil {
+			return nil, err
+		}
+		if slices.Equal(parents, cur.ParentIDs) || (len(parents) == 0 && len(cur.ParentIDs) == 0) {
+			p.Parents = nil
+		} else {
+			p.Parents = &parents
+		}
+	}
+	if p.Active != nil && *p.Active == cur.Active {
+		p.Active = nil
+	}
+	if p == (check.Patch{}) {
+		if version != 0 && version != cur.Version {
+			return nil, ErrVersionConflict
+		}
+		return cur, nil
 	}
-	upd.UserID = requesterID
-	upd.UpdatedAt = time.Now().UTC()
 
-	if err := u.repo.Update(ctx, upd); err != nil {
+	var upd *check.Check
+	err = u.tx.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		if upd, err = u.repo.Patch(ctx, id, p, version); err != nil {
+			return err
+		}
+		if p.Parents == nil {
+			return nil
+		}
+		return u.setParents(ctx, upd, *p.Parents)
+	})
+	if err != nil {
+		if errors.Is(err, postgres.ErrConflict) {
+			return nil, ErrVersionConflict
+		}
 		return nil, err
 	}
 	return upd, nil
 }
 
 func (u *Usecase) Delete(ctx context.Context, requesterID int64, id int64) error {
-	cur, err := u.repo.GetByID(ctx, id)
-	if err != nil {
+	if _, err := u.authorized(ctx, requesterID, id, policy.WriteChecks); err != nil {
 		return err
 	}
-	if cur.UserID != requesterID {
-		return ErrForbidden
-	}
 	return u.repo.Delete(ctx, id)
 }
 
-func (u *Usecase) ListByUser(ctx context.Context, requesterID int64) ([]*check.Check, error) {
-	return u.repo.ListByUser(ctx, requesterID)
+// validateSchedule checks the fields that say what a check of typ watches
+// and how often.
+func validateSchedule(typ, url string, interval, grace time.Duration) error {
+	switch typ {
+	case check.TypeHTTP:
+		if !validURL(url) {
+			return ErrInvalidURL
+		}
+		if interval < minInterval || interval > maxInterval {
+			return ErrInvalidInterval
+		}
+		if grace != 0 {
+			return ErrNotHeartbeat
+		}
+	case check.TypeHeartbeat:
+		if url != "" {
+			return ErrHeartbeatURL
+		}
+		if interval < minInterval || interval > maxPeriod {
+			return ErrInvalidPeriod
+		}
+		if grace < 0 || grace > maxGrace {
+			return ErrInvalidGrace
+		}
+	default:
+		return ErrInvalidType
+	}
+	return nil
+}
+
+// validURL accepts what the ping-worker can probe: http and https.
+func validURL(s string) bool {
+	if len(s) < 4 || len(s) > 2048 {
+		return false
+	}
+	parsed, err := url.Parse(s)
+	return err == nil && parsed.Host != "" && (parsed.Scheme == "http" || parsed.Scheme == "https")
+}
+
+func normalizeName(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > maxNameLen {
+		return "", ErrInvalidName
+	}
+	return s, nil
+}
+
+// normalizeTags lowercases and dedupes tags, keeping their order.
+func normalizeTags(in []string) ([]string, error) {
+	out := make([]string, 0, len(in))
+	seen := make(map[string]bool, len(in))
+	for _, t := range in {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || len(t) > maxTagLen {
+			return nil, ErrInvalidTags
+		}
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	if len(out) > maxTags {
+		return nil, ErrInvalidTags
+	}
+	return out, nil
+}
+
+// resolveOrg authorizes action in orgID, defaulting orgID like Create does.
+func (u *Usecase) resolveOrg(ctx context.Context, userID, orgID int64, action policy.Action) (int64, error) {
+	if orgID == 0 {
+		var err error
+		if orgID, err = u.pol.DefaultOrg(ctx, userID); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := u.pol.Authorize(ctx, userID, orgID, action); err != nil {
+		return 0, err
+	}
+	return orgID, nil
+}
+
+func (u *Usecase) authorized(ctx context.Context, requesterID, id int64, action policy.Action) (*check.Check, error) {
+	c, err := u.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := u.pol.Authorize(ctx, requesterID, c.OrgID, action); err != nil {
+		return nil, err
+	}
+	return c, nil
 }
@@ -0,0 +1,392 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/NordCoder/Pingerus/internal/domain/check"
+	"github.com/NordCoder/Pingerus/internal/services/api-gateway/policy"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrWatchUnavailable = errors.New("watching checks is unavailable; retry shortly")
+	ErrWatcherBehind    = errors.New("watcher fell behind; resume from the last cursor")
+	ErrCursorExpired    = errors.New("cursor is older than the retained events; reload and watch without it")
+)
+
+// EventHeartbeat is sent by Watch while nothing happens; its ID is the
+// current cursor.
+const EventHeartbeat check.EventKind = "heartbeat"
+
+const (
+	eventBatch = 500
+	// gapGrace bounds how long the hub waits for a missing event ID: it is
+	// usually a transaction that took its ID but has not committed yet.
+	gapGrace = 2 * time.Second
+	gapRetry = 100 * time.Millisecond
+)
+
+// WatchConfig tunes the Hub and the streams built on it.
+type WatchConfig struct {
+	Heartbeat     time.Duration
+	PollInterval  time.Duration
+	Retention     time.Duration
+	PruneInterval time.Duration
+	Buffer        int
+}
+
+// Hub tails check events in ID order and fans them out to watchers, so a
+// gateway instance reads every event once however many streams are open.
+type Hub struct {
+	events check.EventRepo
+	cfg    WatchConfig
+	log    *zap.Logger
+
+	mu      sync.Mutex
+	running bool
+	last    int64 // newest event handed to subscribers
+	gapAt   time.Time
+	subs    map[*subscription]struct{}
+}
+
+type subscription struct {
+	checkID int64
+	orgs    map[int64]bool
+	ch      chan *check.Event
+	err     error // why ch was closed; set before closing it
+}
+
+func (s *subscription) matches(ev *check.Event) bool {
+	return s.orgs[ev.OrgID] && (s.checkID == 0 || s.checkID == ev.CheckID)
+}
+
+func NewHub(events check.EventRepo, cfg WatchConfig, log *zap.Logger) *Hub {
+	if cfg.Heartbeat <= 0 {
+		cfg.Heartbeat = 15 * time.Second
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = 256
+	}
+	if log == nil {
+		log = zap.L()
+	}
+	return &Hub{
+		events: events,
+		cfg:    cfg,
+		log:    log.With(zap.String("component", "check.hub")),
+		subs:   make(map[*subscription]struct{}),
+	}
+}
+
+// Run tails events until ctx is done, then ends every open watch. A value on
+// wake, usually from a postgres.Listener on postgres.CheckEventsChannel,
+// triggers a read right away; otherwise the hub polls every PollInterval.
+func (h *Hub) Run(ctx context.Context, wake <-chan struct{}) error {
+	if err := h.start(ctx); err != nil {
+		return err
+	}
+	defer h.stop()
+
+	poll := time.NewTicker(h.cfg.PollInterval)
+	defer poll.Stop()
+	var prune <-chan time.Time
+	if h.cfg.Retention > 0 && h.cfg.PruneInterval > 0 {
+		t := time.NewTicker(h.cfg.PruneInterval)
+		defer t.Stop()
+		prune = t.C
+	}
+
+	var retry <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-prune:
+			h.prune(ctx)
+			continue
+		case <-wake:
+		case <-poll.C:
+		case <-retry:
+		}
+
+		retry = nil
+		held, err := h.tail(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			h.log.Warn("read check events", zap.Error(err))
+			continue
+		}
+		if held {
+			retry = time.After(gapRetry)
+		}
+	}
+}
+
+// start positions the hub at the newest stored event.
+func (h *Hub) start(ctx context.Context) error {
+	for {
+		_, last, err := h.events.EventBounds(ctx)
+		if err == nil {
+			h.mu.Lock()
+			h.last, h.running = last, true
+			h.mu.Unlock()
+			h.log.Info("tailing check events", zap.Int64("after", last))
+			return nil
+		}
+		h.log.Warn("locate check events", zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(h.cfg.PollInterval):
+		}
+	}
+}
+
+func (h *Hub) stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.running = false
+	for s := range h.subs {
+		h.drop(s, ErrWatchUnavailable)
+	}
+}
+
+// tail delivers the events after h.last. held reports that it stopped at a
+// gap in the IDs and should be retried shortly.
+func (h *Hub) tail(ctx context.Context) (held bool, err error) {
+	for {
+		h.mu.Lock()
+		after := h.last
+		h.mu.Unlock()
+
+		evs, err := h.events.Events(ctx, check.EventQuery{After: after, Limit: eventBatch})
+		if err != nil || len(evs) == 0 {
+			return false, err
+		}
+
+		h.mu.Lock()
+		held = h.deliver(evs)
+		h.mu.Unlock()
+		if held || len(evs) < eventBatch {
+			return held, nil
+		}
+	}
+}
+
+// deliver hands evs to the matching subscribers in order, stopping at the
+// first gap younger than gapGrace so that a late commit is not skipped.
+// h.mu must be held.
+func (h *Hub) deliver(evs []*check.Event) (held bool) {
+	for _, ev := range evs {
+		if ev.ID != h.last+1 {
+			if h.gapAt.IsZero() {
+				h.gapAt = time.Now()
+			}
+			if time.Since(h.gapAt) < gapGrace {
+				return true
+			}
+		}
+		h.gapAt = time.Time{}
+		h.last = ev.ID
+
+		for s := range h.subs {
+			if !s.matches(ev) {
+				continue
+			}
+			select {
+			case s.ch <- ev:
+			default:
+				h.drop(s, ErrWatcherBehind)
+			}
+		}
+	}
+	return false
+}
+
+func (h *Hub) prune(ctx context.Context) {
+	n, err := h.events.PruneEvents(ctx, time.Now().Add(-h.cfg.Retention))
+	if err != nil {
+		h.log.Warn("prune check events", zap.Error(err))
+		return
+	}
+	if n > 0 {
+		h.log.Info("pruned check events", zap.Int64("deleted", n))
+	}
+}
+
+// subscribe registers a watcher of orgs, narrowed to checkID when it is not
+// 0, and returns the ID after which it receives events.
+func (h *Hub) subscribe(orgs []int64, checkID int64) (*subscription, int64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.running {
+		return nil, 0, ErrWatchUnavailable
+	}
+	s := &subscription{checkID: checkID, orgs: orgSet(orgs), ch: make(chan *check.Event, h.cfg.Buffer)}
+	h.subs[s] = struct{}{}
+	return s, h.last, nil
+}
+
+func (h *Hub) unsubscribe(s *subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[s]; ok {
+		h.drop(s, nil)
+	}
+}
+
+func (h *Hub) setOrgs(s *subscription, orgs []int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s.orgs = orgSet(orgs)
+}
+
+// drop removes s and closes its channel. h.mu must be held.
+func (h *Hub) drop(s *subscription, err error) {
+	delete(h.subs, s)
+	s.err = err
+	close(s.ch)
+}
+
+func orgSet(ids []int64) map[int64]bool {
+	m := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		m[id] = true
+	}
+	return m
+}
+
+// WatchQuery scopes a Watch to one check when CheckID is set, otherwise to
+// OrgID or, when that is 0 too, to every org the caller can read. A non-zero
+// Cursor replays the events after it first.
+type WatchQuery struct {
+	OrgID     int64
+	CheckID   int64
+	Cursor    int64
+	Heartbeat time.Duration
+}
+
+// Watch calls send with every check event in scope until ctx is done or
+// send fails. An EventHeartbeat carrying the current cursor goes out once
+// the replay is done and again whenever the stream has been quiet for a
+// heartbeat interval; access is re-checked at each of them.
+func (u *Usecase) Watch(ctx context.Context, requesterID int64, q WatchQuery, send func(*check.Event) error) error {
+	if u.hub == nil {
+		return ErrWatchUnavailable
+	}
+	orgs, err := u.watchScope(ctx, requesterID, q)
+	if err != nil {
+		return err
+	}
+	sub, horizon, err := u.hub.subscribe(orgs, q.CheckID)
+	if err != nil {
+		return err
+	}
+	defer u.hub.unsubscribe(sub)
+
+	cursor := horizon
+	if q.Cursor > 0 {
+		if cursor, err = u.replay(ctx, q, orgs, horizon, send); err != nil {
+			return err
+		}
+	}
+
+	every := q.Heartbeat
+	if every <= 0 {
+		every = u.hub.cfg.Heartbeat
+	}
+	heartbeat := func() error {
+		return send(&check.Event{ID: cursor, Kind: EventHeartbeat, CreatedAt: time.Now().UTC()})
+	}
+	if err := heartbeat(); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev, ok := <-sub.ch:
+			if !ok {
+				return sub.err
+			}
+			if ev.ID <= cursor {
+				continue
+			}
+			if err := send(ev); err != nil {
+				return err
+			}
+			cursor = ev.ID
+			ticker.Reset(every)
+		case <-ticker.C:
+			orgs, err := u.watchScope(ctx, requesterID, q)
+			if err != nil {
+				return err
+			}
+			u.hub.setOrgs(sub, orgs)
+			if err := heartbeat(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// replay sends the stored events in (q.Cursor, horizon] and returns the
+// cursor live delivery continues from.
+func (u *Usecase) replay(ctx context.Context, q WatchQuery, orgs []int64, horizon int64, send func(*check.Event) error) (int64, error) {
+	first, _, err := u.hub.events.EventBounds(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if first > 0 && q.Cursor+1 < first {
+		return 0, ErrCursorExpired
+	}
+
+	for after := q.Cursor; after < horizon; {
+		evs, err := u.hub.events.Events(ctx, check.EventQuery{
+			OrgIDs: orgs, CheckID: q.CheckID, After: after, UpTo: horizon, Limit: eventBatch,
+		})
+		if err != nil {
+			return 0, err
+		}
+		for _, ev := range evs {
+			if err := send(ev); err != nil {
+				return 0, err
+			}
+		}
+		if len(evs) < eventBatch {
+			break
+		}
+		after = evs[len(evs)-1].ID
+	}
+	return max(q.Cursor, horizon), nil
+}
+
+func (u *Usecase) watchScope(ctx context.Context, requesterID int64, q WatchQuery) ([]int64, error) {
+	switch {
+	case q.CheckID != 0:
+		c, err := u.authorized(ctx, requesterID, q.CheckID, policy.ReadChecks)
+		if err != nil {
+			return nil, err
+		}
+		return []int64{c.OrgID}, nil
+	case q.OrgID != 0:
+		if _, err := u.pol.Authorize(ctx, requesterID, q.OrgID, policy.ReadChecks); err != nil {
+			return nil, err
+		}
+		return []int64{q.OrgID}, nil
+	default:
+		return u.pol.VisibleOrgs(ctx, requesterID, policy.ReadChecks)
+	}
+}
@@ -0,0 +1,40 @@
+package heartbeat
+
+import (
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// Handler serves the ping URLs of heartbeat checks: /ping/{token} for a
+// success, and /ping/{token}/start or /ping/{token}/fail. Jobs call them
+// without credentials, so any method cron tooling tends to use is fine.
+func Handler(uc *Usecase, log *zap.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		kind := KindSuccess
+		switch r.PathValue("kind") {
+		case "":
+		case "start":
+			kind = KindStart
+		case "fail":
+			kind = KindFail
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		err := uc.Ping(r.Context(), r.PathValue("token"), kind)
+		switch {
+		case err == nil:
+			_, _ = w.Write([]byte("OK"))
+		case errors.Is(err, ErrNotFound):
+			http.Error(w, "not found", http.StatusNotFound)
+		default:
+			log.Warn("heartbeat ping", zap.String("kind", string(kind)), zap.Error(err))
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	})
+}
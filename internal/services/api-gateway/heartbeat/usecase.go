@@ -0,0 +1,111 @@
+// Package heartbeat takes the pings that jobs send to their heartbeat
+// checks.
+package heartbeat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NordCoder/Pingerus/internal/domain/check"
+	"github.com/NordCoder/Pingerus/internal/domain/outbox"
+	"github.com/NordCoder/Pingerus/internal/domain/run"
+	intoutbox "github.com/NordCoder/Pingerus/internal/outbox"
+	"github.com/NordCoder/Pingerus/internal/repository/postgres"
+)
+
+var ErrNotFound = errors.New("heartbeat not found")
+
+// Kind is what a ping reports about the job behind a heartbeat.
+type Kind string
+
+const (
+	// KindSuccess says the job finished fine; it is what a bare ping means.
+	KindSuccess Kind = "success"
+	// KindStart says the job began; the next ping then records its duration.
+	KindStart Kind = "start"
+	KindFail  Kind = "fail"
+)
+
+type Usecase struct {
+	checks check.Repo
+	runs   run.Repo
+	outbox outbox.Repository
+	tx     postgres.Transactor
+}
+
+func NewUsecase(checks check.Repo, runs run.Repo, ob outbox.Repository, tx postgres.Transactor) *Usecase {
+	return &Usecase{checks: checks, runs: runs, outbox: ob, tx: tx}
+}
+
+// Ping records a ping of kind on the heartbeat named by token. Success and
+// fail pings store a run, timed from the last start ping if there was one,
+// and move the deadline a full period ahead; a start ping gives the job its
+// grace time to finish, or its period if it has no grace, but never brings
+// the deadline closer. A status change is published through the outbox
+// like the ping-worker does for HTTP checks.
+func (u *Usecase) Ping(ctx context.Context, token string, kind Kind) error {
+	return u.tx.WithTx(ctx, func(ctx context.Context) error {
+		c, err := u.checks.LockByPingToken(ctx, token)
+		if err != nil {
+			if errors.Is(err, postgres.ErrNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		if !c.Active {
+			return nil
+		}
+
+		now := time.Now().UTC()
+		if kind == KindStart {
+			next := now.Add(runBudget(c))
+			if c.NextRun.After(next) {
+				next = c.NextRun
+			}
+			return u.checks.RecordPing(ctx, c.ID, check.Ping{At: now, StartedAt: &now, NextRun: next})
+		}
+
+		up := kind == KindSuccess
+		var latency time.Duration
+		if c.StartedAt != nil && now.Sub(*c.StartedAt) <= c.Interval+c.Grace {
+			latency = now.Sub(*c.StartedAt)
+		}
+		if err := u.runs.Insert(ctx, &run.Run{
+			CheckID:   c.ID,
+			Timestamp: now,
+			Status:    up,
+			Latency:   latency.Milliseconds(),
+		}); err != nil {
+			return fmt.Errorf("insert run: %w", err)
+		}
+		if err := u.checks.RecordPing(ctx, c.ID, check.Ping{
+			At:      now,
+			Status:  &up,
+			NextRun: now.Add(c.Interval + c.Grace),
+		}); err != nil {
+			return err
+		}
+
+		// like HTTP checks, a first result only alerts when it is up
+		prev := c.LastStatus
+		if (prev == nil && !up) || (prev != nil && *prev == up) {
+			return nil
+		}
+		payload := intoutbox.StatusChangedPayload{CheckID: c.ID, Old: !up, New: up, At: now}
+		key := fmt.Sprintf("status:%d:%d", c.ID, payload.At.UnixNano())
+		if err := intoutbox.StatusChanged.Enqueue(ctx, u.outbox, key, payload); err != nil {
+			return fmt.Errorf("outbox enqueue: %w", err)
+		}
+		return nil
+	})
+}
+
+// runBudget is how long a started job of c may run before it is missed.
+func runBudget(c *check.Check) time.Duration {
+	if c.Grace == 0 {
+		return c.Interval
+	}
+	return c.Grace
+}
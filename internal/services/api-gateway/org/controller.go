@@ -0,0 +1,228 @@
+package org
+
+import (
+	"context"
+	"errors"
+
+	"github.com/NordCoder/Pingerus/internal/domain/org"
+	"github.com/NordCoder/Pingerus/internal/repository/postgres"
+	"github.com/NordCoder/Pingerus/internal/services/api-gateway/auth"
+
+	pb "github.com/NordCoder/Pingerus/generated/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type Server struct {
+	pb.UnimplementedOrgServiceServer
+	log *zap.Logger
+	uc  *Usecase
+}
+
+func NewServer(log *zap.Logger, uc *Usecase) *Server {
+	return &Server{log: log, uc: uc}
+}
+
+func (s *Server) userID(ctx context.Context) (int64, error) {
+	uid, ok := auth.UserIDFromCtx(ctx)
+	if !ok {
+		return 0, status.Error(codes.Unauthenticated, "auth required")
+	}
+	return uid, nil
+}
+
+func (s *Server) mapErr(err error) error {
+	switch {
+	case errors.Is(err, ErrInvalidRole), errors.Is(err, errEmptyOrgName):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrForbidden), errors.Is(err, ErrOwnerRequired):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, ErrPersonalOrg), errors.Is(err, ErrLastOwner):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrAlreadyMember):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, ErrUserNotFound), errors.Is(err, ErrMemberNotFound), errors.Is(err, postgres.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	default:
+		return err
+	}
+}
+
+func (s *Server) CreateOrg(ctx context.Context, req *pb.CreateOrgRequest) (*pb.Org, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("org.create", zap.Int64("uid", uid), zap.String("name", req.GetName()))
+
+	m, err := s.uc.Create(ctx, uid, req.GetName())
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	return toPBOrg(m), nil
+}
+
+func (s *Server) ListOrgs(ctx context.Context, _ *emptypb.Empty) (*pb.ListOrgsResponse, error) {
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ms, err := s.uc.List(ctx, uid)
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	out := make([]*pb.Org, 0, len(ms))
+	for _, m := range ms {
+		out = append(out, toPBOrg(m))
+	}
+	return &pb.ListOrgsResponse{Orgs: out}, nil
+}
+
+func (s *Server) DeleteOrg(ctx context.Context, req *pb.DeleteOrgRequest) (*emptypb.Empty, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("org.delete", zap.Int64("uid", uid), zap.Int64("org_id", req.GetOrgId()))
+
+	if err := s.uc.Delete(ctx, uid, req.GetOrgId()); err != nil {
+		return nil, s.mapErr(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) ListOrgMembers(ctx context.Context, req *pb.ListOrgMembersRequest) (*pb.ListOrgMembersResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ms, err := s.uc.ListMembers(ctx, uid, req.GetOrgId())
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	out := make([]*pb.OrgMember, 0, len(ms))
+	for _, m := range ms {
+		out = append(out, toPBMember(m))
+	}
+	return &pb.ListOrgMembersResponse{Members: out}, nil
+}
+
+func (s *Server) AddOrgMember(ctx context.Context, req *pb.AddOrgMemberRequest) (*pb.OrgMember, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("org.add_member", zap.Int64("uid", uid), zap.Int64("org_id", req.GetOrgId()), zap.String("role", req.GetRole().String()))
+
+	m, err := s.uc.AddMember(ctx, uid, req.GetOrgId(), req.GetEmail(), roleFromPB(req.GetRole()))
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	return toPBMember(m), nil
+}
+
+func (s *Server) UpdateOrgMember(ctx context.Context, req *pb.UpdateOrgMemberRequest) (*pb.OrgMember, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("org.update_member", zap.Int64("uid", uid), zap.Int64("org_id", req.GetOrgId()),
+		zap.Int64("member_id", req.GetUserId()), zap.String("role", req.GetRole().String()))
+
+	m, err := s.uc.UpdateMember(ctx, uid, req.GetOrgId(), req.GetUserId(), roleFromPB(req.GetRole()))
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	return toPBMember(m), nil
+}
+
+func (s *Server) RemoveOrgMember(ctx context.Context, req *pb.RemoveOrgMemberRequest) (*emptypb.Empty, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("org.remove_member", zap.Int64("uid", uid), zap.Int64("org_id", req.GetOrgId()), zap.Int64("member_id", req.GetUserId()))
+
+	if err := s.uc.RemoveMember(ctx, uid, req.GetOrgId(), req.GetUserId()); err != nil {
+		return nil, s.mapErr(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func toPBOrg(m *org.Membership) *pb.Org {
+	return &pb.Org{
+		Id:        m.Org.ID,
+		Name:      m.Org.Name,
+		Personal:  m.Org.Personal,
+		CreatedAt: timestamppb.New(m.Org.CreatedAt),
+		Role:      roleToPB(m.Role),
+	}
+}
+
+func toPBMember(m *org.Member) *pb.OrgMember {
+	return &pb.OrgMember{
+		OrgId:     m.OrgID,
+		UserId:    m.UserID,
+		Email:     m.Email,
+		Role:      roleToPB(m.Role),
+		CreatedAt: timestamppb.New(m.CreatedAt),
+	}
+}
+
+func roleFromPB(r pb.OrgRole) org.Role {
+	switch r {
+	case pb.OrgRole_ORG_ROLE_VIEWER:
+		return org.RoleViewer
+	case pb.OrgRole_ORG_ROLE_EDITOR:
+		return org.RoleEditor
+	case pb.OrgRole_ORG_ROLE_ADMIN:
+		return org.RoleAdmin
+	case pb.OrgRole_ORG_ROLE_OWNER:
+		return org.RoleOwner
+	default:
+		return ""
+	}
+}
+
+func roleToPB(r org.Role) pb.OrgRole {
+	switch r {
+	case org.RoleViewer:
+		return pb.OrgRole_ORG_ROLE_VIEWER
+	case org.RoleEditor:
+		return pb.OrgRole_ORG_ROLE_EDITOR
+	case org.RoleAdmin:
+		return pb.OrgRole_ORG_ROLE_ADMIN
+	case org.RoleOwner:
+		return pb.OrgRole_ORG_ROLE_OWNER
+	default:
+		return pb.OrgRole_ORG_ROLE_UNSPECIFIED
+	}
+}
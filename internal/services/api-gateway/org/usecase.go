@@ -0,0 +1,183 @@
+package org
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/NordCoder/Pingerus/internal/domain/org"
+	"github.com/NordCoder/Pingerus/internal/domain/user"
+	"github.com/NordCoder/Pingerus/internal/repository/postgres"
+	"github.com/NordCoder/Pingerus/internal/services/api-gateway/policy"
+)
+
+var (
+	ErrInvalidRole    = errors.New("invalid role")
+	ErrPersonalOrg    = errors.New("personal orgs cannot be shared or deleted")
+	ErrLastOwner      = errors.New("an org must keep at least one owner")
+	ErrAlreadyMember  = errors.New("user is already a member")
+	ErrUserNotFound   = errors.New("no user with that email")
+	ErrMemberNotFound = errors.New("member not found")
+	ErrOwnerRequired  = errors.New("only owners can grant or change the owner role")
+	ErrForbidden      = policy.ErrForbidden
+	errEmptyOrgName   = errors.New("org name is required")
+)
+
+type Usecase struct {
+	orgs  org.Repo
+	users user.Repo
+	pol   *policy.Policy
+}
+
+func NewUsecase(orgs org.Repo, users user.Repo, pol *policy.Policy) *Usecase {
+	return &Usecase{orgs: orgs, users: users, pol: pol}
+}
+
+func (u *Usecase) Create(ctx context.Context, userID int64, name string) (*org.Membership, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errEmptyOrgName
+	}
+	o := &org.Org{Name: name}
+	if err := u.orgs.Create(ctx, o, userID); err != nil {
+		return nil, err
+	}
+	return &org.Membership{Org: *o, Role: org.RoleOwner}, nil
+}
+
+// List returns the caller's orgs; an API key only sees its own.
+func (u *Usecase) List(ctx context.Context, userID int64) ([]*org.Membership, error) {
+	ms, err := u.orgs.ListForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	key, pinned := policy.KeyOrgFromCtx(ctx)
+	if !pinned {
+		return ms, nil
+	}
+	out := ms[:0]
+	for _, m := range ms {
+		if m.Org.ID == key {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func (u *Usecase) Delete(ctx context.Context, userID, orgID int64) error {
+	if _, err := u.pol.Authorize(ctx, userID, orgID, policy.DeleteOrg); err != nil {
+		return err
+	}
+	o, err := u.orgs.GetByID(ctx, orgID)
+	if err != nil {
+		return err
+	}
+	if o.Personal {
+		return ErrPersonalOrg
+	}
+	return u.orgs.Delete(ctx, orgID)
+}
+
+func (u *Usecase) ListMembers(ctx context.Context, userID, orgID int64) ([]*org.Member, error) {
+	if _, err := u.pol.Authorize(ctx, userID, orgID, policy.ReadOrg); err != nil {
+		return nil, err
+	}
+	return u.orgs.ListMembers(ctx, orgID)
+}
+
+func (u *Usecase) AddMember(ctx context.Context, userID, orgID int64, email string, role org.Role) (*org.Member, error) {
+	if !role.Valid() {
+		return nil, ErrInvalidRole
+	}
+	callerRole, err := u.pol.Authorize(ctx, userID, orgID, policy.ManageMembers)
+	if err != nil {
+		return nil, err
+	}
+	if role == org.RoleOwner && callerRole != org.RoleOwner {
+		return nil, ErrOwnerRequired
+	}
+	o, err := u.orgs.GetByID(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if o.Personal {
+		return nil, ErrPersonalOrg
+	}
+
+	usr, err := u.users.GetByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		if errors.Is(err, postgres.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	if err := u.orgs.AddMember(ctx, orgID, usr.ID, role); err != nil {
+		if errors.Is(err, postgres.ErrConflict) {
+			return nil, ErrAlreadyMember
+		}
+		return nil, err
+	}
+	return u.orgs.GetMember(ctx, orgID, usr.ID)
+}
+
+func (u *Usecase) UpdateMember(ctx context.Context, userID, orgID, memberID int64, role org.Role) (*org.Member, error) {
+	if !role.Valid() {
+		return nil, ErrInvalidRole
+	}
+	callerRole, err := u.pol.Authorize(ctx, userID, orgID, policy.ManageMembers)
+	if err != nil {
+		return nil, err
+	}
+	target, err := u.member(ctx, orgID, memberID)
+	if err != nil {
+		return nil, err
+	}
+	if (role == org.RoleOwner || target.Role == org.RoleOwner) && callerRole != org.RoleOwner {
+		return nil, ErrOwnerRequired
+	}
+	if err := u.orgs.SetRole(ctx, orgID, memberID, role); err != nil {
+		if errors.Is(err, postgres.ErrConflict) {
+			return nil, ErrLastOwner
+		}
+		return nil, err
+	}
+	target.Role = role
+	return target, nil
+}
+
+// RemoveMember removes memberID from the org; anyone may leave on their own.
+func (u *Usecase) RemoveMember(ctx context.Context, userID, orgID, memberID int64) error {
+	action := policy.ManageMembers
+	if memberID == userID {
+		action = policy.ReadOrg
+	}
+	callerRole, err := u.pol.Authorize(ctx, userID, orgID, action)
+	if err != nil {
+		return err
+	}
+	target, err := u.member(ctx, orgID, memberID)
+	if err != nil {
+		return err
+	}
+	if memberID != userID && target.Role == org.RoleOwner && callerRole != org.RoleOwner {
+		return ErrOwnerRequired
+	}
+	if err := u.orgs.RemoveMember(ctx, orgID, memberID); err != nil {
+		if errors.Is(err, postgres.ErrConflict) {
+			return ErrLastOwner
+		}
+		return err
+	}
+	return nil
+}
+
+func (u *Usecase) member(ctx context.Context, orgID, userID int64) (*org.Member, error) {
+	m, err := u.orgs.GetMember(ctx, orgID, userID)
+	if err != nil {
+		if errors.Is(err, postgres.ErrNotFound) {
+			return nil, ErrMemberNotFound
+		}
+		return nil, err
+	}
+	return m, nil
+}
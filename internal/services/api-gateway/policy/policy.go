@@ -0,0 +1,117 @@
+// Package policy decides what a user may do inside an org. Every service
+// that touches org-owned resources asks it instead of comparing owner IDs.
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/NordCoder/Pingerus/internal/domain/org"
+	"github.com/NordCoder/Pingerus/internal/repository/postgres"
+)
+
+var ErrForbidden = errors.New("forbidden")
+
+type Action string
+
+const (
+	ReadChecks    Action = "checks.read"
+	WriteChecks   Action = "checks.write"
+	ReadOrg       Action = "org.read"
+	ManageMembers Action = "org.members.manage"
+	ManageAPIKeys Action = "org.api_keys.manage"
+	DeleteOrg     Action = "org.delete"
+
+	ReadStatusPages  Action = "status_pages.read"
+	WriteStatusPages Action = "status_pages.write"
+)
+
+// minRole is the least role allowed to perform each action.
+var minRole = map[Action]org.Role{
+	ReadChecks:    org.RoleViewer,
+	WriteChecks:   org.RoleEditor,
+	ReadOrg:       org.RoleViewer,
+	ManageMembers: org.RoleAdmin,
+	ManageAPIKeys: org.RoleAdmin,
+	DeleteOrg:     org.RoleOwner,
+
+	ReadStatusPages:  org.RoleViewer,
+	WriteStatusPages: org.RoleEditor,
+}
+
+type ctxKey struct{}
+
+// WithKeyOrg pins the request to the org of the API key it was made with.
+func WithKeyOrg(ctx context.Context, orgID int64) context.Context {
+	return context.WithValue(ctx, ctxKey{}, orgID)
+}
+
+// KeyOrgFromCtx returns the org the request is pinned to, if any.
+func KeyOrgFromCtx(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(ctxKey{}).(int64)
+	return id, ok
+}
+
+type Policy struct {
+	orgs org.Repo
+}
+
+func New(orgs org.Repo) *Policy {
+	return &Policy{orgs: orgs}
+}
+
+// Authorize returns the caller's role in orgID, or ErrForbidden when the
+// role is not enough for action or the org is not theirs.
+func (p *Policy) Authorize(ctx context.Context, userID, orgID int64, action Action) (org.Role, error) {
+	min, ok := minRole[action]
+	if !ok {
+		return "", fmt.Errorf("unknown action %q", action)
+	}
+	if id, ok := KeyOrgFromCtx(ctx); ok && id != orgID {
+		return "", ErrForbidden
+	}
+	m, err := p.orgs.GetMember(ctx, orgID, userID)
+	if err != nil {
+		if errors.Is(err, postgres.ErrNotFound) {
+			return "", ErrForbidden
+		}
+		return "", err
+	}
+	if !m.Role.AtLeast(min) {
+		return "", ErrForbidden
+	}
+	return m.Role, nil
+}
+
+// DefaultOrg is where resources go when the caller names no org: the API
+// key's org, or else the user's personal org.
+func (p *Policy) DefaultOrg(ctx context.Context, userID int64) (int64, error) {
+	if id, ok := KeyOrgFromCtx(ctx); ok {
+		return id, nil
+	}
+	return p.orgs.PersonalOrgID(ctx, userID)
+}
+
+// VisibleOrgs lists the orgs in which the caller may perform action.
+func (p *Policy) VisibleOrgs(ctx context.Context, userID int64, action Action) ([]int64, error) {
+	min, ok := minRole[action]
+	if !ok {
+		return nil, fmt.Errorf("unknown action %q", action)
+	}
+	ms, err := p.orgs.ListForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	key, pinned := KeyOrgFromCtx(ctx)
+	var out []int64
+	for _, m := range ms {
+		if pinned && m.Org.ID != key {
+			continue
+		}
+		if m.Role.AtLeast(min) {
+			out = append(out, m.Org.ID)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,357 @@
+package statuspage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/NordCoder/Pingerus/internal/domain/check"
+	"github.com/NordCoder/Pingerus/internal/domain/statuspage"
+	"github.com/NordCoder/Pingerus/internal/services/api-gateway/auth"
+
+	pb "github.com/NordCoder/Pingerus/generated/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type Server struct {
+	pb.UnimplementedStatusPageServiceServer
+	log *zap.Logger
+	uc  *Usecase
+}
+
+func NewServer(log *zap.Logger, uc *Usecase) *Server {
+	return &Server{log: log, uc: uc}
+}
+
+func (s *Server) userID(ctx context.Context) (int64, error) {
+	uid, ok := auth.UserIDFromCtx(ctx)
+	if !ok {
+		return 0, status.Error(codes.Unauthenticated, "auth required")
+	}
+	return uid, nil
+}
+
+func (s *Server) mapErr(err error) error {
+	switch {
+	case errors.Is(err, ErrInvalidSlug), errors.Is(err, ErrInvalidTitle), errors.Is(err, ErrInvalidDescription),
+		errors.Is(err, ErrInvalidLogoURL), errors.Is(err, ErrInvalidChecks), errors.Is(err, ErrForeignCheck),
+		errors.Is(err, ErrInvalidNote):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrSlugTaken):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, ErrPageNotFound), errors.Is(err, ErrNoteNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	default:
+		return err
+	}
+}
+
+func toPB(p *statuspage.Page) *pb.StatusPage {
+	checks := make([]*pb.StatusPageCheck, 0, len(p.Checks))
+	for _, c := range p.Checks {
+		checks = append(checks, &pb.StatusPageCheck{CheckId: c.CheckID, DisplayName: c.DisplayName})
+	}
+	return &pb.StatusPage{
+		Id:          p.ID,
+		OrgId:       p.OrgID,
+		Slug:        p.Slug,
+		Title:       p.Title,
+		Description: p.Description,
+		LogoUrl:     p.LogoURL,
+		Checks:      checks,
+		CreatedAt:   timestamppb.New(p.CreatedAt),
+		UpdatedAt:   timestamppb.New(p.UpdatedAt),
+	}
+}
+
+func checksFromPB(in []*pb.StatusPageCheck) []statuspage.PageCheck {
+	out := make([]statuspage.PageCheck, 0, len(in))
+	for _, c := range in {
+		out = append(out, statuspage.PageCheck{CheckID: c.GetCheckId(), DisplayName: c.GetDisplayName()})
+	}
+	return out
+}
+
+func noteToPB(n *statuspage.Note) *pb.StatusPageNote {
+	out := &pb.StatusPageNote{
+		Id:        n.ID,
+		PageId:    n.PageID,
+		Title:     n.Title,
+		Body:      n.Body,
+		CreatedAt: timestamppb.New(n.CreatedAt),
+	}
+	if n.ResolvedAt != nil {
+		out.ResolvedAt = timestamppb.New(*n.ResolvedAt)
+	}
+	return out
+}
+
+var statusToPB = map[check.Status]pb.CheckStatus{
+	check.StatusUp:      pb.CheckStatus_CHECK_STATUS_UP,
+	check.StatusDown:    pb.CheckStatus_CHECK_STATUS_DOWN,
+	check.StatusUnknown: pb.CheckStatus_CHECK_STATUS_UNKNOWN,
+}
+
+func viewToPB(v *View) *pb.PublicStatusPage {
+	out := &pb.PublicStatusPage{
+		Slug:        v.Page.Slug,
+		Title:       v.Page.Title,
+		Description: v.Page.Description,
+		LogoUrl:     v.Page.LogoURL,
+		Status:      statusToPB[v.Status],
+		GeneratedAt: timestamppb.New(v.GeneratedAt),
+	}
+	for _, c := range v.Checks {
+		pc := &pb.PublicCheck{Name: c.Name, Status: statusToPB[c.Status], Uptime: max(c.Uptime, 0)}
+		for _, d := range c.Days {
+			pc.Days = append(pc.Days, &pb.UptimeDay{Date: d.Day.Format(dayLayout), Total: d.Total, Up: d.Up})
+		}
+		out.Checks = append(out.Checks, pc)
+	}
+	for _, in := range v.Incidents {
+		pi := &pb.PublicIncident{CheckName: in.CheckName, OpenedAt: timestamppb.New(in.OpenedAt), Note: in.Note}
+		if in.ResolvedAt != nil {
+			pi.ResolvedAt = timestamppb.New(*in.ResolvedAt)
+		}
+		out.Incidents = append(out.Incidents, pi)
+	}
+	for _, n := range v.Notes {
+		out.Notes = append(out.Notes, noteToPB(n))
+	}
+	return out
+}
+
+// outputOnlyPaths are ignored in update masks, like for checks.
+var outputOnlyPaths = map[string]bool{
+	"id": true, "org_id": true, "created_at": true, "updated_at": true,
+}
+
+func patchFromPB(in *pb.StatusPage, mask *fieldmaskpb.FieldMask) (statuspage.Patch, error) {
+	paths := mask.GetPaths()
+	if len(paths) == 0 || (len(paths) == 1 && paths[0] == "*") {
+		paths = []string{"slug", "title", "description", "logo_url", "checks"}
+	}
+
+	var p statuspage.Patch
+	for _, path := range paths {
+		switch path {
+		case "slug":
+			v := in.GetSlug()
+			p.Slug = &v
+		case "title":
+			v := in.GetTitle()
+			p.Title = &v
+		case "description":
+			v := in.GetDescription()
+			p.Description = &v
+		case "logo_url":
+			v := in.GetLogoUrl()
+			p.LogoURL = &v
+		case "checks":
+			v := checksFromPB(in.GetChecks())
+			p.Checks = &v
+		default:
+			if !outputOnlyPaths[path] {
+				return statuspage.Patch{}, fmt.Errorf("unknown or immutable field %q in update_mask", path)
+			}
+		}
+	}
+	return p, nil
+}
+
+func (s *Server) CreateStatusPage(ctx context.Context, req *pb.CreateStatusPageRequest) (*pb.StatusPage, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("statuspage.create", zap.Int64("uid", uid), zap.Int64("org_id", req.GetOrgId()), zap.String("slug", req.GetSlug()))
+
+	p, err := s.uc.Create(ctx, uid, &statuspage.Page{
+		OrgID:       req.GetOrgId(),
+		Slug:        req.GetSlug(),
+		Title:       req.GetTitle(),
+		Description: req.GetDescription(),
+		LogoURL:     req.GetLogoUrl(),
+		Checks:      checksFromPB(req.GetChecks()),
+	})
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	return toPB(p), nil
+}
+
+func (s *Server) GetStatusPage(ctx context.Context, req *pb.GetStatusPageRequest) (*pb.StatusPage, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := s.uc.Get(ctx, uid, req.GetId())
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	return toPB(p), nil
+}
+
+func (s *Server) ListStatusPages(ctx context.Context, req *pb.ListStatusPagesRequest) (*pb.ListStatusPagesResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pages, err := s.uc.List(ctx, uid, req.GetOrgId())
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	out := make([]*pb.StatusPage, 0, len(pages))
+	for _, p := range pages {
+		out = append(out, toPB(p))
+	}
+	return &pb.ListStatusPagesResponse{Pages: out}, nil
+}
+
+func (s *Server) UpdateStatusPage(ctx context.Context, req *pb.UpdateStatusPageRequest) (*pb.StatusPage, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	in := req.GetPage()
+	if in.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "page.id is required")
+	}
+	p, err := patchFromPB(in, req.GetUpdateMask())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s.log.Info("statuspage.update", zap.Int64("uid", uid), zap.Int64("id", in.GetId()),
+		zap.Strings("update_mask", req.GetUpdateMask().GetPaths()))
+
+	updated, err := s.uc.Update(ctx, uid, in.GetId(), p)
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	return toPB(updated), nil
+}
+
+func (s *Server) DeleteStatusPage(ctx context.Context, req *pb.DeleteStatusPageRequest) (*emptypb.Empty, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("statuspage.delete", zap.Int64("uid", uid), zap.Int64("id", req.GetId()))
+
+	if err := s.uc.Delete(ctx, uid, req.GetId()); err != nil {
+		return nil, s.mapErr(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) CreateStatusPageNote(ctx context.Context, req *pb.CreateStatusPageNoteRequest) (*pb.StatusPageNote, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("statuspage.note.create", zap.Int64("uid", uid), zap.Int64("page_id", req.GetPageId()))
+
+	n, err := s.uc.AddNote(ctx, uid, req.GetPageId(), req.GetTitle(), req.GetBody())
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	return noteToPB(n), nil
+}
+
+func (s *Server) ListStatusPageNotes(ctx context.Context, req *pb.ListStatusPageNotesRequest) (*pb.ListStatusPageNotesResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	notes, err := s.uc.ListNotes(ctx, uid, req.GetPageId())
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	out := make([]*pb.StatusPageNote, 0, len(notes))
+	for _, n := range notes {
+		out = append(out, noteToPB(n))
+	}
+	return &pb.ListStatusPageNotesResponse{Notes: out}, nil
+}
+
+func (s *Server) ResolveStatusPageNote(ctx context.Context, req *pb.StatusPageNoteRef) (*pb.StatusPageNote, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("statuspage.note.resolve", zap.Int64("uid", uid), zap.Int64("page_id", req.GetPageId()), zap.Int64("id", req.GetId()))
+
+	n, err := s.uc.ResolveNote(ctx, uid, req.GetPageId(), req.GetId())
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	return noteToPB(n), nil
+}
+
+func (s *Server) DeleteStatusPageNote(ctx context.Context, req *pb.StatusPageNoteRef) (*emptypb.Empty, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uid, err := s.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("statuspage.note.delete", zap.Int64("uid", uid), zap.Int64("page_id", req.GetPageId()), zap.Int64("id", req.GetId()))
+
+	if err := s.uc.DeleteNote(ctx, uid, req.GetPageId(), req.GetId()); err != nil {
+		return nil, s.mapErr(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// GetPublicStatusPage is anonymous: the slug is the only credential.
+func (s *Server) GetPublicStatusPage(ctx context.Context, req *pb.GetPublicStatusPageRequest) (*pb.PublicStatusPage, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	v, err := s.uc.Public(ctx, req.GetSlug())
+	if err != nil {
+		return nil, s.mapErr(err)
+	}
+	return viewToPB(v), nil
+}
@@ -0,0 +1,94 @@
+package statuspage
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	pb "github.com/NordCoder/Pingerus/generated/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// htmlMaxAge lets browsers and proxies reuse a rendered page briefly.
+const htmlMaxAge = 30 * time.Second
+
+//go:embed page.html
+var pageHTML string
+
+var pageTmpl = template.Must(template.New("page").Funcs(template.FuncMap{
+	"statusText": func(s pb.CheckStatus) string {
+		switch s {
+		case pb.CheckStatus_CHECK_STATUS_UP:
+			return "Operational"
+		case pb.CheckStatus_CHECK_STATUS_DOWN:
+			return "Down"
+		default:
+			return "Unknown"
+		}
+	},
+	"statusClass": func(s pb.CheckStatus) string {
+		switch s {
+		case pb.CheckStatus_CHECK_STATUS_UP:
+			return "up"
+		case pb.CheckStatus_CHECK_STATUS_DOWN:
+			return "down"
+		default:
+			return "unknown"
+		}
+	},
+	"dayClass": func(d *pb.UptimeDay) string {
+		switch {
+		case d.GetTotal() == 0:
+			return "none"
+		case d.GetUp() == d.GetTotal():
+			return "up"
+		case d.GetUp()*100 >= d.GetTotal()*95:
+			return "partial"
+		default:
+			return "down"
+		}
+	},
+	"dayTitle": func(d *pb.UptimeDay) string {
+		if d.GetTotal() == 0 {
+			return d.GetDate() + ": no data"
+		}
+		return fmt.Sprintf("%s: %.2f%% up", d.GetDate(), float64(d.GetUp())*100/float64(d.GetTotal()))
+	},
+	"pct": func(v float64) string { return fmt.Sprintf("%.2f%%", v) },
+	"when": func(ts *timestamppb.Timestamp) string {
+		if ts == nil {
+			return ""
+		}
+		return ts.AsTime().UTC().Format("2006-01-02 15:04 UTC")
+	},
+}).Parse(pageHTML))
+
+// HTMLHandler serves GET /status/{slug}, the server-rendered version of
+// GetPublicStatusPage. It goes through client like any other gateway route,
+// so it gets the same interceptors and the same cache.
+func HTMLHandler(client pb.StatusPageServiceClient, log *zap.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, err := client.GetPublicStatusPage(r.Context(), &pb.GetPublicStatusPageRequest{Slug: r.PathValue("slug")})
+		if err != nil {
+			switch status.Code(err) {
+			case codes.NotFound, codes.InvalidArgument:
+				http.Error(w, "status page not found", http.StatusNotFound)
+			default:
+				log.Warn("render status page", zap.Error(err))
+				http.Error(w, "status page unavailable", http.StatusBadGateway)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(htmlMaxAge/time.Second)))
+		if err := pageTmpl.Execute(w, page); err != nil {
+			log.Warn("execute status page template", zap.Error(err))
+		}
+	})
+}
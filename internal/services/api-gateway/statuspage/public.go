@@ -0,0 +1,225 @@
+package statuspage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NordCoder/Pingerus/internal/domain/check"
+	"github.com/NordCoder/Pingerus/internal/domain/plan"
+	"github.com/NordCoder/Pingerus/internal/domain/run"
+	"github.com/NordCoder/Pingerus/internal/domain/statuspage"
+	"github.com/NordCoder/Pingerus/internal/repository/postgres"
+)
+
+const (
+	uptimeDays       = int(plan.PublicHistory / (24 * time.Hour))
+	publicIncidents  = 10
+	publicNotes      = 10
+	maxCachedPages   = 1000
+	dayLayout        = "2006-01-02"
+	unnamedCheckName = "Service %d"
+)
+
+// View is a status page as visitors see it: no check URLs or IDs.
+type View struct {
+	Page        *statuspage.Page
+	Status      check.Status
+	Checks      []CheckView
+	Incidents   []IncidentView
+	Notes       []*statuspage.Note
+	GeneratedAt time.Time
+}
+
+type CheckView struct {
+	Name   string
+	Status check.Status
+	// Uptime is the percentage of successful runs over Days, -1 without runs.
+	Uptime float64
+	// Days holds one entry per UTC day of the window, oldest first.
+	Days []run.Day
+}
+
+type IncidentView struct {
+	CheckName  string
+	OpenedAt   time.Time
+	ResolvedAt *time.Time
+	// Note sums up the dependent checks affected by the incident.
+	Note string
+}
+
+type cachedView struct {
+	view *View
+	at   time.Time
+}
+
+// Public renders the page published under slug; it needs no caller.
+func (u *Usecase) Public(ctx context.Context, slug string) (*View, error) {
+	slug = strings.ToLower(slug)
+	if v, ok := u.cached(slug); ok {
+		return v, nil
+	}
+	p, err := u.pages.GetBySlug(ctx, slug)
+	if err != nil {
+		if errors.Is(err, postgres.ErrNotFound) {
+			return nil, ErrPageNotFound
+		}
+		return nil, err
+	}
+	v, err := u.render(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	u.store(slug, v)
+	return v, nil
+}
+
+func (u *Usecase) render(ctx context.Context, p *statuspage.Page) (*View, error) {
+	now := time.Now().UTC()
+	today := now.Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, -(uptimeDays - 1))
+
+	v := &View{Page: p, GeneratedAt: now, Checks: make([]CheckView, 0, len(p.Checks))}
+	ids := make([]int64, 0, len(p.Checks))
+	names := make(map[int64]string, len(p.Checks))
+	up, down := 0, 0
+	for i, pc := range p.Checks {
+		c, err := u.checks.GetByID(ctx, pc.CheckID)
+		if err != nil {
+			if errors.Is(err, postgres.ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		name := pc.DisplayName
+		if name == "" {
+			name = c.Name
+		}
+		if name == "" {
+			name = fmt.Sprintf(unnamedCheckName, i+1)
+		}
+		st := check.StatusUnknown
+		if c.Active && c.LastStatus != nil {
+			st = check.StatusDown
+			if *c.LastStatus {
+				st = check.StatusUp
+			}
+		}
+		switch st {
+		case check.StatusUp:
+			up++
+		case check.StatusDown:
+			down++
+		}
+		ids = append(ids, c.ID)
+		names[c.ID] = name
+		v.Checks = append(v.Checks, CheckView{Name: name, Status: st})
+	}
+
+	switch {
+	case down > 0:
+		v.Status = check.StatusDown
+	case up > 0 && up == len(v.Checks):
+		v.Status = check.StatusUp
+	default:
+		v.Status = check.StatusUnknown
+	}
+	if len(ids) == 0 {
+		v.Notes = []*statuspage.Note{}
+		return v, nil
+	}
+
+	days, err := u.runs.DailyUptime(ctx, ids, since)
+	if err != nil {
+		return nil, err
+	}
+	byCheck := make(map[int64]map[string]run.Day, len(ids))
+	for _, d := range days {
+		if byCheck[d.CheckID] == nil {
+			byCheck[d.CheckID] = make(map[string]run.Day)
+		}
+		byCheck[d.CheckID][d.Day.Format(dayLayout)] = d
+	}
+	for i, id := range ids {
+		cv := &v.Checks[i]
+		cv.Days = make([]run.Day, 0, uptimeDays)
+		var total, ok int64
+		for day := since; !day.After(today); day = day.AddDate(0, 0, 1) {
+			d, found := byCheck[id][day.Format(dayLayout)]
+			if !found {
+				d = run.Day{CheckID: id, Day: day}
+			}
+			total += d.Total
+			ok += d.Up
+			cv.Days = append(cv.Days, d)
+		}
+		cv.Uptime = -1
+		if total > 0 {
+			cv.Uptime = float64(ok) * 100 / float64(total)
+		}
+	}
+
+	incidents, err := u.events.Incidents(ctx, ids, now.Add(-u.incidentWindow), publicIncidents)
+	if err != nil {
+		return nil, err
+	}
+	for _, in := range incidents {
+		iv := IncidentView{CheckName: names[in.CheckID], OpenedAt: in.OpenedAt, ResolvedAt: in.ResolvedAt}
+		switch {
+		case in.Suppressed == 1:
+			iv.Note = "1 dependent service was affected."
+		case in.Suppressed > 1:
+			iv.Note = fmt.Sprintf("%d dependent services were affected.", in.Suppressed)
+		}
+		v.Incidents = append(v.Incidents, iv)
+	}
+
+	if v.Notes, err = u.pages.ListNotes(ctx, p.ID, publicNotes); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (u *Usecase) cached(slug string) (*View, bool) {
+	if u.cacheTTL <= 0 {
+		return nil, false
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	e, ok := u.cache[slug]
+	if !ok || time.Since(e.at) > u.cacheTTL {
+		return nil, false
+	}
+	return e.view, true
+}
+
+func (u *Usecase) store(slug string, v *View) {
+	if u.cacheTTL <= 0 {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.cache) >= maxCachedPages {
+		for k, e := range u.cache {
+			if time.Since(e.at) > u.cacheTTL {
+				delete(u.cache, k)
+			}
+		}
+		if len(u.cache) >= maxCachedPages {
+			return
+		}
+	}
+	u.cache[slug] = cachedView{view: v, at: time.Now()}
+}
+
+// forget drops cached views after an owner edits a page, so this instance
+// shows the change right away; others catch up within the cache TTL.
+func (u *Usecase) forget(slugs ...string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, s := range slugs {
+		delete(u.cache, s)
+	}
+}
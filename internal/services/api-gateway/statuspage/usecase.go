@@ -0,0 +1,326 @@
+package statuspage
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NordCoder/Pingerus/internal/domain/check"
+	"github.com/NordCoder/Pingerus/internal/domain/run"
+	"github.com/NordCoder/Pingerus/internal/domain/statuspage"
+	"github.com/NordCoder/Pingerus/internal/repository/postgres"
+	"github.com/NordCoder/Pingerus/internal/services/api-gateway/policy"
+)
+
+var (
+	ErrInvalidSlug        = errors.New("slug must be 3 to 63 lowercase letters, digits and dashes, starting and ending with a letter or digit")
+	ErrInvalidTitle       = errors.New("title must be 1 to 128 characters")
+	ErrInvalidDescription = errors.New("description must be at most 2000 characters")
+	ErrInvalidLogoURL     = errors.New("logo url must be an absolute http or https URL of at most 2048 characters")
+	ErrInvalidChecks      = errors.New("a page shows at most 50 distinct checks with display names of at most 128 characters")
+	ErrForeignCheck       = errors.New("every check must belong to the page's org")
+	ErrInvalidNote        = errors.New("note title must be 1 to 200 characters and body at most 10000")
+	ErrSlugTaken          = errors.New("slug is already taken")
+	ErrPageNotFound       = errors.New("status page not found")
+	ErrNoteNotFound       = errors.New("note not found")
+	ErrForbidden          = policy.ErrForbidden
+)
+
+const (
+	maxTitleLen       = 128
+	maxDescriptionLen = 2000
+	maxLogoURLLen     = 2048
+	maxChecks         = 50
+	maxDisplayNameLen = 128
+	maxNoteTitleLen   = 200
+	maxNoteBodyLen    = 10000
+	listNotesLimit    = 100
+)
+
+var slugRe = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,61}[a-z0-9]$`)
+
+type Usecase struct {
+	pages  statuspage.Repo
+	checks check.Repo
+	runs   run.Repo
+	events check.EventRepo
+	pol    *policy.Policy
+	tx     postgres.Transactor
+
+	cacheTTL       time.Duration
+	incidentWindow time.Duration
+	mu             sync.Mutex
+	cache          map[string]cachedView
+}
+
+// NewUsecase builds the status page usecase. Public views are cached for
+// cacheTTL, since anyone can request them and they aggregate 90 days of runs.
+// Incidents come from check events, so incidentWindow must not reach past
+// their retention.
+func NewUsecase(pages statuspage.Repo, checks check.Repo, runs run.Repo, events check.EventRepo,
+	pol *policy.Policy, tx postgres.Transactor, cacheTTL, incidentWindow time.Duration) *Usecase {
+	return &Usecase{
+		pages: pages, checks: checks, runs: runs, events: events, pol: pol, tx: tx,
+		cacheTTL: cacheTTL, incidentWindow: incidentWindow,
+		cache: make(map[string]cachedView),
+	}
+}
+
+// Create stores a page in in.OrgID, or in the caller's default org when
+// that is 0.
+func (u *Usecase) Create(ctx context.Context, userID int64, in *statuspage.Page) (*statuspage.Page, error) {
+	p := &statuspage.Page{
+		Slug:        in.Slug,
+		Title:       in.Title,
+		Description: in.Description,
+		LogoURL:     in.LogoURL,
+	}
+	if err := normalize(p); err != nil {
+		return nil, err
+	}
+	orgID, err := u.resolveOrg(ctx, userID, in.OrgID, policy.WriteStatusPages)
+	if err != nil {
+		return nil, err
+	}
+	p.OrgID = orgID
+	if p.Checks, err = u.normalizeChecks(ctx, orgID, in.Checks); err != nil {
+		return nil, err
+	}
+
+	err = u.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := u.pages.Create(ctx, p); err != nil {
+			return err
+		}
+		return u.pages.SetChecks(ctx, p.ID, p.Checks)
+	})
+	if err != nil {
+		if errors.Is(err, postgres.ErrConflict) {
+			return nil, ErrSlugTaken
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+func (u *Usecase) Get(ctx context.Context, userID, id int64) (*statuspage.Page, error) {
+	return u.authorized(ctx, userID, id, policy.ReadStatusPages)
+}
+
+// List returns the pages of orgID, or of every org the caller can read when
+// orgID is 0.
+func (u *Usecase) List(ctx context.Context, userID, orgID int64) ([]*statuspage.Page, error) {
+	orgIDs := []int64{orgID}
+	if orgID != 0 {
+		if _, err := u.pol.Authorize(ctx, userID, orgID, policy.ReadStatusPages); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if orgIDs, err = u.pol.VisibleOrgs(ctx, userID, policy.ReadStatusPages); err != nil {
+			return nil, err
+		}
+	}
+	return u.pages.ListByOrgs(ctx, orgIDs)
+}
+
+// Update applies p to page id.
+func (u *Usecase) Update(ctx context.Context, userID, id int64, p statuspage.Patch) (*statuspage.Page, error) {
+	cur, err := u.authorized(ctx, userID, id, policy.WriteStatusPages)
+	if err != nil {
+		return nil, err
+	}
+	oldSlug := cur.Slug
+
+	if p.Slug != nil {
+		cur.Slug = *p.Slug
+	}
+	if p.Title != nil {
+		cur.Title = *p.Title
+	}
+	if p.Description != nil {
+		cur.Description = *p.Description
+	}
+	if p.LogoURL != nil {
+		cur.LogoURL = *p.LogoURL
+	}
+	if err := normalize(cur); err != nil {
+		return nil, err
+	}
+	if p.Checks != nil {
+		if cur.Checks, err = u.normalizeChecks(ctx, cur.OrgID, *p.Checks); err != nil {
+			return nil, err
+		}
+	}
+
+	err = u.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := u.pages.Update(ctx, cur); err != nil {
+			return err
+		}
+		if p.Checks == nil {
+			return nil
+		}
+		return u.pages.SetChecks(ctx, cur.ID, cur.Checks)
+	})
+	if err != nil {
+		if errors.Is(err, postgres.ErrConflict) {
+			return nil, ErrSlugTaken
+		}
+		return nil, err
+	}
+	u.forget(oldSlug, cur.Slug)
+	return cur, nil
+}
+
+func (u *Usecase) Delete(ctx context.Context, userID, id int64) error {
+	p, err := u.authorized(ctx, userID, id, policy.WriteStatusPages)
+	if err != nil {
+		return err
+	}
+	if err := u.pages.Delete(ctx, id); err != nil {
+		return err
+	}
+	u.forget(p.Slug)
+	return nil
+}
+
+func (u *Usecase) AddNote(ctx context.Context, userID, pageID int64, title, body string) (*statuspage.Note, error) {
+	title, body = strings.TrimSpace(title), strings.TrimSpace(body)
+	if title == "" || len(title) > maxNoteTitleLen || len(body) > maxNoteBodyLen {
+		return nil, ErrInvalidNote
+	}
+	p, err := u.authorized(ctx, userID, pageID, policy.WriteStatusPages)
+	if err != nil {
+		return nil, err
+	}
+	n := &statuspage.Note{PageID: pageID, Title: title, Body: body}
+	if err := u.pages.AddNote(ctx, n); err != nil {
+		return nil, err
+	}
+	u.forget(p.Slug)
+	return n, nil
+}
+
+func (u *Usecase) ListNotes(ctx context.Context, userID, pageID int64) ([]*statuspage.Note, error) {
+	if _, err := u.authorized(ctx, userID, pageID, policy.ReadStatusPages); err != nil {
+		return nil, err
+	}
+	return u.pages.ListNotes(ctx, pageID, listNotesLimit)
+}
+
+// ResolveNote marks a note resolved; resolving it again keeps the first time.
+func (u *Usecase) ResolveNote(ctx context.Context, userID, pageID, id int64) (*statuspage.Note, error) {
+	p, err := u.authorized(ctx, userID, pageID, policy.WriteStatusPages)
+	if err != nil {
+		return nil, err
+	}
+	n, err := u.pages.ResolveNote(ctx, pageID, id, time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, postgres.ErrNotFound) {
+			return nil, ErrNoteNotFound
+		}
+		return nil, err
+	}
+	u.forget(p.Slug)
+	return n, nil
+}
+
+func (u *Usecase) DeleteNote(ctx context.Context, userID, pageID, id int64) error {
+	p, err := u.authorized(ctx, userID, pageID, policy.WriteStatusPages)
+	if err != nil {
+		return err
+	}
+	if err := u.pages.DeleteNote(ctx, pageID, id); err != nil {
+		if errors.Is(err, postgres.ErrNotFound) {
+			return ErrNoteNotFound
+		}
+		return err
+	}
+	u.forget(p.Slug)
+	return nil
+}
+
+// normalize trims and validates the page fields a user edits.
+func normalize(p *statuspage.Page) error {
+	p.Slug = strings.ToLower(strings.TrimSpace(p.Slug))
+	if !slugRe.MatchString(p.Slug) {
+		return ErrInvalidSlug
+	}
+	p.Title = strings.TrimSpace(p.Title)
+	if p.Title == "" || len(p.Title) > maxTitleLen {
+		return ErrInvalidTitle
+	}
+	p.Description = strings.TrimSpace(p.Description)
+	if len(p.Description) > maxDescriptionLen {
+		return ErrInvalidDescription
+	}
+	p.LogoURL = strings.TrimSpace(p.LogoURL)
+	if p.LogoURL != "" {
+		parsed, err := url.Parse(p.LogoURL)
+		if err != nil || len(p.LogoURL) > maxLogoURLLen || parsed.Host == "" ||
+			(parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return ErrInvalidLogoURL
+		}
+	}
+	return nil
+}
+
+// normalizeChecks validates a page's check selection and that every check
+// belongs to orgID. Unknown checks look like foreign ones.
+func (u *Usecase) normalizeChecks(ctx context.Context, orgID int64, in []statuspage.PageCheck) ([]statuspage.PageCheck, error) {
+	if len(in) > maxChecks {
+		return nil, ErrInvalidChecks
+	}
+	out := make([]statuspage.PageCheck, 0, len(in))
+	seen := make(map[int64]bool, len(in))
+	for _, pc := range in {
+		pc.DisplayName = strings.TrimSpace(pc.DisplayName)
+		if pc.CheckID <= 0 || seen[pc.CheckID] || len(pc.DisplayName) > maxDisplayNameLen {
+			return nil, ErrInvalidChecks
+		}
+		seen[pc.CheckID] = true
+
+		c, err := u.checks.GetByID(ctx, pc.CheckID)
+		if err != nil {
+			if errors.Is(err, postgres.ErrNotFound) {
+				return nil, ErrForeignCheck
+			}
+			return nil, err
+		}
+		if c.OrgID != orgID {
+			return nil, ErrForeignCheck
+		}
+		out = append(out, pc)
+	}
+	return out, nil
+}
+
+func (u *Usecase) resolveOrg(ctx context.Context, userID, orgID int64, action policy.Action) (int64, error) {
+	if orgID == 0 {
+		var err error
+		if orgID, err = u.pol.DefaultOrg(ctx, userID); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := u.pol.Authorize(ctx, userID, orgID, action); err != nil {
+		return 0, err
+	}
+	return orgID, nil
+}
+
+func (u *Usecase) authorized(ctx context.Context, userID, id int64, action policy.Action) (*statuspage.Page, error) {
+	p, err := u.pages.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, postgres.ErrNotFound) {
+			return nil, ErrPageNotFound
+		}
+		return nil, err
+	}
+	if _, err := u.pol.Authorize(ctx, userID, p.OrgID, action); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
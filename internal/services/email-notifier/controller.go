@@ -7,14 +7,20 @@ import (
 	"time"
 
 	pb "github.com/NordCoder/Pingerus/generated/v1"
+	"github.com/NordCoder/Pingerus/internal/inbox"
 	kafkax "github.com/NordCoder/Pingerus/internal/repository/kafka"
 	"go.uber.org/zap"
 )
 
 type Controller struct {
-	Log *zap.Logger
-	Sub *kafkax.Consumer
-	UC  *Handler
+	Log   *zap.Logger
+	Sub   *kafkax.Consumer
+	UC    *Handler
+	Inbox *inbox.Inbox
+
+	// EmailSub, when set, delivers EmailRequest messages; see RunEmails.
+	EmailSub   *kafkax.Consumer
+	EmailInbox *inbox.Inbox
 }
 
 func (c *Controller) logger() *zap.Logger {
@@ -82,6 +88,15 @@ func (c *Controller) Run(ctx context.Context) error {
 		},
 	)
 
+	if c.Inbox != nil {
+		// the handler claims each recipient on its own through UC.Inbox, so
+		// it runs outside the transaction claiming the message
+		h := handler
+		handler = c.Inbox.WrapEffect(func(ctx context.Context, key, value []byte) (func(context.Context) error, error) {
+			return nil, h(ctx, key, value)
+		})
+	}
+
 	if err := c.Sub.Consume(ctx, handler); err != nil {
 		if errors.Is(err, context.Canceled) {
 			log.Info("controller stopped (context canceled)")
@@ -91,3 +106,63 @@ func (c *Controller) Run(ctx context.Context) error {
 	}
 	return nil
 }
+
+func (c *Controller) RunEmails(ctx context.Context) error {
+	log := c.logger().With(zap.String("component", "email-notifier.controller"), zap.String("stream", "email-requests"))
+	if c.EmailSub == nil {
+		log.Info("email requests disabled")
+		return nil
+	}
+	log.Info("subscribing to kafka")
+
+	handler := kafkax.ProtoHandler(
+		func() *pb.EmailRequest { return &pb.EmailRequest{} },
+		func(parent context.Context, _ []byte, ev *pb.EmailRequest) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Error("panic in handler", zap.Any("panic", r))
+					if err == nil {
+						err = fmt.Errorf("panic: %v", r)
+					}
+				}
+			}()
+
+			if ev.GetTo() == "" {
+				log.Warn("email-request: empty recipient", zap.Int64("user_id", ev.GetUserId()))
+				return nil
+			}
+
+			ctxMsg, cancel := context.WithTimeout(parent, 10*time.Second)
+			defer cancel()
+
+			req := EmailRequest{
+				UserID:   ev.GetUserId(),
+				To:       ev.GetTo(),
+				Template: ev.GetTemplate(),
+				Params:   ev.GetParams(),
+				At:       ev.GetTs().AsTime(),
+			}
+			if err := c.UC.HandleEmailRequest(ctxMsg, req); err != nil {
+				if errors.Is(err, ErrUnknownTemplate) {
+					// retrying won't help; leave it to the DLQ tooling
+					log.Error("email-request: unknown template", zap.String("template", req.Template))
+				}
+				return err
+			}
+			return nil
+		},
+	)
+
+	if c.EmailInbox != nil {
+		handler = c.EmailInbox.Wrap(handler)
+	}
+
+	if err := c.EmailSub.Consume(ctx, handler); err != nil {
+		if errors.Is(err, context.Canceled) {
+			log.Info("controller stopped (context canceled)")
+			return nil
+		}
+		return err
+	}
+	return nil
+}
@@ -2,10 +2,15 @@ package notifier
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/NordCoder/Pingerus/internal/domain/check"
 	"github.com/NordCoder/Pingerus/internal/domain/notification"
+	"github.com/NordCoder/Pingerus/internal/inbox"
+	"github.com/NordCoder/Pingerus/internal/outbox"
 	"github.com/NordCoder/Pingerus/internal/services/email-notifier/repo"
 	"go.uber.org/zap"
 )
@@ -23,9 +28,21 @@ type Handler struct {
 	Checks repo.CheckReader
 	Users  repo.UserReader
 	Store  repo.NotificationRepo
-	Out    notification.EmailSender
-	Clock  notification.Clock
-	Log    *zap.Logger
+	// Deps, when set, holds back alerts of checks whose parent is down.
+	Deps *repo.Dependencies
+	// Members, when set, sends alerts to the owners and admins of the
+	// check's org instead of the user who created the check.
+	Members *repo.Members
+	// Plans, when set, caps the recipients at the max_channels of the
+	// check owner's plan.
+	Plans *repo.Plans
+	// Inbox, when set, sends each recipient's alert and holds back an
+	// event at most once per message, so a redelivery after one failed
+	// send does not alert the others again.
+	Inbox *inbox.Inbox
+	Out   notification.EmailSender
+	Clock notification.Clock
+	Log   *zap.Logger
 }
 
 func (h *Handler) logger() *zap.Logger {
@@ -35,6 +52,15 @@ func (h *Handler) logger() *zap.Logger {
 	return zap.NewNop()
 }
 
+// once runs fn as the given part of the message being handled; see
+// inbox.Inbox.Once.
+func (h *Handler) once(ctx context.Context, part string, fn func(ctx context.Context) error) error {
+	if h.Inbox == nil {
+		return fn(ctx)
+	}
+	return h.Inbox.Once(ctx, part, fn)
+}
+
 func (h *Handler) HandleStatusChange(ctx context.Context, ev StatusChange) error {
 	log := h.logger().With(
 		zap.String("component", "email-notifier.handler"),
@@ -56,20 +82,73 @@ func (h *Handler) HandleStatusChange(ctx context.Context, ev StatusChange) error
 	log = log.With(zap.Int64("user_id", chk.UserID), zap.String("url", chk.URL))
 	log.Debug("check loaded")
 
-	u, err := h.Users.GetByID(ctx, chk.UserID)
+	held, err := h.holdBack(ctx, chk.ID, ev)
+	if err != nil {
+		log.Error("check dependencies failed", zap.Error(err))
+		return fmt.Errorf("check dependencies: %w", err)
+	}
+	if held {
+		log.Info("alert held back while a parent check is down")
+		return nil
+	}
+
+	recipients := []int64{chk.UserID}
+	if h.Members != nil {
+		if recipients, err = h.Members.Alerted(ctx, chk.OrgID); err != nil {
+			log.Error("list org members failed", zap.Error(err))
+			return fmt.Errorf("list org members: %w", err)
+		}
+	}
+	if h.Plans != nil {
+		limit, err := h.Plans.MaxChannels(ctx, chk.UserID)
+		if err != nil {
+			log.Error("get plan failed", zap.Error(err))
+			return fmt.Errorf("get plan: %w", err)
+		}
+		if limit > 0 && len(recipients) > limit {
+			log.Info("alert recipients capped by plan", zap.Int("recipients", len(recipients)), zap.Int("max_channels", limit))
+			recipients = recipients[:limit]
+		}
+	}
+
+	subject, body := buildEmail(describe(chk), ev, h.Clock)
+	if h.Deps != nil && ev.NewStatus && !ev.OldStatus {
+		sup, err := h.Deps.Suppressed(ctx, chk.ID)
+		if err != nil {
+			log.Warn("list held back alerts failed", zap.Error(err))
+		}
+		body += summarize(sup)
+	}
+
+	var errs []error
+	for _, uid := range recipients {
+		err := h.once(ctx, fmt.Sprintf("alert:%d", uid), func(ctx context.Context) error {
+			return h.alert(ctx, log.With(zap.Int64("recipient_id", uid)), chk.ID, uid, subject, body)
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// alert emails userID about checkID and records the notification.
+func (h *Handler) alert(ctx context.Context, log *zap.Logger, checkID, userID int64, subject, body string) error {
+	u, err := h.Users.GetByID(ctx, userID)
 	if err != nil {
 		log.Error("get user failed", zap.Error(err))
 		return fmt.Errorf("get user: %w", err)
 	}
 	if u.Email == "" {
-		err := fmt.Errorf("user has no email")
-		log.Error("missing recipient email", zap.Error(err))
-		return err
+		log.Warn("recipient has no email; alert skipped")
+		return nil
+	}
+	if !u.EmailVerified() {
+		log.Info("recipient email not verified; alert skipped", zap.String("email", u.Email))
+		return nil
 	}
 	log.Debug("user loaded", zap.String("email", u.Email))
 
-	subject, body := buildEmail(chk.URL, ev, h.Clock)
-
 	sendStart := h.Clock.Now()
 	if err := h.Out.Send(ctx, u.Email, subject, body); err != nil {
 		log.Error("send email failed",
@@ -87,7 +166,7 @@ func (h *Handler) HandleStatusChange(ctx context.Context, ev StatusChange) error
 	)
 
 	if err := h.Store.Create(ctx, &notification.Notification{
-		CheckID: chk.ID,
+		CheckID: checkID,
 		UserID:  u.ID,
 		Type:    notificationTypeEmail,
 		SentAt:  h.Clock.Now().UTC(),
@@ -101,11 +180,153 @@ func (h *Handler) HandleStatusChange(ctx context.Context, ev StatusChange) error
 	return nil
 }
 
-func buildEmail(url string, ev StatusChange, clk notification.Clock) (subject, body string) {
+// holdBack records ev instead of alerting when a parent of checkID is down,
+// and when ev is the recovery of a check whose down alert was held back.
+func (h *Handler) holdBack(ctx context.Context, checkID int64, ev StatusChange) (bool, error) {
+	if h.Deps == nil {
+		return false, nil
+	}
+	parents, err := h.Deps.DownParents(ctx, checkID)
+	if err != nil {
+		return false, err
+	}
+	if len(parents) == 0 && ev.NewStatus {
+		parent, err := h.Deps.HeldBack(ctx, checkID)
+		if err != nil {
+			return false, err
+		}
+		if parent != 0 {
+			parents = []int64{parent}
+		}
+	}
+	if len(parents) == 0 {
+		return false, nil
+	}
+
+	sup := make([]check.Suppression, 0, len(parents))
+	for _, p := range parents {
+		sup = append(sup, check.Suppression{CheckID: checkID, ParentID: p, Old: ev.OldStatus, New: ev.NewStatus, ChangedAt: ev.At})
+	}
+	return true, h.once(ctx, "held", func(ctx context.Context) error { return h.Deps.Suppress(ctx, sup) })
+}
+
+// summarize is the note on a parent's recovery alert listing the dependent
+// checks held back during its incident, each with its latest status.
+func summarize(sup []check.Suppression) string {
+	if len(sup) == 0 {
+		return ""
+	}
+	var order []int64
+	last := map[int64]check.Suppression{}
+	for _, s := range sup {
+		if _, ok := last[s.CheckID]; !ok {
+			order = append(order, s.CheckID)
+		}
+		last[s.CheckID] = s
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n\nWhile it was down, alerts for %d dependent check(s) were held back:\n", len(order))
+	for _, id := range order {
+		s := last[id]
+		name := s.CheckName
+		if name == "" {
+			name = s.URL
+		}
+		if name == "" {
+			name = fmt.Sprintf("#%d", id)
+		}
+		state := "down"
+		if s.New {
+			state = "up"
+		}
+		fmt.Fprintf(&b, "  - %s: now %s\n", name, state)
+	}
+	return b.String()
+}
+
+// describe names a check in an alert: HTTP checks by URL, heartbeats, which
+// have none, by name.
+func describe(c *check.Check) string {
+	if c.Type != check.TypeHeartbeat {
+		return c.URL
+	}
+	if c.Name != "" {
+		return "heartbeat " + c.Name
+	}
+	return fmt.Sprintf("heartbeat #%d", c.ID)
+}
+
+func buildEmail(target string, ev StatusChange, clk notification.Clock) (subject, body string) {
 	subject = fmt.Sprintf("Site status changed: %t → %t", ev.OldStatus, ev.NewStatus)
 	body = fmt.Sprintf(
 		"Hello!\n\nYour check (%s) changed status: %t → %t at %s.\n\n— Pingerus",
-		url, ev.OldStatus, ev.NewStatus, clk.Now().UTC().Format(time.RFC3339),
+		target, ev.OldStatus, ev.NewStatus, clk.Now().UTC().Format(time.RFC3339),
 	)
 	return
 }
+
+type EmailRequest struct {
+	UserID   int64
+	To       string
+	Template string
+	Params   map[string]string
+	At       time.Time
+}
+
+var ErrUnknownTemplate = errors.New("unknown email template")
+
+func (h *Handler) HandleEmailRequest(ctx context.Context, req EmailRequest) error {
+	log := h.logger().With(
+		zap.String("component", "email-notifier.handler"),
+		zap.Int64("user_id", req.UserID),
+		zap.String("template", req.Template),
+	)
+
+	subject, body, err := renderTemplate(req)
+	if err != nil {
+		log.Error("render email failed", zap.Error(err))
+		return err
+	}
+
+	sendStart := h.Clock.Now()
+	if err := h.Out.Send(ctx, req.To, subject, body); err != nil {
+		log.Error("send email failed",
+			zap.String("to", req.To),
+			zap.Duration("elapsed", h.Clock.Now().Sub(sendStart)),
+			zap.Error(err),
+		)
+		return fmt.Errorf("send email: %w", err)
+	}
+	log.Info("email sent",
+		zap.String("to", req.To),
+		zap.String("subject", subject),
+		zap.Duration("elapsed", h.Clock.Now().Sub(sendStart)),
+	)
+	return nil
+}
+
+func renderTemplate(req EmailRequest) (subject, body string, err error) {
+	link := req.Params["link"]
+	if link == "" {
+		return "", "", fmt.Errorf("%s: missing link", req.Template)
+	}
+	switch req.Template {
+	case outbox.EmailTemplateVerify:
+		subject = "Confirm your email address"
+		body = fmt.Sprintf(
+			"Hello!\n\nPlease confirm your email address by opening this link:\n%s\n\nIt expires at %s.\n\n— Pingerus",
+			link, req.Params["expires_at"],
+		)
+	case outbox.EmailTemplateReset:
+		subject = "Reset your password"
+		body = fmt.Sprintf(
+			"Hello!\n\nSomeone asked to reset your Pingerus password. If it was you, open this link:\n%s\n\n"+
+				"It expires at %s. If it wasn't you, ignore this email.\n\n— Pingerus",
+			link, req.Params["expires_at"],
+		)
+	default:
+		return "", "", fmt.Errorf("%w: %q", ErrUnknownTemplate, req.Template)
+	}
+	return subject, body, nil
+}
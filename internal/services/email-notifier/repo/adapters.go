@@ -2,28 +2,39 @@ package repo
 
 import (
 	"context"
+	"errors"
+
 	"github.com/NordCoder/Pingerus/internal/domain/check"
 	"github.com/NordCoder/Pingerus/internal/domain/notification"
+	"github.com/NordCoder/Pingerus/internal/domain/org"
+	"github.com/NordCoder/Pingerus/internal/domain/plan"
 	"github.com/NordCoder/Pingerus/internal/domain/user"
+	"github.com/NordCoder/Pingerus/internal/repository/postgres"
 )
 
 type CheckReader struct{ R check.Repo }
 type UserReader struct{ R user.Repo }
 type NotificationRepo struct{ R notification.Repo }
+type Dependencies struct{ R check.DependencyRepo }
+type Members struct{ R org.Repo }
+type Plans struct {
+	R       plan.Repo
+	Catalog plan.Catalog
+}
 
 func (a CheckReader) GetByID(ctx context.Context, id int64) (*check.Check, error) {
 	c, err := a.R.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	return &check.Check{ID: c.ID, UserID: c.UserID, URL: c.URL}, nil
+	return &check.Check{ID: c.ID, UserID: c.UserID, OrgID: c.OrgID, Name: c.Name, Type: c.Type, URL: c.URL}, nil
 }
 func (a UserReader) GetByID(ctx context.Context, id int64) (*user.User, error) {
 	u, err := a.R.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	return &user.User{ID: u.ID, Email: u.Email}, nil
+	return &user.User{ID: u.ID, Email: u.Email, EmailVerifiedAt: u.EmailVerifiedAt}, nil
 }
 func (a NotificationRepo) Create(ctx context.Context, n *notification.Notification) error {
 	return a.R.Create(ctx, &notification.Notification{
@@ -31,3 +42,44 @@ func (a NotificationRepo) Create(ctx context.Context, n *notification.Notificati
 		SentAt: n.SentAt, Payload: n.Payload,
 	})
 }
+
+func (a Dependencies) DownParents(ctx context.Context, id int64) ([]int64, error) {
+	return a.R.DownParents(ctx, id)
+}
+func (a Dependencies) HeldBack(ctx context.Context, id int64) (int64, error) {
+	return a.R.HeldBack(ctx, id)
+}
+func (a Dependencies) Suppress(ctx context.Context, s []check.Suppression) error {
+	return a.R.Suppress(ctx, s)
+}
+func (a Dependencies) Suppressed(ctx context.Context, parentID int64) ([]check.Suppression, error) {
+	return a.R.Suppressed(ctx, parentID)
+}
+
+// Alerted lists the members of orgID who get its alerts: owners, then
+// admins, each in the order they joined.
+func (a Members) Alerted(ctx context.Context, orgID int64) ([]int64, error) {
+	members, err := a.R.ListMembers(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	var owners, admins []int64
+	for _, m := range members {
+		switch {
+		case m.Role.AtLeast(org.RoleOwner):
+			owners = append(owners, m.UserID)
+		case m.Role.AtLeast(org.RoleAdmin):
+			admins = append(admins, m.UserID)
+		}
+	}
+	return append(owners, admins...), nil
+}
+
+// MaxChannels is the max_channels of userID's plan; 0 is unlimited.
+func (a Plans) MaxChannels(ctx context.Context, userID int64) (int, error) {
+	name, err := a.R.PlanOf(ctx, userID)
+	if err != nil && !errors.Is(err, postgres.ErrNotFound) {
+		return 0, err
+	}
+	return a.Catalog.Lookup(name).MaxChannels, nil
+}
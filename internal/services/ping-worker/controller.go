@@ -3,25 +3,39 @@ package ping_worker
 import (
 	"context"
 	pb "github.com/NordCoder/Pingerus/generated/v1"
+	"github.com/NordCoder/Pingerus/internal/inbox"
 
 	kafkax "github.com/NordCoder/Pingerus/internal/repository/kafka"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
 )
 
 type Controller struct {
-	Log *zap.Logger
-	Sub *kafkax.Consumer
-	UC  *Handler
+	Log   *zap.Logger
+	Sub   *kafkax.Consumer
+	UC    *Handler
+	Inbox *inbox.Inbox
 }
 
 func (c *Controller) Run(ctx context.Context) error {
-	handler := kafkax.ProtoHandler(
-		func() *pb.CheckRequest { return &pb.CheckRequest{} },
-		func(ctx context.Context, _ []byte, msg *pb.CheckRequest) error {
-			c.Log.Debug("check-request", zap.Int64("check_id", int64(msg.GetCheckId())))
-			cid := int64(msg.GetCheckId())
-			return c.UC.HandleCheck(ctx, cid)
-		},
-	)
+	probe := func(ctx context.Context, _, value []byte) (func(context.Context) error, error) {
+		msg := &pb.CheckRequest{}
+		if err := proto.Unmarshal(value, msg); err != nil {
+			return nil, err
+		}
+		c.Log.Debug("check-request", zap.Int64("check_id", int64(msg.GetCheckId())))
+		return c.UC.Probe(ctx, int64(msg.GetCheckId()))
+	}
+	var handler kafkax.Handler = func(ctx context.Context, key, value []byte) error {
+		record, err := probe(ctx, key, value)
+		if err != nil || record == nil {
+			return err
+		}
+		return record(ctx)
+	}
+	if c.Inbox != nil {
+		// the probe runs before the inbox opens its transaction
+		handler = c.Inbox.WrapEffect(probe)
+	}
 	return c.Sub.Consume(ctx, handler)
 }
@@ -2,7 +2,6 @@ package ping_worker
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/NordCoder/Pingerus/internal/domain/notification"
 	"github.com/NordCoder/Pingerus/internal/domain/outbox"
@@ -11,7 +10,6 @@ import (
 	"github.com/NordCoder/Pingerus/internal/repository/postgres"
 	"github.com/NordCoder/Pingerus/internal/services/ping-worker/repo"
 	"strings"
-	"time"
 )
 
 type Handler struct {
@@ -19,53 +17,48 @@ type Handler struct {
 	Runs       repo.RunRepo
 	Outbox     outbox.Repository // todo adapter
 	Transactor postgres.Transactor
-	Events     repo.Events
 	Clock      notification.Clock
 	HTTP       HTTPPing
 }
 
-func (h *Handler) HandleCheck(ctx context.Context, checkID int64) error {
+// Probe requests the check's URL and returns the writes recording the
+// result: the run and, when the status flipped, the check and its outbox
+// message. Probe writes nothing itself, so no transaction is held open
+// across the request; the writes re-read the status under a row lock, so
+// concurrent probes of one check can't both report the same flip.
+func (h *Handler) Probe(ctx context.Context, checkID int64) (func(context.Context) error, error) {
 	if checkID <= 0 {
-		return nil
+		return nil, nil
 	}
 	chk, err := h.Checks.GetByID(ctx, checkID)
 	if err != nil {
-		return fmt.Errorf("get check: %w", err)
+		return nil, fmt.Errorf("get check: %w", err)
 	}
 
 	url := normalizeURL(chk.URL)
 
 	start := h.Clock.Now()
-	code, status, pingErr := h.HTTP.Do(ctx, url)
+	code, status, _ := h.HTTP.Do(ctx, url)
 	lat := h.Clock.Now().Sub(start)
+	at := h.Clock.Now().UTC()
 
-	_ = h.Runs.Insert(ctx, &run.Run{
-		CheckID:   chk.ID,
-		Timestamp: h.Clock.Now().UTC(),
-		Status:    status,
-		Code:      code,
-		Latency:   lat.Milliseconds(),
-	})
-
-	changed := false
-	switch prev := chk.LastStatus; {
-	case prev == nil && status:
-		changed = true
-	case prev != nil && *prev != status:
-		changed = true
-	}
-
-	if changed {
-		old := false
-		if chk.LastStatus != nil {
-			old = *chk.LastStatus
-		}
-		newVal := status
+	return func(ctx context.Context) error {
+		return h.Transactor.WithTx(ctx, func(txCtx context.Context) error {
+			chk, err := h.Checks.LockByID(txCtx, checkID)
+			if err != nil {
+				return fmt.Errorf("lock check: %w", err)
+			}
+			changed := false
+			switch prev := chk.LastStatus; {
+			case prev == nil && status:
+				changed = true
+			case prev != nil && *prev != status:
+				changed = true
+			}
 
-		if err := h.Transactor.WithTx(ctx, func(txCtx context.Context) error {
 			runRec := &run.Run{
 				CheckID:   chk.ID,
-				Timestamp: time.Now().UTC(),
+				Timestamp: at,
 				Status:    status,
 				Code:      code,
 				Latency:   lat.Milliseconds(),
@@ -73,8 +66,15 @@ func (h *Handler) HandleCheck(ctx context.Context, checkID int64) error {
 			if err := h.Runs.Insert(txCtx, runRec); err != nil {
 				return fmt.Errorf("insert run: %w", err)
 			}
+			if !changed {
+				return nil
+			}
 
-			chk.LastStatus = &newVal
+			old := false
+			if chk.LastStatus != nil {
+				old = *chk.LastStatus
+			}
+			chk.LastStatus = &status
 			if err := h.Checks.Update(txCtx, chk); err != nil {
 				return fmt.Errorf("update check: %w", err)
 			}
@@ -82,23 +82,17 @@ func (h *Handler) HandleCheck(ctx context.Context, checkID int64) error {
 			payload := intoutbox.StatusChangedPayload{
 				CheckID: chk.ID,
 				Old:     old,
-				New:     newVal,
-				At:      time.Now().UTC(),
+				New:     status,
+				At:      at,
 			}
-			b, _ := json.Marshal(payload)
 			key := fmt.Sprintf("status:%d:%d", chk.ID, payload.At.UnixNano())
 
-			if err := h.Outbox.Enqueue(txCtx, key, outbox.KindStatusChanged, b); err != nil {
+			if err := intoutbox.StatusChanged.Enqueue(txCtx, h.Outbox, key, payload); err != nil {
 				return fmt.Errorf("outbox enqueue: %w", err)
 			}
 			return nil
-		}); err != nil {
-			fmt.Println("pain") // todo withlogger and logging
-		}
-	}
-
-	_ = pingErr
-	return nil
+		})
+	}, nil
 }
 
 func normalizeURL(s string) string {
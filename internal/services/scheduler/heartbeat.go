@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/NordCoder/Pingerus/internal/domain/check"
+	"github.com/NordCoder/Pingerus/internal/domain/outbox"
+	"github.com/NordCoder/Pingerus/internal/domain/run"
+	intoutbox "github.com/NordCoder/Pingerus/internal/outbox"
+	"github.com/NordCoder/Pingerus/internal/repository/postgres"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Heartbeats takes down heartbeat checks whose job missed its deadline.
+type Heartbeats struct {
+	Checks     check.Repo
+	Runs       run.Repo
+	Outbox     outbox.Repository
+	Transactor postgres.Transactor
+}
+
+// Sweep marks up to limit overdue heartbeats down, each with a failed run
+// and the same StatusChange the ping-worker publishes for HTTP checks, and
+// returns how many it marked.
+func (h *Heartbeats) Sweep(ctx context.Context, limit int) (int, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	ctx, span := otel.Tracer("scheduler.uc").Start(ctx, "scheduler.heartbeats")
+	defer span.End()
+
+	missed := 0
+	err := h.Transactor.WithTx(ctx, func(ctx context.Context) error {
+		list, err := h.Checks.LockMissedHeartbeats(ctx, limit)
+		if err != nil {
+			return fmt.Errorf("lock missed heartbeats: %w", err)
+		}
+		now := time.Now().UTC()
+		for _, c := range list {
+			if err := h.Runs.Insert(ctx, &run.Run{CheckID: c.ID, Timestamp: now, Status: false}); err != nil {
+				return fmt.Errorf("insert run: %w", err)
+			}
+			down := false
+			c.LastStatus = &down
+			if err := h.Checks.Update(ctx, c); err != nil {
+				return fmt.Errorf("update check: %w", err)
+			}
+			payload := intoutbox.StatusChangedPayload{CheckID: c.ID, Old: true, New: false, At: now}
+			key := fmt.Sprintf("status:%d:%d", c.ID, payload.At.UnixNano())
+			if err := intoutbox.StatusChanged.Enqueue(ctx, h.Outbox, key, payload); err != nil {
+				return fmt.Errorf("outbox enqueue: %w", err)
+			}
+		}
+		missed = len(list)
+		return nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+	span.SetAttributes(attribute.Int("heartbeats.missed", missed))
+	return missed, nil
+}
@@ -14,8 +14,11 @@ type Runner struct {
 	Log *zap.Logger
 	UC  *Usecase
 	Cfg *config.SchedCfg
+	// HB, when set, is swept for missed heartbeats on every tick.
+	HB *Heartbeats
 
 	mFetched prometheus.Counter
+	mMissed  prometheus.Counter
 	mSent    prometheus.Counter
 	mErr     prometheus.Counter
 	mLoopDur prometheus.Histogram
@@ -32,6 +35,9 @@ func New(log *zap.Logger, uc *Usecase, cfg *config.SchedCfg) *Runner {
 		mSent: promauto.NewCounter(prometheus.CounterOpts{
 			Name: "scheduler_messages_sent_total", Help: "CheckRequest published to Kafka",
 		}),
+		mMissed: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "scheduler_heartbeats_missed_total", Help: "Heartbeat checks marked down for a missed ping",
+		}),
 		mErr: promauto.NewCounter(prometheus.CounterOpts{
 			Name: "scheduler_errors_total", Help: "Errors in scheduler loop",
 		}),
@@ -58,6 +64,17 @@ func (r *Runner) tick(ctx context.Context) {
 		r.Log.Debug("scheduled batch", zap.Int("fetched", fetched), zap.Int("sent", sent), zap.Int("errors", errs))
 
 	}
+	if r.HB != nil {
+		missed, err := r.HB.Sweep(ctx, r.Cfg.BatchLimit)
+		if err != nil {
+			r.mErr.Inc()
+			r.Log.Warn("heartbeat sweep error", zap.Error(err))
+		}
+		if missed > 0 {
+			r.mMissed.Add(float64(missed))
+			r.Log.Info("heartbeats missed", zap.Int("count", missed))
+		}
+	}
 	r.mLoopDur.Observe(time.Since(start).Seconds())
 }
 
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -154,6 +155,11 @@ func Test_CheckCreation_LeadsToEmail(t *testing.T) {
 	require.NoError(t, err)
 	t.Logf("signed up as %s (id=%d)", aresp.User.Email, uid)
 
+	// alerts only go to verified addresses
+	token := waitVerificationToken(t, c, email)
+	postJSON(t, c.APIBase+"/v1/auth/email/verify", map[string]string{"token": token}, &struct{}{}, "")
+	t.Log("email verified")
+
 	var cresp checkResp
 	postJSON(t, c.APIBase+"/v1/checks", createCheckReq{
 		Url:         "http://http-echo",
@@ -186,6 +192,31 @@ func Test_CheckCreation_LeadsToEmail(t *testing.T) {
 	require.NoError(t, lastErr, "email didn't arrive in time")
 }
 
+func waitVerificationToken(t *testing.T, c cfg, email string) string {
+	t.Helper()
+	deadline := time.Now().Add(c.WaitEmail)
+	for time.Now().Before(deadline) {
+		for _, m := range fetchMailhog(t, c, email) {
+			if !strings.Contains(headerFirst(m.Content.Headers, "Subject"), "Confirm your email") {
+				continue
+			}
+			_, rest, ok := strings.Cut(m.Content.Body, "token=")
+			if !ok {
+				continue
+			}
+			if i := strings.IndexAny(rest, " \r\n"); i >= 0 {
+				rest = rest[:i]
+			}
+			tok, err := url.QueryUnescape(rest)
+			require.NoError(t, err)
+			return tok
+		}
+		time.Sleep(1 * time.Second)
+	}
+	t.Fatalf("verification email didn't arrive in time")
+	return ""
+}
+
 func fetchMailhog(t *testing.T, c cfg, toEmail string) []mailhogMsg {
 	t.Helper()
 	var out mailhogMessages
@@ -3,10 +3,16 @@
 package integration
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -50,6 +56,79 @@ func httpGetAuth(t *testing.T, url, token string, wantCode int) []byte {
 	return data
 }
 
+// agDo sends body, if any, as JSON with token as the bearer and returns the
+// response body.
+func agDo(t *testing.T, method, path, token string, body any, wantCode int) []byte {
+	t.Helper()
+	var rd io.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		rd = bytes.NewReader(b)
+	}
+	req, _ := http.NewRequest(method, agBaseURL+path, rd)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("http %s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != wantCode {
+		t.Fatalf("http %s %s: got %d want %d body=%s", method, path, resp.StatusCode, wantCode, string(data))
+	}
+	return data
+}
+
+// signUp registers a fresh account and returns its access token.
+func signUp(t *testing.T, prefix string) string {
+	t.Helper()
+	data := httpPostJSON(t, agBaseURL+"/v1/auth/sign-up", map[string]string{
+		"email":    fmt.Sprintf("%s-%d@example.com", prefix, RandID()),
+		"password": "supersecret",
+	}, 200)
+	var su struct {
+		AccessToken string `json:"accessToken"`
+	}
+	if err := json.Unmarshal(data, &su); err != nil || su.AccessToken == "" {
+		t.Fatalf("sign-up: %v body=%s", err, string(data))
+	}
+	return su.AccessToken
+}
+
+// itCheck is the part of a Check the tests look at.
+type itCheck struct {
+	ID        int64    `json:"id,string"`
+	OrgID     int64    `json:"orgId,string"`
+	Version   int64    `json:"version,string"`
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	URL       string   `json:"url"`
+	PingToken string   `json:"pingToken"`
+	Status    *bool    `json:"lastStatus"`
+	Active    *bool    `json:"active"`
+	Tags      []string `json:"tags"`
+	// int64s come as JSON strings
+	ParentIDs []string `json:"parentIds"`
+}
+
+func createCheck(t *testing.T, token string, body map[string]any) itCheck {
+	t.Helper()
+	data := agDo(t, http.MethodPost, "/v1/checks", token, body, 200)
+	var resp struct {
+		Check itCheck `json:"check"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil || resp.Check.ID == 0 {
+		t.Fatalf("create check: %v body=%s", err, string(data))
+	}
+	return resp.Check
+}
+
 func TestAuthAndChecks_Basic(t *testing.T) {
 	email := "it-gw@example.com"
 	pass := "supersecret"
@@ -115,7 +194,7 @@ func TestCheck_EnqueueKafka(t *testing.T) {
 	createReq := map[string]any{
 		"user_id":      1,
 		"url":          "http://example.com/ping",
-		"interval_sec": 30,
+		"interval_sec": 60,
 	}
 	reqBody, _ := json.Marshal(createReq)
 	req, _ := http.NewRequest(http.MethodPost, agBaseURL+"/v1/checks", bytes.NewReader(reqBody))
@@ -133,3 +212,602 @@ func TestCheck_EnqueueKafka(t *testing.T) {
 	}
 	t.Logf("[create check] ok: %s", string(data))
 }
+
+// TestOIDCLogin_MockIdP signs in through the mock-oidc service from
+// docker-compose, playing the browser's part of the redirects.
+func TestOIDCLogin_MockIdP(t *testing.T) {
+	const mockIdPAddr = "127.0.0.1:8085"
+	client := &http.Client{
+		Timeout:       10 * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	resp, err := client.Get(agBaseURL + "/v1/auth/oidc/mock/start")
+	if err != nil {
+		t.Fatalf("oidc start: %v", err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("oidc start: got %d body=%s", resp.StatusCode, string(data))
+	}
+	var start struct {
+		AuthorizationURL string `json:"authorizationUrl"`
+	}
+	if err := json.Unmarshal(data, &start); err != nil {
+		t.Fatalf("unmarshal start: %v body=%s", err, string(data))
+	}
+	var stateCookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == "oidc_state" {
+			stateCookie = c
+		}
+	}
+	if stateCookie == nil {
+		t.Fatalf("oidc start: no oidc_state cookie")
+	}
+
+	// the gateway knows the IdP by its compose hostname
+	authURL, err := url.Parse(start.AuthorizationURL)
+	if err != nil {
+		t.Fatalf("parse authorization url: %v", err)
+	}
+	authURL.Host = mockIdPAddr
+	resp, err = client.Get(authURL.String())
+	if err != nil {
+		t.Fatalf("idp authorize: %v", err)
+	}
+	resp.Body.Close()
+	cb, err := url.Parse(resp.Header.Get("Location"))
+	if resp.StatusCode != http.StatusFound || err != nil {
+		t.Fatalf("idp authorize: got %d location=%q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	body, _ := json.Marshal(map[string]string{
+		"code":  cb.Query().Get("code"),
+		"state": cb.Query().Get("state"),
+	})
+	req, _ := http.NewRequest(http.MethodPost, agBaseURL+"/v1/auth/oidc/mock/callback", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(stateCookie)
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("oidc callback: %v", err)
+	}
+	data, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("oidc callback: got %d body=%s", resp.StatusCode, string(data))
+	}
+	var si struct {
+		AccessToken string `json:"accessToken"`
+	}
+	if err := json.Unmarshal(data, &si); err != nil || si.AccessToken == "" {
+		t.Fatalf("oidc callback: no access token: %v body=%s", err, string(data))
+	}
+
+	var me struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"emailVerified"`
+	}
+	meResp := httpGetAuth(t, agBaseURL+"/v1/auth/me", si.AccessToken, 200)
+	if err := json.Unmarshal(meResp, &me); err != nil {
+		t.Fatalf("unmarshal me: %v body=%s", err, string(meResp))
+	}
+	if me.Email != "sso-user@example.com" || !me.EmailVerified {
+		t.Fatalf("me: got %+v", me)
+	}
+}
+
+func checkPath(id int64) string { return "/v1/checks/" + strconv.FormatInt(id, 10) }
+
+// TestCheck_UpdateStaleVersion_Aborted: a PATCH carrying a version that
+// another write already moved past is rejected with 409 and changes nothing.
+func TestCheck_UpdateStaleVersion_Aborted(t *testing.T) {
+	token := signUp(t, "it-version")
+	c := createCheck(t, token, map[string]any{"name": "v1", "url": "http://example.com/v", "interval_sec": 60})
+
+	data := agDo(t, http.MethodPatch, checkPath(c.ID)+"?update_mask=name", token,
+		map[string]any{"name": "v2", "version": c.Version}, 200)
+	var updated itCheck
+	if err := json.Unmarshal(data, &updated); err != nil || updated.Version <= c.Version {
+		t.Fatalf("first update: %v body=%s", err, string(data))
+	}
+
+	agDo(t, http.MethodPatch, checkPath(c.ID)+"?update_mask=name", token,
+		map[string]any{"name": "v3", "version": c.Version}, 409)
+
+	var got itCheck
+	_ = json.Unmarshal(agDo(t, http.MethodGet, checkPath(c.ID), token, nil, 200), &got)
+	if got.Name != "v2" || got.Version != updated.Version {
+		t.Fatalf("after stale update: name=%q version=%d, want v2/%d", got.Name, got.Version, updated.Version)
+	}
+}
+
+// TestCheck_Update_UnmaskedKeepsFieldsAndPauses: a PUT without an
+// update_mask changes only the fields it sends, and active pauses and
+// resumes a check.
+func TestCheck_Update_UnmaskedKeepsFieldsAndPauses(t *testing.T) {
+	token := signUp(t, "it-mask")
+	c := createCheck(t, token, map[string]any{
+		"name": "kept", "tags": []string{"prod"}, "url": "http://example.com/a", "interval_sec": 60,
+	})
+
+	var got itCheck
+	data := agDo(t, http.MethodPut, checkPath(c.ID), token,
+		map[string]any{"check": map[string]any{"url": "http://example.com/b"}}, 200)
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("put: %v body=%s", err, string(data))
+	}
+	if got.URL != "http://example.com/b" || got.Name != "kept" || len(got.Tags) != 1 || got.Tags[0] != "prod" {
+		t.Fatalf("after unmasked put: url=%q name=%q tags=%v", got.URL, got.Name, got.Tags)
+	}
+	if got.Active == nil || !*got.Active {
+		t.Fatalf("new check not active: %v", got.Active)
+	}
+
+	for _, active := range []bool{false, true} {
+		data = agDo(t, http.MethodPatch, checkPath(c.ID)+"?update_mask=active", token,
+			map[string]any{"active": active}, 200)
+		got = itCheck{}
+		if err := json.Unmarshal(data, &got); err != nil || got.Active == nil || *got.Active != active {
+			t.Fatalf("patch active=%v: %v body=%s", active, err, string(data))
+		}
+		if got.Name != "kept" || got.URL != "http://example.com/b" {
+			t.Fatalf("patch active=%v changed name=%q url=%q", active, got.Name, got.URL)
+		}
+	}
+}
+
+// listAll walks every page of the org's checks under the given sort and
+// returns the ids in the order they came.
+func listAll(t *testing.T, token string, orgID int64, sort string, pageSize int) []int64 {
+	t.Helper()
+	var ids []int64
+	pageToken := ""
+	for pages := 0; ; pages++ {
+		if pages > 50 {
+			t.Fatalf("list %s: page tokens never ran out", sort)
+		}
+		q := url.Values{"sort": {sort}, "page_size": {strconv.Itoa(pageSize)}}
+		if pageToken != "" {
+			q.Set("page_token", pageToken)
+		}
+		data := agDo(t, http.MethodGet, fmt.Sprintf("/v1/orgs/%d/checks?%s", orgID, q.Encode()), token, nil, 200)
+		var page struct {
+			Checks        []itCheck `json:"checks"`
+			NextPageToken string    `json:"nextPageToken"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			t.Fatalf("list %s: %v body=%s", sort, err, string(data))
+		}
+		for _, c := range page.Checks {
+			ids = append(ids, c.ID)
+		}
+		if page.NextPageToken == "" {
+			return ids
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// TestCheck_ListPagination_Ties: checks sharing a sort key are neither
+// repeated nor skipped across page boundaries.
+func TestCheck_ListPagination_Ties(t *testing.T) {
+	token := signUp(t, "it-pages")
+	const n = 7
+	want := map[int64]bool{}
+	var orgID int64
+	for i := 0; i < n; i++ {
+		c := createCheck(t, token, map[string]any{
+			"name": "same", "url": fmt.Sprintf("http://example.com/tie/%d", i), "interval_sec": 60,
+		})
+		want[c.ID], orgID = true, c.OrgID
+	}
+
+	// every check gets the same status_changed_at as well
+	db := DBOpen(t, LoadCfg().DBDSN)
+	defer db.Close()
+	if _, err := db.Exec(`update checks set status_changed_at = '2026-01-01T00:00:00Z' where org_id = $1`, orgID); err != nil {
+		t.Fatalf("[db] tie status_changed_at: %v", err)
+	}
+
+	for _, sort := range []string{"CHECK_SORT_NAME", "CHECK_SORT_STATUS_CHANGED"} {
+		ids := listAll(t, token, orgID, sort, 2)
+		seen := map[int64]bool{}
+		for _, id := range ids {
+			if seen[id] {
+				t.Fatalf("%s: check %d listed twice: %v", sort, id, ids)
+			}
+			seen[id] = true
+		}
+		if len(seen) != len(want) {
+			t.Fatalf("%s: listed %d checks, want %d: %v", sort, len(seen), len(want), ids)
+		}
+		for id := range want {
+			if !seen[id] {
+				t.Fatalf("%s: check %d skipped: %v", sort, id, ids)
+			}
+		}
+	}
+}
+
+type itImport struct {
+	Applied bool `json:"applied"`
+	Rows    []struct {
+		Row     int32    `json:"row"`
+		Action  string   `json:"action"`
+		CheckID int64    `json:"checkId,string"`
+		Errors  []string `json:"errors"`
+	} `json:"rows"`
+	Created int32 `json:"created"`
+	Updated int32 `json:"updated"`
+	Failed  int32 `json:"failed"`
+}
+
+func importChecks(t *testing.T, token string, orgID int64, specs []map[string]any, dryRun bool) itImport {
+	t.Helper()
+	raw, _ := json.Marshal(specs)
+	data := agDo(t, http.MethodPost, "/v1/checks:import", token, map[string]any{
+		"orgId": strconv.FormatInt(orgID, 10), "format": "CHECK_FORMAT_JSON", "data": string(raw), "dryRun": dryRun,
+	}, 200)
+	var resp itImport
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("import: %v body=%s", err, string(data))
+	}
+	return resp
+}
+
+// TestCheck_Import_DryRunAndRollback: a dry run plans without writing, and a
+// file with one conflicting row writes none of its rows.
+func TestCheck_Import_DryRunAndRollback(t *testing.T) {
+	token := signUp(t, "it-import")
+	existing := createCheck(t, token, map[string]any{"name": "api", "url": "http://example.com/api", "interval_sec": 60})
+	orgID := existing.OrgID
+	countChecks := func() int { return len(listAll(t, token, orgID, "CHECK_SORT_NAME", 100)) }
+
+	specs := []map[string]any{
+		{"name": "api", "url": "http://example.com/api", "interval_sec": 120},
+		{"name": "web", "url": "http://example.com/web", "interval_sec": 60},
+	}
+	dry := importChecks(t, token, orgID, specs, true)
+	if dry.Applied || dry.Updated != 1 || dry.Created != 1 || dry.Failed != 0 {
+		t.Fatalf("dry run: %+v", dry)
+	}
+	if n := countChecks(); n != 1 {
+		t.Fatalf("dry run wrote checks: have %d, want 1", n)
+	}
+	var got itCheck
+	_ = json.Unmarshal(agDo(t, http.MethodGet, checkPath(existing.ID), token, nil, 200), &got)
+	if got.Version != existing.Version {
+		t.Fatalf("dry run updated check %d: version %d -> %d", existing.ID, existing.Version, got.Version)
+	}
+
+	// the third row matches "api" by URL, which row 1 already claimed
+	conflicting := append(specs, map[string]any{"name": "", "url": "http://example.com/api", "interval_sec": 60})
+	res := importChecks(t, token, orgID, conflicting, false)
+	if res.Applied || res.Failed != 1 {
+		t.Fatalf("conflicting import: %+v", res)
+	}
+	if len(res.Rows) != 3 || res.Rows[2].Action != "IMPORT_ACTION_ERROR" || len(res.Rows[2].Errors) == 0 {
+		t.Fatalf("conflicting import rows: %+v", res.Rows)
+	}
+	if n := countChecks(); n != 1 {
+		t.Fatalf("rejected import wrote checks: have %d, want 1", n)
+	}
+	_ = json.Unmarshal(agDo(t, http.MethodGet, checkPath(existing.ID), token, nil, 200), &got)
+	if got.Version != existing.Version {
+		t.Fatalf("rejected import updated check %d", existing.ID)
+	}
+
+	res = importChecks(t, token, orgID, specs, false)
+	if !res.Applied || res.Created != 1 || res.Updated != 1 {
+		t.Fatalf("import: %+v", res)
+	}
+	if n := countChecks(); n != 2 {
+		t.Fatalf("after import: have %d checks, want 2", n)
+	}
+}
+
+type itEvent struct {
+	Cursor  int64  `json:"cursor,string"`
+	Kind    string `json:"kind"`
+	CheckID int64  `json:"checkId,string"`
+}
+
+// watchEvents opens an SSE watch and collects the first n events other than
+// heartbeats; events the gateway has not picked up yet when the watch opens
+// arrive live after the replay. It returns the HTTP status and, on errors,
+// the body.
+func watchEvents(t *testing.T, path, token, lastEventID string, n int) (int, []itEvent, string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, agBaseURL+path, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Authorization", "Bearer "+token)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("watch %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, nil, string(data)
+	}
+
+	var events []itEvent
+	sc := bufio.NewScanner(resp.Body)
+	for len(events) < n && sc.Scan() {
+		line, ok := strings.CutPrefix(sc.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var chunk struct {
+			Result *itEvent        `json:"result"`
+			Error  json.RawMessage `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil || chunk.Result == nil {
+			t.Fatalf("watch %s: bad chunk %s: %v", path, line, err)
+		}
+		if chunk.Result.Kind != "CHECK_EVENT_KIND_HEARTBEAT" {
+			events = append(events, *chunk.Result)
+		}
+	}
+	if len(events) < n {
+		t.Fatalf("watch %s: got %d of %d events: %v", path, len(events), n, sc.Err())
+	}
+	return resp.StatusCode, events, ""
+}
+
+// TestCheck_Watch_ReplayAndExpiry: a watch resuming from a cursor, by query
+// or by Last-Event-ID, replays exactly the later events, and a cursor older
+// than the retained history is refused.
+func TestCheck_Watch_ReplayAndExpiry(t *testing.T) {
+	token := signUp(t, "it-watch")
+	c := createCheck(t, token, map[string]any{"url": "http://example.com/watch", "interval_sec": 60})
+
+	db := DBOpen(t, LoadCfg().DBDSN)
+	defer db.Close()
+	// down then up: status_changed, incident_opened, status_changed, incident_resolved
+	for _, s := range []bool{false, true} {
+		if _, err := db.Exec(`update checks set last_status = $2 where id = $1`, c.ID, s); err != nil {
+			t.Fatalf("[db] set status: %v", err)
+		}
+	}
+	rows, err := db.Query(`select id from check_events where check_id = $1 order by id`, c.ID)
+	if err != nil {
+		t.Fatalf("[db] events: %v", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		_ = rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if len(ids) != 4 {
+		t.Fatalf("[db] want 4 events for check %d, got %v", c.ID, ids)
+	}
+
+	wantKinds := []string{"CHECK_EVENT_KIND_INCIDENT_OPENED", "CHECK_EVENT_KIND_STATUS_CHANGED", "CHECK_EVENT_KIND_INCIDENT_RESOLVED"}
+	base := fmt.Sprintf("/v1/checks/%d:watch", c.ID)
+	for name, try := range map[string]struct{ path, lastEventID string }{
+		"cursor":        {base + "?cursor=" + strconv.FormatInt(ids[0], 10), ""},
+		"last-event-id": {base, strconv.FormatInt(ids[0], 10)},
+	} {
+		code, events, body := watchEvents(t, try.path, token, try.lastEventID, len(wantKinds))
+		if code != 200 {
+			t.Fatalf("%s: got %d body=%s", name, code, body)
+		}
+		for i, ev := range events {
+			if ev.Cursor != ids[i+1] || ev.Kind != wantKinds[i] || ev.CheckID != c.ID {
+				t.Fatalf("%s: event %d = %+v, want cursor %d kind %s", name, i, ev, ids[i+1], wantKinds[i])
+			}
+		}
+	}
+
+	// prune everything before the third event, as retention would
+	if _, err := db.Exec(`delete from check_events where id < $1`, ids[2]); err != nil {
+		t.Fatalf("[db] prune: %v", err)
+	}
+	code, _, body := watchEvents(t, base+"?cursor="+strconv.FormatInt(ids[0], 10), token, "", 1)
+	if code != http.StatusBadRequest {
+		t.Fatalf("expired cursor: got %d body=%s, want 400", code, body)
+	}
+}
+
+// TestStatusPage_AnonymousSeesSelectedChecks: the public page, JSON and HTML,
+// lists only the checks picked for it, in order, and leaks nothing about the
+// org's other checks.
+func TestStatusPage_AnonymousSeesSelectedChecks(t *testing.T) {
+	token := signUp(t, "it-status")
+	api := createCheck(t, token, map[string]any{"name": "api", "url": "http://example.com/sp-api", "interval_sec": 60})
+	web := createCheck(t, token, map[string]any{"name": "web", "url": "http://example.com/sp-web", "interval_sec": 60})
+	secret := createCheck(t, token, map[string]any{"name": "internal-billing", "url": "http://example.com/sp-secret", "interval_sec": 60})
+
+	// the hidden check has an incident; it must not show up either
+	db := DBOpen(t, LoadCfg().DBDSN)
+	defer db.Close()
+	if _, err := db.Exec(`update checks set last_status = false where id = $1`, secret.ID); err != nil {
+		t.Fatalf("[db] set status: %v", err)
+	}
+
+	slug := fmt.Sprintf("it-status-%d", RandID())
+	agDo(t, http.MethodPost, "/v1/status-pages", token, map[string]any{
+		"slug":  slug,
+		"title": "Acme status",
+		"checks": []map[string]any{
+			{"checkId": strconv.FormatInt(web.ID, 10), "displayName": "Website"},
+			{"checkId": strconv.FormatInt(api.ID, 10)},
+		},
+	}, 200)
+
+	data := agDo(t, http.MethodGet, "/v1/status/"+slug, "", nil, 200)
+	var page struct {
+		Checks []struct {
+			Name string `json:"name"`
+		} `json:"checks"`
+		Incidents []struct {
+			CheckName string `json:"checkName"`
+		} `json:"incidents"`
+	}
+	if err := json.Unmarshal(data, &page); err != nil {
+		t.Fatalf("public page: %v body=%s", err, string(data))
+	}
+	if len(page.Checks) != 2 || page.Checks[0].Name != "Website" || page.Checks[1].Name != "api" {
+		t.Fatalf("public page checks: %+v", page.Checks)
+	}
+	if len(page.Incidents) != 0 {
+		t.Fatalf("public page incidents: %+v", page.Incidents)
+	}
+	for _, leak := range []string{secret.Name, secret.URL, api.URL, web.URL} {
+		if strings.Contains(string(data), leak) {
+			t.Fatalf("public page leaks %q: %s", leak, string(data))
+		}
+	}
+
+	html := agDo(t, http.MethodGet, "/status/"+slug, "", nil, 200)
+	if !strings.Contains(string(html), "Website") || strings.Contains(string(html), secret.Name) {
+		t.Fatalf("html page shows the wrong checks: %s", string(html))
+	}
+}
+
+func rotateBadge(t *testing.T, token string, checkID int64) (svgPath, jsonPath string) {
+	t.Helper()
+	data := agDo(t, http.MethodPost, checkPath(checkID)+"/badge:rotate", token, nil, 200)
+	var b struct {
+		SvgPath  string `json:"svgPath"`
+		JSONPath string `json:"jsonPath"`
+	}
+	if err := json.Unmarshal(data, &b); err != nil || b.SvgPath == "" {
+		t.Fatalf("rotate badge: %v body=%s", err, string(data))
+	}
+	return b.SvgPath, b.JSONPath
+}
+
+// TestBadge_DisabledOrRotatedToken_NotFound: a badge URL stops resolving once
+// its token is rotated out or the badge is disabled. Tokens are not fetched
+// before they are revoked, so the gateway's badge cache plays no part.
+func TestBadge_DisabledOrRotatedToken_NotFound(t *testing.T) {
+	token := signUp(t, "it-badge")
+	live := createCheck(t, token, map[string]any{"url": "http://example.com/badge-live", "interval_sec": 60})
+	c := createCheck(t, token, map[string]any{"url": "http://example.com/badge", "interval_sec": 60})
+
+	liveSVG, _ := rotateBadge(t, token, live.ID)
+	agDo(t, http.MethodGet, liveSVG, "", nil, 200)
+
+	oldSVG, _ := rotateBadge(t, token, c.ID)
+	curSVG, curJSON := rotateBadge(t, token, c.ID)
+	agDo(t, http.MethodGet, oldSVG, "", nil, 404)
+
+	agDo(t, http.MethodDelete, checkPath(c.ID)+"/badge", token, nil, 200)
+	agDo(t, http.MethodGet, curSVG, "", nil, 404)
+	data := agDo(t, http.MethodGet, curJSON, "", nil, 404)
+	var ep struct {
+		IsError bool `json:"isError"`
+	}
+	if err := json.Unmarshal(data, &ep); err != nil || !ep.IsError {
+		t.Fatalf("disabled badge json: %v body=%s", err, string(data))
+	}
+}
+
+// TestCheck_DependencyCycle_Rejected: parents that would make a check depend
+// on itself, directly or through others, are refused with 400.
+func TestCheck_DependencyCycle_Rejected(t *testing.T) {
+	token := signUp(t, "it-deps")
+	a := createCheck(t, token, map[string]any{"name": "db", "url": "http://example.com/deps-a", "interval_sec": 60})
+	b := createCheck(t, token, map[string]any{"name": "api", "url": "http://example.com/deps-b", "interval_sec": 60,
+		"parentIds": []string{strconv.FormatInt(a.ID, 10)}})
+	c := createCheck(t, token, map[string]any{"name": "web", "url": "http://example.com/deps-c", "interval_sec": 60,
+		"parentIds": []string{strconv.FormatInt(b.ID, 10)}})
+
+	setParents := func(id int64, parents []int64, want int) {
+		t.Helper()
+		ps := make([]string, 0, len(parents))
+		for _, p := range parents {
+			ps = append(ps, strconv.FormatInt(p, 10))
+		}
+		agDo(t, http.MethodPatch, checkPath(id)+"?update_mask=parent_ids", token, map[string]any{"parentIds": ps}, want)
+	}
+	setParents(a.ID, []int64{a.ID}, 400)
+	setParents(a.ID, []int64{b.ID}, 400)
+	setParents(a.ID, []int64{c.ID}, 400)
+	agDo(t, http.MethodPost, "/v1/checks", token, map[string]any{
+		"url": "http://example.com/deps-missing", "interval_sec": 60, "parentIds": []string{"999999999"},
+	}, 400)
+
+	var got itCheck
+	_ = json.Unmarshal(agDo(t, http.MethodGet, checkPath(a.ID), token, nil, 200), &got)
+	if len(got.ParentIDs) != 0 {
+		t.Fatalf("check %d kept parents %v after rejected updates", a.ID, got.ParentIDs)
+	}
+	// moving web under db directly is fine
+	setParents(c.ID, []int64{a.ID}, 200)
+}
+
+// TestCheck_PlanLimits_ResourceExhausted: creates past the plan's check
+// limit or below its minimum interval get 429, and two creates racing for
+// the last slot can't both win.
+func TestCheck_PlanLimits_ResourceExhausted(t *testing.T) {
+	token := signUp(t, "it-quota")
+	data := agDo(t, http.MethodGet, "/v1/usage", token, nil, 200)
+	var usage struct {
+		Plan      string `json:"plan"`
+		Used      int64  `json:"checksUsed,string"`
+		MaxChecks int32  `json:"maxChecks"`
+	}
+	if err := json.Unmarshal(data, &usage); err != nil || usage.MaxChecks <= 1 {
+		t.Fatalf("usage: %v body=%s", err, string(data))
+	}
+	spec := func(i int) map[string]any {
+		return map[string]any{"url": fmt.Sprintf("http://example.com/quota/%d", i), "interval_sec": 60}
+	}
+
+	agDo(t, http.MethodPost, "/v1/checks", token, map[string]any{"url": "http://example.com/quota/fast", "interval_sec": 10}, 429)
+
+	var first itCheck
+	for i := 0; i < int(usage.MaxChecks)-1; i++ {
+		c := createCheck(t, token, spec(i))
+		if i == 0 {
+			first = c
+		}
+	}
+
+	codes := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func(i int) {
+			b, _ := json.Marshal(spec(1000 + i))
+			req, _ := http.NewRequest(http.MethodPost, agBaseURL+"/v1/checks", bytes.NewReader(b))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+			if err != nil {
+				codes <- 0
+				return
+			}
+			resp.Body.Close()
+			codes <- resp.StatusCode
+		}(i)
+	}
+	got := map[int]int{}
+	for i := 0; i < 2; i++ {
+		got[<-codes]++
+	}
+	if got[200] != 1 || got[429] != 1 {
+		t.Fatalf("racing for the last slot: status codes %v, want one 200 and one 429", got)
+	}
+
+	agDo(t, http.MethodPost, "/v1/checks", token, spec(2000), 429)
+	_ = json.Unmarshal(agDo(t, http.MethodGet, "/v1/usage", token, nil, 200), &usage)
+	if usage.Used != int64(usage.MaxChecks) {
+		t.Fatalf("usage after filling the plan: %d of %d", usage.Used, usage.MaxChecks)
+	}
+
+	// deleting one frees a slot
+	agDo(t, http.MethodDelete, checkPath(first.ID), token, nil, 200)
+	createCheck(t, token, spec(3000))
+}
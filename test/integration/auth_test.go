@@ -0,0 +1,619 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	domainauth "github.com/NordCoder/Pingerus/internal/domain/auth"
+	"github.com/NordCoder/Pingerus/internal/services/api-gateway/auth"
+)
+
+// itRefreshCookie is auth.cookie_name in the api-gateway config.
+const itRefreshCookie = "refresh_token"
+
+// authDo posts body to an auth route with refresh as the refresh cookie and
+// returns the status, the response body and the refresh token it set, if any.
+func authDo(t *testing.T, path, refresh string, body any) (int, []byte, string) {
+	t.Helper()
+	var rd io.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		rd = bytes.NewReader(b)
+	}
+	req, _ := http.NewRequest(http.MethodPost, agBaseURL+path, rd)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if refresh != "" {
+		req.AddCookie(&http.Cookie{Name: itRefreshCookie, Value: refresh})
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("http POST %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	var set string
+	for _, c := range resp.Cookies() {
+		if c.Name == itRefreshCookie && c.MaxAge >= 0 {
+			set = c.Value
+		}
+	}
+	return resp.StatusCode, data, set
+}
+
+// signUpSession registers a fresh account and returns its email and the
+// refresh token of its first session.
+func signUpSession(t *testing.T, prefix string) (string, string) {
+	t.Helper()
+	email := fmt.Sprintf("%s-%d@example.com", prefix, RandID())
+	code, data, refresh := authDo(t, "/v1/auth/sign-up", "", map[string]string{
+		"email":    email,
+		"password": "supersecret",
+	})
+	if code != 200 || refresh == "" {
+		t.Fatalf("sign-up: code=%d refresh=%q body=%s", code, refresh, string(data))
+	}
+	return email, refresh
+}
+
+// refreshReuseEvents counts the refresh_token_reuse events of the user
+// signed up as email.
+func refreshReuseEvents(t *testing.T, email string) int {
+	t.Helper()
+	db := DBOpen(t, LoadCfg().DBDSN)
+	defer db.Close()
+	var n int
+	if err := db.QueryRow(`
+SELECT COUNT(*) FROM auth_events e JOIN users u ON u.id = e.user_id
+WHERE u.email = $1 AND e.event_type = 'refresh_token_reuse'`, email).Scan(&n); err != nil {
+		t.Fatalf("count reuse events: %v", err)
+	}
+	return n
+}
+
+func TestAuth_RefreshRotationAndReuse(t *testing.T) {
+	WaitHealthz(t, agBaseURL+"/healthz", 60*time.Second)
+
+	t.Run("rotation and replay", func(t *testing.T) {
+		email, r0 := signUpSession(t, "rt-rotate")
+
+		code, _, r1 := authDo(t, "/v1/auth/refresh", r0, nil)
+		if code != 200 || r1 == "" || r1 == r0 {
+			t.Fatalf("refresh r0: code=%d rotated=%v", code, r1 != "" && r1 != r0)
+		}
+		code, _, r2 := authDo(t, "/v1/auth/refresh", r1, nil)
+		if code != 200 || r2 == "" {
+			t.Fatalf("refresh r1: code=%d", code)
+		}
+
+		// r0 was rotated away: replaying it means it leaked
+		if code, _, _ := authDo(t, "/v1/auth/refresh", r0, nil); code != 401 {
+			t.Fatalf("replay r0: got %d want 401", code)
+		}
+		if n := refreshReuseEvents(t, email); n != 1 {
+			t.Fatalf("reuse events: got %d want 1", n)
+		}
+		// and the whole family goes with it
+		if code, _, _ := authDo(t, "/v1/auth/refresh", r2, nil); code != 401 {
+			t.Fatalf("refresh r2 after reuse: got %d want 401", code)
+		}
+	})
+
+	t.Run("logged out token is not reuse", func(t *testing.T) {
+		email, r0 := signUpSession(t, "rt-logout")
+		code, _, r1 := authDo(t, "/v1/auth/refresh", r0, nil)
+		if code != 200 || r1 == "" {
+			t.Fatalf("refresh r0: code=%d", code)
+		}
+		if code, _, _ := authDo(t, "/v1/auth/logout", r1, nil); code != 200 {
+			t.Fatalf("logout: got %d", code)
+		}
+		if code, _, _ := authDo(t, "/v1/auth/refresh", r1, nil); code != 401 {
+			t.Fatalf("refresh after logout: got %d want 401", code)
+		}
+		if n := refreshReuseEvents(t, email); n != 0 {
+			t.Fatalf("reuse events after logout: got %d want 0", n)
+		}
+	})
+
+	t.Run("concurrent refresh", func(t *testing.T) {
+		email, r0 := signUpSession(t, "rt-race")
+
+		const n = 4
+		codes := make([]int, n)
+		rotated := make([]string, n)
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				codes[i], _, rotated[i] = authDo(t, "/v1/auth/refresh", r0, nil)
+			}(i)
+		}
+		wg.Wait()
+
+		var won []string
+		for i, c := range codes {
+			if c == 200 {
+				won = append(won, rotated[i])
+			} else if c != 401 {
+				t.Fatalf("refresh %d: got %d", i, c)
+			}
+		}
+		if len(won) != 1 {
+			t.Fatalf("concurrent refresh: %d won, want exactly 1 (codes %v)", len(won), codes)
+		}
+		// the losers presented a rotated token, so the family is gone
+		if refreshReuseEvents(t, email) == 0 {
+			t.Fatal("no reuse event for the losing refreshes")
+		}
+		if code, _, _ := authDo(t, "/v1/auth/refresh", won[0], nil); code != 401 {
+			t.Fatalf("refresh winner after reuse: got %d want 401", code)
+		}
+	})
+}
+
+// signInFrom signs in with xff as the X-Forwarded-For header and returns the
+// status, the Retry-After header and the access token.
+func signInFrom(t *testing.T, xff, email, password string) (int, string, string) {
+	t.Helper()
+	b, _ := json.Marshal(map[string]string{"email": email, "password": password})
+	req, _ := http.NewRequest(http.MethodPost, agBaseURL+"/v1/auth/sign-in", bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-For", xff)
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("http POST sign-in: %v", err)
+	}
+	defer resp.Body.Close()
+	var out struct {
+		AccessToken string `json:"accessToken"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, resp.Header.Get("Retry-After"), out.AccessToken
+}
+
+// itIP picks an address in one of the documentation ranges, unlikely to be
+// shared between runs.
+func itIP(block int) string {
+	prefixes := []string{"198.51.100.", "192.0.2.", "203.0.113."}
+	return prefixes[block%len(prefixes)] + strconv.FormatInt(RandID()%254+1, 10)
+}
+
+// TestAuth_SignInLockout: failures lock out the pair of IP and account with
+// a doubling Retry-After, while the account stays reachable from elsewhere
+// however many other IPs guess at it.
+func TestAuth_SignInLockout(t *testing.T) {
+	WaitHealthz(t, agBaseURL+"/healthz", 60*time.Second)
+	email, _ := signUpSession(t, "rl-lockout")
+	attacker := itIP(0)
+
+	// lockout_threshold is 5
+	for i := 0; i < 5; i++ {
+		if code, _, _ := signInFrom(t, attacker, email, "wrong-password"); code != 401 {
+			t.Fatalf("failure %d: got %d want 401", i+1, code)
+		}
+	}
+	code, retry, _ := signInFrom(t, attacker, email, "supersecret")
+	secs, _ := strconv.Atoi(retry)
+	if code != 429 || secs <= 0 || secs > 30 {
+		t.Fatalf("locked: code=%d Retry-After=%q, want 429 within lockout_base 30s", code, retry)
+	}
+
+	// a failure after the lock ran out locks for twice as long
+	db := DBOpen(t, LoadCfg().DBDSN)
+	defer db.Close()
+	if _, err := db.Exec(`update auth_rate_limits set locked_until = now() - interval '1 second' where key = $1`,
+		"ip-email:"+attacker+"/"+email); err != nil {
+		t.Fatalf("[db] expire lock: %v", err)
+	}
+	if code, _, _ := signInFrom(t, attacker, email, "wrong-password"); code != 401 {
+		t.Fatalf("failure after lock: got %d want 401", code)
+	}
+	code, retry, _ = signInFrom(t, attacker, email, "supersecret")
+	secs, _ = strconv.Atoi(retry)
+	if code != 429 || secs <= 30 || secs > 60 {
+		t.Fatalf("relocked: code=%d Retry-After=%q, want 429 within 30-60s", code, retry)
+	}
+
+	// more guessing IPs than max_per_email doesn't throttle the owner
+	for i := 0; i < 12; i++ {
+		if code, _, _ := signInFrom(t, fmt.Sprintf("203.0.113.%d", i+1), email, "wrong-password"); code != 401 {
+			t.Fatalf("guess from ip %d: got %d want 401", i+1, code)
+		}
+	}
+	if code, retry, _ := signInFrom(t, itIP(1), email, "supersecret"); code != 200 {
+		t.Fatalf("owner sign-in: code=%d Retry-After=%q, want 200", code, retry)
+	}
+}
+
+// TestAuth_ForwardedForWalk: X-Forwarded-For is believed only through
+// trusted proxies, so a spoofed leftmost hop doesn't become the client.
+func TestAuth_ForwardedForWalk(t *testing.T) {
+	WaitHealthz(t, agBaseURL+"/healthz", 60*time.Second)
+	email, _ := signUpSession(t, "rl-xff")
+
+	client := itIP(2)
+	cases := []struct {
+		name, xff, want string
+	}{
+		// 172.20.0.5 is inside trusted_proxies, so the walk goes past it
+		{"through trusted proxy", client + ", 172.20.0.5", client},
+		// 203.0.113.200 isn't, so it is the client and the left hop is ignored
+		{"spoofed behind untrusted hop", "192.0.2.1, 203.0.113.200", "203.0.113.200"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, _, access := signInFrom(t, tc.xff, email, "supersecret")
+			if code != 200 || access == "" {
+				t.Fatalf("sign-in: got %d", code)
+			}
+			var resp struct {
+				Sessions []struct {
+					IPAddress string `json:"ipAddress"`
+				} `json:"sessions"`
+			}
+			data := agDo(t, http.MethodGet, "/v1/auth/sessions", access, nil, 200)
+			if err := json.Unmarshal(data, &resp); err != nil {
+				t.Fatalf("sessions: %v body=%s", err, string(data))
+			}
+			var ips []string
+			for _, s := range resp.Sessions {
+				if s.IPAddress == tc.want {
+					return
+				}
+				ips = append(ips, s.IPAddress)
+			}
+			t.Fatalf("no session from %s, got %v", tc.want, ips)
+		})
+	}
+}
+
+// itTOTP is RFC 6238 with SHA-1, the parameters the gateway enrolls with,
+// written independently of it.
+func itTOTP(secret []byte, step int64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	off := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+	mod := uint32(1)
+	for range digits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, bin%mod)
+}
+
+// mfaSignIn signs in to an account with a second factor and returns the
+// MFA token.
+func mfaSignIn(t *testing.T, email string) string {
+	t.Helper()
+	data := httpPostJSON(t, agBaseURL+"/v1/auth/sign-in", map[string]string{
+		"email":    email,
+		"password": "supersecret",
+	}, 200)
+	var resp struct {
+		AccessToken string `json:"accessToken"`
+		MFARequired bool   `json:"mfaRequired"`
+		MFAToken    string `json:"mfaToken"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil || !resp.MFARequired || resp.MFAToken == "" || resp.AccessToken != "" {
+		t.Fatalf("sign-in with mfa: %v body=%s", err, string(data))
+	}
+	return resp.MFAToken
+}
+
+// verifyMFA passes code or recoveryCode to VerifyMFA and returns the status.
+func verifyMFA(t *testing.T, mfaToken, code, recoveryCode string) int {
+	t.Helper()
+	status, _, _ := authDo(t, "/v1/auth/mfa/verify", "", map[string]string{
+		"mfaToken": mfaToken, "code": code, "recoveryCode": recoveryCode,
+	})
+	return status
+}
+
+// TestAuth_TOTPAndRecoveryCodes: the gateway accepts RFC 6238 codes,
+// spends each recovery code once, and burns an MFA challenge after five
+// wrong codes.
+func TestAuth_TOTPAndRecoveryCodes(t *testing.T) {
+	WaitHealthz(t, agBaseURL+"/healthz", 60*time.Second)
+
+	// RFC 6238 appendix B, SHA-1 rows
+	rfcSecret := []byte("12345678901234567890")
+	for _, v := range []struct {
+		at   int64
+		want string
+	}{
+		{59, "94287082"}, {1111111109, "07081804"}, {1111111111, "14050471"},
+		{1234567890, "89005924"}, {2000000000, "69279037"}, {20000000000, "65353130"},
+	} {
+		if got := itTOTP(rfcSecret, v.at/30, 8); got != v.want {
+			t.Fatalf("RFC 6238 T=%d: got %s want %s", v.at, got, v.want)
+		}
+	}
+
+	email := fmt.Sprintf("it-totp-%d@example.com", RandID())
+	data := httpPostJSON(t, agBaseURL+"/v1/auth/sign-up", map[string]string{"email": email, "password": "supersecret"}, 200)
+	var su struct {
+		AccessToken string `json:"accessToken"`
+	}
+	_ = json.Unmarshal(data, &su)
+
+	var enroll struct {
+		Secret string `json:"secret"`
+	}
+	data = agDo(t, http.MethodPost, "/v1/auth/mfa/totp/enroll", su.AccessToken, nil, 200)
+	if err := json.Unmarshal(data, &enroll); err != nil || enroll.Secret == "" {
+		t.Fatalf("enroll: %v body=%s", err, string(data))
+	}
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enroll.Secret)
+	if err != nil {
+		t.Fatalf("decode secret %q: %v", enroll.Secret, err)
+	}
+	step := time.Now().Unix() / 30
+
+	// three steps out is past the one step of skew either way
+	agDo(t, http.MethodPost, "/v1/auth/mfa/totp/confirm", su.AccessToken,
+		map[string]string{"code": itTOTP(secret, step+3, 6)}, 401)
+	var confirm struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+	data = agDo(t, http.MethodPost, "/v1/auth/mfa/totp/confirm", su.AccessToken,
+		map[string]string{"code": itTOTP(secret, step, 6)}, 200)
+	if err := json.Unmarshal(data, &confirm); err != nil || len(confirm.RecoveryCodes) != 10 {
+		t.Fatalf("confirm: %v body=%s", err, string(data))
+	}
+
+	t.Run("recovery code is spent once", func(t *testing.T) {
+		if got := verifyMFA(t, mfaSignIn(t, email), "", confirm.RecoveryCodes[0]); got != 200 {
+			t.Fatalf("first use: got %d want 200", got)
+		}
+		ch := mfaSignIn(t, email)
+		if got := verifyMFA(t, ch, "", confirm.RecoveryCodes[0]); got != 401 {
+			t.Fatalf("second use: got %d want 401", got)
+		}
+		// a wrong code leaves the challenge open for the next one
+		if got := verifyMFA(t, ch, "", confirm.RecoveryCodes[1]); got != 200 {
+			t.Fatalf("next code on the same challenge: got %d want 200", got)
+		}
+	})
+
+	t.Run("five wrong codes burn the challenge", func(t *testing.T) {
+		ch := mfaSignIn(t, email)
+		wrong := itTOTP(secret, step+10, 6)
+		for i := 0; i < 5; i++ {
+			if got := verifyMFA(t, ch, wrong, ""); got != 401 {
+				t.Fatalf("wrong code %d: got %d want 401", i+1, got)
+			}
+		}
+		// the confirm used step, so the next one is still fresh
+		good := itTOTP(secret, step+1, 6)
+		if got := verifyMFA(t, ch, good, ""); got != 401 {
+			t.Fatalf("right code on a burned challenge: got %d want 401", got)
+		}
+		if got := verifyMFA(t, mfaSignIn(t, email), good, ""); got != 200 {
+			t.Fatalf("right code on a new challenge: got %d want 200", got)
+		}
+	})
+}
+
+// writeKeyPEM stores key as a PKCS#8 PEM file in dir.
+func writeKeyPEM(t *testing.T, dir, name string, key crypto.Signer) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal %s: %v", name, err)
+	}
+	path := filepath.Join(dir, name+".pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+// tokenHeader decodes the JOSE header of token.
+func tokenHeader(t *testing.T, token string) map[string]string {
+	t.Helper()
+	raw, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+	if err != nil {
+		t.Fatalf("decode header: %v", err)
+	}
+	var h map[string]string
+	if err := json.Unmarshal(raw, &h); err != nil {
+		t.Fatalf("unmarshal header: %v", err)
+	}
+	return h
+}
+
+// TestAuth_KeySetRotation: asymmetric tokens verify, a scheduled key is in
+// the JWKS before it signs, and HS256 is refused both with an asymmetric
+// kid and once accept_hs256 is off.
+func TestAuth_KeySetRotation(t *testing.T) {
+	dir := t.TempDir()
+	_, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("gen ed25519: %v", err)
+	}
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("gen rsa: %v", err)
+	}
+	secret := []byte("it-hs256-secret")
+	now := time.Now()
+	clock := now
+	newKeySet := func(acceptHS256 bool) *auth.KeySet {
+		ks, err := auth.NewKeySet(auth.KeySetConfig{
+			Keys: []auth.KeyConfig{
+				{Kid: "ed", Alg: auth.AlgEdDSA, PrivateKeyFile: writeKeyPEM(t, dir, "ed", edPriv), ActiveFrom: now.Add(-time.Hour)},
+				{Kid: "rs-next", Alg: auth.AlgRS256, PrivateKeyFile: writeKeyPEM(t, dir, "rs", rsaPriv), ActiveFrom: now.Add(time.Hour)},
+			},
+			Secret:      secret,
+			AcceptHS256: acceptHS256,
+			Issuer:      "https://it.pingerus.test",
+			Audience:    "pingerus-api",
+			Now:         func() time.Time { return clock },
+		})
+		if err != nil {
+			t.Fatalf("new key set: %v", err)
+		}
+		return ks
+	}
+	claims := domainauth.AccessClaims{Sub: "42", Iat: now.Unix(), Exp: now.Add(15 * time.Minute).Unix()}
+
+	ks := newKeySet(true)
+	edToken, err := ks.Sign(claims)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if kid := tokenHeader(t, edToken)["kid"]; kid != "ed" {
+		t.Fatalf("signing kid before rotation: %q want ed", kid)
+	}
+	if c, err := ks.Verify(edToken); err != nil || c.Sub != "42" || c.Aud != "pingerus-api" {
+		t.Fatalf("verify EdDSA: claims=%+v err=%v", c, err)
+	}
+
+	// the scheduled key is published an hour before it signs
+	rec := httptest.NewRecorder()
+	auth.JWKSHandler(ks).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	var set auth.JWKSet
+	if err := json.Unmarshal(rec.Body.Bytes(), &set); err != nil {
+		t.Fatalf("jwks: %v body=%s", err, rec.Body.String())
+	}
+	kids := map[string]string{}
+	for _, k := range set.Keys {
+		kids[k.Kid] = k.Kty
+	}
+	if kids["ed"] != "OKP" || kids["rs-next"] != "RSA" {
+		t.Fatalf("jwks kids: %v, want ed/OKP and rs-next/RSA", kids)
+	}
+
+	clock = now.Add(2 * time.Hour)
+	rsToken, err := ks.Sign(claims)
+	if err != nil {
+		t.Fatalf("sign after rotation: %v", err)
+	}
+	if h := tokenHeader(t, rsToken); h["kid"] != "rs-next" || h["alg"] != auth.AlgRS256 {
+		t.Fatalf("signing header after rotation: %v", h)
+	}
+	if _, err := ks.Verify(rsToken); err != nil {
+		t.Fatalf("verify RS256: %v", err)
+	}
+	if _, err := ks.Verify(edToken); err != nil {
+		t.Fatalf("retired EdDSA key stopped verifying: %v", err)
+	}
+
+	// HS256 keyed with the public key, pointing at the RSA kid
+	pubDER, _ := x509.MarshalPKIXPublicKey(&rsaPriv.PublicKey)
+	payload, _ := json.Marshal(claims)
+	sigInput := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT","kid":"rs-next"}`)) +
+		"." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+	mac.Write([]byte(sigInput))
+	confused := sigInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	if _, err := ks.Verify(confused); err == nil {
+		t.Fatal("HS256 token with an asymmetric kid was accepted")
+	}
+
+	hsToken, err := auth.SignedString(claims, secret)
+	if err != nil {
+		t.Fatalf("sign HS256: %v", err)
+	}
+	if _, err := ks.Verify(hsToken); err != nil {
+		t.Fatalf("HS256 with accept_hs256: %v", err)
+	}
+	if _, err := newKeySet(false).Verify(hsToken); err == nil {
+		t.Fatal("HS256 accepted with accept_hs256 off")
+	}
+}
+
+// createAPIKey makes a key on the personal org of the session behind token
+// and returns its id and secret.
+func createAPIKey(t *testing.T, token, scope string) (int64, string) {
+	t.Helper()
+	data := agDo(t, http.MethodPost, "/v1/auth/api-keys", token, map[string]any{"name": "it-" + scope, "scope": scope}, 200)
+	var resp struct {
+		APIKey struct {
+			ID int64 `json:"id,string"`
+		} `json:"apiKey"`
+		Secret string `json:"secret"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil || resp.Secret == "" || resp.APIKey.ID == 0 {
+		t.Fatalf("create api key: %v body=%s", err, string(data))
+	}
+	return resp.APIKey.ID, resp.Secret
+}
+
+// TestAuth_APIKeyScopeOrgAndRevocation: a read key can't write, a key
+// can't reach beyond its org, and a revoked key stops authenticating.
+func TestAuth_APIKeyScopeOrgAndRevocation(t *testing.T) {
+	token := signUp(t, "it-keys")
+	own := createCheck(t, token, map[string]any{"name": "own", "url": "http://example.com/own", "interval_sec": 60})
+
+	var other struct {
+		ID int64 `json:"id,string"`
+	}
+	data := agDo(t, http.MethodPost, "/v1/orgs", token, map[string]any{"name": "it-keys-other"}, 200)
+	if err := json.Unmarshal(data, &other); err != nil || other.ID == 0 {
+		t.Fatalf("create org: %v body=%s", err, string(data))
+	}
+	elsewhere := createCheck(t, token, map[string]any{
+		"org_id": other.ID, "name": "elsewhere", "url": "http://example.com/elsewhere", "interval_sec": 60,
+	})
+	newCheck := map[string]any{"name": "by-key", "url": "http://example.com/key", "interval_sec": 60}
+
+	t.Run("read key cannot write", func(t *testing.T) {
+		_, ro := createAPIKey(t, token, "API_KEY_SCOPE_READ")
+		agDo(t, http.MethodGet, checkPath(own.ID), ro, nil, 200)
+		agDo(t, http.MethodPost, "/v1/checks", ro, newCheck, 403)
+	})
+
+	t.Run("key is pinned to its org", func(t *testing.T) {
+		_, rw := createAPIKey(t, token, "API_KEY_SCOPE_READ_WRITE")
+		agDo(t, http.MethodPost, "/v1/checks", rw, newCheck, 200)
+		// the user may reach the other org, the key may not
+		agDo(t, http.MethodGet, checkPath(elsewhere.ID), token, nil, 200)
+		agDo(t, http.MethodGet, checkPath(elsewhere.ID), rw, nil, 403)
+		withOrg := map[string]any{"org_id": other.ID}
+		for k, v := range newCheck {
+			withOrg[k] = v
+		}
+		agDo(t, http.MethodPost, "/v1/checks", rw, withOrg, 403)
+	})
+
+	t.Run("revoked key is unauthenticated", func(t *testing.T) {
+		id, rw := createAPIKey(t, token, "API_KEY_SCOPE_READ_WRITE")
+		agDo(t, http.MethodGet, checkPath(own.ID), rw, nil, 200)
+		agDo(t, http.MethodDelete, "/v1/auth/api-keys/"+strconv.FormatInt(id, 10), token, nil, 200)
+		agDo(t, http.MethodGet, checkPath(own.ID), rw, nil, 401)
+	})
+}
@@ -3,12 +3,21 @@
 package integration
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	pb "github.com/NordCoder/Pingerus/generated/v1"
+	"github.com/NordCoder/Pingerus/internal/inbox"
+	kafkax "github.com/NordCoder/Pingerus/internal/repository/kafka"
+	pg "github.com/NordCoder/Pingerus/internal/repository/postgres"
+	notifier "github.com/NordCoder/Pingerus/internal/services/email-notifier"
+	"github.com/NordCoder/Pingerus/internal/services/email-notifier/repo"
+	"go.uber.org/zap"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -73,3 +82,249 @@ func TestEmailNotifier_InvalidCheckID_Ignored(t *testing.T) {
 	PublishProto(t, cfg.KafkaBootstrap, cfg.PWOutTopic, []byte("0"), msg)
 	ExpectNoMailhog(t, cfg.MailhogAPI, 6*time.Second)
 }
+
+// TestEmailNotifier_AlertsOrgAdmins moves a check into another user's org:
+// the org's owner gets the alert and the creator, no longer a member, does
+// not.
+func TestEmailNotifier_AlertsOrgAdmins(t *testing.T) {
+	cfg := LoadCfg()
+	MailhogPurge(t, cfg.MailhogAPI)
+	EnsureTopic(t, cfg.KafkaBootstrap, cfg.PWOutTopic)
+
+	db := DBOpen(t, cfg.DBDSN)
+	defer db.Close()
+
+	creatorID := RandID()
+	ownerID := creatorID + 1
+	checkID := RandID()
+	ownerEmail := fmt.Sprintf("en-owner-%d@example.com", ownerID)
+	SeedUser(t, db, creatorID, fmt.Sprintf("en-creator-%d@example.com", creatorID))
+	SeedUser(t, db, ownerID, ownerEmail)
+	SeedCheck(t, db, checkID, creatorID, "http://example.com/org", itPtrBool(false))
+	if _, err := db.Exec(`update checks set org_id = (select id from orgs where personal_owner_id = $1) where id = $2`,
+		ownerID, checkID); err != nil {
+		t.Fatalf("[db] move check: %v", err)
+	}
+
+	PublishProto(t, cfg.KafkaBootstrap, cfg.PWOutTopic, KeyFromInt64(checkID), &pb.StatusChange{
+		CheckId:   int32(checkID),
+		OldStatus: false,
+		NewStatus: true,
+		Ts:        timestamppb.New(time.Now().UTC()),
+	})
+
+	rep := WaitMailhogCount(t, cfg.MailhogAPI, 1, 25*time.Second)
+	if len(rep.Items) != 1 {
+		t.Fatalf("want 1 mail, got %d", len(rep.Items))
+	}
+	if to := rep.Items[0].Content.Headers["To"]; len(to) == 0 || !strings.Contains(to[0], ownerEmail) {
+		t.Fatalf("mail went to %v, want %s", to, ownerEmail)
+	}
+	if ok, _ := FindNotification(t, db, creatorID, checkID); ok {
+		t.Fatalf("creator outside the org was notified")
+	}
+}
+
+// TestEmailNotifier_HoldsBackDependentAlerts: while a parent check is down
+// its dependents' alerts are held back, and the parent's recovery mail sums
+// them up.
+func TestEmailNotifier_HoldsBackDependentAlerts(t *testing.T) {
+	cfg := LoadCfg()
+	MailhogPurge(t, cfg.MailhogAPI)
+	EnsureTopic(t, cfg.KafkaBootstrap, cfg.PWOutTopic)
+
+	db := DBOpen(t, cfg.DBDSN)
+	defer db.Close()
+
+	userID := RandID()
+	parentID, childID := RandID(), RandID()
+	childHost := fmt.Sprintf("http://example.com/child-%d", childID)
+	SeedUser(t, db, userID, fmt.Sprintf("en-deps-%d@example.com", userID))
+	SeedCheck(t, db, parentID, userID, "http://example.com/parent", itPtrBool(true))
+	SeedCheck(t, db, childID, userID, childHost, itPtrBool(true))
+	if _, err := db.Exec(`insert into check_dependencies (check_id, parent_id) values ($1, $2)`, childID, parentID); err != nil {
+		t.Fatalf("[db] add dependency: %v", err)
+	}
+
+	// the pipeline stores the status before publishing the change
+	flip := func(id int64, up bool) {
+		t.Helper()
+		if _, err := db.Exec(`update checks set last_status = $2 where id = $1`, id, up); err != nil {
+			t.Fatalf("[db] set status: %v", err)
+		}
+		PublishProto(t, cfg.KafkaBootstrap, cfg.PWOutTopic, KeyFromInt64(id), &pb.StatusChange{
+			CheckId:   int32(id),
+			OldStatus: !up,
+			NewStatus: up,
+			Ts:        timestamppb.New(time.Now().UTC()),
+		})
+	}
+
+	flip(parentID, false)
+	WaitMailhogCount(t, cfg.MailhogAPI, 1, 25*time.Second)
+	MailhogPurge(t, cfg.MailhogAPI)
+
+	flip(childID, false)
+	ExpectNoMailhog(t, cfg.MailhogAPI, 6*time.Second)
+	var held int
+	if err := db.QueryRow(`select count(1) from suppressed_alerts where check_id = $1 and parent_id = $2`,
+		childID, parentID).Scan(&held); err != nil || held != 1 {
+		t.Fatalf("[db] suppressed alerts for %d: %d, %v", childID, held, err)
+	}
+	if ok, _ := FindNotification(t, db, userID, childID); ok {
+		t.Fatalf("held back alert was stored as sent")
+	}
+
+	flip(parentID, true)
+	rep := WaitMailhogCount(t, cfg.MailhogAPI, 1, 25*time.Second)
+	if body := rep.Items[0].Content.Body; !strings.Contains(body, "held back") || !strings.Contains(body, childHost) {
+		t.Fatalf("recovery mail does not sum up the held back alert: %q", body)
+	}
+}
+
+// TestEmailNotifier_CapsRecipientsByPlan: an org with an owner and an admin
+// alerts only the owner while the check's owner is on a one-channel plan,
+// and both once the plan allows more.
+func TestEmailNotifier_CapsRecipientsByPlan(t *testing.T) {
+	cfg := LoadCfg()
+	MailhogPurge(t, cfg.MailhogAPI)
+	EnsureTopic(t, cfg.KafkaBootstrap, cfg.PWOutTopic)
+
+	db := DBOpen(t, cfg.DBDSN)
+	defer db.Close()
+
+	ownerID := RandID()
+	adminID := ownerID + 1
+	checkID := RandID()
+	ownerEmail := fmt.Sprintf("en-plan-owner-%d@example.com", ownerID)
+	SeedUser(t, db, ownerID, ownerEmail)
+	SeedUser(t, db, adminID, fmt.Sprintf("en-plan-admin-%d@example.com", adminID))
+	SeedCheck(t, db, checkID, ownerID, "http://example.com/plan", itPtrBool(false))
+	if _, err := db.Exec(`
+    insert into org_members (org_id, user_id, role)
+    select id, $2, 'admin' from orgs where personal_owner_id = $1
+  `, ownerID, adminID); err != nil {
+		t.Fatalf("[db] add admin: %v", err)
+	}
+
+	publish := func(up bool) {
+		PublishProto(t, cfg.KafkaBootstrap, cfg.PWOutTopic, KeyFromInt64(checkID), &pb.StatusChange{
+			CheckId:   int32(checkID),
+			OldStatus: !up,
+			NewStatus: up,
+			Ts:        timestamppb.New(time.Now().UTC()),
+		})
+	}
+
+	// free: max_channels 1
+	publish(true)
+	rep := WaitMailhogCount(t, cfg.MailhogAPI, 1, 25*time.Second)
+	if to := rep.Items[0].Content.Headers["To"]; len(to) == 0 || !strings.Contains(to[0], ownerEmail) {
+		t.Fatalf("mail went to %v, want %s", to, ownerEmail)
+	}
+	// give a second mail time to show up
+	time.Sleep(5 * time.Second)
+	if n, _, _ := mailhogCountRaw(t, cfg.MailhogAPI); n != 1 {
+		t.Fatalf("free plan: %d mails, want 1", n)
+	}
+
+	if _, err := db.Exec(`update users set plan = 'pro' where id = $1`, ownerID); err != nil {
+		t.Fatalf("[db] upgrade plan: %v", err)
+	}
+	MailhogPurge(t, cfg.MailhogAPI)
+	publish(false)
+	if rep := WaitMailhogCount(t, cfg.MailhogAPI, 2, 25*time.Second); len(rep.Items) != 2 {
+		t.Fatalf("pro plan: %d mails, want 2", len(rep.Items))
+	}
+}
+
+type itClock struct{}
+
+func (itClock) Now() time.Time { return time.Now().UTC() }
+
+// flakySender fails the first send to failTo and counts the others.
+type flakySender struct {
+	mu     sync.Mutex
+	failTo string
+	failed bool
+	sent   map[string]int
+}
+
+func (s *flakySender) Send(_ context.Context, to, _, _ string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if to == s.failTo && !s.failed {
+		s.failed = true
+		return errors.New("smtp: 451 try again later")
+	}
+	s.sent[to]++
+	return nil
+}
+
+// TestEmailNotifier_RetryAlertsOnlyFailedRecipients runs the handler
+// in-process on one message twice, as the consumer does after a failed
+// send: the owner's alert went out on the first delivery and is not sent
+// again, the admin's is, and a third delivery sends nothing.
+func TestEmailNotifier_RetryAlertsOnlyFailedRecipients(t *testing.T) {
+	cfg := LoadCfg()
+	db := DBOpen(t, cfg.DBDSN)
+	defer db.Close()
+
+	ownerID := RandID()
+	adminID := ownerID + 1
+	checkID := RandID()
+	ownerEmail := fmt.Sprintf("en-once-owner-%d@example.com", ownerID)
+	adminEmail := fmt.Sprintf("en-once-admin-%d@example.com", adminID)
+	SeedUser(t, db, ownerID, ownerEmail)
+	SeedUser(t, db, adminID, adminEmail)
+	SeedCheck(t, db, checkID, ownerID, "http://example.com/once", itPtrBool(false))
+	if _, err := db.Exec(`
+    insert into org_members (org_id, user_id, role)
+    select id, $2, 'admin' from orgs where personal_owner_id = $1
+  `, ownerID, adminID); err != nil {
+		t.Fatalf("[db] add admin: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	pool, err := pg.NewDB(ctx, pg.Config{DSN: cfg.DBDSN})
+	if err != nil {
+		t.Fatalf("[db] open pool: %v", err)
+	}
+	defer pool.Close()
+
+	out := &flakySender{failTo: adminEmail, sent: map[string]int{}}
+	h := &notifier.Handler{
+		Checks:  repo.CheckReader{R: pg.NewCheckRepo(pool)},
+		Users:   repo.UserReader{R: pg.NewUserRepo(pool)},
+		Store:   repo.NotificationRepo{R: pg.NewNotificationRepo(pool)},
+		Members: &repo.Members{R: pg.NewOrgRepo(pool)},
+		Inbox:   inbox.New(fmt.Sprintf("it-en-once-%d", checkID), pg.NewInboxRepo(pool), pg.NewTransactor(pool, zap.NewNop()), nil),
+		Out:     out,
+		Clock:   itClock{},
+	}
+	msgCtx := kafkax.ContextWithMessage(ctx, kafkax.MessageMeta{Topic: cfg.PWOutTopic, Partition: 0, Offset: checkID})
+	ev := notifier.StatusChange{CheckID: checkID, OldStatus: false, NewStatus: true, At: time.Now().UTC()}
+
+	if err := h.HandleStatusChange(msgCtx, ev); err == nil {
+		t.Fatalf("first delivery: want the admin's send error")
+	}
+	if out.sent[ownerEmail] != 1 || out.sent[adminEmail] != 0 {
+		t.Fatalf("first delivery sent %v", out.sent)
+	}
+	for i := 0; i < 2; i++ {
+		if err := h.HandleStatusChange(msgCtx, ev); err != nil {
+			t.Fatalf("redelivery %d: %v", i+1, err)
+		}
+	}
+	if out.sent[ownerEmail] != 1 || out.sent[adminEmail] != 1 {
+		t.Fatalf("after redeliveries sent %v, want one alert each", out.sent)
+	}
+	for _, uid := range []int64{ownerID, adminID} {
+		var n int
+		if err := db.QueryRow(`select count(1) from notifications where user_id = $1 and check_id = $2`,
+			uid, checkID).Scan(&n); err != nil || n != 1 {
+			t.Fatalf("[db] notifications of %d: %d, %v", uid, n, err)
+		}
+	}
+}
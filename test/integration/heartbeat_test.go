@@ -0,0 +1,128 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	pg "github.com/NordCoder/Pingerus/internal/repository/postgres"
+	"github.com/NordCoder/Pingerus/internal/services/scheduler"
+	"go.uber.org/zap"
+)
+
+type hbState struct {
+	status    sql.NullBool
+	startedAt sql.NullTime
+	runs      int
+	downRuns  int
+	changes   int
+}
+
+func heartbeatState(t *testing.T, db *sql.DB, id int64) hbState {
+	t.Helper()
+	var s hbState
+	err := db.QueryRow(`
+    select c.last_status, c.started_at,
+      (select count(1) from runs r where r.check_id = c.id),
+      (select count(1) from runs r where r.check_id = c.id and not r.status),
+      (select count(1) from outbox o where o.idempotency_key like 'status:' || c.id || ':%')
+    from checks c where c.id = $1
+  `, id).Scan(&s.status, &s.startedAt, &s.runs, &s.downRuns, &s.changes)
+	if err != nil {
+		t.Fatalf("[db] heartbeat %d: %v", id, err)
+	}
+	return s
+}
+
+// sweepHeartbeats runs the scheduler's missed-heartbeat pass against the IT
+// database; the IT stack runs no scheduler, whose HTTP dispatch would probe
+// every check the other tests create.
+func sweepHeartbeats(t *testing.T) int {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	db, err := pg.NewDB(ctx, pg.Config{DSN: LoadCfg().DBDSN})
+	if err != nil {
+		t.Fatalf("[db] open pool: %v", err)
+	}
+	defer db.Close()
+	h := &scheduler.Heartbeats{
+		Checks:     pg.NewCheckRepo(db),
+		Runs:       pg.NewRunRepo(db),
+		Outbox:     pg.NewOutboxRepo(db),
+		Transactor: pg.NewTransactor(db, zap.NewNop()),
+	}
+	n, err := h.Sweep(ctx, 100)
+	if err != nil {
+		t.Fatalf("sweep heartbeats: %v", err)
+	}
+	return n
+}
+
+// TestHeartbeat_PingsAndMissedSweep: start, success and fail pings record
+// runs and status changes, and a heartbeat past its deadline is marked down
+// by the sweep exactly once.
+func TestHeartbeat_PingsAndMissedSweep(t *testing.T) {
+	token := signUp(t, "it-hb")
+	c := createCheck(t, token, map[string]any{"type": "heartbeat", "name": "nightly", "interval_sec": 3600, "grace_sec": 60})
+	if c.PingToken == "" {
+		t.Fatalf("heartbeat %d has no ping token", c.ID)
+	}
+	db := DBOpen(t, LoadCfg().DBDSN)
+	defer db.Close()
+
+	ping := func(suffix string, want int) {
+		t.Helper()
+		agDo(t, http.MethodGet, "/ping/"+c.PingToken+suffix, "", nil, want)
+	}
+	expect := func(step string, status bool, runs, downRuns, changes int) {
+		t.Helper()
+		s := heartbeatState(t, db, c.ID)
+		if !s.status.Valid || s.status.Bool != status || s.runs != runs || s.downRuns != downRuns || s.changes != changes {
+			t.Fatalf("%s: status=%v runs=%d down=%d changes=%d, want %v/%d/%d/%d",
+				step, s.status, s.runs, s.downRuns, s.changes, status, runs, downRuns, changes)
+		}
+	}
+
+	ping("/start", 200)
+	if s := heartbeatState(t, db, c.ID); !s.startedAt.Valid || s.runs != 0 {
+		t.Fatalf("start ping: started_at=%v runs=%d", s.startedAt, s.runs)
+	}
+	ping("", 200)
+	expect("success", true, 1, 0, 1)
+	ping("/fail", 200)
+	expect("fail", false, 2, 1, 2)
+	ping("", 200)
+	expect("recovery", true, 3, 1, 3)
+
+	ping("/bogus", 404)
+	agDo(t, http.MethodGet, "/ping/no-such-token-"+strconv.FormatInt(RandID(), 10), "", nil, 404)
+
+	// jump past the deadline instead of waiting out interval and grace
+	if _, err := db.Exec(`update checks set next_run = now() - interval '1 second' where id = $1`, c.ID); err != nil {
+		t.Fatalf("[db] expire heartbeat: %v", err)
+	}
+	if n := sweepHeartbeats(t); n < 1 {
+		t.Fatalf("sweep marked %d heartbeats, want at least 1", n)
+	}
+	expect("missed", false, 4, 2, 4)
+
+	// already down: a second sweep leaves it alone
+	sweepHeartbeats(t)
+	expect("second sweep", false, 4, 2, 4)
+
+	// without grace a started job still gets its period, so the sweep
+	// doesn't mark it down while it runs
+	z := createCheck(t, token, map[string]any{"type": "heartbeat", "name": "no-grace", "interval_sec": 3600})
+	agDo(t, http.MethodGet, "/ping/"+z.PingToken, "", nil, 200)
+	agDo(t, http.MethodGet, "/ping/"+z.PingToken+"/start", "", nil, 200)
+	sweepHeartbeats(t)
+	if s := heartbeatState(t, db, z.ID); !s.status.Valid || !s.status.Bool || !s.startedAt.Valid || s.downRuns != 0 {
+		t.Fatalf("zero-grace start: status=%v started_at=%v down=%d, want up and running", s.status, s.startedAt, s.downRuns)
+	}
+}
@@ -0,0 +1,137 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/NordCoder/Pingerus/internal/inbox"
+	kafkax "github.com/NordCoder/Pingerus/internal/repository/kafka"
+	pg "github.com/NordCoder/Pingerus/internal/repository/postgres"
+	"go.uber.org/zap"
+)
+
+func newInbox(t *testing.T, db *pg.DB, consumer string) *inbox.Inbox {
+	t.Helper()
+	return inbox.New(consumer, pg.NewInboxRepo(db), pg.NewTransactor(db, zap.NewNop()), nil)
+}
+
+// TestInbox_SkipsRedeliveries: a wrapped handler runs once per message; a
+// failed run leaves the message unclaimed, and an effect repeats on
+// redelivery while its record does not.
+func TestInbox_SkipsRedeliveries(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	db := openPool(t, ctx)
+	defer db.Close()
+
+	consumer := fmt.Sprintf("it-inbox-%d", RandID())
+	in := newInbox(t, db, consumer)
+	msg := func(offset int64) context.Context {
+		return kafkax.ContextWithMessage(ctx, kafkax.MessageMeta{Topic: "it-inbox", Partition: 1, Offset: offset})
+	}
+
+	var runs int
+	fail := true
+	h := in.Wrap(func(context.Context, []byte, []byte) error {
+		runs++
+		if fail {
+			fail = false
+			return errors.New("boom")
+		}
+		return nil
+	})
+	if err := h(msg(1), nil, nil); err == nil {
+		t.Fatalf("want the handler's error")
+	}
+	for i := 0; i < 2; i++ {
+		if err := h(msg(1), nil, nil); err != nil {
+			t.Fatalf("delivery %d: %v", i+2, err)
+		}
+	}
+	if runs != 2 {
+		t.Fatalf("handler ran %d times, want 2: once failing, once succeeding", runs)
+	}
+	if err := h(msg(2), nil, nil); err != nil || runs != 3 {
+		t.Fatalf("next offset: runs %d, %v", runs, err)
+	}
+
+	var effects, records int
+	e := newInbox(t, db, consumer+"-effect").WrapEffect(func(context.Context, []byte, []byte) (func(context.Context) error, error) {
+		effects++
+		return func(context.Context) error { records++; return nil }, nil
+	})
+	for i := 0; i < 2; i++ {
+		if err := e(msg(1), nil, nil); err != nil {
+			t.Fatalf("effect delivery %d: %v", i+1, err)
+		}
+	}
+	if effects != 2 || records != 1 {
+		t.Fatalf("effects %d, records %d; want 2 and 1", effects, records)
+	}
+
+	// parts of one message are claimed apart from each other and from it
+	var parts []string
+	for _, p := range []string{"a", "b", "a"} {
+		if err := in.Once(msg(1), p, func(context.Context) error { parts = append(parts, p); return nil }); err != nil {
+			t.Fatalf("once %s: %v", p, err)
+		}
+	}
+	if fmt.Sprint(parts) != "[a b]" {
+		t.Fatalf("parts run %v, want [a b]", parts)
+	}
+}
+
+// TestInbox_PruneDropsOldRecords: Prune deletes the consumer's records and
+// its parts' past retention, and keeps newer ones and other consumers'.
+func TestInbox_PruneDropsOldRecords(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	pool := openPool(t, ctx)
+	defer pool.Close()
+	db := DBOpen(t, LoadCfg().DBDSN)
+	defer db.Close()
+
+	consumer := fmt.Sprintf("it-inbox-prune-%d", RandID())
+	seed := func(consumer string, offset int64, age time.Duration) {
+		t.Helper()
+		if _, err := db.Exec(`
+    insert into inbox (consumer, topic, partition, kafka_offset, processed_at)
+    values ($1, 'it-inbox', 0, $2, now() - $3::interval)
+  `, consumer, offset, fmt.Sprintf("%d seconds", int64(age.Seconds()))); err != nil {
+			t.Fatalf("[db] seed inbox: %v", err)
+		}
+	}
+	seed(consumer, 1, 48*time.Hour)
+	seed(consumer+"/alert:1", 1, 48*time.Hour)
+	seed(consumer, 2, time.Minute)
+	seed(consumer+"x", 1, 48*time.Hour)
+
+	count := func(consumer string) int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow(`select count(1) from inbox where consumer = $1`, consumer).Scan(&n); err != nil {
+			t.Fatalf("[db] count inbox: %v", err)
+		}
+		return n
+	}
+
+	pruneCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	go newInbox(t, pool, consumer).Prune(pruneCtx, 24*time.Hour, 100*time.Millisecond)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for count(consumer) != 1 || count(consumer+"/alert:1") != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("old records not pruned: %d own, %d parts", count(consumer), count(consumer+"/alert:1"))
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	if n := count(consumer + "x"); n != 1 {
+		t.Fatalf("pruned %d records of another consumer", 1-n)
+	}
+}
@@ -33,6 +33,7 @@ type Cfg struct {
 	PWOutTopic     string
 	PWHealthURL    string
 	AGBaseURL      string
+	AGGRPCAddr     string
 	AGUsersPath    string
 	AGChecksPath   string
 	AGEnqueueTmpl  string
@@ -47,6 +48,7 @@ func LoadCfg() Cfg {
 		PWOutTopic:     getenv("IT_PW_OUT_TOPIC", "status-change"),
 		PWHealthURL:    getenv("IT_PW_HEALTH", "http://127.0.0.1:8083/healthz"),
 		AGBaseURL:      getenv("IT_AG_BASE", "http://127.0.0.1:8080"),
+		AGGRPCAddr:     getenv("IT_AG_GRPC", "127.0.0.1:19090"),
 		AGUsersPath:    getenv("IT_AG_USERS", "/v1/users"),
 		AGChecksPath:   getenv("IT_AG_CHECKS", "/v1/checks"),
 		AGEnqueueTmpl:  getenv("IT_AG_ENQ_TMPL", "/v1/checks/%d/enqueue"),
@@ -226,15 +228,30 @@ func SeedUser(t *testing.T, db *sql.DB, id int64, email string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 12*time.Second)
 	defer cancel()
 	_, err := db.ExecContext(ctx, `
-    insert into users (id, email, password_hash)
-    values ($1, $2, $3)
+    insert into users (id, email, password_hash, email_verified_at)
+    values ($1, $2, $3, now())
     on conflict (id) do update set
       email = excluded.email,
-      password_hash = excluded.password_hash
+      password_hash = excluded.password_hash,
+      email_verified_at = excluded.email_verified_at
   `, id, email, "not_used_for_itests")
 	if err != nil {
 		t.Fatalf("[db] seed user: %v", err)
 	}
+	_, err = db.ExecContext(ctx, `
+    with o as (
+      insert into orgs (name, personal_owner_id)
+      values ('Personal', $1)
+      on conflict (personal_owner_id) do update set name = orgs.name
+      returning id
+    )
+    insert into org_members (org_id, user_id, role)
+    select id, $1, 'owner' from o
+    on conflict (org_id, user_id) do nothing
+  `, id)
+	if err != nil {
+		t.Fatalf("[db] seed personal org: %v", err)
+	}
 }
 
 func SeedCheck(t *testing.T, db *sql.DB, id, userID int64, host string, lastStatus *bool) {
@@ -243,10 +260,11 @@ func SeedCheck(t *testing.T, db *sql.DB, id, userID int64, host string, lastStat
 	defer cancel()
 	if lastStatus == nil {
 		_, _ = db.ExecContext(ctx, `
-      insert into checks (id, user_id, host, interval_sec, next_run, active)
-      values ($1, $2, $3, $4, $5, $6)
+      insert into checks (id, user_id, org_id, host, interval_sec, next_run, active)
+      values ($1, $2, (select id from orgs where personal_owner_id = $2), $3, $4, $5, $6)
       on conflict (id) do update set
         user_id = excluded.user_id,
+        org_id = excluded.org_id,
         host = excluded.host,
         interval_sec = excluded.interval_sec,
         next_run = excluded.next_run,
@@ -254,10 +272,11 @@ func SeedCheck(t *testing.T, db *sql.DB, id, userID int64, host string, lastStat
     `, id, userID, host, 30, time.Now().UTC(), true)
 	} else {
 		_, _ = db.ExecContext(ctx, `
-      insert into checks (id, user_id, host, interval_sec, last_status, next_run, active)
-      values ($1, $2, $3, $4, $5, $6, $7)
+      insert into checks (id, user_id, org_id, host, interval_sec, last_status, next_run, active)
+      values ($1, $2, (select id from orgs where personal_owner_id = $2), $3, $4, $5, $6, $7)
       on conflict (id) do update set
         user_id = excluded.user_id,
+        org_id = excluded.org_id,
         host = excluded.host,
         interval_sec = excluded.interval_sec,
         last_status = excluded.last_status,
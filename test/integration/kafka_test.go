@@ -0,0 +1,260 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	kafkax "github.com/NordCoder/Pingerus/internal/repository/kafka"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+// seenValues counts the values a test handler got.
+type seenValues struct {
+	mu sync.Mutex
+	n  map[string]int
+}
+
+func (s *seenValues) add(v string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.n[v]++
+}
+
+func (s *seenValues) count(v string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.n[v]
+}
+
+func waitFor(t *testing.T, what string, timeout time.Duration, ok func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !ok() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out after %v waiting for %s", timeout, what)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// readAt reads the message at offset of partition 0 of topic.
+func readAt(t *testing.T, bootstrap, topic string, offset int64) kafka.Message {
+	t.Helper()
+	r := kafka.NewReader(kafka.ReaderConfig{Brokers: []string{bootstrap}, Topic: topic, MaxWait: time.Second})
+	defer r.Close()
+	if err := r.SetOffset(offset); err != nil {
+		t.Fatalf("[kafka] set offset: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	m, err := r.ReadMessage(ctx)
+	if err != nil {
+		t.Fatalf("[kafka] read %s@%d: %v", topic, offset, err)
+	}
+	return m
+}
+
+func header(m kafka.Message, key string) string {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// consume runs c with h until the test ends.
+func consume(t *testing.T, c *kafkax.Consumer, h kafkax.Handler) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = c.Consume(ctx, h)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		_ = c.Close()
+	})
+}
+
+// TestKafka_DeadLettersAndReplays: a message failing every attempt goes to
+// the DLQ with its origin without holding up the next one, and the dlq tool
+// replays it, without the DLQ headers, to a consumer that now handles it.
+func TestKafka_DeadLettersAndReplays(t *testing.T) {
+	cfg := LoadCfg()
+	topic := fmt.Sprintf("it-dlq-%d", RandID())
+	dlq := kafkax.DLQTopic(topic)
+	EnsureTopic(t, cfg.KafkaBootstrap, topic)
+	EnsureTopic(t, cfg.KafkaBootstrap, dlq)
+
+	seen := &seenValues{n: map[string]int{}}
+	var healed atomic.Bool
+	group := topic + "-it"
+	consume(t, kafkax.NewConsumer(&kafkax.ConsumerConfig{
+		Brokers:       []string{cfg.KafkaBootstrap},
+		GroupID:       group,
+		Topic:         topic,
+		FromBeginning: true,
+		Logger:        zap.NewNop(),
+		MaxAttempts:   2,
+		RetryBackoff:  50 * time.Millisecond,
+		DLQ:           true,
+	}), func(_ context.Context, _, value []byte) error {
+		seen.add(string(value))
+		if string(value) == "poison" && !healed.Load() {
+			return errors.New("boom: cannot handle poison")
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	p := kafkax.NewProducer([]string{cfg.KafkaBootstrap}, topic)
+	defer p.Close()
+	for _, v := range []string{"poison", "ok"} {
+		if err := p.Publish(ctx, []byte("k"), []byte(v)); err != nil {
+			t.Fatalf("[kafka] publish %s: %v", v, err)
+		}
+	}
+
+	waitFor(t, "the message after the poison one", 30*time.Second, func() bool { return seen.count("ok") == 1 })
+	if n := seen.count("poison"); n != 2 {
+		t.Fatalf("poison handled %d times, want MaxAttempts 2", n)
+	}
+
+	m := readAt(t, cfg.KafkaBootstrap, dlq, 0)
+	if string(m.Value) != "poison" || string(m.Key) != "k" {
+		t.Fatalf("dlq message %s=%s", m.Key, m.Value)
+	}
+	for k, want := range map[string]string{
+		kafkax.HeaderDLQTopic:     topic,
+		kafkax.HeaderDLQPartition: "0",
+		kafkax.HeaderDLQOffset:    "0",
+		kafkax.HeaderDLQGroup:     group,
+		kafkax.HeaderDLQAttempts:  "2",
+	} {
+		if got := header(m, k); got != want {
+			t.Fatalf("dlq header %s = %q, want %q", k, got, want)
+		}
+	}
+	if got := header(m, kafkax.HeaderDLQError); !strings.Contains(got, "boom") {
+		t.Fatalf("dlq error header %q", got)
+	}
+
+	healed.Store(true)
+	out, err := exec.Command("go", "run", "github.com/NordCoder/Pingerus/cmd/dlq", "replay",
+		"-brokers", cfg.KafkaBootstrap, "-topic", dlq).CombinedOutput()
+	if err != nil {
+		t.Fatalf("dlq replay: %v\n%s", err, out)
+	}
+	t.Logf("[dlq] %s", out)
+
+	waitFor(t, "the replayed message", 30*time.Second, func() bool { return seen.count("poison") == 3 })
+	replayed := readAt(t, cfg.KafkaBootstrap, topic, 2)
+	if string(replayed.Value) != "poison" {
+		t.Fatalf("offset 2 of %s is %q, want the replay", topic, replayed.Value)
+	}
+	for _, h := range replayed.Headers {
+		if strings.HasPrefix(h.Key, "x-dlq-") {
+			t.Fatalf("replayed message kept header %s", h.Key)
+		}
+	}
+}
+
+// committedOffset is the group's committed offset on partition 0 of topic,
+// -1 when it has none.
+func committedOffset(t *testing.T, bootstrap, group, topic string) int64 {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := &kafka.Client{Addr: kafka.TCP(bootstrap)}
+	resp, err := client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{GroupID: group, Topics: map[string][]int{topic: {0}}})
+	if err == nil {
+		err = resp.Error
+	}
+	if err != nil {
+		t.Fatalf("[kafka] offset fetch %s: %v", group, err)
+	}
+	for _, p := range resp.Topics[topic] {
+		if p.Partition == 0 {
+			if p.Error != nil {
+				t.Fatalf("[kafka] offset fetch %s/0: %v", topic, p.Error)
+			}
+			return p.CommittedOffset
+		}
+	}
+	return -1
+}
+
+// TestKafka_ConcurrentCommitsOnlyContiguousOffsets: while a slow message
+// holds up its key, later messages of other keys complete on other workers
+// but the group's offset does not move past the slow one; once it finishes
+// the offset covers everything.
+func TestKafka_ConcurrentCommitsOnlyContiguousOffsets(t *testing.T) {
+	cfg := LoadCfg()
+	topic := fmt.Sprintf("it-lanes-%d", RandID())
+	group := topic + "-it"
+	EnsureTopic(t, cfg.KafkaBootstrap, topic)
+
+	seen := &seenValues{n: map[string]int{}}
+	release := make(chan struct{})
+	consume(t, kafkax.NewConsumer(&kafkax.ConsumerConfig{
+		Brokers:       []string{cfg.KafkaBootstrap},
+		GroupID:       group,
+		Topic:         topic,
+		FromBeginning: true,
+		Logger:        zap.NewNop(),
+		Concurrency:   4,
+	}), func(ctx context.Context, key, _ []byte) error {
+		if string(key) == "slow" {
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		seen.add("done")
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	p := kafkax.NewProducer([]string{cfg.KafkaBootstrap}, topic)
+	defer p.Close()
+	const fast = 20
+	// offset 0 is the slow one
+	if err := p.Publish(ctx, []byte("slow"), []byte("0")); err != nil {
+		t.Fatalf("[kafka] publish slow: %v", err)
+	}
+	for i := 1; i <= fast; i++ {
+		if err := p.Publish(ctx, []byte(fmt.Sprintf("key-%d", i)), []byte(fmt.Sprint(i))); err != nil {
+			t.Fatalf("[kafka] publish %d: %v", i, err)
+		}
+	}
+
+	// the keys sharing the slow key's worker wait behind it; the rest finish
+	waitFor(t, "later offsets to complete", 30*time.Second, func() bool { return seen.count("done") >= fast/2 })
+	// give the commit loop time to (wrongly) commit them
+	time.Sleep(2 * time.Second)
+	if off := committedOffset(t, cfg.KafkaBootstrap, group, topic); off > 0 {
+		t.Fatalf("committed offset %d while offset 0 is still in flight", off)
+	}
+
+	close(release)
+	waitFor(t, "all messages", 30*time.Second, func() bool { return seen.count("done") == fast+1 })
+	waitFor(t, "the offset past the last message", 15*time.Second, func() bool {
+		return committedOffset(t, cfg.KafkaBootstrap, group, topic) == fast+1
+	})
+}
@@ -0,0 +1,174 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	domoutbox "github.com/NordCoder/Pingerus/internal/domain/outbox"
+	"github.com/NordCoder/Pingerus/internal/outbox"
+	pg "github.com/NordCoder/Pingerus/internal/repository/postgres"
+	"go.uber.org/zap"
+)
+
+// itOutboxKind is a kind the IT stack registers no handler for, so it
+// parks the rows these tests enqueue instead of publishing them.
+const itOutboxKind domoutbox.Kind = 9026
+
+// openPool opens the pgx pool the services use against the IT database.
+func openPool(t *testing.T, ctx context.Context) *pg.DB {
+	t.Helper()
+	db, err := pg.NewDB(ctx, pg.Config{DSN: LoadCfg().DBDSN})
+	if err != nil {
+		t.Fatalf("[db] open pool: %v", err)
+	}
+	return db
+}
+
+// memOutbox hands the runner the messages enqueued through it, so the
+// stack's own runners, which share the outbox table, cannot take them.
+// Enqueue also writes the row through repo to get its NOTIFY.
+type memOutbox struct {
+	repo *pg.OutboxRepo
+
+	mu      sync.Mutex
+	pending []domoutbox.Message
+	picks   chan struct{}
+}
+
+func (m *memOutbox) Enqueue(ctx context.Context, key string, kind domoutbox.Kind, data []byte) error {
+	if err := m.repo.Enqueue(ctx, key, kind, data); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending = append(m.pending, domoutbox.Message{IdempotencyKey: key, Kind: kind, Data: data})
+	return nil
+}
+
+func (m *memOutbox) PickBatch(_ context.Context, batch int, _ time.Duration) ([]domoutbox.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	select {
+	case m.picks <- struct{}{}:
+	default:
+	}
+	n := min(batch, len(m.pending))
+	out := m.pending[:n]
+	m.pending = m.pending[n:]
+	return out, nil
+}
+
+func (m *memOutbox) MarkSuccess(context.Context, []string) error     { return nil }
+func (m *memOutbox) MarkUnknownKind(context.Context, []string) error { return nil }
+
+// TestOutbox_NotifyWakesRunner: a runner polling once an hour dispatches a
+// message right after the transaction enqueueing it commits, and not before.
+func TestOutbox_NotifyWakesRunner(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	db := openPool(t, ctx)
+	defer db.Close()
+
+	channel := fmt.Sprintf("it_outbox_%d", RandID())
+	repo := &memOutbox{repo: pg.NewOutboxRepo(db).WithNotify(channel), picks: make(chan struct{}, 1)}
+	dispatched := make(chan string, 1)
+	dispatch := func(domoutbox.Kind) (domoutbox.KindHandler, error) {
+		return func(_ context.Context, data []byte) error {
+			dispatched <- string(data)
+			return nil
+		}, nil
+	}
+
+	wake := make(chan struct{}, 1)
+	go func() { _ = pg.NewListener(db, channel, zap.NewNop()).Run(ctx, wake) }()
+	outbox.NewOutboxRunner(zap.NewNop(), repo, dispatch, 1, 10, time.Hour, 30*time.Second).
+		WithWakeup(wake).
+		Start(ctx)
+
+	// the listener wakes the runner once it is connected
+	select {
+	case <-repo.picks:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("runner never woke up after the listener connected")
+	}
+
+	key := fmt.Sprintf("it-outbox-notify-%d", RandID())
+	err := pg.NewTransactor(db, zap.NewNop()).WithTx(ctx, func(ctx context.Context) error {
+		if err := repo.Enqueue(ctx, key, itOutboxKind, []byte(`{"n":1}`)); err != nil {
+			return err
+		}
+		select {
+		case <-dispatched:
+			t.Errorf("message dispatched before its transaction committed")
+		case <-time.After(time.Second):
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	select {
+	case data := <-dispatched:
+		if data != `{"n":1}` {
+			t.Fatalf("dispatched %q", data)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("message not dispatched within 3s of commit; the runner waited for its poll")
+	}
+}
+
+// TestOutbox_ParksUnknownKind: the ping-worker parks a row of a kind it has
+// no handler for as UNKNOWN_KIND and then leaves it alone instead of
+// picking it again every poll.
+func TestOutbox_ParksUnknownKind(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 40*time.Second)
+	defer cancel()
+	pool := openPool(t, ctx)
+	defer pool.Close()
+	db := DBOpen(t, LoadCfg().DBDSN)
+	defer db.Close()
+
+	key := fmt.Sprintf("it-outbox-unknown-%d", RandID())
+	if err := pg.NewOutboxRepo(pool).Enqueue(ctx, key, itOutboxKind, []byte(`{}`)); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	state := func() (string, time.Time) {
+		t.Helper()
+		var (
+			status  string
+			updated time.Time
+		)
+		if err := db.QueryRow(`select status::text, updated_at from outbox where idempotency_key = $1`, key).
+			Scan(&status, &updated); err != nil {
+			t.Fatalf("[db] outbox row %s: %v", key, err)
+		}
+		return status, updated
+	}
+
+	var parkedAt time.Time
+	deadline := time.Now().Add(20 * time.Second)
+	for {
+		status, updated := state()
+		if status == string(domoutbox.StatusUnknownKind) {
+			parkedAt = updated
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("row still %s after 20s, want %s", status, domoutbox.StatusUnknownKind)
+		}
+		time.Sleep(300 * time.Millisecond)
+	}
+
+	// a couple of the worker's 2s polls
+	time.Sleep(5 * time.Second)
+	if status, updated := state(); status != string(domoutbox.StatusUnknownKind) || !updated.Equal(parkedAt) {
+		t.Fatalf("parked row picked again: %s updated %v, parked at %v", status, updated, parkedAt)
+	}
+}
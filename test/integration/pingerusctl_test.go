@@ -0,0 +1,49 @@
+//go:build integration
+
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// runCtl runs pingerusctl against the gateway's gRPC port.
+func runCtl(t *testing.T, token string, args ...string) string {
+	t.Helper()
+	cfg := LoadCfg()
+	args = append([]string{"run", "github.com/NordCoder/Pingerus/cmd/pingerusctl"}, args...)
+	args = append(args, "-server", cfg.AGGRPCAddr, "-token", token)
+	out, err := exec.Command("go", args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("pingerusctl %v: %v\n%s", args[2:], err, out)
+	}
+	return string(out)
+}
+
+func TestPingerusctl_ApplyKeepsHeartbeats(t *testing.T) {
+	token := signUp(t, "it-ctl")
+
+	hb := createCheck(t, token, map[string]any{"type": "heartbeat", "name": "nightly backup", "interval_sec": 3600})
+	stale := createCheck(t, token, map[string]any{"url": "http://example.com/stale", "interval_sec": 60})
+
+	file := filepath.Join(t.TempDir(), "checks.yaml")
+	spec := "checks:\n  - name: kept\n    url: http://example.com/kept\n    interval_sec: 60\n"
+	if err := os.WriteFile(file, []byte(spec), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out := runCtl(t, token, "apply", "-f", file)
+	t.Logf("[apply] %s", out)
+
+	// the HTTP check missing from the file is pruned, the heartbeat is not
+	agDo(t, http.MethodGet, "/v1/checks/"+strconv.FormatInt(stale.ID, 10), token, nil, 404)
+	data := agDo(t, http.MethodGet, "/v1/checks/"+strconv.FormatInt(hb.ID, 10), token, nil, 200)
+	var got itCheck
+	if err := json.Unmarshal(data, &got); err != nil || got.Type != "heartbeat" {
+		t.Fatalf("heartbeat after apply: %v body=%s", err, string(data))
+	}
+}